@@ -3,14 +3,20 @@ module gotui
 go 1.18
 
 require (
-	github.com/creack/pty v1.1.23 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/creack/pty v1.1.23
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/gdamore/tcell/v2 v2.7.4
+	github.com/mattn/go-runewidth v0.0.15
+	github.com/rivo/tview v0.0.0-20240818110301-fd649dbf1223
+	github.com/yuin/gopher-lua v1.1.1
+	golang.org/x/sys v0.17.0
+	golang.org/x/text v0.14.0
+)
+
+require (
 	github.com/gdamore/encoding v1.0.0 // indirect
-	github.com/gdamore/tcell/v2 v2.7.4 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
-	github.com/mattn/go-runewidth v0.0.15 // indirect
-	github.com/rivo/tview v0.0.0-20240818110301-fd649dbf1223 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
-	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/term v0.17.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// ansiColorNames are the tview names of the 16 basic ANSI colors, which
+// terminals draw from their palette
+var ansiColorNames = []string{
+	"black", "maroon", "green", "olive", "navy", "purple", "teal", "silver",
+	"gray", "red", "lime", "yellow", "blue", "fuchsia", "aqua", "white",
+}
+
+// ansiCubeLevels are the intensities of the 6x6x6 color cube of the 256
+// color palette, as xterm draws them
+var ansiCubeLevels = []int{0, 95, 135, 175, 215, 255}
+
+// ansiAttributes maps the SGR parameters setting an attribute to its tview
+// attribute letter
+var ansiAttributes = map[int]byte{1: 'b', 2: 'd', 3: 'i', 4: 'u', 5: 'l', 7: 'r', 9: 's'}
+
+// ansiTranslator turns the SGR escape sequences in the output of a command
+// into tview style tags and escapes the rest of the text. The style carries
+// over from one call to the next, as it does in a terminal, so a translator
+// is kept for every stream.
+type ansiTranslator struct {
+	foreground, background string // tview colors, "" for the default
+	attributes             string // tview attribute letters
+}
+
+// translate returns a line of output with its SGR sequences turned into
+// tags, and its text without them. Other escape sequences are dropped. The
+// tags of a line reset the style at its end, so nothing written after it
+// takes over the style, and the next line sets it again.
+func (a *ansiTranslator) translate(line string) (string, string) {
+	if !strings.ContainsRune(line, '\x1b') && a.plain() {
+		return tview.Escape(line), line
+	}
+	var tagged, plain, text strings.Builder
+	if !a.plain() {
+		tagged.WriteString(a.tag())
+	}
+	flush := func() {
+		tagged.WriteString(tview.Escape(text.String()))
+		text.Reset()
+	}
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\x1b' {
+			text.WriteByte(line[i])
+			plain.WriteByte(line[i])
+			continue
+		}
+		if i+1 >= len(line) {
+			break
+		}
+		switch line[i+1] {
+		case '[':
+			// A control sequence: parameters, intermediates, a final byte
+			end := i + 2
+			for end < len(line) && (line[end] < 0x40 || line[end] > 0x7e) {
+				end++
+			}
+			if end == len(line) {
+				i = end
+				break
+			}
+			if line[end] == 'm' {
+				flush()
+				a.apply(line[i+2 : end])
+				tagged.WriteString(a.tag())
+			}
+			i = end
+		case ']':
+			// An operating system command, ended by BEL or ESC \
+			end := i + 2
+			for end < len(line) && line[end] != '\a' && !(line[end] == '\x1b' && end+1 < len(line) && line[end+1] == '\\') {
+				end++
+			}
+			if end < len(line) && line[end] == '\x1b' {
+				end++
+			}
+			i = end
+		case '(', ')', '*', '+':
+			// A character set designation, such as ESC ( B
+			i += 2
+		default:
+			i++
+		}
+	}
+	flush()
+	if !a.plain() {
+		tagged.WriteString("[-:-:-]")
+	}
+	return tagged.String(), plain.String()
+}
+
+// plain reports whether the style is the default one
+func (a *ansiTranslator) plain() bool {
+	return a.foreground == "" && a.background == "" && a.attributes == ""
+}
+
+// apply updates the style with the parameters of an SGR sequence
+func (a *ansiTranslator) apply(params string) {
+	fields := strings.Split(params, ";")
+	for i := 0; i < len(fields); i++ {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil && fields[i] != "" {
+			continue
+		}
+		switch {
+		case n == 0:
+			a.foreground, a.background, a.attributes = "", "", ""
+		case ansiAttributes[n] != 0:
+			if letter := ansiAttributes[n]; strings.IndexByte(a.attributes, letter) < 0 {
+				a.attributes += string(letter)
+			}
+		case n == 22:
+			a.removeAttributes("bd")
+		case n >= 23 && n <= 29:
+			a.removeAttributes(string(ansiAttributes[n-20]))
+		case n >= 30 && n <= 37:
+			a.foreground = ansiColorNames[n-30]
+		case n >= 40 && n <= 47:
+			a.background = ansiColorNames[n-40]
+		case n >= 90 && n <= 97:
+			a.foreground = ansiColorNames[n-90+8]
+		case n >= 100 && n <= 107:
+			a.background = ansiColorNames[n-100+8]
+		case n == 39:
+			a.foreground = ""
+		case n == 49:
+			a.background = ""
+		case n == 38 || n == 48:
+			color, used := ansiExtendedColor(fields[i+1:])
+			i += used
+			if n == 38 {
+				a.foreground = color
+			} else {
+				a.background = color
+			}
+		}
+	}
+}
+
+// removeAttributes drops attribute letters from the style
+func (a *ansiTranslator) removeAttributes(letters string) {
+	a.attributes = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(letters, r) {
+			return -1
+		}
+		return r
+	}, a.attributes)
+}
+
+// tag returns the tags that set the whole current style
+func (a *ansiTranslator) tag() string {
+	foreground, background := a.foreground, a.background
+	if foreground == "" {
+		foreground = "-"
+	}
+	if background == "" {
+		background = "-"
+	}
+	// Attribute letters add to the current attributes, so reset them first
+	tag := fmt.Sprintf("[%s:%s:-]", foreground, background)
+	if a.attributes != "" {
+		tag += "[::" + a.attributes + "]"
+	}
+	return tag
+}
+
+// ansiExtendedColor reads the color of a 38 or 48 SGR parameter, 5;n for the
+// 256 color palette or 2;r;g;b, and returns it with the number of fields
+// used. An unknown, out of range or truncated color is the default one.
+func ansiExtendedColor(fields []string) (string, int) {
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		numbers[i], _ = strconv.Atoi(field)
+	}
+	if len(numbers) == 0 {
+		return "", 0
+	}
+	switch numbers[0] {
+	case 5:
+		if len(numbers) < 2 || numbers[1] < 0 || numbers[1] > 255 {
+			return "", len(numbers)
+		}
+		n := numbers[1]
+		switch {
+		case n < 16:
+			return ansiColorNames[n], 2
+		case n < 232:
+			n -= 16
+			return fmt.Sprintf("#%02x%02x%02x", ansiCubeLevels[n/36], ansiCubeLevels[n/6%6], ansiCubeLevels[n%6]), 2
+		}
+		grey := 8 + 10*(n-232)
+		return fmt.Sprintf("#%02x%02x%02x", grey, grey, grey), 2
+	case 2:
+		if len(numbers) < 4 {
+			return "", len(numbers)
+		}
+		r, g, b := numbers[1], numbers[2], numbers[3]
+		if r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
+			return "", 4
+		}
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b), 4
+	}
+	return "", 1
+}
@@ -0,0 +1,43 @@
+package app
+
+import "testing"
+
+func TestANSIExtendedColor(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		color  string
+		used   int
+	}{
+		{"basic", []string{"5", "9"}, "red", 2},
+		{"cube", []string{"5", "196", "1"}, "#ff0000", 2},
+		{"grey", []string{"5", "232"}, "#080808", 2},
+		{"negative index", []string{"5", "-1"}, "", 2},
+		{"index past the palette", []string{"5", "256"}, "", 2},
+		{"truncated index", []string{"5"}, "", 1},
+		{"rgb", []string{"2", "1", "2", "3", "4"}, "#010203", 4},
+		{"negative rgb", []string{"2", "-1", "2", "3"}, "", 4},
+		{"rgb past 255", []string{"2", "1", "256", "3"}, "", 4},
+		{"truncated rgb", []string{"2", "1", "2"}, "", 3},
+		{"unknown kind", []string{"7", "1"}, "", 1},
+		{"no kind", nil, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			color, used := ansiExtendedColor(tt.fields)
+			if color != tt.color || used != tt.used {
+				t.Errorf("ansiExtendedColor(%q) = %q, %d, want %q, %d", tt.fields, color, used, tt.color, tt.used)
+			}
+		})
+	}
+}
+
+func TestANSITranslateBadColors(t *testing.T) {
+	for _, line := range []string{"\x1b[38;5mx", "\x1b[48;5;-1mx", "\x1b[38;5;300mx", "\x1b[38;2mx", "\x1b[48;2;1;2mx"} {
+		var a ansiTranslator
+		tagged, plain := a.translate(line)
+		if tagged != "[-:-:-]x" || plain != "x" {
+			t.Errorf("translate(%q) = %q, %q, want the text in the default style", line, tagged, plain)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Log levels, from the most verbose
+const (
+	logDebug = iota
+	logInfo
+	logWarn
+	logError
+)
+
+// logLevelNames are the names of the log levels in the config file and in
+// the log, indexed by level
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+// appLogger gathers what goui logs about itself, from any goroutine, into
+// the Log channel of the Output pane and the log file if one is configured.
+// Each entry has a level, a message, and fields given as key and value
+// pairs.
+type appLogger struct {
+	mu    sync.Mutex
+	level int // entries below it are dropped
+	view  *tview.TextView
+	file  *os.File
+}
+
+var applog = appLogger{level: logInfo}
+
+// parseLogLevel returns the level of a name in logLevelNames
+func parseLogLevel(name string) (int, error) {
+	for level, levelName := range logLevelNames {
+		if name == levelName {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected one of %s", name, strings.Join(logLevelNames, ", "))
+}
+
+// createLogView creates the Log channel of the Output pane
+func createLogView() *tview.TextView {
+	applog.view = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetMaxLines(5000)
+	applog.view.SetTitle("Log")
+	return applog.view
+}
+
+// configure applies the log settings, opening the log file if one is set
+// and keeping the current one if it is the same
+func (l *appLogger) configure(c logConfig) error {
+	level, err := parseLogLevel(c.Level)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+	path := expandHome(c.File)
+	if l.file != nil && l.file.Name() == path {
+		return nil
+	}
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	if path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open the log file: %w", err)
+	}
+	l.file = file
+	return nil
+}
+
+// capture sends what the standard log package prints to the log instead of
+// the screen, while the UI is running
+func (l *appLogger) capture() {
+	log.SetFlags(0)
+	log.SetOutput(l.writer(logInfo, ""))
+}
+
+// release sends the standard log package back to stderr and closes the log
+// file
+func (l *appLogger) release() {
+	log.SetFlags(log.LstdFlags)
+	log.SetOutput(os.Stderr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// log adds an entry. fields alternate keys and values, such as
+// "path", path, "err", err.
+func (l *appLogger) log(level int, message string, fields ...interface{}) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level < l.level {
+		return
+	}
+	text := message + formatLogFields(fields)
+	if l.file != nil {
+		fmt.Fprintf(l.file, "%s %-5s %s\n", now.Format("2006-01-02T15:04:05.000Z07:00"), strings.ToUpper(logLevelNames[level]), text)
+	}
+	if l.view != nil {
+		fmt.Fprintf(l.view, "%s%s[-] %s%-5s[-] %s\n", colorTag(theme.Muted), now.Format("15:04:05.000"),
+			colorTag(logLevelColor(level)), strings.ToUpper(logLevelNames[level]), tview.Escape(text))
+	}
+}
+
+// formatLogFields formats key and value pairs as key=value, quoting values
+// that have spaces or quotes in them
+func formatLogFields(fields []interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		value := "(missing)"
+		if i+1 < len(fields) {
+			value = fmt.Sprint(fields[i+1])
+		}
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %v=%s", fields[i], value)
+	}
+	return b.String()
+}
+
+// logLevelColor returns the color a log level is shown in
+func logLevelColor(level int) tcell.Color {
+	switch level {
+	case logError:
+		return theme.Error
+	case logWarn:
+		return theme.Warning
+	case logInfo:
+		return theme.Info
+	}
+	return theme.Muted
+}
+
+// logWriter logs every line written to it, for the output of programs and
+// of the standard log package
+type logWriter struct {
+	level   int
+	program string // added to the entries as a field, if set
+	mu      sync.Mutex
+	partial []byte // text after the last newline
+}
+
+// writer returns a writer logging each line at level, naming the program it
+// comes from if set
+func (l *appLogger) writer(level int, program string) *logWriter {
+	return &logWriter{level: level, program: program}
+}
+
+// Write logs the complete lines of p, keeping the rest for the next call
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		end := bytes.IndexByte(w.partial, '\n')
+		if end < 0 {
+			break
+		}
+		line := strings.TrimRight(string(w.partial[:end]), "\r")
+		w.partial = w.partial[end+1:]
+		if line == "" {
+			continue
+		}
+		if w.program != "" {
+			applog.log(w.level, line, "program", w.program)
+		} else {
+			applog.log(w.level, line)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+// autosaver saves the buffers with unsaved changes once no edits were made
+// for a while, or when the editor loses the focus, as the config file says
+type autosaver struct {
+	edits    int  // number of edits, to drop the waits of earlier ones
+	optedOut bool // the workspace's config file turns autosave off
+	failed   bool // the last autosave could not write a file
+}
+
+var autosave autosaver
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "autosave", Order: 20, Text: autosave.status})
+}
+
+// loadProjectSetting reads whether the workspace's config file turns
+// autosave off. A config file that cannot be read turns it off too.
+func (a *autosaver) loadProjectSetting() error {
+	project, err := loadProjectConfig()
+	a.optedOut = err != nil || (project.Autosave != nil && !*project.Autosave)
+	return err
+}
+
+// enabled reports whether files are saved automatically in any way
+func (a *autosaver) enabled() bool {
+	return !a.optedOut && (config.Editor.Autosave > 0 || config.Editor.AutosaveOnFocusLoss)
+}
+
+// changed starts the wait for the next save over after an edit
+func (a *autosaver) changed() {
+	a.edits++
+	if a.optedOut || config.Editor.Autosave == 0 {
+		return
+	}
+	edits := a.edits
+	time.AfterFunc(time.Duration(config.Editor.Autosave)*time.Second, func() {
+		ui.app.QueueUpdateDraw(func() {
+			if a.edits == edits {
+				a.save()
+			}
+		})
+	})
+}
+
+// focusLost saves when the editor loses the focus, if the config asks for it
+func (a *autosaver) focusLost() {
+	if !a.optedOut && config.Editor.AutosaveOnFocusLoss {
+		a.save()
+	}
+}
+
+// save writes every buffer with unsaved changes, unformatted so the text
+// does not move while it is being typed. Files changed on disk since they
+// were loaded are left for the user to save.
+func (a *autosaver) save() {
+	a.failed = false
+	for _, buf := range buffers.buffers {
+		if !buf.dirty || buf.ReadOnly() || buf.changedOnDisk() {
+			continue
+		}
+		if err := writeBuffer(buf); err != nil {
+			a.failed = true
+			notify(noticeError, fmt.Sprintf("Error saving file automatically: %s", err))
+			continue
+		}
+		scripts.fileSaved(buf)
+	}
+}
+
+// status is the status bar field showing whether autosave is on
+func (a *autosaver) status() string {
+	switch {
+	case !a.enabled():
+		return ""
+	case a.failed:
+		return "Autosave failed"
+	}
+	return "Autosave"
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blameDelay is how long after the last edit the blame is computed again
+const blameDelay = 300 * time.Millisecond
+
+// blameLine is the commit that last changed a line
+type blameLine struct {
+	sha     string
+	author  string
+	time    time.Time
+	summary string
+}
+
+// uncommitted reports whether the line has not been committed yet
+func (l blameLine) uncommitted() bool {
+	return strings.Trim(l.sha, "0") == ""
+}
+
+// blameState annotates the lines of one buffer with the commit that last
+// changed them
+type blameState struct {
+	path    string // annotated buffer, "" while blame is off
+	lines   []blameLine
+	updates int // number of blame runs started, to drop stale ones
+	timer   *time.Timer
+}
+
+var blame blameState
+
+// shown reports whether the active buffer is annotated
+func (b *blameState) shown() bool {
+	buf := buffers.current()
+	return buf != nil && b.path != "" && buf.Path() == b.path
+}
+
+// toggle switches the annotations of the active buffer on or off
+func (b *blameState) toggle() {
+	if b.shown() {
+		b.path, b.lines = "", nil
+		ui.editor.SetAnnotations(nil)
+		return
+	}
+	buf := buffers.current()
+	if buf == nil {
+		ui.output.message("No file loaded")
+		return
+	}
+	if buf.onDisk() {
+		ui.output.message("Blame is not available for large and binary files")
+		return
+	}
+	if git.root == "" {
+		ui.output.message("Not a git repository")
+		return
+	}
+	b.path, b.lines = buf.Path(), nil
+	b.refresh()
+}
+
+// refresh runs git blame on the text of the annotated buffer in the
+// background, so lines edited since the last commit are annotated as such
+func (b *blameState) refresh() {
+	if !b.shown() {
+		return
+	}
+	b.updates++
+	update, path, text := b.updates, b.path, ui.editor.GetText()
+	gitRun(text, []string{"blame", "--porcelain", "--contents", "-", "--", path}, func(out string, err error) {
+		if b.updates != update || b.path != path {
+			return
+		}
+		if err != nil {
+			b.path = ""
+			ui.editor.SetAnnotations(nil)
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		b.lines = parseBlame(out)
+		b.decorate()
+	})
+}
+
+// changed schedules a refresh after the annotated buffer was edited
+func (b *blameState) changed() {
+	if !b.shown() {
+		return
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.timer = time.AfterFunc(blameDelay, func() {
+		ui.app.QueueUpdateDraw(b.refresh)
+	})
+}
+
+// decorate shows the annotations if the active buffer is the annotated one.
+// The commit is only named on the first of the lines it changed in a row.
+func (b *blameState) decorate() {
+	if ui.editor == nil {
+		return
+	}
+	if !b.shown() {
+		ui.editor.SetAnnotations(nil)
+		return
+	}
+	annotations := make([]string, len(b.lines))
+	for i, line := range b.lines {
+		switch {
+		case i > 0 && line.sha == b.lines[i-1].sha:
+		case line.uncommitted():
+			annotations[i] = "Not committed yet"
+		default:
+			annotations[i] = fmt.Sprintf("%.7s %-12.12s %8s", line.sha, line.author, formatAge(line.time))
+		}
+	}
+	ui.editor.SetAnnotations(annotations)
+}
+
+// showCommit opens the commit that last changed a line of the annotated
+// buffer
+func (b *blameState) showCommit(line int) {
+	if !b.shown() || line < 0 || line >= len(b.lines) {
+		return
+	}
+	commit := b.lines[line]
+	if commit.uncommitted() {
+		ui.output.message(fmt.Sprintf("Line %d is not committed yet", line+1))
+		return
+	}
+	showCommit(commit.sha)
+}
+
+// parseBlame parses the output of git blame --porcelain into the commit of
+// every line
+func parseBlame(out string) []blameLine {
+	var lines []blameLine
+	commits := map[string]*blameLine{}
+	var current *blameLine
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") {
+			// The content of the line ends its entry
+			if current != nil {
+				lines = append(lines, *current)
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			current.author = value
+		case "author-time":
+			seconds, _ := strconv.ParseInt(value, 10, 64)
+			current.time = time.Unix(seconds, 0)
+		case "summary":
+			current.summary = value
+		default:
+			if len(key) == 40 && strings.Trim(key, "0123456789abcdef") == "" {
+				if commits[key] == nil {
+					commits[key] = &blameLine{sha: key}
+				}
+				current = commits[key]
+			}
+		}
+	}
+	return lines
+}
+
+// formatAge describes how long ago t was, in the largest whole unit
+func formatAge(t time.Time) string {
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dmin ago", int(age/time.Minute))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age/time.Hour))
+	case age < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(age/(24*time.Hour)))
+	case age < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(age/(30*24*time.Hour)))
+	}
+	return fmt.Sprintf("%dy ago", int(age/(365*24*time.Hour)))
+}
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rivo/tview"
+)
+
+// bookmarkDigits are the names of numbered bookmarks, in the order they are
+// handed out
+const bookmarkDigits = "123456789"
+
+// bookmark marks a line of a file to come back to. Numbered bookmarks are
+// named by their digit.
+type bookmark struct {
+	Name string `toml:"name"`
+	Path string `toml:"path"`
+	Line int    `toml:"line"` // counted from 1 in the file, from 0 otherwise
+}
+
+// before reports whether b comes before o in the order of files and lines
+func (b bookmark) before(o bookmark) bool {
+	if b.Path != o.Path {
+		return b.Path < o.Path
+	}
+	return b.Line < o.Line
+}
+
+// bookmarkList holds the bookmarks of all files, sorted by file and line
+// and kept between sessions
+type bookmarkList struct {
+	entries []bookmark
+}
+
+var bookmarks bookmarkList
+
+// bookmarksPath returns the location of the file keeping the bookmarks
+// between sessions
+func bookmarksPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "goui", "bookmarks.toml"), nil
+}
+
+// bookmarkFile is the content of the bookmarks file
+type bookmarkFile struct {
+	Bookmarks []bookmark `toml:"bookmark"`
+}
+
+// load reads the bookmarks of the last session. A missing file has none.
+func (l *bookmarkList) load() error {
+	path, err := bookmarksPath()
+	if err != nil {
+		return err
+	}
+	var saved bookmarkFile
+	if _, err := toml.DecodeFile(path, &saved); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	l.entries = l.entries[:0]
+	for _, b := range saved.Bookmarks {
+		if b.Name != "" && b.Path != "" && b.Line > 0 {
+			b.Line--
+			l.entries = append(l.entries, b)
+		}
+	}
+	l.sort()
+	return nil
+}
+
+// save remembers the bookmarks for the next session
+func (l *bookmarkList) save() error {
+	path, err := bookmarksPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	saved := bookmarkFile{Bookmarks: make([]bookmark, len(l.entries))}
+	for i, b := range l.entries {
+		b.Line++
+		saved.Bookmarks[i] = b
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer file.Close()
+	return toml.NewEncoder(file).Encode(saved)
+}
+
+// sort puts the bookmarks in the order of files and lines
+func (l *bookmarkList) sort() {
+	sort.SliceStable(l.entries, func(i, j int) bool { return l.entries[i].before(l.entries[j]) })
+}
+
+// changed saves the bookmarks and updates the gutter after they changed
+func (l *bookmarkList) changed() error {
+	l.sort()
+	l.decorate()
+	return l.save()
+}
+
+// remove drops the bookmarks for which drop returns true
+func (l *bookmarkList) remove(drop func(b bookmark) bool) {
+	kept := l.entries[:0]
+	for _, b := range l.entries {
+		if !drop(b) {
+			kept = append(kept, b)
+		}
+	}
+	l.entries = kept
+}
+
+// here returns an unnamed bookmark at the cursor of the active buffer
+func (l *bookmarkList) here() (bookmark, error) {
+	buf := buffers.current()
+	if buf == nil || buf.Path() == "" {
+		return bookmark{}, fmt.Errorf("no file loaded")
+	}
+	if buf.onDisk() {
+		return bookmark{}, fmt.Errorf("%s cannot be bookmarked", buf.Name())
+	}
+	return bookmark{Path: buf.Path(), Line: buf.Cursor().Line}, nil
+}
+
+// toggle removes the bookmarks on the cursor's line, or else gives the line
+// the first free number
+func (l *bookmarkList) toggle() error {
+	here, err := l.here()
+	if err != nil {
+		return err
+	}
+	count := len(l.entries)
+	l.remove(func(b bookmark) bool { return b.Path == here.Path && b.Line == here.Line })
+	if len(l.entries) == count {
+		taken := map[string]bool{}
+		for _, b := range l.entries {
+			taken[b.Name] = true
+		}
+		for _, digit := range bookmarkDigits {
+			if !taken[string(digit)] {
+				here.Name = string(digit)
+				break
+			}
+		}
+		if here.Name == "" {
+			return fmt.Errorf("all numbered bookmarks are in use; name this one instead")
+		}
+		l.entries = append(l.entries, here)
+	}
+	return l.changed()
+}
+
+// set bookmarks the cursor's line by name, moving the bookmark of that name
+// if there is one and replacing any other bookmark on the line
+func (l *bookmarkList) set(name string) error {
+	here, err := l.here()
+	if err != nil {
+		return err
+	}
+	here.Name = name
+	l.remove(func(b bookmark) bool { return b.Name == name || b.Path == here.Path && b.Line == here.Line })
+	l.entries = append(l.entries, here)
+	return l.changed()
+}
+
+// promptBookmarkName asks for a name to bookmark the cursor's line with
+func promptBookmarkName() {
+	showPrompt("Bookmark name: ", "", func(name string) {
+		if name = strings.TrimSpace(name); name == "" {
+			return
+		}
+		if err := bookmarks.set(name); err != nil {
+			notify(noticeError, fmt.Sprintf("Error setting bookmark: %s", err))
+		}
+	})
+}
+
+// goTo moves the cursor to a bookmark, recording the jump
+func (l *bookmarkList) goTo(b bookmark) error {
+	jumps.push()
+	pos := editor.Position{Line: b.Line}
+	return openFileAt(b.Path, pos, pos)
+}
+
+// jumpTo moves the cursor to the bookmark of a name
+func (l *bookmarkList) jumpTo(name string) error {
+	for _, b := range l.entries {
+		if b.Name == name {
+			return l.goTo(b)
+		}
+	}
+	return fmt.Errorf("no bookmark %s", name)
+}
+
+// next moves the cursor to the bookmark after the cursor's line, or the one
+// before it for a negative delta, going on into the other files and
+// wrapping around
+func (l *bookmarkList) next(delta int) error {
+	n := len(l.entries)
+	if n == 0 {
+		return fmt.Errorf("no bookmarks")
+	}
+	var here bookmark
+	if buf := buffers.current(); buf != nil {
+		here = bookmark{Path: buf.Path(), Line: buf.Cursor().Line}
+	}
+	// The first bookmark at or after the cursor's line
+	i := sort.Search(n, func(i int) bool { return !l.entries[i].before(here) })
+	if delta < 0 {
+		i--
+	} else if i < n && !here.before(l.entries[i]) {
+		i++
+	}
+	return l.goTo(l.entries[(i%n+n)%n])
+}
+
+// show lists the bookmarks in a picker that jumps to the chosen one
+func (l *bookmarkList) show() {
+	if len(l.entries) == 0 {
+		ui.output.message("No bookmarks; Alt+K bookmarks the cursor's line")
+		return
+	}
+	entries := append([]bookmark{}, l.entries...)
+	items := make([]string, len(entries))
+	for i, b := range entries {
+		items[i] = tview.Escape(fmt.Sprintf("%-8s %s:%d", b.Name, relativePath(b.Path), b.Line+1))
+	}
+	showPicker("Bookmarks", items, 0, func(index int) {
+		if err := l.goTo(entries[index]); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	})
+}
+
+// decorate marks the bookmarked lines of the active buffer in the gutter,
+// with their digit for numbered bookmarks
+func (l *bookmarkList) decorate() {
+	if ui.editor == nil {
+		return
+	}
+	buf := buffers.current()
+	if buf == nil {
+		ui.editor.SetGutterMarks("bookmarks", nil)
+		return
+	}
+	marks := map[int]editor.GutterMark{}
+	for _, b := range l.entries {
+		if b.Path != buf.Path() {
+			continue
+		}
+		mark := '◆'
+		if len(b.Name) == 1 && strings.Contains(bookmarkDigits, b.Name) {
+			mark = rune(b.Name[0])
+		}
+		marks[b.Line] = editor.GutterMark{Rune: mark, Color: theme.Accent}
+	}
+	ui.editor.SetGutterMarks("bookmarks", marks)
+}
+
+// shiftBookmarks moves the bookmarks of the file at path along with their
+// lines across a replacement of [from, to) with text ending at end
+func shiftBookmarks(path string, from, to, end editor.Position) {
+	if path == "" || from.Line == to.Line && to.Line == end.Line {
+		return
+	}
+	moved := false
+	for i := range bookmarks.entries {
+		b := &bookmarks.entries[i]
+		if b.Path != path || b.Line <= from.Line {
+			continue
+		}
+		if line := editor.ShiftPosition(editor.Position{Line: b.Line}, from, to, end).Line; line != b.Line {
+			b.Line = line
+			moved = true
+		}
+	}
+	if moved {
+		bookmarks.decorate()
+	}
+}
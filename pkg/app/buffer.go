@@ -0,0 +1,447 @@
+package app
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Buffer is a document open in goui: the text and view state shown in the
+// editor, with the state of the file it is saved to
+type Buffer struct {
+	*editor.Buffer
+
+	dirty    bool
+	encoding *textEncoding // encoding of the file, kept when it is saved
+	// lineEnding is the line ending the file is saved with, lineEndingLF
+	// or lineEndingCRLF
+	lineEnding string
+
+	// modTime and diskHash are the modification time and the SHA-256 hash
+	// of the file when it was last read or written. declined is the
+	// modification time of an outside change the user chose not to reload.
+	modTime  time.Time
+	diskHash [sha256.Size]byte
+	declined time.Time
+
+	// unswapped is set by edits the recovery copy does not hold yet, and
+	// swapped while there is a recovery copy
+	unswapped bool
+	swapped   bool
+
+	// large is set for a file opened in large-file mode, which is shown by
+	// the large-file view instead of lines
+	large *largeFile
+	// hex is set for a binary file, which is shown by the hex viewer
+	hex *hexFile
+}
+
+// NewBuffer returns a buffer for the file at path holding text. An empty
+// path creates an unnamed scratch buffer.
+func NewBuffer(path, text string) *Buffer {
+	return &Buffer{Buffer: editor.NewBuffer(path, text), encoding: encodingUTF8, lineEnding: lineEndingLF}
+}
+
+// onDisk reports whether the buffer leaves its file on disk, to be shown by
+// a view of its own, rather than holding its text
+func (b *Buffer) onDisk() bool {
+	return b.large != nil || b.hex != nil
+}
+
+// setDiskState records the file's content and modification time after it
+// was read or written
+func (b *Buffer) setDiskState(content []byte) {
+	b.modTime = fileModTime(b.Path())
+	b.diskHash = sha256.Sum256(content)
+}
+
+// changedOnDisk reports whether another program changed the file since the
+// buffer was read or written. A file that was only touched has its new
+// modification time recorded and counts as unchanged.
+func (b *Buffer) changedOnDisk() bool {
+	modTime := fileModTime(b.Path())
+	if modTime.IsZero() || modTime.Equal(b.modTime) {
+		return false
+	}
+	if b.onDisk() {
+		// Hashing the file may be too slow, and it is never saved anyway
+		return true
+	}
+	content, err := os.ReadFile(b.Path())
+	if err != nil {
+		return true
+	}
+	if sha256.Sum256(content) != b.diskHash {
+		return true
+	}
+	b.modTime = modTime
+	return false
+}
+
+// bufferManager tracks the open buffers and renders them as tabs
+type bufferManager struct {
+	buffers  []*Buffer
+	active   int // index of the buffer shown in the editor, -1 if none
+	scratch  *Buffer
+	tabBar   *tview.TextView
+	readOnly bool // open files read-only
+}
+
+var buffers = bufferManager{active: -1}
+
+// createTabBar creates and returns the editor tab bar component
+func createTabBar() *tview.TextView {
+	tabBar := tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(false)
+
+	tabBar.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		if index, err := strconv.Atoi(added[0]); err == nil && index != buffers.active {
+			jumps.push()
+			buffers.switchTo(index)
+		}
+		tabBar.Highlight()
+	})
+
+	return tabBar
+}
+
+// current returns the active buffer, or nil if no file is open
+func (m *bufferManager) current() *Buffer {
+	if m.active < 0 {
+		return nil
+	}
+	return m.buffers[m.active]
+}
+
+// shown returns the buffer the editor shows: the active one, or the
+// scratch buffer while no file is open
+func (m *bufferManager) shown() *Buffer {
+	if buf := m.current(); buf != nil {
+		return buf
+	}
+	return m.scratch
+}
+
+// find returns the index of the buffer for path, or -1
+func (m *bufferManager) find(path string) int {
+	for i, buf := range m.buffers {
+		if buf.Path() == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// open switches to the buffer for path, loading the file if it is not open yet
+func (m *bufferManager) open(path string) error {
+	if index := m.find(path); index >= 0 {
+		m.switchTo(index)
+		return nil
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		var buf *Buffer
+		switch {
+		case isBinaryFile(path):
+			buf, err = NewHexBuffer(path)
+		case isLargeFile(info):
+			buf, err = NewLargeBuffer(path)
+		}
+		if err != nil {
+			return err
+		}
+		if buf != nil {
+			m.add(buf)
+			return nil
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	enc := detectEncoding(content)
+	text, err := enc.decode(content)
+	if err != nil {
+		return err
+	}
+	text, ending := splitLineEndings(text)
+	buf := NewBuffer(path, text)
+	buf.encoding, buf.lineEnding = enc, ending
+	buf.setDiskState(content)
+	m.add(buf)
+	return nil
+}
+
+// create opens an empty buffer for a file that does not exist yet; saving
+// the buffer creates the file
+func (m *bufferManager) create(path string) {
+	m.add(NewBuffer(path, ""))
+}
+
+// add appends a buffer and switches to it
+func (m *bufferManager) add(buf *Buffer) {
+	buf.SetReadOnly(m.readOnly || buf.onDisk())
+	m.buffers = append(m.buffers, buf)
+	m.switchTo(len(m.buffers) - 1)
+	gopls.didOpen(buf)
+	plugins.fileOpened(buf)
+	scripts.fileOpened(buf)
+	watcher.watch(filepath.Dir(buf.Path()))
+}
+
+// reload replaces the content of a buffer with its file, read in the
+// buffer's encoding, dropping unsaved changes. The cursor stays where it
+// was as far as possible.
+func (m *bufferManager) reload(buf *Buffer) error {
+	if buf.onDisk() {
+		var err error
+		if buf.large != nil {
+			err = buf.large.load()
+		} else {
+			err = buf.hex.load()
+		}
+		if err != nil {
+			return err
+		}
+		buf.modTime = fileModTime(buf.Path())
+		return nil
+	}
+	content, err := os.ReadFile(buf.Path())
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	text, err := buf.encoding.decode(content)
+	if err != nil {
+		return err
+	}
+	text, buf.lineEnding = splitLineEndings(text)
+	cursor := buf.Cursor()
+	buf.SetText(text)
+	buf.setDiskState(content)
+	buf.dirty = false
+	if buf.Buffer == ui.editor.Buffer() {
+		ui.editor.SetBuffer(buf.Buffer)
+		ui.editor.SetCursor(cursor)
+		finder.update(false)
+	} else {
+		buf.SetCursor(cursor)
+	}
+	gopls.didChange(buf)
+	m.refresh()
+	return nil
+}
+
+// switchTo makes the buffer at index the active one
+func (m *bufferManager) switchTo(index int) {
+	if index < 0 || index >= len(m.buffers) {
+		return
+	}
+	m.active = index
+	ui.editor.SetBuffer(m.buffers[index].Buffer)
+	applyIndentSettings()
+	showEditorView(m.buffers[index])
+	finder.update(false)
+	coverage.decorate()
+	problems.decorate()
+	git.decorate()
+	blame.decorate()
+	bookmarks.decorate()
+	delve.decorate()
+	m.refresh()
+}
+
+// showEditorView shows the view of a buffer in the editor area: the
+// editor, or the view standing in for it for large and binary files. The
+// focus moves along.
+func showEditorView(buf *Buffer) {
+	page, view, standIn := "editor", tview.Primitive(ui.editor), tview.Primitive(nil)
+	switch {
+	case buf.large != nil:
+		page, view = "large", ui.largeView.SetFile(buf.large)
+		standIn = view
+	case buf.hex != nil:
+		page, view = "hex", ui.hexView.SetFile(buf.hex)
+		standIn = view
+	}
+	focused := ui.editor.HasFocus() || ui.largeView.HasFocus() || ui.hexView.HasFocus()
+	ui.editor.SetStandIn(standIn)
+	ui.editorArea.SwitchToPage(page)
+	if focused {
+		ui.app.SetFocus(view)
+	}
+}
+
+// cycle moves the active tab by delta positions, wrapping around
+func (m *bufferManager) cycle(delta int) {
+	if len(m.buffers) == 0 {
+		return
+	}
+	jumps.push()
+	m.switchTo(((m.active+delta)%len(m.buffers) + len(m.buffers)) % len(m.buffers))
+}
+
+// close removes the active buffer, refusing to drop unsaved changes
+func (m *bufferManager) close() error {
+	if m.current() == nil {
+		return nil
+	}
+	return m.closeAt(m.active)
+}
+
+// discard removes the active buffer, dropping its unsaved changes
+func (m *bufferManager) discard() error {
+	buf := m.current()
+	if buf == nil {
+		return nil
+	}
+	buf.dirty = false
+	return m.closeAt(m.active)
+}
+
+// dirtyBuffers returns the buffers with unsaved changes
+func (m *bufferManager) dirtyBuffers() []*Buffer {
+	var dirty []*Buffer
+	for _, buf := range m.buffers {
+		if buf.dirty {
+			dirty = append(dirty, buf)
+		}
+	}
+	return dirty
+}
+
+// closeAt removes the buffer at index, refusing to drop unsaved changes
+func (m *bufferManager) closeAt(index int) error {
+	buf := m.buffers[index]
+	if buf.dirty {
+		return fmt.Errorf("%s has unsaved changes", buf.Name())
+	}
+	gopls.didClose(buf)
+	recovery.remove(buf)
+	if buf.large != nil {
+		buf.large.close()
+	}
+	if buf.hex != nil {
+		buf.hex.close()
+	}
+	m.buffers = append(m.buffers[:index], m.buffers[index+1:]...)
+	if index != m.active {
+		if index < m.active {
+			m.active--
+		}
+		m.refresh()
+		return nil
+	}
+	if len(m.buffers) == 0 {
+		m.active = -1
+		ui.editor.SetBuffer(m.scratch.Buffer)
+		applyIndentSettings()
+		showEditorView(m.scratch)
+		finder.update(false)
+		coverage.decorate()
+		problems.decorate()
+		git.decorate()
+		blame.decorate()
+		bookmarks.decorate()
+		delve.decorate()
+		m.refresh()
+		return nil
+	}
+	if m.active >= len(m.buffers) {
+		m.active = len(m.buffers) - 1
+	}
+	m.switchTo(m.active)
+	return nil
+}
+
+// withinPath reports whether path is target or lies inside the directory
+// target
+func withinPath(path, target string) bool {
+	return path == target || strings.HasPrefix(path, target+string(filepath.Separator))
+}
+
+// renamed updates the buffers of files that were moved from one path to
+// another, including the files inside a moved directory
+func (m *bufferManager) renamed(from, to string) {
+	for _, buf := range m.buffers {
+		if !withinPath(buf.Path(), from) {
+			continue
+		}
+		gopls.didClose(buf)
+		recovery.remove(buf)
+		buf.SetPath(to + strings.TrimPrefix(buf.Path(), from))
+		// Open files move along with their path
+		if buf.large != nil {
+			buf.large.path = buf.Path()
+		}
+		if buf.hex != nil {
+			buf.hex.path = buf.Path()
+		}
+		buf.unswapped = buf.dirty
+		gopls.didOpen(buf)
+	}
+	watcher.watch(filepath.Dir(to))
+	m.refresh()
+}
+
+// removed closes the buffers of deleted files. Buffers with unsaved changes
+// stay open, so saving them recreates the file. It returns the number of
+// buffers kept.
+func (m *bufferManager) removed(path string) int {
+	kept := 0
+	for i := len(m.buffers) - 1; i >= 0; i-- {
+		if !withinPath(m.buffers[i].Path(), path) {
+			continue
+		}
+		if m.buffers[i].dirty {
+			kept++
+			continue
+		}
+		_ = m.closeAt(i)
+	}
+	return kept
+}
+
+// setDirty updates the modified flag of the active buffer
+func (m *bufferManager) setDirty(dirty bool) {
+	buf := m.current()
+	if buf == nil || buf.dirty == dirty {
+		return
+	}
+	buf.dirty = dirty
+	m.refresh()
+}
+
+// refresh redraws the tab bar
+func (m *bufferManager) refresh() {
+	if m.tabBar == nil {
+		return
+	}
+	var b strings.Builder
+	for i, buf := range m.buffers {
+		colors := highlightTag(theme.Text, tcell.ColorDefault)
+		if i == m.active {
+			colors = highlightTag(theme.AccentText, theme.Accent)
+		}
+		marker := ""
+		if buf.dirty {
+			marker = " ●"
+		} else if buf.ReadOnly() {
+			marker = " (read-only)"
+		}
+		fmt.Fprintf(&b, `["%d"]%s %s%s [-:-:-][""] `, i, colors, tview.Escape(buf.Name()), marker)
+	}
+	m.tabBar.SetText(b.String())
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// stackDepth is the number of frames asked for in a call stack
+const stackDepth = 50
+
+// dapThread is a thread of a threads response; for dlv, a goroutine
+type dapThread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// stackViewer lists the goroutines of the paused program and the call stack
+// of one of them. Selecting a goroutine or a frame moves the editor and the
+// Variables panel there.
+type stackViewer struct {
+	panel      *tview.Flex
+	goroutines *tview.List
+	frames     *tview.List
+	threads    []dapThread
+	stack      []dapStackFrame
+	frame      int // index in stack of the selected frame
+	loads      int // number of loads started, to drop replies for replaced ones
+}
+
+var callStack stackViewer
+
+// createStackPanel creates and returns the Call Stack panel: the goroutines
+// and the frames of the selected one side by side
+func createStackPanel() *tview.Flex {
+	s := &callStack
+	s.goroutines = s.newList("Goroutines")
+	s.goroutines.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		s.selectThread(index)
+	})
+	s.frames = s.newList("Call Stack")
+	s.frames.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		s.selectFrame(index)
+	})
+	s.panel = tview.NewFlex().
+		AddItem(s.frames, 0, 1, true).
+		AddItem(s.goroutines, 0, 1, false)
+	s.render()
+	return s.panel
+}
+
+// newList creates one of the lists of the panel
+func (s *stackViewer) newList(title string) *tview.List {
+	list := tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true)
+	list.SetBorder(true).SetTitle(title)
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab {
+			if list == s.frames {
+				ui.app.SetFocus(s.goroutines)
+			} else {
+				ui.app.SetFocus(s.frames)
+			}
+			return nil
+		}
+		return event
+	})
+	list.SetDoneFunc(func() {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return list
+}
+
+// toggleStack shows the Call Stack panel, or hides it if it is showing
+func toggleStack() {
+	if name, _ := ui.panels.GetFrontPage(); name == "stack" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	showPanel("stack")
+	ui.app.SetFocus(callStack.frames)
+}
+
+// paused takes the call stack the program stopped with and lists the
+// goroutines, which are read from the debugger
+func (s *stackViewer) paused(frames []dapStackFrame) {
+	s.loads++
+	s.stack, s.frame, s.threads = frames, 0, nil
+	load := s.loads
+	delve.request("threads", nil, func(body json.RawMessage) {
+		if s.loads != load {
+			return
+		}
+		var result struct {
+			Threads []dapThread `json:"threads"`
+		}
+		if json.Unmarshal(body, &result) == nil {
+			s.threads = result.Threads
+			s.render()
+		}
+	})
+	s.render()
+}
+
+// clear empties the lists once the program runs again
+func (s *stackViewer) clear() {
+	s.loads++
+	s.stack, s.frame, s.threads = nil, 0, nil
+	s.render()
+}
+
+// render fills both lists
+func (s *stackViewer) render() {
+	if s.frames == nil {
+		return
+	}
+	s.frames.Clear()
+	for i, frame := range s.stack {
+		item := tview.Escape(frame.Name)
+		if frame.Source != nil && frame.Source.Path != "" {
+			item += fmt.Sprintf("  %s%s:%d[-]", colorTag(theme.Muted), tview.Escape(filepath.Base(frame.Source.Path)), frame.Line)
+		}
+		if i == s.frame {
+			item = colorTag(theme.Warning) + "▶[-] " + item
+		} else {
+			item = "  " + item
+		}
+		s.frames.AddItem(item, "", 0, nil)
+	}
+	if len(s.stack) == 0 {
+		s.frames.AddItem(colorTag(theme.Muted)+"Not paused[-]", "", 0, nil)
+	}
+	s.frames.SetCurrentItem(s.frame)
+
+	current := 0
+	s.goroutines.Clear()
+	for i, thread := range s.threads {
+		item := "  " + tview.Escape(thread.Name)
+		if thread.ID == delve.thread {
+			item = colorTag(theme.Warning) + "▶[-] " + tview.Escape(thread.Name)
+			current = i
+		}
+		s.goroutines.AddItem(item, "", 0, nil)
+	}
+	s.goroutines.SetTitle(fmt.Sprintf("Goroutines (%d)", len(s.threads)))
+	if len(s.threads) == 0 {
+		s.goroutines.SetTitle("Goroutines")
+	}
+	s.goroutines.SetCurrentItem(current)
+}
+
+// selectFrame moves the editor and the Variables panel to a frame of the
+// call stack
+func (s *stackViewer) selectFrame(index int) {
+	if index < 0 || index >= len(s.stack) || delve.state != debugPaused {
+		return
+	}
+	s.frame = index
+	s.render()
+	delve.showFrame(s.stack[index])
+}
+
+// selectThread lists the call stack of a goroutine and moves to its top
+// frame
+func (s *stackViewer) selectThread(index int) {
+	if index < 0 || index >= len(s.threads) || delve.state != debugPaused {
+		return
+	}
+	delve.thread = s.threads[index].ID
+	s.loads++
+	load := s.loads
+	delve.request("stackTrace", map[string]int{"threadId": delve.thread, "startFrame": 0, "levels": stackDepth}, func(body json.RawMessage) {
+		if s.loads != load {
+			return
+		}
+		var trace struct {
+			StackFrames []dapStackFrame `json:"stackFrames"`
+		}
+		if err := json.Unmarshal(body, &trace); err != nil {
+			return
+		}
+		s.stack, s.frame = trace.StackFrames, 0
+		s.render()
+		if len(s.stack) > 0 {
+			delve.showFrame(s.stack[0])
+		}
+	})
+	s.render()
+}
@@ -0,0 +1,36 @@
+package app
+
+import (
+	"os"
+
+	"gotui/pkg/clipboard"
+)
+
+// systemClipboard copies and pastes through the best available provider
+var systemClipboard = clipboard.New(func() clipboard.Provider {
+	return clipboard.Detect(ttyWriter{})
+})
+
+// ttyWriter writes to the terminal goui runs in, for OSC 52. The terminal is
+// opened for each write, as the screen tview draws on is not shared.
+type ttyWriter struct{}
+
+// Write sends p to the terminal
+func (ttyWriter) Write(p []byte) (int, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer tty.Close()
+	return tty.Write(p)
+}
+
+// copyToClipboard writes text to the system clipboard
+func copyToClipboard(text string) error {
+	return systemClipboard.Copy(text)
+}
+
+// readClipboard returns the content of the system clipboard
+func readClipboard() (string, error) {
+	return systemClipboard.Paste()
+}
@@ -0,0 +1,527 @@
+package app
+
+import (
+	"fmt"
+)
+
+// Command is an action that can be bound to keys and run from the command
+// palette or the menu bar
+type Command struct {
+	Name  string   // used in the keys file, such as "go-to-line"
+	Title string   // shown in the palette, such as "Go to Line"
+	Scope string   // where its keys are active, one of the scope constants
+	Keys  []string // default keys, used when the keys file does not bind it
+	Run   func()
+}
+
+// commands are the registered commands by name
+var commands = map[string]*Command{}
+
+// RegisterCommand adds a command, replacing any with the same name
+func RegisterCommand(c Command) {
+	commands[c.Name] = &c
+}
+
+// runCommand runs the named command, reporting whether there is one
+func runCommand(name string) bool {
+	c, ok := commands[name]
+	if !ok {
+		return false
+	}
+	c.Run()
+	return true
+}
+
+// defaultKeySpecs returns the default keys of every command
+func defaultKeySpecs() map[string][]string {
+	specs := make(map[string][]string, len(commands))
+	for name, c := range commands {
+		specs[name] = c.Keys
+	}
+	return specs
+}
+
+func init() {
+	// Global commands
+	for _, c := range []Command{
+		{Name: "save", Title: "Save File", Keys: []string{"Ctrl+S"}, Run: func() {
+			if commit.focused() {
+				commit.submit()
+				return
+			}
+			if err := saveFile(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error saving file: %s", err))
+			}
+		}},
+		{Name: "quit", Title: "Quit", Keys: []string{"Ctrl+Q"}, Run: quit},
+		{Name: "focus-terminal", Title: "Focus Terminal", Keys: []string{"Ctrl+T"}, Run: func() {
+			showPane(terminalPane)
+			ui.app.SetFocus(ui.terminal)
+		}},
+		{Name: "focus-editor", Title: "Focus Editor", Keys: []string{"Ctrl+E"}, Run: func() {
+			showPane(editorPane)
+			ui.app.SetFocus(ui.editor)
+		}},
+		{Name: "focus-explorer", Title: "Focus File Explorer", Keys: []string{"Ctrl+F"}, Run: func() {
+			showPane(explorerPane)
+			ui.app.SetFocus(ui.fileExplorer)
+		}},
+		{Name: "close-tab", Title: "Close Tab", Keys: []string{"Ctrl+W"}, Run: closeTab},
+		{Name: "next-tab", Title: "Next Tab", Keys: []string{"Ctrl+Tab", "Ctrl+PgDn"}, Run: func() {
+			buffers.cycle(1)
+		}},
+		{Name: "previous-tab", Title: "Previous Tab", Keys: []string{"Ctrl+Shift+Tab", "Ctrl+PgUp"}, Run: func() {
+			buffers.cycle(-1)
+		}},
+		{Name: "find", Title: "Find and Replace", Keys: []string{"Alt+F"}, Run: func() {
+			if buf := buffers.current(); buf != nil && buf.hex != nil {
+				promptHexSearch()
+				return
+			}
+			finder.show()
+		}},
+		{Name: "search-files", Title: "Search in Files", Keys: []string{"F3"}, Run: func() {
+			projectSearch.open()
+		}},
+		{Name: "add-workspace-folder", Title: "Add Folder to Workspace", Run: func() {
+			promptAddWorkspaceFolder()
+		}},
+		{Name: "remove-workspace-folder", Title: "Remove Folder from Workspace", Run: func() {
+			pickRemoveWorkspaceFolder()
+		}},
+		{Name: "problems", Title: "Toggle Problems Panel", Keys: []string{"F8"}, Run: func() {
+			toggleProblems()
+		}},
+		{Name: "go-to-line", Title: "Go to Line", Keys: []string{"Ctrl+G"}, Run: func() {
+			promptGoToLine()
+		}},
+		{Name: "reload-keys", Title: "Reload Key Bindings", Keys: []string{"Alt+R"}, Run: func() {
+			if err := reloadKeyBindings(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error loading key bindings: %s", err))
+			} else {
+				ui.output.message("Key bindings reloaded")
+			}
+		}},
+		{Name: "reload-config", Title: "Reload Config", Keys: []string{"Alt+Shift+R"}, Run: func() {
+			if err := reloadConfig(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error loading config: %s", err))
+			} else {
+				ui.output.message("Config reloaded")
+			}
+		}},
+		{Name: "theme", Title: "Switch Theme", Keys: []string{"Alt+Shift+T"}, Run: func() {
+			showThemes()
+		}},
+		{Name: "encoding", Title: "Change File Encoding", Keys: []string{"Alt+Shift+E"}, Run: func() {
+			changeEncoding()
+		}},
+		{Name: "line-endings", Title: "Change Line Endings", Keys: []string{"Alt+Shift+L"}, Run: func() {
+			changeLineEndings()
+		}},
+		{Name: "command-palette", Title: "Command Palette", Keys: []string{"Ctrl+Shift+P", "Ctrl+P"}, Run: func() {
+			showCommandPalette()
+		}},
+		{Name: "explorer-wider", Title: "Widen File Explorer", Keys: []string{"Alt+Shift+Right"}, Run: func() {
+			resizeExplorer(resizeStep)
+		}},
+		{Name: "explorer-narrower", Title: "Narrow File Explorer", Keys: []string{"Alt+Shift+Left"}, Run: func() {
+			resizeExplorer(-resizeStep)
+		}},
+		{Name: "pane-taller", Title: "Make Pane Taller", Keys: []string{"Alt+Shift+Up"}, Run: func() {
+			resizeFocusedPane(resizeStep)
+		}},
+		{Name: "pane-shorter", Title: "Make Pane Shorter", Keys: []string{"Alt+Shift+Down"}, Run: func() {
+			resizeFocusedPane(-resizeStep)
+		}},
+		{Name: "toggle-explorer", Title: "Show or Hide File Explorer", Keys: []string{"Alt+E"}, Run: func() {
+			togglePane(explorerPane)
+		}},
+		{Name: "toggle-panels", Title: "Show or Hide Output Panels", Keys: []string{"Alt+O"}, Run: func() {
+			togglePane(panelsPane)
+		}},
+		{Name: "next-output", Title: "Next Output Channel", Run: func() {
+			outputs.cycle(1)
+		}},
+		{Name: "previous-output", Title: "Previous Output Channel", Run: func() {
+			outputs.cycle(-1)
+		}},
+		{Name: "clear-output", Title: "Clear Output Channel", Run: func() {
+			outputs.clear()
+		}},
+		{Name: "copy-output", Title: "Copy Output Channel", Run: func() {
+			outputs.copy()
+		}},
+		{Name: "toggle-output-follow", Title: "Toggle Following Output", Run: func() {
+			outputs.toggleFollow()
+		}},
+		{Name: "notifications", Title: "Show or Hide Notifications", Run: func() {
+			toggleNotifications()
+		}},
+		{Name: "dismiss-notifications", Title: "Dismiss Notifications", Run: func() {
+			notifications.dismiss()
+		}},
+		{Name: "toggle-terminal", Title: "Show or Hide Terminal", Keys: []string{"Alt+J"}, Run: func() {
+			togglePane(terminalPane)
+		}},
+		{Name: "zoom", Title: "Zoom Pane", Keys: []string{"Alt+Z"}, Run: func() {
+			toggleZoom()
+		}},
+		{Name: "open-from-terminal", Title: "Open File from Terminal Directory", Keys: []string{"Alt+Shift+O"}, Run: func() {
+			promptOpenFromTerminal()
+		}},
+		{Name: "new-terminal", Title: "New Terminal", Keys: []string{"Alt+T"}, Run: func() {
+			if err := terminals.spawn(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error starting terminal: %s", err))
+			} else {
+				showPane(terminalPane)
+				ui.app.SetFocus(ui.terminal)
+			}
+		}},
+		{Name: "build", Title: "Build", Keys: []string{"F7"}, Run: func() {
+			builds.start("build", "./...")
+		}},
+		{Name: "run", Title: "Run", Keys: []string{"F5"}, Run: func() {
+			builds.start("run", ".")
+		}},
+		{Name: "run-configuration", Title: "Run Configuration", Run: func() {
+			showRunConfigs(false)
+		}},
+		{Name: "next-error", Title: "Next Build Error", Keys: []string{"F4"}, Run: func() {
+			builds.nextError(1)
+		}},
+		{Name: "previous-error", Title: "Previous Build Error", Keys: []string{"Shift+F4"}, Run: func() {
+			builds.nextError(-1)
+		}},
+		{Name: "go-doc-package", Title: "Go Doc for Package or Symbol", Keys: []string{"Shift+F1"}, Run: func() {
+			promptDoc()
+		}},
+		{Name: "dependencies", Title: "Toggle Dependencies Panel", Keys: []string{"Ctrl+F7"}, Run: func() {
+			toggleModules()
+		}},
+		{Name: "update-dependencies", Title: "Update All Dependencies", Run: func() {
+			modules.run("go get -u ./...")
+		}},
+		{Name: "go-mod-tidy", Title: "Go Mod Tidy", Run: func() {
+			modules.run("go mod tidy")
+		}},
+		{Name: "go-mod-vendor", Title: "Go Mod Vendor", Run: func() {
+			modules.run("go mod vendor")
+		}},
+		{Name: "debug", Title: "Start Debugging or Continue", Keys: []string{"Shift+F9"}, Run: func() {
+			delve.start()
+		}},
+		{Name: "debug-configuration", Title: "Debug Configuration", Run: func() {
+			showRunConfigs(true)
+		}},
+		{Name: "stop-debugging", Title: "Stop Debugging", Keys: []string{"Ctrl+F9"}, Run: func() {
+			delve.stop()
+		}},
+		{Name: "pause", Title: "Pause Program", Run: func() {
+			delve.pause()
+		}},
+		{Name: "step-over", Title: "Step Over", Keys: []string{"F10"}, Run: func() {
+			delve.resume("next")
+		}},
+		{Name: "step-into", Title: "Step Into", Keys: []string{"F11"}, Run: func() {
+			delve.resume("stepIn")
+		}},
+		{Name: "step-out", Title: "Step Out", Keys: []string{"Shift+F11"}, Run: func() {
+			delve.resume("stepOut")
+		}},
+		{Name: "variables", Title: "Toggle Variables Panel", Keys: []string{"Alt+F9"}, Run: func() {
+			toggleVariables()
+		}},
+		{Name: "call-stack", Title: "Toggle Call Stack Panel", Keys: []string{"Alt+F10"}, Run: func() {
+			toggleStack()
+		}},
+		{Name: "debug-console", Title: "Toggle Debug Console", Keys: []string{"Alt+F11"}, Run: func() {
+			toggleConsole()
+		}},
+		{Name: "add-watch", Title: "Add Watch Expression", Run: func() {
+			promptWatch()
+		}},
+		{Name: "tasks", Title: "Run Task", Keys: []string{"Alt+F5"}, Run: func() {
+			showTasks()
+		}},
+		{Name: "cancel-task", Title: "Cancel Task", Run: func() {
+			tasks.cancel()
+		}},
+		{Name: "cancel", Title: "Cancel Running Command", Keys: []string{"Shift+F5"}, Run: func() {
+			jobs.cancel()
+		}},
+		{Name: "toggle-watch", Title: "Toggle Watch Mode", Keys: []string{"Ctrl+F5"}, Run: func() {
+			watchMode.toggle()
+		}},
+		{Name: "tests", Title: "Toggle Tests Panel", Keys: []string{"Ctrl+F6"}, Run: func() {
+			toggleTests()
+		}},
+		{Name: "test-all", Title: "Run All Tests", Keys: []string{"F6"}, Run: func() {
+			tests.runAll()
+		}},
+		{Name: "test-failed", Title: "Run Failed Tests", Keys: []string{"Alt+F6"}, Run: func() {
+			tests.runFailed()
+		}},
+		{Name: "lint", Title: "Run golangci-lint", Keys: []string{"Alt+F12"}, Run: func() {
+			lint.run()
+		}},
+		{Name: "test-coverage", Title: "Run All Tests with Coverage", Keys: []string{"Alt+F7"}, Run: func() {
+			tests.runCoverage()
+		}},
+		{Name: "coverage", Title: "Toggle Coverage Panel", Keys: []string{"Alt+F8"}, Run: func() {
+			toggleCoverage()
+		}},
+		{Name: "toggle-coverage-marks", Title: "Toggle Coverage in Gutter", Run: func() {
+			coverage.toggleMarks()
+		}},
+		{Name: "git", Title: "Toggle Git Panel", Keys: []string{"Alt+G"}, Run: func() {
+			toggleGit()
+		}},
+		{Name: "diff", Title: "Diff File Against HEAD", Keys: []string{"Alt+D"}, Run: func() {
+			if buf := buffers.current(); buf != nil {
+				showFileDiff(buf.Path())
+			} else {
+				ui.output.message("No file loaded")
+			}
+		}},
+		{Name: "diff-revisions", Title: "Diff Revisions", Keys: []string{"Alt+Shift+D"}, Run: func() {
+			promptDiffRevisions()
+		}},
+		{Name: "blame", Title: "Toggle Blame", Keys: []string{"Alt+B"}, Run: func() {
+			blame.toggle()
+		}},
+		{Name: "branches", Title: "Switch Branch", Keys: []string{"Alt+Shift+B"}, Run: func() {
+			showBranches()
+		}},
+		{Name: "git-log", Title: "Git Log", Keys: []string{"Alt+L"}, Run: func() {
+			showLog()
+		}},
+		{Name: "bookmarks", Title: "List Bookmarks", Keys: []string{"Alt+M"}, Run: func() {
+			bookmarks.show()
+		}},
+		{Name: "next-bookmark", Title: "Next Bookmark", Keys: []string{"Alt+N"}, Run: func() {
+			if err := bookmarks.next(1); err != nil {
+				notify(noticeError, fmt.Sprintf("Error going to bookmark: %s", err))
+			}
+		}},
+		{Name: "previous-bookmark", Title: "Previous Bookmark", Keys: []string{"Alt+Shift+N"}, Run: func() {
+			if err := bookmarks.next(-1); err != nil {
+				notify(noticeError, fmt.Sprintf("Error going to bookmark: %s", err))
+			}
+		}},
+		{Name: "record-macro", Title: "Start or Stop Recording Macro", Keys: []string{"Alt+Q"}, Run: func() {
+			macros.toggle()
+		}},
+		{Name: "play-macro", Title: "Play Macro", Keys: []string{"Alt+A"}, Run: func() {
+			if err := macros.play(1); err != nil {
+				notify(noticeError, fmt.Sprintf("Error playing macro: %s", err))
+			}
+		}},
+		{Name: "play-macro-times", Title: "Play Macro Several Times", Keys: []string{"Alt+Shift+A"}, Run: func() {
+			promptPlayMacro()
+		}},
+	} {
+		c.Scope = scopeGlobal
+		RegisterCommand(c)
+	}
+	for _, digit := range bookmarkDigits {
+		name := string(digit)
+		RegisterCommand(Command{Name: "bookmark-" + name, Title: "Go to Bookmark " + name, Keys: []string{"Alt+" + name}, Scope: scopeGlobal, Run: func() {
+			if err := bookmarks.jumpTo(name); err != nil {
+				notify(noticeError, fmt.Sprintf("Error going to bookmark: %s", err))
+			}
+		}})
+	}
+
+	// Commands of the focused editor
+	for _, c := range []Command{
+		{Name: "complete", Title: "Complete", Keys: []string{"Ctrl+Space"}, Run: func() {
+			gopls.complete()
+		}},
+		{Name: "toggle-fold", Title: "Fold or Unfold at Cursor", Keys: []string{"Alt+-"}, Run: foldCurrent},
+		{Name: "fold-all", Title: "Fold All", Keys: []string{"Alt+0"}, Run: func() {
+			ui.editor.FoldAll()
+		}},
+		{Name: "unfold-all", Title: "Unfold All", Keys: []string{"Alt+="}, Run: func() {
+			ui.editor.UnfoldAll()
+		}},
+		{Name: "jump-to-bracket", Title: "Go to Matching Bracket", Keys: []string{"Ctrl+\\"}, Run: func() {
+			if !ui.editor.JumpToBracket() {
+				ui.output.message("No bracket at the cursor")
+			}
+		}},
+		{Name: "add-next-match", Title: "Add Cursor at Next Occurrence", Keys: []string{"Ctrl+D"}, Run: func() {
+			if !ui.editor.AddNextMatch() {
+				ui.output.message("No further occurrence of the selection")
+			}
+		}},
+		{Name: "toggle-comment", Title: "Toggle Comment", Keys: []string{"Ctrl+/"}, Run: func() {
+			toggleComment()
+		}},
+		{Name: "toggle-occurrences", Title: "Toggle Occurrence Highlighting", Run: func() {
+			ui.editor.SetWordHighlight(!ui.editor.WordHighlight())
+		}},
+		{Name: "toggle-invisibles", Title: "Toggle Invisibles", Run: func() {
+			ui.editor.SetInvisibles(!ui.editor.Invisibles())
+		}},
+		{Name: "toggle-wrap", Title: "Toggle Word Wrap", Keys: []string{"Alt+Shift+W"}, Run: func() {
+			ui.editor.SetWrap(!ui.editor.Wrap())
+		}},
+		{Name: "reindent", Title: "Reindent to Current Settings", Run: func() {
+			reindent()
+		}},
+		{Name: "toggle-bookmark", Title: "Toggle Bookmark", Keys: []string{"Alt+K"}, Run: func() {
+			if err := bookmarks.toggle(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error setting bookmark: %s", err))
+			}
+		}},
+		{Name: "name-bookmark", Title: "Add Named Bookmark", Keys: []string{"Alt+Shift+K"}, Run: func() {
+			promptBookmarkName()
+		}},
+		{Name: "toggle-breakpoint", Title: "Toggle Breakpoint", Keys: []string{"F9"}, Run: func() {
+			if err := delve.toggleBreakpoint(ui.editor.Cursor().Line); err != nil {
+				notify(noticeError, fmt.Sprintf("Error setting breakpoint: %s", err))
+			}
+		}},
+		{Name: "hover", Title: "Show Documentation", Keys: []string{"F1"}, Run: func() {
+			gopls.hover()
+		}},
+		{Name: "go-doc", Title: "Show Documentation in Panel", Keys: []string{"Alt+F1"}, Run: func() {
+			docs.showAtCursor()
+		}},
+		{Name: "definition", Title: "Go to Definition", Keys: []string{"F12", "Ctrl+]"}, Run: func() {
+			gopls.definition()
+		}},
+		{Name: "references", Title: "Find References", Keys: []string{"Shift+F12"}, Run: func() {
+			gopls.references()
+		}},
+		{Name: "rename", Title: "Rename Symbol", Keys: []string{"F2"}, Run: func() {
+			gopls.rename()
+		}},
+		{Name: "jump-back", Title: "Jump Back", Keys: []string{"Alt+Left"}, Run: func() {
+			if err := jumps.back(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error going back: %s", err))
+			}
+		}},
+		{Name: "jump-forward", Title: "Jump Forward", Keys: []string{"Alt+Right"}, Run: func() {
+			if err := jumps.forward(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error going forward: %s", err))
+			}
+		}},
+		{Name: "test-at-cursor", Title: "Run Test at Cursor", Keys: []string{"Shift+F6"}, Run: func() {
+			tests.runAtCursor()
+		}},
+		{Name: "paste-to-terminal", Title: "Send Selection to Terminal", Keys: []string{"Alt+P"}, Run: func() {
+			pasteToTerminal()
+		}},
+		{Name: "copy", Title: "Copy", Keys: []string{"Ctrl+C"}, Run: func() {
+			copySelection(false)
+		}},
+		{Name: "cut", Title: "Cut", Keys: []string{"Ctrl+X"}, Run: func() {
+			copySelection(true)
+		}},
+		{Name: "paste", Title: "Paste", Keys: []string{"Ctrl+V"}, Run: func() {
+			pasteClipboard()
+		}},
+	} {
+		c.Scope = scopeEditor
+		RegisterCommand(c)
+	}
+
+	// Commands of the focused terminal
+	for _, c := range []Command{
+		{Name: "customize-terminal", Title: "Customize Terminal Colors", Keys: []string{"Ctrl+A"}, Run: func() {
+			customizeTerminal()
+		}},
+		{Name: "scroll-up", Title: "Scroll Terminal Up", Keys: []string{"Shift+Up"}, Run: func() {
+			ui.terminal.Scroll(1)
+		}},
+		{Name: "scroll-down", Title: "Scroll Terminal Down", Keys: []string{"Shift+Down"}, Run: func() {
+			ui.terminal.Scroll(-1)
+		}},
+		{Name: "scroll-page-up", Title: "Scroll Terminal Page Up", Keys: []string{"Shift+PgUp"}, Run: func() {
+			ui.terminal.ScrollPage(1)
+		}},
+		{Name: "scroll-page-down", Title: "Scroll Terminal Page Down", Keys: []string{"Shift+PgDn"}, Run: func() {
+			ui.terminal.ScrollPage(-1)
+		}},
+		{Name: "scroll-to-bottom", Title: "Scroll Terminal to Bottom", Keys: []string{"Shift+End"}, Run: func() {
+			ui.terminal.ScrollToBottom()
+		}},
+		{Name: "toggle-follow", Title: "Toggle Following Terminal Output", Keys: []string{"Alt+End"}, Run: func() {
+			ui.terminal.SetFollow(!ui.terminal.Follow())
+			if ui.terminal.Follow() {
+				ui.output.message("Terminal scrolls to the bottom on new output")
+			} else {
+				ui.output.message("Terminal keeps its scroll position on new output")
+			}
+		}},
+		{Name: "close-terminal", Title: "Close Terminal", Keys: []string{"Alt+W"}, Run: func() {
+			if err := terminals.close(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error starting terminal: %s", err))
+			}
+		}},
+		{Name: "copy-mode", Title: "Terminal Copy Mode", Keys: []string{"Alt+C"}, Run: func() {
+			ui.terminal.EnterCopyMode()
+		}},
+		{Name: "search-terminal", Title: "Search Terminal", Keys: []string{"Alt+Shift+F"}, Run: func() {
+			showPane(terminalPane)
+			ui.app.SetFocus(ui.terminal)
+			ui.terminal.StartSearch(false)
+		}},
+		{Name: "terminal-links", Title: "Open Terminal Link", Keys: []string{"Alt+Shift+U"}, Run: pickTerminalLink},
+		{Name: "toggle-terminal-log", Title: "Toggle Terminal Session Log", Keys: []string{"Alt+Shift+S"}, Run: toggleTerminalLog},
+		{Name: "terminal-paste", Title: "Paste into Terminal", Keys: []string{"Alt+V"}, Run: func() {
+			text, err := readClipboard()
+			if err != nil {
+				notify(noticeError, fmt.Sprintf("Error reading clipboard: %s", err))
+			} else {
+				terminals.current().paste(text)
+			}
+		}},
+		{Name: "next-terminal", Title: "Next Terminal", Keys: []string{"Alt+PgDn"}, Run: func() {
+			terminals.cycle(1)
+		}},
+		{Name: "previous-terminal", Title: "Previous Terminal", Keys: []string{"Alt+PgUp"}, Run: func() {
+			terminals.cycle(-1)
+		}},
+	} {
+		c.Scope = scopeTerminal
+		RegisterCommand(c)
+	}
+
+	// Commands of the focused file explorer, acting on its selected entry
+	for _, c := range []Command{
+		{Name: "new-file", Title: "New File", Keys: []string{"a"}, Run: func() {
+			promptNewEntry(false)
+		}},
+		{Name: "new-directory", Title: "New Directory", Keys: []string{"Shift+A"}, Run: func() {
+			promptNewEntry(true)
+		}},
+		{Name: "rename-file", Title: "Rename File", Keys: []string{"r", "F2"}, Run: func() {
+			promptRename()
+		}},
+		{Name: "delete-file", Title: "Delete File", Keys: []string{"d", "Delete"}, Run: func() {
+			confirmDelete()
+		}},
+		{Name: "terminal-here", Title: "Open Terminal Here", Keys: []string{"t"}, Run: func() {
+			openTerminalHere()
+		}},
+		{Name: "find-in-folder", Title: "Find in Folder", Keys: []string{"f"}, Run: func() {
+			searchSelectedDir()
+		}},
+		{Name: "explorer-menu", Title: "File Menu", Keys: []string{"m"}, Run: func() {
+			showExplorerMenu()
+		}},
+		{Name: "toggle-hidden", Title: "Toggle Hidden Files", Keys: []string{"."}, Run: func() {
+			toggleHidden()
+		}},
+		{Name: "diff-file", Title: "Show Changes of File", Keys: []string{"c"}, Run: func() {
+			if node := ui.fileExplorer.GetCurrentNode(); node != nil {
+				showFileDiff(ui.fileExplorer.Path(node))
+			}
+		}},
+	} {
+		c.Scope = scopeExplorer
+		RegisterCommand(c)
+	}
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gotui/pkg/editor"
+)
+
+// commentStyle is how a language comments out a line: with a token in front
+// of it, or between the start and end of a block comment if it has no line
+// comments
+type commentStyle struct {
+	start string
+	end   string // "" for line comments
+}
+
+// commentStyles are the comment styles by file extension, or by base name
+// for files without one
+var commentStyles = func() map[string]commentStyle {
+	styles := map[string]commentStyle{}
+	for token, patterns := range map[string][]string{
+		"//": {".go", ".c", ".h", ".cc", ".cpp", ".hpp", ".java", ".js", ".jsx", ".ts", ".tsx", ".rs", ".swift", ".kt", ".scala", ".cs", ".proto", ".zig", ".dart", ".mod", ".jsonc"},
+		"#":  {".sh", ".bash", ".zsh", ".bashrc", ".profile", ".py", ".rb", ".pl", ".toml", ".yaml", ".yml", ".conf", ".ini", ".r", ".tf", ".mk", ".gitignore", ".dockerignore", "Makefile", "Dockerfile", "Gemfile"},
+		"--": {".lua", ".sql", ".hs", ".elm"},
+		";":  {".lisp", ".el", ".clj", ".scm", ".asm"},
+		"%":  {".tex", ".erl"},
+		`"`:  {".vim", ".vimrc"},
+	} {
+		for _, pattern := range patterns {
+			styles[pattern] = commentStyle{start: token}
+		}
+	}
+	for _, pattern := range []string{".html", ".htm", ".xml", ".svg", ".md", ".markdown", ".vue"} {
+		styles[pattern] = commentStyle{start: "<!--", end: "-->"}
+	}
+	styles[".css"] = commentStyle{start: "/*", end: "*/"}
+	styles[".scss"] = commentStyle{start: "//"}
+	return styles
+}()
+
+// commentStyleFor returns the comment style of a file, and false if its
+// language is not known
+func commentStyleFor(path string) (commentStyle, bool) {
+	base := filepath.Base(path)
+	if style, ok := commentStyles[base]; ok {
+		return style, true
+	}
+	style, ok := commentStyles[strings.ToLower(filepath.Ext(base))]
+	return style, ok
+}
+
+// commented reports whether a line without its indentation is commented out
+func (s commentStyle) commented(text string) bool {
+	return strings.HasPrefix(text, s.start) && strings.HasSuffix(text, s.end) && len(text) >= len(s.start)+len(s.end)
+}
+
+// uncomment removes the comment around a line without its indentation, and
+// the space that separates it from the text
+func (s commentStyle) uncomment(text string) string {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, s.start), " ")
+	if s.end != "" {
+		text = strings.TrimSuffix(strings.TrimSuffix(text, s.end), " ")
+	}
+	return text
+}
+
+// comment comments out a line without its indentation
+func (s commentStyle) comment(text string) string {
+	if s.end != "" {
+		return s.start + " " + text + " " + s.end
+	}
+	return s.start + " " + text
+}
+
+// toggleComment comments out the lines of the selection, or the cursor's
+// line, or uncomments them if they all are commented out. Blank lines are
+// left alone, and comments go at the smallest indentation of the lines.
+func toggleComment() {
+	buf := buffers.current()
+	if buf == nil {
+		return
+	}
+	if buf.ReadOnly() {
+		ui.output.message(fmt.Sprintf("%s is read-only", buf.Name()))
+		return
+	}
+	style, ok := commentStyleFor(buf.Path())
+	if !ok {
+		ui.output.message("No comment syntax known for " + buf.Name())
+		return
+	}
+	e := ui.editor
+	from, to := e.Selection()
+	first, last := from.Line, to.Line
+	if last > first && to.Col == 0 {
+		// A selection of whole lines ends at the start of the next one
+		last--
+	}
+	lines := make([]string, last-first+1)
+	indent, uncommenting := -1, true
+	for i := range lines {
+		line := buf.Lines()[first+i]
+		lines[i] = string(line)
+		n := len(editor.LeadingSpace(line))
+		if n == len(line) {
+			continue
+		}
+		if indent < 0 || n < indent {
+			indent = n
+		}
+		uncommenting = uncommenting && style.commented(string(line[n:]))
+	}
+	if indent < 0 {
+		return
+	}
+	for i, line := range lines {
+		runes := []rune(line)
+		if len(editor.LeadingSpace(runes)) == len(runes) {
+			continue
+		}
+		if uncommenting {
+			n := len(editor.LeadingSpace(runes))
+			lines[i] = string(runes[:n]) + style.uncomment(string(runes[n:]))
+		} else {
+			lines[i] = string(runes[:indent]) + style.comment(string(runes[indent:]))
+		}
+	}
+	cursor := buf.Cursor()
+	oldLength := len(buf.Lines()[cursor.Line])
+	end := e.Replace(editor.Position{Line: first}, editor.Position{Line: last, Col: len(buf.Lines()[last])}, strings.Join(lines, "\n"))
+	if from != to {
+		e.Select(editor.Position{Line: first}, end)
+		return
+	}
+	// The cursor stays on the same text
+	col := cursor.Col + len(buf.Lines()[cursor.Line]) - oldLength
+	if col < 0 {
+		col = 0
+	}
+	e.SetCursor(editor.Position{Line: cursor.Line, Col: col})
+}
@@ -0,0 +1,408 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// terminalConfig describes the shell started in terminal sessions
+type terminalConfig struct {
+	Shell string            `toml:"shell"` // defaults to $SHELL, then bash
+	Args  []string          `toml:"args"`
+	Dir   string            `toml:"dir"` // defaults to the current directory
+	Env   map[string]string `toml:"env"`
+	// Log writes the output of every session to a file from its start
+	Log    bool   `toml:"log"`
+	LogDir string `toml:"log_dir"` // defaults to goui/terminal-logs in the cache directory
+}
+
+// explorerConfig holds the file explorer settings
+type explorerConfig struct {
+	ShowHidden bool `toml:"show_hidden"` // show dotfiles and ignored files
+}
+
+// editorConfig holds the editor settings
+type editorConfig struct {
+	TabWidth     int  `toml:"tab_width"`     // display width of a tab character
+	InsertSpaces bool `toml:"insert_spaces"` // Tab and new indentation levels insert spaces
+	AutoIndent   bool `toml:"auto_indent"`   // Enter keeps the indentation, adding a level after an opening bracket
+	AutoClose    bool `toml:"auto_close"`    // typing an opening bracket or quote inserts the closing one
+	// Autosave is the number of seconds without edits after which changed
+	// files are saved, 0 to leave saving to the user
+	Autosave            int  `toml:"autosave"`
+	AutosaveOnFocusLoss bool `toml:"autosave_on_focus_loss"` // save when the editor loses the focus
+	// LargeFileSize is the size in megabytes from which files are opened
+	// read-only in large-file mode
+	LargeFileSize int `toml:"large_file_size"`
+	// HighlightOccurrences highlights the other occurrences of the
+	// identifier at the cursor
+	HighlightOccurrences bool `toml:"highlight_occurrences"`
+	// TrimTrailingWhitespace strips the spaces and tabs ending lines, and
+	// InsertFinalNewline makes files end with a newline, when saving
+	TrimTrailingWhitespace bool `toml:"trim_trailing_whitespace"`
+	InsertFinalNewline     bool `toml:"insert_final_newline"`
+	ShowInvisibles         bool `toml:"show_invisibles"` // mark the spaces and tabs ending lines
+	SoftWrap               bool `toml:"soft_wrap"`       // wrap long lines at the editor's width
+}
+
+// layoutConfig holds the sizes of the panes. The editor, panel, and terminal
+// heights are weights: they share the height left of the window in that
+// ratio.
+type layoutConfig struct {
+	ExplorerWidth  int `toml:"explorer_width"` // in columns
+	EditorHeight   int `toml:"editor_height"`
+	PanelHeight    int `toml:"panel_height"`
+	TerminalHeight int `toml:"terminal_height"`
+}
+
+// lintConfig configures golangci-lint
+type lintConfig struct {
+	Command string   `toml:"command"` // defaults to golangci-lint
+	Args    []string `toml:"args"`    // replace the arguments printing JSON for ./...
+	OnSave  bool     `toml:"on_save"` // lint the workspace when a Go file is saved
+}
+
+// filetypeConfig overrides the editor settings for the files of a language
+type filetypeConfig struct {
+	TabWidth     int   `toml:"tab_width"` // 0 keeps the editor's
+	InsertSpaces *bool `toml:"insert_spaces"`
+}
+
+// pluginConfig describes an external program extending goui
+type pluginConfig struct {
+	Command string   `toml:"command"`
+	Args    []string `toml:"args"`
+}
+
+// logConfig holds the settings of goui's own log
+type logConfig struct {
+	Level string `toml:"level"` // least severe level logged: debug, info, warn, or error
+	File  string `toml:"file"`  // also append the log to this file, if set
+}
+
+// appConfig is the layout of the config file
+type appConfig struct {
+	Theme    string                  `toml:"theme"` // name of a built-in theme
+	Editor   editorConfig            `toml:"editor"`
+	Layout   layoutConfig            `toml:"layout"`
+	Terminal terminalConfig          `toml:"terminal"`
+	Explorer explorerConfig          `toml:"explorer"`
+	Lint     lintConfig              `toml:"lint"`
+	Log      logConfig               `toml:"log"`
+	Keys     keysFile                `toml:"keys"`    // same layout as the keys file
+	Plugins  map[string]pluginConfig `toml:"plugins"` // by plugin name
+	// Filetypes holds settings by language: the name of the file's syntax
+	// highlighting, such as "go" or "markdown", or else its extension
+	Filetypes map[string]filetypeConfig `toml:"filetypes"`
+}
+
+var config = defaultConfig()
+
+// terminalOverrides are the terminal settings given on the command line,
+// applied again whenever the config file is reloaded
+var terminalOverrides *terminalFlags
+
+// defaultConfig returns the settings used where the config file is silent
+func defaultConfig() appConfig {
+	return appConfig{
+		Theme:  "dark",
+		Editor: editorConfig{TabWidth: 4, AutoIndent: true, LargeFileSize: 32, HighlightOccurrences: true},
+		Layout: layoutConfig{ExplorerWidth: 30, EditorHeight: 2, PanelHeight: 1, TerminalHeight: 1},
+		Log:    logConfig{Level: "info"},
+	}
+}
+
+// projectConfigFile is the name of the per-workspace config file
+const projectConfigFile = ".goui.toml"
+
+// projectConfig is the layout of the workspace's config file
+type projectConfig struct {
+	Tasks    map[string]string    `toml:"tasks"`    // task name to shell command
+	Run      map[string]runConfig `toml:"run"`      // run configurations by name
+	Autosave *bool                `toml:"autosave"` // false turns autosave off in the workspace
+}
+
+// runConfig is a way of running or debugging a main package of the
+// workspace. Relative paths are taken from the workspace.
+type runConfig struct {
+	Program string            `toml:"program"` // the package's directory, the workspace by default
+	Args    []string          `toml:"args"`
+	Env     map[string]string `toml:"env"` // added to the editor's environment
+	Cwd     string            `toml:"cwd"` // the program's working directory, the workspace by default
+}
+
+// loadProjectConfig reads the workspace's config file. A missing file gives
+// an empty config.
+func loadProjectConfig() (projectConfig, error) {
+	var c projectConfig
+	path := filepath.Join(workspaceRoot, projectConfigFile)
+	meta, err := toml.DecodeFile(path, &c)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return projectConfig{}, nil
+		}
+		return projectConfig{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return projectConfig{}, fmt.Errorf("%s: unknown setting %q", path, undecoded[0].String())
+	}
+	return c, nil
+}
+
+// configFilePath returns the location of a file in the goui config directory
+func configFilePath(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "goui", name), nil
+}
+
+// configPath returns the location of the config file
+func configPath() (string, error) {
+	return configFilePath("config.toml")
+}
+
+// loadConfig reads the config file on top of the defaults. A missing file
+// gives the defaults.
+func loadConfig() (appConfig, error) {
+	c := defaultConfig()
+	path, err := configPath()
+	if err != nil {
+		return defaultConfig(), err
+	}
+	meta, err := toml.DecodeFile(path, &c)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return defaultConfig(), nil
+		}
+		return defaultConfig(), fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		// Bindings are checked against the actions when they are built
+		for _, key := range undecoded {
+			if len(key) < 2 || key[0] != "keys" || key[1] != "bindings" {
+				return defaultConfig(), fmt.Errorf("%s: unknown setting %q", path, key.String())
+			}
+		}
+	}
+	if err := c.validate(); err != nil {
+		return defaultConfig(), fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// validate checks that every setting is in range
+func (c *appConfig) validate() error {
+	if _, ok := themes[c.Theme]; !ok {
+		return fmt.Errorf("unknown theme %q, expected one of %s", c.Theme, strings.Join(themeNames(), ", "))
+	}
+	if c.Editor.TabWidth < 1 || c.Editor.TabWidth > 16 {
+		return fmt.Errorf("editor tab_width must be between 1 and 16, got %d", c.Editor.TabWidth)
+	}
+	for name, filetype := range c.Filetypes {
+		if filetype.TabWidth < 0 || filetype.TabWidth > 16 {
+			return fmt.Errorf("filetypes.%s tab_width must be between 1 and 16, got %d", name, filetype.TabWidth)
+		}
+	}
+	if c.Editor.Autosave < 0 || c.Editor.Autosave > 3600 {
+		return fmt.Errorf("editor autosave must be between 0 and 3600 seconds, got %d", c.Editor.Autosave)
+	}
+	if c.Editor.LargeFileSize < 1 || c.Editor.LargeFileSize > 4096 {
+		return fmt.Errorf("editor large_file_size must be between 1 and 4096 megabytes, got %d", c.Editor.LargeFileSize)
+	}
+	if c.Layout.ExplorerWidth < 10 || c.Layout.ExplorerWidth > 200 {
+		return fmt.Errorf("layout explorer_width must be between 10 and 200, got %d", c.Layout.ExplorerWidth)
+	}
+	for _, weight := range []struct {
+		name  string
+		value int
+	}{
+		{"editor_height", c.Layout.EditorHeight},
+		{"panel_height", c.Layout.PanelHeight},
+		{"terminal_height", c.Layout.TerminalHeight},
+	} {
+		if weight.value < 1 || weight.value > 1000 {
+			return fmt.Errorf("layout %s must be between 1 and 1000, got %d", weight.name, weight.value)
+		}
+	}
+	for name, plugin := range c.Plugins {
+		if name == "" || strings.ContainsAny(name, ". ") {
+			return fmt.Errorf("plugin name %q must not be empty or contain dots or spaces", name)
+		}
+		if plugin.Command == "" {
+			return fmt.Errorf("plugin %s has no command", name)
+		}
+	}
+	if _, err := parseLogLevel(c.Log.Level); err != nil {
+		return fmt.Errorf("log level: %w", err)
+	}
+	return c.Terminal.validate()
+}
+
+// reloadConfig reads the config file again and applies it to the running
+// editor, keeping the current settings if it is invalid. Terminals that are
+// already open keep their shell, and plugins keep running until goui is
+// restarted.
+func reloadConfig() error {
+	loaded, err := loadConfig()
+	if err == nil && terminalOverrides != nil {
+		err = terminalOverrides.apply(&loaded.Terminal)
+	}
+	if err != nil {
+		return err
+	}
+	previous := config
+	config = loaded
+	if err := reloadKeyBindings(); err != nil {
+		config = previous
+		return err
+	}
+	if config.Theme != previous.Theme {
+		_ = setTheme(config.Theme)
+	}
+	applyConfig()
+	if err := applog.configure(config.Log); err != nil {
+		return err
+	}
+	if err := autosave.loadProjectSetting(); err != nil {
+		return fmt.Errorf("autosave is off: %w", err)
+	}
+	if config.Explorer.ShowHidden != previous.Explorer.ShowHidden && explorerFilter.showHidden != config.Explorer.ShowHidden {
+		toggleHidden()
+	}
+	return nil
+}
+
+// applyConfig applies the editor and layout settings to the UI
+func applyConfig() {
+	ui.editor.SetAutoIndent(config.Editor.AutoIndent).
+		SetAutoClose(config.Editor.AutoClose).
+		SetWordHighlight(config.Editor.HighlightOccurrences).
+		SetInvisibles(config.Editor.ShowInvisibles).
+		SetWrap(config.Editor.SoftWrap)
+	applyIndentSettings()
+	applyLayout()
+}
+
+// validate checks the terminal settings
+func (c *terminalConfig) validate() error {
+	if c.Dir != "" {
+		info, err := os.Stat(expandHome(c.Dir))
+		if err != nil {
+			return fmt.Errorf("invalid terminal directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("terminal directory %s is not a directory", c.Dir)
+		}
+	}
+	for name := range c.Env {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return fmt.Errorf("invalid terminal environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// expandHome replaces a leading "~" with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// envFlag collects repeated -terminal-env NAME=VALUE flags
+type envFlag map[string]string
+
+// String returns the flag value for the usage message
+func (f envFlag) String() string {
+	return ""
+}
+
+// Set adds one NAME=VALUE pair
+func (f envFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", value)
+	}
+	f[name] = val
+	return nil
+}
+
+// terminalFlags holds the command line overrides of the terminal settings
+type terminalFlags struct {
+	shell string
+	dir   string
+	env   envFlag
+}
+
+// registerTerminalFlags defines the terminal command line flags
+func registerTerminalFlags(fs *flag.FlagSet) *terminalFlags {
+	f := &terminalFlags{env: envFlag{}}
+	fs.StringVar(&f.shell, "shell", "", "shell command for terminals, with optional arguments (default $SHELL)")
+	fs.StringVar(&f.dir, "terminal-dir", "", "starting directory of terminals")
+	fs.Var(f.env, "terminal-env", "extra terminal environment variable as NAME=VALUE (repeatable)")
+	return f
+}
+
+// apply overrides the config with the flags that were given
+func (f *terminalFlags) apply(c *terminalConfig) error {
+	if fields := strings.Fields(f.shell); len(fields) > 0 {
+		c.Shell, c.Args = fields[0], fields[1:]
+	}
+	if f.dir != "" {
+		// Resolved now, as opening a workspace changes the current directory
+		c.Dir = expandHome(f.dir)
+		if abs, err := filepath.Abs(c.Dir); err == nil {
+			c.Dir = abs
+		}
+	}
+	if len(f.env) > 0 && c.Env == nil {
+		c.Env = map[string]string{}
+	}
+	for name, value := range f.env {
+		c.Env[name] = value
+	}
+	return c.validate()
+}
+
+// environ returns the environment of a new terminal session: the editor's
+// own environment with TERM describing the emulator, plus the configured
+// variables
+func (c *terminalConfig) environ() []string {
+	env := append(os.Environ(), "TERM=xterm-256color", "COLORTERM=truecolor")
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+c.Env[name])
+	}
+	return env
+}
+
+// command returns the shell command of a new terminal session
+func (c *terminalConfig) command() *exec.Cmd {
+	shell := c.Shell
+	if shell == "" {
+		shell = defaultShell()
+	}
+	cmd := exec.Command(expandHome(shell), c.Args...)
+	cmd.Dir = expandHome(c.Dir)
+	cmd.Env = c.environ()
+	return cmd
+}
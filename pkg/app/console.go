@@ -0,0 +1,130 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// debugConsole is the log of the debugging sessions, with what dlv and the
+// program print, and a line to evaluate expressions in the selected frame
+type debugConsole struct {
+	panel    *tview.Flex
+	view     *tview.TextView
+	input    *tview.InputField
+	history  []string // the expressions evaluated, oldest first
+	recalled int      // index in history of the expression in the input
+}
+
+var console debugConsole
+
+// createConsolePanel creates and returns the Debug Console panel
+func createConsolePanel() *tview.Flex {
+	c := &console
+	c.view = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetMaxLines(5000).
+		ScrollToEnd()
+	c.input = tview.NewInputField().
+		SetLabel("> ").
+		SetFieldBackgroundColor(theme.Background)
+	c.input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp:
+			c.recall(-1)
+		case tcell.KeyDown:
+			c.recall(1)
+		case tcell.KeyPgUp, tcell.KeyPgDn:
+			c.view.InputHandler()(event, nil)
+		default:
+			return event
+		}
+		return nil
+	})
+	c.input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			c.evaluate(c.input.GetText())
+		case tcell.KeyEscape:
+			showPanel("output")
+			ui.app.SetFocus(ui.editor)
+		}
+	})
+	c.panel = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(c.view, 0, 1, false).
+		AddItem(c.input, 1, 0, true)
+	c.panel.SetBorder(true).SetTitle("Debug Console")
+	return c.panel
+}
+
+// toggleConsole shows the Debug Console, or hides it if it is showing
+func toggleConsole() {
+	if name, _ := ui.panels.GetFrontPage(); name == "console" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	showPanel("console")
+	ui.app.SetFocus(console.input)
+}
+
+// recall puts an earlier or later expression of the history in the input
+func (c *debugConsole) recall(delta int) {
+	index := c.recalled + delta
+	if index < 0 || index > len(c.history) {
+		return
+	}
+	c.recalled = index
+	if index == len(c.history) {
+		c.input.SetText("")
+		return
+	}
+	c.input.SetText(c.history[index])
+}
+
+// evaluate evaluates an expression in the frame selected while the program
+// is paused and logs its value
+func (c *debugConsole) evaluate(expression string) {
+	if expression = strings.TrimSpace(expression); expression == "" {
+		return
+	}
+	c.input.SetText("")
+	if len(c.history) == 0 || c.history[len(c.history)-1] != expression {
+		c.history = append(c.history, expression)
+	}
+	c.recalled = len(c.history)
+	c.view.ScrollToEnd()
+	fmt.Fprintf(c.view, "%s> %s[-]\n", colorTag(theme.Accent), tview.Escape(expression))
+	if delve.state != debugPaused {
+		fmt.Fprintf(c.view, "%sThe program is not paused[-]\n", colorTag(theme.Warning))
+		return
+	}
+	session := delve.sessions
+	arguments := map[string]interface{}{"expression": expression, "frameId": delve.frame, "context": "repl"}
+	delve.client.call("evaluate", arguments, func(body json.RawMessage, err error) {
+		ui.app.QueueUpdateDraw(func() {
+			if delve.sessions != session {
+				return
+			}
+			if err != nil {
+				fmt.Fprintf(c.view, "%s%s[-]\n", colorTag(theme.Error), tview.Escape(err.Error()))
+				return
+			}
+			var result struct {
+				Result string `json:"result"`
+				Type   string `json:"type"`
+			}
+			_ = json.Unmarshal(body, &result)
+			if result.Type != "" {
+				fmt.Fprintf(c.view, "%s%s[-] ", colorTag(theme.Muted), tview.Escape(result.Type))
+			}
+			fmt.Fprintf(c.view, "%s\n", tview.Escape(result.Result))
+			// The expression may have set a variable or called a function
+			debugVars.render()
+		})
+	})
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// fileCoverage is how much of a file the tests ran
+type fileCoverage struct {
+	path       string
+	statements int
+	covered    int
+	lines      map[int]bool // whether the statements on a line ran, by line
+}
+
+// coverageReport holds the coverage profile of the last run of the tests
+// with coverage, shown as percentages in the Coverage panel and as marks in
+// the gutter of the files it covers
+type coverageReport struct {
+	tree   *tview.TreeView
+	root   *tview.TreeNode
+	files  map[string]*fileCoverage // by path, as buffers name them
+	hidden bool                     // set when the marks are hidden from the gutter
+}
+
+var coverage = coverageReport{files: map[string]*fileCoverage{}}
+
+// createCoveragePanel creates and returns the Coverage panel: the packages
+// of the last run with coverage and their files
+func createCoveragePanel() *tview.TreeView {
+	c := &coverage
+	c.root = tview.NewTreeNode("Coverage: run the tests with coverage").SetColor(theme.Muted)
+	c.tree = tview.NewTreeView().
+		SetRoot(c.root).
+		SetCurrentNode(c.root)
+	c.tree.SetBorder(true).SetTitle("Coverage")
+	c.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		if file, ok := node.GetReference().(*fileCoverage); ok {
+			tests.open(file.path, 0)
+			return
+		}
+		node.SetExpanded(!node.IsExpanded())
+	})
+	c.tree.SetDoneFunc(func(key tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return c.tree
+}
+
+// toggleCoverage shows the Coverage panel, or hides it if it is showing
+func toggleCoverage() {
+	if name, _ := ui.panels.GetFrontPage(); name == "coverage" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	showPanel("coverage")
+	ui.app.SetFocus(coverage.tree)
+}
+
+// load reads a coverage profile written by go test, finding the files of
+// the packages in their directories, and shows it
+func (c *coverageReport) load(profile string, packages map[string]*testPackage) error {
+	f, err := os.Open(profile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	files := map[string]*fileCoverage{}
+	byName := map[string]*fileCoverage{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// A block is name.go:line.column,line.column statements count
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") || line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		colon := strings.LastIndex(line, ":")
+		if len(fields) != 3 || colon < 0 {
+			continue
+		}
+		name := line[:colon]
+		var startLine, startCol, endLine, endCol int
+		if _, err := fmt.Sscanf(line[colon+1:], "%d.%d,%d.%d", &startLine, &startCol, &endLine, &endCol); err != nil {
+			continue
+		}
+		statements, _ := strconv.Atoi(fields[1])
+		count, _ := strconv.Atoi(fields[2])
+		file := byName[name]
+		if file == nil {
+			pkg := packages[path.Dir(name)]
+			if pkg == nil || pkg.dir == "" {
+				continue
+			}
+			file = &fileCoverage{path: workspacePath(filepath.Join(pkg.dir, path.Base(name))), lines: map[int]bool{}}
+			byName[name] = file
+			files[file.path] = file
+		}
+		file.statements += statements
+		if count > 0 {
+			file.covered += statements
+		}
+		if endCol <= 1 && endLine > startLine {
+			// The block ends before the first character of its last line
+			endLine--
+		}
+		for n := startLine - 1; n < endLine; n++ {
+			// A line shared by blocks counts as run if any of them ran
+			file.lines[n] = file.lines[n] || count > 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	c.files, c.hidden = files, false
+	c.render()
+	c.decorate()
+	return nil
+}
+
+// render fills the tree with the coverage of every package and its files
+func (c *coverageReport) render() {
+	c.root.ClearChildren()
+	byDir := map[string][]*fileCoverage{}
+	for _, file := range c.files {
+		dir := filepath.Dir(file.path)
+		byDir[dir] = append(byDir[dir], file)
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	total, covered := 0, 0
+	for _, dir := range dirs {
+		files := byDir[dir]
+		sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+		statements, ran := 0, 0
+		node := tview.NewTreeNode("")
+		for _, file := range files {
+			statements += file.statements
+			ran += file.covered
+			node.AddChild(tview.NewTreeNode(coverageText(file.covered, file.statements, filepath.Base(file.path))).
+				SetColor(coverageColor(file.covered, file.statements)).
+				SetReference(file))
+		}
+		total += statements
+		covered += ran
+		node.SetText(coverageText(ran, statements, relativePath(dir))).
+			SetColor(coverageColor(ran, statements)).
+			SetExpanded(len(dirs) == 1)
+		c.root.AddChild(node)
+	}
+	c.root.SetText(fmt.Sprintf("Coverage: %s of statements", coveragePercent(covered, total))).
+		SetColor(coverageColor(covered, total))
+	c.tree.SetCurrentNode(c.root)
+}
+
+// coveragePercent formats the share of statements covered
+func coveragePercent(covered, statements int) string {
+	if statements == 0 {
+		return "no statements"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(covered)/float64(statements))
+}
+
+// coverageText returns the label of a package or file in the tree
+func coverageText(covered, statements int, name string) string {
+	return fmt.Sprintf("%6s  %s", coveragePercent(covered, statements), name)
+}
+
+// coverageColor returns the color of a share of statements covered: green
+// from 80%, yellow from 50%, and red below
+func coverageColor(covered, statements int) tcell.Color {
+	switch {
+	case statements == 0:
+		return theme.Muted
+	case covered*100 >= statements*80:
+		return theme.Success
+	case covered*100 >= statements*50:
+		return theme.Warning
+	}
+	return theme.Error
+}
+
+// toggleMarks hides the coverage marks from the gutter, or shows them again
+func (c *coverageReport) toggleMarks() {
+	if len(c.files) == 0 {
+		ui.output.message("No coverage to show; run the tests with coverage first")
+		return
+	}
+	c.hidden = !c.hidden
+	c.decorate()
+}
+
+// decorate marks the lines of the active buffer whose statements ran in
+// green and the others in red
+func (c *coverageReport) decorate() {
+	if ui.editor == nil {
+		return
+	}
+	buf := buffers.current()
+	if buf == nil || c.hidden || c.files[buf.Path()] == nil {
+		ui.editor.SetGutterMarks("coverage", nil)
+		return
+	}
+	marks := map[int]editor.GutterMark{}
+	for line, ran := range c.files[buf.Path()].lines {
+		mark := editor.GutterMark{Rune: '▌', Color: theme.Error}
+		if ran {
+			mark.Color = theme.Success
+		}
+		marks[line] = mark
+	}
+	ui.editor.SetGutterMarks("coverage", marks)
+}
+
+// shiftCoverage moves the coverage marks of the file at path along with
+// their lines across a replacement of [from, to) with text ending at end
+func shiftCoverage(path string, from, to, end editor.Position) {
+	file := coverage.files[path]
+	if file == nil || from.Line == to.Line && to.Line == end.Line {
+		return
+	}
+	lines := map[int]bool{}
+	for line, ran := range file.lines {
+		if line > from.Line {
+			line = editor.ShiftPosition(editor.Position{Line: line}, from, to, end).Line
+		}
+		lines[line] = lines[line] || ran
+	}
+	file.lines = lines
+	coverage.decorate()
+}
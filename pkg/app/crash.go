@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// crashing is set once a panic is being handled, so a second one, or the
+// main goroutine returning from Run because the screen was stopped, waits
+// for the first to exit instead of exiting cleanly and deleting the
+// recovery copies
+var crashing int32
+
+// recoverPanic handles a panic of the goroutine it is deferred in. Every
+// goroutine defers it first, so a panic anywhere restores the terminal
+// rather than leaving it in raw mode with a garbled screen.
+func recoverPanic() {
+	if p := recover(); p != nil {
+		crash(p, debug.Stack())
+	}
+}
+
+// waitIfCrashing blocks forever if a panic is being handled, leaving the
+// exit to crash
+func waitIfCrashing() {
+	if atomic.LoadInt32(&crashing) != 0 {
+		select {}
+	}
+}
+
+// crash restores the terminal, writes recovery copies of the buffers with
+// unsaved changes, writes a crash report, and exits after telling on stderr
+// what happened
+func crash(p interface{}, stack []byte) {
+	if !atomic.CompareAndSwapInt32(&crashing, 0, 1) {
+		select {}
+	}
+	// Stopping is safe even if tview already finalized the screen
+	ui.app.Stop()
+
+	saved, failed := saveRecoveryCopies()
+	applog.log(logError, "Panic", "value", p)
+	applog.release()
+	report, err := writeCrashReport(p, stack)
+
+	fmt.Fprintf(os.Stderr, "goui crashed: %v\n\n", p)
+	if saved > 0 {
+		fmt.Fprintf(os.Stderr, "Unsaved changes of %s were kept and will be offered back at the next start.\n", plural(saved, "file"))
+	}
+	for _, failure := range failed {
+		fmt.Fprintf(os.Stderr, "Error keeping unsaved changes: %s\n", failure)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing crash report: %s\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "A crash report was written to %s\n", report)
+	}
+	fmt.Fprintf(os.Stderr, "\n%s", stack)
+	os.Exit(2)
+}
+
+// saveRecoveryCopies writes the recovery copies of the buffers with unsaved
+// changes, returning how many were written and why the others were not
+func saveRecoveryCopies() (int, []string) {
+	var saved int
+	var failed []string
+	if recovery.dir == "" {
+		dir, err := swapDir()
+		if err == nil {
+			err = os.MkdirAll(dir, 0700)
+		}
+		if err != nil {
+			return 0, []string{err.Error()}
+		}
+		recovery.dir = dir
+	}
+	for _, buf := range buffers.buffers {
+		if !buf.dirty || buf.Path() == "" {
+			continue
+		}
+		if err := recovery.write(buf); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", buf.Path(), err))
+			continue
+		}
+		saved++
+	}
+	return saved, failed
+}
+
+// writeCrashReport writes what is known of a panic to a file in the cache
+// directory and returns its path
+func writeCrashReport(p interface{}, stack []byte) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	dir = filepath.Join(dir, "goui")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".log")
+	var b strings.Builder
+	fmt.Fprintf(&b, "goui crashed at %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Workspace: %s\n", workspaceRoot)
+	fmt.Fprintf(&b, "Panic: %v\n\n%s", p, stack)
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
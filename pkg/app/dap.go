@@ -0,0 +1,269 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dapStartTimeout bounds the wait for the debug adapter to start listening
+const dapStartTimeout = 10 * time.Second
+
+// dapShutdownTimeout bounds the wait for the debug adapter to end a session
+const dapShutdownTimeout = 2 * time.Second
+
+// dapMessage is a Debug Adapter Protocol request, response, or event
+type dapMessage struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  interface{}     `json:"arguments,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    bool            `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// dapClient speaks the Debug Adapter Protocol with dlv dap over the TCP
+// connection it listens on
+type dapClient struct {
+	cmd   *exec.Cmd
+	conn  net.Conn
+	queue chan []byte
+
+	mu      sync.Mutex
+	nextSeq int
+	pending map[int]func(json.RawMessage, error)
+
+	// handle is called from the reader goroutine for every event, and
+	// output for every line dlv itself prints
+	handle func(event string, body json.RawMessage)
+	done   chan struct{}
+}
+
+// startDAPClient launches dlv dap in dir and connects to it once it says
+// where it listens
+func startDAPClient(dir string, handle func(string, json.RawMessage), output func(string)) (*dapClient, error) {
+	cmd := exec.Command("dlv", "dap", "--listen=127.0.0.1:0")
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start dlv: %w", err)
+	}
+	go func() {
+		defer recoverPanic()
+		_ = cmd.Wait()
+		writer.Close()
+	}()
+
+	listening := make(chan string, 1)
+	go func() {
+		defer recoverPanic()
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if address := strings.TrimPrefix(line, "DAP server listening at: "); address != line {
+				listening <- strings.TrimSpace(address)
+				continue
+			}
+			output(line)
+		}
+		close(listening)
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+	var address string
+	select {
+	case address = <-listening:
+	case <-time.After(dapStartTimeout):
+	}
+	if address == "" {
+		killProcessGroup(cmd)
+		return nil, fmt.Errorf("dlv did not start listening")
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		killProcessGroup(cmd)
+		return nil, fmt.Errorf("failed to connect to dlv: %w", err)
+	}
+
+	c := &dapClient{
+		cmd:     cmd,
+		conn:    conn,
+		queue:   make(chan []byte, 256),
+		pending: make(map[int]func(json.RawMessage, error)),
+		handle:  handle,
+		done:    make(chan struct{}),
+	}
+	go c.writeLoop()
+	go c.readLoop(bufio.NewReader(conn))
+	return c, nil
+}
+
+// writeLoop sends queued messages in order so callers never block on the
+// connection
+func (c *dapClient) writeLoop() {
+	defer recoverPanic()
+	for {
+		select {
+		case body := <-c.queue:
+			header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(body))
+			if _, err := io.WriteString(c.conn, header); err != nil {
+				return
+			}
+			if _, err := c.conn.Write(body); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop decodes incoming messages until the connection closes
+func (c *dapClient) readLoop(r *bufio.Reader) {
+	defer recoverPanic()
+	defer close(c.done)
+	headers := textproto.NewReader(r)
+	for {
+		mime, err := headers.ReadMIMEHeader()
+		if err != nil {
+			c.failPending(fmt.Errorf("debugger exited: %w", err))
+			return
+		}
+		length, err := strconv.Atoi(mime.Get("Content-Length"))
+		if err != nil {
+			c.failPending(fmt.Errorf("invalid message header: %w", err))
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			c.failPending(fmt.Errorf("failed to read message: %w", err))
+			return
+		}
+		var msg dapMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+		c.dispatch(&msg)
+	}
+}
+
+// dispatch routes a decoded message to its response handler or to handle
+func (c *dapClient) dispatch(msg *dapMessage) {
+	switch msg.Type {
+	case "event":
+		if c.handle != nil {
+			c.handle(msg.Event, msg.Body)
+		}
+	case "request":
+		// goui implements no reverse request, such as runInTerminal
+		c.send(dapMessage{Type: "response", RequestSeq: msg.Seq, Command: msg.Command, Message: "not supported"})
+	case "response":
+		c.mu.Lock()
+		handler := c.pending[msg.RequestSeq]
+		delete(c.pending, msg.RequestSeq)
+		c.mu.Unlock()
+		if handler == nil {
+			return
+		}
+		if !msg.Success {
+			message := msg.Message
+			var body struct {
+				Error *struct {
+					Format string `json:"format"`
+				} `json:"error"`
+			}
+			if json.Unmarshal(msg.Body, &body) == nil && body.Error != nil && body.Error.Format != "" {
+				message = body.Error.Format
+			}
+			handler(nil, fmt.Errorf("%s", message))
+			return
+		}
+		handler(msg.Body, nil)
+	}
+}
+
+// failPending reports err to every request still waiting for a response
+func (c *dapClient) failPending(err error) {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[int]func(json.RawMessage, error))
+	c.mu.Unlock()
+	for _, handler := range pending {
+		handler(nil, err)
+	}
+}
+
+// send numbers, encodes, and queues a message
+func (c *dapClient) send(msg dapMessage) {
+	c.mu.Lock()
+	c.nextSeq++
+	msg.Seq = c.nextSeq
+	c.mu.Unlock()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case <-c.done:
+	case c.queue <- body:
+	}
+}
+
+// call sends a request; handler runs on the reader goroutine with the body
+// of the response
+func (c *dapClient) call(command string, arguments interface{}, handler func(json.RawMessage, error)) {
+	c.mu.Lock()
+	c.nextSeq++
+	seq := c.nextSeq
+	c.pending[seq] = handler
+	c.mu.Unlock()
+	body, err := json.Marshal(dapMessage{Seq: seq, Type: "request", Command: command, Arguments: arguments})
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		handler(nil, err)
+		return
+	}
+	select {
+	case <-c.done:
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+		handler(nil, fmt.Errorf("debugger exited"))
+	case c.queue <- body:
+	}
+}
+
+// close ends the session, stopping the program being debugged, and waits
+// for dlv to exit, killing it if it does not in time
+func (c *dapClient) close() {
+	defer recoverPanic()
+	acknowledged := make(chan struct{})
+	c.call("disconnect", map[string]bool{"terminateDebuggee": true}, func(json.RawMessage, error) {
+		close(acknowledged)
+	})
+	select {
+	case <-acknowledged:
+	case <-c.done:
+	case <-time.After(dapShutdownTimeout):
+	}
+	c.conn.Close()
+	select {
+	case <-c.done:
+	case <-time.After(dapShutdownTimeout):
+	}
+	killProcessGroup(c.cmd)
+}
@@ -0,0 +1,449 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// States of a debugging session
+const (
+	debugIdle = iota
+	debugStarting
+	debugRunning
+	debugPaused
+)
+
+// breakpoint is a line the program stops at when it is debugged
+type breakpoint struct {
+	line     int
+	verified bool // set once the debugger placed it on code
+}
+
+// debugger runs the workspace's main package under dlv and shows where it
+// is paused. Breakpoints stay from one debugging session to the next.
+type debugger struct {
+	client      *dapClient
+	state       int
+	sessions    int                     // number of sessions started, to drop events of ended ones
+	thread      int                     // the thread the program last stopped on
+	frame       int                     // id of the stack frame paused at
+	paused      jumpLocation            // where the program is paused, while it is
+	breakpoints map[string][]breakpoint // by path, sorted by line
+}
+
+var delve = debugger{breakpoints: map[string][]breakpoint{}}
+
+// dapStackFrame is a stack frame of a stackTrace response
+type dapStackFrame struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Source *struct {
+		Path string `json:"path"`
+	} `json:"source"`
+}
+
+// log adds a line about the session to the Debug Console
+func (d *debugger) log(color tcell.Color, text string) {
+	fmt.Fprintf(console.view, "%s%s[-]\n", colorTag(color), tview.Escape(text))
+}
+
+// notice tells why a command did nothing, in the session's log while there
+// is a session
+func (d *debugger) notice(text string) {
+	if d.client == nil {
+		ui.output.message(text)
+		return
+	}
+	d.log(theme.Warning, text)
+}
+
+// start debugs the workspace's main package, or continues the program when
+// it is paused
+func (d *debugger) start() {
+	if d.state == debugPaused {
+		d.resume("continue")
+		return
+	}
+	d.debug(runConfig{})
+}
+
+// debug starts debugging the program of a run configuration
+func (d *debugger) debug(config runConfig) {
+	if d.state != debugIdle {
+		d.notice("The program is already being debugged")
+		return
+	}
+	if _, err := exec.LookPath("dlv"); err != nil {
+		notify(noticeError, "Error starting debugger: dlv not found; install it with go install github.com/go-delve/delve/cmd/dlv@latest")
+		return
+	}
+	launch, err := config.launchArguments()
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error starting debugger: %s", err))
+		return
+	}
+	d.sessions++
+	session := d.sessions
+	d.state = debugStarting
+	showPanel("console")
+	console.view.Clear()
+	fmt.Fprintf(console.view, "%s$ dlv dap[-]\n", colorTag(theme.Accent))
+
+	handle := func(event string, body json.RawMessage) {
+		ui.app.QueueUpdateDraw(func() {
+			if d.sessions == session {
+				d.event(event, body)
+			}
+		})
+	}
+	output := func(line string) {
+		ui.app.QueueUpdateDraw(func() {
+			if d.sessions == session {
+				fmt.Fprintf(console.view, "%s\n", tview.Escape(line))
+			}
+		})
+	}
+	go func() {
+		defer recoverPanic()
+		client, err := startDAPClient(workspaceRoot, handle, output)
+		ui.app.QueueUpdateDraw(func() {
+			if d.sessions != session {
+				if client != nil {
+					go client.close()
+				}
+				return
+			}
+			if err != nil {
+				d.state = debugIdle
+				d.log(theme.Error, fmt.Sprintf("Error starting debugger: %s", err))
+				return
+			}
+			d.client = client
+			go func() {
+				defer recoverPanic()
+				<-client.done
+				ui.app.QueueUpdateDraw(func() {
+					if d.client == client {
+						d.end()
+					}
+				})
+			}()
+			d.launch(launch)
+		})
+	}()
+}
+
+// request sends a request of the session and runs handler on the event
+// loop with the body of the response, provided the session is still on.
+// Failures are logged.
+func (d *debugger) request(command string, arguments interface{}, handler func(body json.RawMessage)) {
+	if d.client == nil {
+		return
+	}
+	session := d.sessions
+	d.client.call(command, arguments, func(body json.RawMessage, err error) {
+		ui.app.QueueUpdateDraw(func() {
+			if d.sessions != session {
+				return
+			}
+			if err != nil {
+				d.log(theme.Error, fmt.Sprintf("Error debugging: %s: %s", command, err))
+				return
+			}
+			if handler != nil {
+				handler(body)
+			}
+		})
+	})
+}
+
+// launch has dlv build and start the program as the launch arguments say;
+// the breakpoints are set once dlv reports it is initialized
+func (d *debugger) launch(launch map[string]interface{}) {
+	d.request("initialize", map[string]interface{}{
+		"clientID":        "goui",
+		"clientName":      "goui",
+		"adapterID":       "go",
+		"linesStartAt1":   true,
+		"columnsStartAt1": true,
+		"pathFormat":      "path",
+	}, func(json.RawMessage) {
+		d.request("launch", launch, nil)
+	})
+}
+
+// event handles an event of the session
+func (d *debugger) event(event string, body json.RawMessage) {
+	switch event {
+	case "initialized":
+		for path := range d.breakpoints {
+			d.sendBreakpoints(path)
+		}
+		d.request("configurationDone", nil, func(json.RawMessage) {
+			d.state = debugRunning
+		})
+	case "stopped":
+		var stopped struct {
+			Reason   string `json:"reason"`
+			ThreadID int    `json:"threadId"`
+		}
+		_ = json.Unmarshal(body, &stopped)
+		d.state = debugPaused
+		if stopped.ThreadID != 0 {
+			d.thread = stopped.ThreadID
+		}
+		d.showPaused(stopped.Reason)
+	case "continued":
+		d.running()
+	case "output":
+		var output struct {
+			Category string `json:"category"`
+			Output   string `json:"output"`
+		}
+		if json.Unmarshal(body, &output) == nil && output.Category != "telemetry" {
+			fmt.Fprint(console.view, tview.Escape(output.Output))
+		}
+	case "exited":
+		var exited struct {
+			ExitCode int `json:"exitCode"`
+		}
+		_ = json.Unmarshal(body, &exited)
+		d.log(theme.Muted, fmt.Sprintf("Program exited with code %d", exited.ExitCode))
+	case "terminated":
+		d.end()
+	}
+}
+
+// showPaused finds where the program stopped, opens the file there, and
+// marks the line
+func (d *debugger) showPaused(reason string) {
+	d.request("stackTrace", map[string]int{"threadId": d.thread, "startFrame": 0, "levels": stackDepth}, func(body json.RawMessage) {
+		var trace struct {
+			StackFrames []dapStackFrame `json:"stackFrames"`
+		}
+		if err := json.Unmarshal(body, &trace); err != nil || len(trace.StackFrames) == 0 {
+			d.log(theme.Warning, fmt.Sprintf("Paused (%s)", reason))
+			return
+		}
+		callStack.paused(trace.StackFrames)
+		top := trace.StackFrames[0]
+		if top.Source == nil || top.Source.Path == "" || top.Line < 1 {
+			d.log(theme.Warning, fmt.Sprintf("Paused (%s) in %s, which has no source", reason, top.Name))
+		} else {
+			d.log(theme.Warning, fmt.Sprintf("Paused (%s) in %s at %s:%d", reason, top.Name, relativePath(workspacePath(top.Source.Path)), top.Line))
+		}
+		if d.showFrame(top) {
+			ui.app.SetFocus(ui.editor)
+		}
+	})
+}
+
+// showFrame makes a stack frame the one variables are read in, and opens
+// its file at its line if it has one, which it reports
+func (d *debugger) showFrame(frame dapStackFrame) bool {
+	d.frame = frame.ID
+	d.paused = jumpLocation{}
+	debugVars.render()
+	if frame.Source == nil || frame.Source.Path == "" || frame.Line < 1 {
+		d.decorate()
+		return false
+	}
+	path := workspacePath(frame.Source.Path)
+	pos := editor.Position{Line: frame.Line - 1}
+	d.paused = jumpLocation{path: path, pos: pos}
+	// Opened without loadFile, which would replace the session's log
+	if err := buffers.open(path); err != nil {
+		d.log(theme.Error, fmt.Sprintf("Error loading file: %s", err))
+		return false
+	}
+	showPane(editorPane)
+	ui.editor.SetCursor(pos)
+	d.decorate()
+	return true
+}
+
+// running forgets where the program was paused
+func (d *debugger) running() {
+	d.state = debugRunning
+	d.paused = jumpLocation{}
+	d.decorate()
+	debugVars.render()
+	callStack.clear()
+}
+
+// resume runs a command that lets the paused program go on: continue,
+// next, stepIn, or stepOut
+func (d *debugger) resume(command string) {
+	if d.state != debugPaused {
+		d.notice("The program is not paused")
+		return
+	}
+	d.request(command, map[string]int{"threadId": d.thread}, nil)
+	d.running()
+}
+
+// pause stops the running program where it is
+func (d *debugger) pause() {
+	if d.state != debugRunning {
+		d.notice("The program is not running")
+		return
+	}
+	d.request("pause", map[string]int{"threadId": d.thread}, nil)
+}
+
+// stop ends the session, stopping the program
+func (d *debugger) stop() {
+	if d.client == nil {
+		ui.output.message("Not debugging")
+		return
+	}
+	d.end()
+}
+
+// end closes the session; dlv is stopped in the background
+func (d *debugger) end() {
+	client := d.client
+	d.client = nil
+	d.sessions++
+	d.state = debugIdle
+	d.paused = jumpLocation{}
+	for path, lines := range d.breakpoints {
+		for i := range lines {
+			lines[i].verified = false
+		}
+		d.breakpoints[path] = lines
+	}
+	d.decorate()
+	debugVars.render()
+	callStack.clear()
+	d.log(theme.Muted, "Debugging ended")
+	if client != nil {
+		go client.close()
+	}
+}
+
+// shutdown stops dlv and the program when the editor quits
+func (d *debugger) shutdown() {
+	if d.client != nil {
+		d.client.close()
+		d.client = nil
+	}
+}
+
+// toggleBreakpoint sets or clears the breakpoint on a line of the active
+// buffer
+func (d *debugger) toggleBreakpoint(line int) error {
+	buf := buffers.current()
+	if buf == nil || buf.Path() == "" {
+		return fmt.Errorf("no file loaded")
+	}
+	lines := d.breakpoints[buf.Path()]
+	i := sort.Search(len(lines), func(i int) bool { return lines[i].line >= line })
+	if i < len(lines) && lines[i].line == line {
+		lines = append(lines[:i], lines[i+1:]...)
+	} else {
+		lines = append(lines, breakpoint{})
+		copy(lines[i+1:], lines[i:])
+		lines[i] = breakpoint{line: line}
+	}
+	if len(lines) == 0 {
+		delete(d.breakpoints, buf.Path())
+	} else {
+		d.breakpoints[buf.Path()] = lines
+	}
+	d.decorate()
+	d.sendBreakpoints(buf.Path())
+	return nil
+}
+
+// sendBreakpoints tells the debugger the breakpoints of a file, which
+// replace the ones it had there, and notes which of them it placed
+func (d *debugger) sendBreakpoints(path string) {
+	if d.client == nil {
+		return
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	lines := d.breakpoints[path]
+	wanted := make([]map[string]int, len(lines))
+	for i, b := range lines {
+		wanted[i] = map[string]int{"line": b.line + 1}
+	}
+	source := map[string]string{"path": abs, "name": filepath.Base(abs)}
+	d.request("setBreakpoints", map[string]interface{}{"source": source, "breakpoints": wanted}, func(body json.RawMessage) {
+		var placed struct {
+			Breakpoints []struct {
+				Verified bool `json:"verified"`
+				Line     int  `json:"line"`
+			} `json:"breakpoints"`
+		}
+		current := d.breakpoints[path]
+		if json.Unmarshal(body, &placed) != nil || len(placed.Breakpoints) != len(current) {
+			return
+		}
+		for i, b := range placed.Breakpoints {
+			current[i].verified = b.Verified
+		}
+		d.decorate()
+	})
+}
+
+// decorate marks the breakpoints of the active buffer in the gutter, and
+// the line the program is paused at
+func (d *debugger) decorate() {
+	if ui.editor == nil {
+		return
+	}
+	buf := buffers.current()
+	breakpoints, execution := map[int]editor.GutterMark{}, map[int]editor.GutterMark{}
+	var ranges []editor.Range
+	if buf != nil {
+		for _, b := range d.breakpoints[buf.Path()] {
+			mark := editor.GutterMark{Rune: '●', Color: theme.Error}
+			if d.client != nil && !b.verified {
+				mark = editor.GutterMark{Rune: '○', Color: theme.Muted}
+			}
+			breakpoints[b.line] = mark
+		}
+		if line := d.paused.pos.Line; d.paused.path != "" && d.paused.path == buf.Path() && line < len(buf.Lines()) {
+			execution[line] = editor.GutterMark{Rune: '▶', Color: theme.Warning}
+			ranges = []editor.Range{{From: editor.Position{Line: line}, To: editor.Position{Line: line, Col: len(buf.Lines()[line])}}}
+		}
+	}
+	ui.editor.SetGutterMarks("breakpoints", breakpoints).SetGutterMarks("execution", execution)
+	ui.editor.SetDecorations("execution", ranges, func(style tcell.Style) tcell.Style {
+		return style.Background(theme.ExecutionLine)
+	})
+}
+
+// shiftBreakpoints moves the breakpoints of the file at path along with
+// their lines across a replacement of [from, to) with text ending at end
+func shiftBreakpoints(path string, from, to, end editor.Position) {
+	lines := delve.breakpoints[path]
+	if path == "" || len(lines) == 0 || from.Line == to.Line && to.Line == end.Line {
+		return
+	}
+	kept := lines[:0]
+	for _, b := range lines {
+		if b.line > from.Line {
+			b.line = editor.ShiftPosition(editor.Position{Line: b.line}, from, to, end).Line
+		}
+		if len(kept) == 0 || kept[len(kept)-1].line != b.line {
+			kept = append(kept, b)
+		}
+	}
+	delve.breakpoints[path] = kept
+	delve.decorate()
+}
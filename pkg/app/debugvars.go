@@ -0,0 +1,300 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// dapVariable is a variable of a variables response, or the result of an
+// evaluate request
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// variableNode is the reference of a node in the variable trees
+type variableNode struct {
+	path   string // names from the root, to keep it expanded across stops
+	ref    int    // the debugger's reference to the children, 0 for none
+	loaded bool
+}
+
+// variablesPanel shows the variables of the frame the program is paused in
+// and the values of watch expressions, both as trees read from the debugger
+// as they are expanded
+type variablesPanel struct {
+	panel     *tview.Flex
+	variables *tview.TreeView
+	watch     *tview.TreeView
+	watches   []string        // the watch expressions, kept across sessions
+	expanded  map[string]bool // paths of the expanded nodes, kept across stops
+	stops     int             // number of refreshes, to drop replies for earlier stops
+}
+
+var debugVars = variablesPanel{expanded: map[string]bool{}}
+
+// createVariablesPanel creates and returns the Variables panel: the
+// variables of the paused frame and the watch expressions side by side
+func createVariablesPanel() *tview.Flex {
+	v := &debugVars
+	v.variables = v.newTree("Variables")
+	v.watch = v.newTree("Watch")
+	v.watch.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyRune && event.Rune() == 'a', event.Key() == tcell.KeyInsert:
+			promptWatch()
+		case event.Key() == tcell.KeyRune && event.Rune() == 'd', event.Key() == tcell.KeyDelete:
+			v.removeWatch(v.watch.GetCurrentNode())
+		case event.Key() == tcell.KeyTab:
+			ui.app.SetFocus(v.variables)
+		default:
+			return event
+		}
+		return nil
+	})
+	v.variables.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab {
+			ui.app.SetFocus(v.watch)
+			return nil
+		}
+		return event
+	})
+	v.panel = tview.NewFlex().
+		AddItem(v.variables, 0, 1, true).
+		AddItem(v.watch, 0, 1, false)
+	v.render()
+	return v.panel
+}
+
+// newTree creates one of the trees of the panel
+func (v *variablesPanel) newTree(title string) *tview.TreeView {
+	root := tview.NewTreeNode(title)
+	tree := tview.NewTreeView().
+		SetRoot(root).
+		SetTopLevel(1)
+	tree.SetBorder(true).SetTitle(title)
+	tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		v.toggle(node)
+	})
+	tree.SetDoneFunc(func(key tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return tree
+}
+
+// toggleVariables shows the Variables panel, or hides it if it is showing
+func toggleVariables() {
+	if name, _ := ui.panels.GetFrontPage(); name == "variables" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	showPanel("variables")
+	ui.app.SetFocus(debugVars.variables)
+}
+
+// render fills both trees again: with what the debugger reports while the
+// program is paused, and with placeholders otherwise
+func (v *variablesPanel) render() {
+	if v.variables == nil {
+		return
+	}
+	v.stops++
+	paused := delve.state == debugPaused
+	root := v.variables.GetRoot().ClearChildren()
+	if paused {
+		v.loadScopes(root)
+	} else {
+		root.AddChild(tview.NewTreeNode("Not paused").SetColor(theme.Muted).SetSelectable(false))
+	}
+	v.variables.SetCurrentNode(nil)
+
+	root = v.watch.GetRoot()
+	current := 0
+	for i, child := range root.GetChildren() {
+		if child == v.watch.GetCurrentNode() {
+			current = i
+		}
+	}
+	root.ClearChildren()
+	for _, expression := range v.watches {
+		node := tview.NewTreeNode(expression).SetColor(theme.Muted).SetReference(&variableNode{path: "watch\x00" + expression})
+		root.AddChild(node)
+		if paused {
+			v.evaluate(node, expression)
+		}
+	}
+	if len(v.watches) == 0 {
+		root.AddChild(tview.NewTreeNode("a adds a watch expression").SetColor(theme.Muted).SetSelectable(false))
+	}
+	if children := root.GetChildren(); current < len(children) {
+		v.watch.SetCurrentNode(children[current])
+	} else {
+		v.watch.SetCurrentNode(children[len(children)-1])
+	}
+}
+
+// loadScopes adds the scopes of the paused frame under root, with the
+// variables of those the debugger can list cheaply
+func (v *variablesPanel) loadScopes(root *tview.TreeNode) {
+	stop := v.stops
+	delve.request("scopes", map[string]int{"frameId": delve.frame}, func(body json.RawMessage) {
+		if v.stops != stop {
+			return
+		}
+		var result struct {
+			Scopes []struct {
+				Name               string `json:"name"`
+				VariablesReference int    `json:"variablesReference"`
+				Expensive          bool   `json:"expensive"`
+			} `json:"scopes"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return
+		}
+		for _, scope := range result.Scopes {
+			path := "scope\x00" + scope.Name
+			node := tview.NewTreeNode(scope.Name).
+				SetColor(theme.Accent).
+				SetReference(&variableNode{path: path, ref: scope.VariablesReference})
+			root.AddChild(node)
+			if _, ok := v.expanded[path]; !ok && !scope.Expensive {
+				v.expanded[path] = true
+			}
+			v.restore(node)
+		}
+		if children := root.GetChildren(); len(children) > 0 && v.variables.GetCurrentNode() == nil {
+			v.variables.SetCurrentNode(children[0])
+		}
+	})
+}
+
+// evaluate shows the value of a watch expression in the paused frame
+func (v *variablesPanel) evaluate(node *tview.TreeNode, expression string) {
+	stop := v.stops
+	arguments := map[string]interface{}{"expression": expression, "frameId": delve.frame, "context": "watch"}
+	delve.client.call("evaluate", arguments, func(body json.RawMessage, err error) {
+		ui.app.QueueUpdateDraw(func() {
+			if v.stops != stop {
+				return
+			}
+			if err != nil {
+				node.SetText(fmt.Sprintf("%s: %s", expression, err)).SetColor(theme.Error)
+				return
+			}
+			var result struct {
+				Result             string `json:"result"`
+				Type               string `json:"type"`
+				VariablesReference int    `json:"variablesReference"`
+			}
+			_ = json.Unmarshal(body, &result)
+			reference := node.GetReference().(*variableNode)
+			reference.ref = result.VariablesReference
+			node.SetText(variableText(dapVariable{Name: expression, Value: result.Result, Type: result.Type})).SetColor(theme.Text)
+			v.restore(node)
+		})
+	})
+}
+
+// restore loads and expands a node that was expanded at the last stop
+func (v *variablesPanel) restore(node *tview.TreeNode) {
+	reference := node.GetReference().(*variableNode)
+	if reference.ref > 0 && v.expanded[reference.path] {
+		v.load(node)
+		node.SetExpanded(true)
+	} else {
+		node.SetExpanded(false)
+	}
+}
+
+// load reads the children of a node from the debugger
+func (v *variablesPanel) load(node *tview.TreeNode) {
+	reference := node.GetReference().(*variableNode)
+	if reference.loaded || reference.ref == 0 || delve.state != debugPaused {
+		return
+	}
+	reference.loaded = true
+	stop := v.stops
+	delve.request("variables", map[string]int{"variablesReference": reference.ref}, func(body json.RawMessage) {
+		if v.stops != stop {
+			return
+		}
+		var result struct {
+			Variables []dapVariable `json:"variables"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return
+		}
+		node.ClearChildren()
+		for _, variable := range result.Variables {
+			child := tview.NewTreeNode(variableText(variable)).
+				SetColor(theme.Text).
+				SetReference(&variableNode{path: reference.path + "\x00" + variable.Name, ref: variable.VariablesReference})
+			node.AddChild(child)
+			v.restore(child)
+		}
+	})
+}
+
+// toggle expands or collapses the children of a node, reading them the
+// first time
+func (v *variablesPanel) toggle(node *tview.TreeNode) {
+	reference, ok := node.GetReference().(*variableNode)
+	if !ok || reference.ref == 0 {
+		return
+	}
+	expand := !node.IsExpanded()
+	if expand {
+		v.load(node)
+	}
+	node.SetExpanded(expand)
+	v.expanded[reference.path] = expand
+}
+
+// variableText returns the label of a variable in the trees
+func variableText(variable dapVariable) string {
+	text := variable.Name
+	if variable.Type != "" {
+		text += " " + variable.Type
+	}
+	value := strings.ReplaceAll(variable.Value, "\n", " ")
+	if variable.VariablesReference > 0 && value == "" {
+		return text
+	}
+	return text + " = " + value
+}
+
+// promptWatch asks for an expression to watch
+func promptWatch() {
+	showPrompt("Watch expression: ", "", func(expression string) {
+		if expression = strings.TrimSpace(expression); expression == "" {
+			return
+		}
+		debugVars.watches = append(debugVars.watches, expression)
+		debugVars.render()
+		showPanel("variables")
+		ui.app.SetFocus(debugVars.watch)
+	})
+}
+
+// removeWatch stops watching the expression of a top-level node of the
+// watch tree
+func (v *variablesPanel) removeWatch(node *tview.TreeNode) {
+	if node == nil {
+		return
+	}
+	for i, child := range v.watch.GetRoot().GetChildren() {
+		if child == node && i < len(v.watches) {
+			v.watches = append(v.watches[:i], v.watches[i+1:]...)
+			v.render()
+			return
+		}
+	}
+}
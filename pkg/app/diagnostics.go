@@ -0,0 +1,253 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Diagnostic severities, numbered as in the Language Server Protocol
+const (
+	SeverityError = iota + 1
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+// Diagnostic is a problem reported for a range of a file
+type Diagnostic struct {
+	Path     string
+	Range    editor.Range
+	Severity int
+	Source   string
+	Message  string
+}
+
+// severityName returns the label of a diagnostic severity
+func severityName(severity int) string {
+	switch severity {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	default:
+		return "hint"
+	}
+}
+
+// diagnosticStore collects the diagnostics reported by every source and
+// renders them in the editor and the Problems panel
+type diagnosticStore struct {
+	bySource map[string]map[string][]Diagnostic // source, then path
+	view     problemsPanel
+}
+
+var problems = diagnosticStore{bySource: make(map[string]map[string][]Diagnostic)}
+
+// set replaces the diagnostics a source reported for one file
+func (d *diagnosticStore) set(source, path string, diagnostics []Diagnostic) {
+	files := d.bySource[source]
+	if files == nil {
+		files = make(map[string][]Diagnostic)
+		d.bySource[source] = files
+	}
+	if len(diagnostics) == 0 {
+		delete(files, path)
+	} else {
+		files[path] = diagnostics
+	}
+	d.refresh()
+}
+
+// replace swaps all diagnostics of a source for a new set
+func (d *diagnosticStore) replace(source string, diagnostics []Diagnostic) {
+	files := make(map[string][]Diagnostic)
+	for _, diagnostic := range diagnostics {
+		files[diagnostic.Path] = append(files[diagnostic.Path], diagnostic)
+	}
+	d.bySource[source] = files
+	d.refresh()
+}
+
+// forPath returns the diagnostics of a file from all sources, in order
+func (d *diagnosticStore) forPath(path string) []Diagnostic {
+	var result []Diagnostic
+	for _, files := range d.bySource {
+		result = append(result, files[path]...)
+	}
+	sortDiagnostics(result)
+	return result
+}
+
+// all returns every diagnostic, ordered by file and position
+func (d *diagnosticStore) all() []Diagnostic {
+	var result []Diagnostic
+	for _, files := range d.bySource {
+		for _, diagnostics := range files {
+			result = append(result, diagnostics...)
+		}
+	}
+	sortDiagnostics(result)
+	return result
+}
+
+// sortDiagnostics orders diagnostics by file and position
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Range.From.Less(b.Range.From)
+	})
+}
+
+// refresh redraws the Problems panel and the markers of the active buffer
+func (d *diagnosticStore) refresh() {
+	if d.view.tree != nil {
+		d.view.render(d.all())
+	}
+	d.decorate()
+}
+
+// decorate underlines the diagnostics of the active buffer and marks their
+// lines in the gutter
+func (d *diagnosticStore) decorate() {
+	if ui.editor == nil {
+		return
+	}
+	var diagnostics []Diagnostic
+	if buf := buffers.current(); buf != nil {
+		diagnostics = d.forPath(buf.Path())
+	}
+	ranges := make(map[int][]editor.Range)
+	worst := make(map[int]int) // most severe diagnostic, by line
+	notes := make(map[int]editor.LineNote)
+	linted := make(map[int]int) // number of lint findings, by line
+	for _, diagnostic := range diagnostics {
+		if line := diagnostic.Range.From.Line; diagnostic.Source == lintSource {
+			// The first finding of a line is shown after it
+			if linted[line] == 0 {
+				notes[line] = editor.LineNote{Text: diagnostic.Message, Color: theme.severityColor(diagnostic.Severity)}
+			}
+			linted[line]++
+		}
+		r := diagnostic.Range
+		if r.To == r.From {
+			// Zero-width diagnostics underline the character they point at
+			r.To = editor.Position{Line: r.From.Line, Col: r.From.Col + 1}
+		}
+		ranges[diagnostic.Severity] = append(ranges[diagnostic.Severity], r)
+		if severity, ok := worst[r.From.Line]; !ok || diagnostic.Severity < severity {
+			worst[r.From.Line] = diagnostic.Severity
+		}
+	}
+	// Layers are drawn in order, so errors are added last to end up on top
+	for severity := SeverityHint; severity >= SeverityError; severity-- {
+		color := theme.severityColor(severity)
+		ui.editor.SetDecorations("diagnostics."+severityName(severity), ranges[severity], func(style tcell.Style) tcell.Style {
+			return style.Underline(true).Foreground(color)
+		})
+	}
+	for line, count := range linted {
+		if count > 1 {
+			note := notes[line]
+			note.Text += fmt.Sprintf(" (+%d more)", count-1)
+			notes[line] = note
+		}
+	}
+	marks := make(map[int]editor.GutterMark, len(worst))
+	for line, severity := range worst {
+		marks[line] = editor.GutterMark{Rune: '●', Color: theme.severityColor(severity)}
+	}
+	ui.editor.SetGutterMarks("diagnostics", marks).SetLineNotes(notes)
+}
+
+// vetLine matches the file:line:col: message lines printed by go vet
+var vetLine = regexp.MustCompile(`^(.+?\.go):(\d+):(?:(\d+):)? (.*)$`)
+
+// runVet runs go vet on the workspace in the background and reports its
+// findings as diagnostics
+func runVet() {
+	go func() {
+		defer recoverPanic()
+		cmd := exec.Command("go", "vet", "./...")
+		cmd.Dir = workspaceRoot
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		_ = cmd.Run()
+
+		var diagnostics []Diagnostic
+		scanner := bufio.NewScanner(&stderr)
+		for scanner.Scan() {
+			diagnostic, ok := parseLocationLine(workspaceRoot, scanner.Text(), "go vet")
+			if ok {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+		ui.app.QueueUpdateDraw(func() {
+			problems.replace("go vet", diagnostics)
+		})
+	}()
+}
+
+// parseLocationLine parses a "file.go:line:col: message" line as printed by
+// the go tool run in dir into a diagnostic
+func parseLocationLine(dir, line, source string) (Diagnostic, bool) {
+	match := vetLine.FindStringSubmatch(line)
+	if match == nil {
+		return Diagnostic{}, false
+	}
+	lineNumber, _ := strconv.Atoi(match[2])
+	col, _ := strconv.Atoi(match[3])
+	return locationDiagnostic(dir, match[1], lineNumber, col, SeverityError, source, match[4]), true
+}
+
+// locationDiagnostic returns the diagnostic of a tool for a location it
+// printed: a file relative to dir, where the tool ran, or absolute, a line, and a byte
+// column, both counted from 1, with 0 for no column
+func locationDiagnostic(dir, file string, line, col, severity int, source, message string) Diagnostic {
+	if col > 0 {
+		col--
+	}
+	path := filepath.Join(dir, file)
+	if filepath.IsAbs(file) {
+		path = uriToPath(pathToURI(file))
+	}
+	pos := editor.Position{Line: line - 1, Col: col}
+	if index := buffers.find(path); index >= 0 && pos.Line < len(buffers.buffers[index].Lines()) {
+		// The go tool reports byte columns
+		pos.Col = byteToRuneColumn(buffers.buffers[index].Lines()[pos.Line], col)
+	}
+	return Diagnostic{
+		Path:     path,
+		Range:    editor.Range{From: pos, To: pos},
+		Severity: severity,
+		Source:   source,
+		Message:  message,
+	}
+}
+
+// byteToRuneColumn converts a UTF-8 byte offset into a rune column
+func byteToRuneColumn(line []rune, offset int) int {
+	n := 0
+	for i, r := range line {
+		if n >= offset {
+			return i
+		}
+		n += utf8.RuneLen(r)
+	}
+	return len(line)
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	pickerMaxHeight = 20  // rows of a picker, borders included
+	dialogMaxWidth  = 100 // columns of a prompt or picker, borders included
+)
+
+// dialog is an open dialog, drawn above the main layout and the dialogs
+// opened before it
+type dialog struct {
+	pages *tview.Pages
+	focus tview.Primitive // focused when the dialog opened
+}
+
+// dialogs are the open dialogs, the topmost last
+var dialogs []dialog
+
+// showDialog shows a primitive above the main layout, or above the dialog
+// that is open, and focuses it. With a zero size the primitive is given the
+// whole screen, as tview.Modal centers itself. Closing the dialog focuses
+// the widget that had the focus before.
+func showDialog(p tview.Primitive, width, height int) {
+	layout := p
+	if width > 0 && height > 0 {
+		layout = tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(p, height, 0, true).
+				AddItem(nil, 0, 1, false), width, 0, true).
+			AddItem(nil, 0, 1, false)
+	}
+	var below tview.Primitive = ui.root
+	if len(dialogs) > 0 {
+		below = dialogs[len(dialogs)-1].pages
+	}
+	pages := tview.NewPages().
+		AddPage("main", below, true, true).
+		AddPage("dialog", layout, true, true)
+	dialogs = append(dialogs, dialog{pages: pages, focus: ui.app.GetFocus()})
+	ui.app.SetRoot(pages, true)
+	ui.app.SetFocus(p)
+}
+
+// closeDialog closes the topmost dialog and gives the focus back to where
+// it was when the dialog opened
+func closeDialog() {
+	if len(dialogs) == 0 {
+		return
+	}
+	top := dialogs[len(dialogs)-1]
+	dialogs = dialogs[:len(dialogs)-1]
+	var root tview.Primitive = ui.root
+	if len(dialogs) > 0 {
+		root = dialogs[len(dialogs)-1].pages
+	}
+	ui.app.SetRoot(root, true)
+	ui.app.SetFocus(top.focus)
+}
+
+// closeDialogs closes every dialog and gives the focus back to where it
+// was when the first one opened
+func closeDialogs() {
+	if len(dialogs) == 0 {
+		return
+	}
+	focus := dialogs[0].focus
+	dialogs = nil
+	ui.app.SetRoot(ui.root, true)
+	ui.app.SetFocus(focus)
+}
+
+// chooseAction asks a question in a modal dialog with a button for each
+// answer and calls chosen with the index of the one pressed. The last button
+// is the default and should cancel; Escape cancels without calling chosen.
+func chooseAction(question string, buttons []string, chosen func(index int)) {
+	modal := tview.NewModal().
+		SetText(question).
+		AddButtons(buttons).
+		SetDoneFunc(func(index int, label string) {
+			closeDialog()
+			if index >= 0 {
+				chosen(index)
+			}
+		})
+	modal.SetFocus(len(buttons) - 1)
+	showDialog(modal, 0, 0)
+}
+
+// confirmAction asks a question in a modal dialog and calls yes if the
+// button confirming it is chosen. Cancel is the default.
+func confirmAction(question, button string, yes func()) {
+	chooseAction(question, []string{button, "Cancel"}, func(index int) {
+		if index == 0 {
+			yes()
+		}
+	})
+}
+
+// showPrompt asks for a line of text, starting from text, and calls done
+// with it when Enter is pressed. Escape cancels.
+func showPrompt(label, text string, done func(text string)) {
+	input := tview.NewInputField().
+		SetLabel(label).
+		SetText(text).
+		SetFieldBackgroundColor(theme.Background)
+	input.SetDoneFunc(func(key tcell.Key) {
+		closeDialog()
+		if key == tcell.KeyEnter {
+			done(input.GetText())
+		}
+	})
+	input.SetBorder(true)
+	field := len([]rune(text)) + 1
+	if field < 30 {
+		field = 30
+	}
+	width := clamp(tview.TaggedStringWidth(tview.Escape(label))+field+2, 40, dialogMaxWidth)
+	showDialog(input, width, 3)
+}
+
+// showPicker opens a list of items, which may hold color tags, starting on
+// the current one. Enter closes it and calls chosen with the index of the
+// selected item; Escape closes it. The list is returned so callers can add
+// keys of their own.
+func showPicker(title string, items []string, current int, chosen func(index int)) *tview.List {
+	list := tview.NewList().ShowSecondaryText(false).SetHighlightFullLine(true)
+	width := tview.TaggedStringWidth(title) + 4
+	for _, item := range items {
+		list.AddItem(item, "", 0, nil)
+		if w := tview.TaggedStringWidth(item) + 4; w > width {
+			width = w
+		}
+	}
+	list.SetCurrentItem(current)
+	list.SetSelectedFunc(func(index int, _, _ string, _ rune) {
+		closeDialog()
+		chosen(index)
+	})
+	list.SetDoneFunc(closeDialog)
+	list.SetBorder(true).SetTitle(title)
+	showDialog(list, clamp(width, 30, dialogMaxWidth), clamp(len(items)+2, 3, pickerMaxHeight))
+	return list
+}
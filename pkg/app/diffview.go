@@ -0,0 +1,535 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// diffRowKind tells what a row of a diff shows
+type diffRowKind int
+
+// Kinds of diff rows
+const (
+	diffFile diffRowKind = iota
+	diffHunk
+	diffContext
+	diffAdded
+	diffRemoved
+	diffNote // text outside of the diff, such as a commit message
+)
+
+// diffRow is one line of a diff
+type diffRow struct {
+	kind    diffRowKind
+	path    string // file the row belongs to, in the new version
+	oldLine int    // line number in the old version, 0 if none
+	newLine int    // line number in the new version, 0 if none
+	text    []rune
+	tokens  []editor.Token
+	patch   string // for hunks, a patch of the file applying just this hunk
+}
+
+// diffPair is one row of the side-by-side layout. Headers have neither side
+// and are drawn across the whole width.
+type diffPair struct {
+	header      *diffRow
+	left, right *diffRow
+}
+
+// DiffView shows a unified diff, either as one column or side by side, with
+// the changed lines syntax colored
+type DiffView struct {
+	*tview.Box
+
+	rows       []diffRow
+	pairs      []diffPair
+	sideBySide bool
+	offset     int // first visible row
+	height     int // rows shown at the last draw
+
+	textStyle   tcell.Style
+	numberStyle tcell.Style
+
+	done   func()
+	opened func(path string, line int)
+}
+
+// NewDiffView returns an empty diff view
+func NewDiffView() *DiffView {
+	d := &DiffView{Box: tview.NewBox()}
+	d.applyTheme(theme)
+	return d
+}
+
+// applyTheme sets the view's styles from a theme
+func (d *DiffView) applyTheme(t *Theme) {
+	d.SetBackgroundColor(t.Background)
+	d.textStyle = tcell.StyleDefault.Background(t.Background).Foreground(t.Text)
+	d.numberStyle = tcell.StyleDefault.Background(t.Background).Foreground(t.Muted)
+}
+
+// SetDiff replaces the shown diff. The view keeps its scroll position, so
+// a diff can be updated in place.
+func (d *DiffView) SetDiff(rows []diffRow) *DiffView {
+	d.rows, d.pairs = rows, pairDiffRows(rows)
+	d.scroll(0)
+	return d
+}
+
+// SetSideBySide switches between the unified and the side-by-side layout,
+// keeping the top row in view
+func (d *DiffView) SetSideBySide(sideBySide bool) *DiffView {
+	if sideBySide == d.sideBySide {
+		return d
+	}
+	top := d.topRow()
+	d.sideBySide = sideBySide
+	d.offset = 0
+	for i := 0; i < d.rowCount(); i++ {
+		if d.rowAt(i) == top {
+			d.offset = i
+			break
+		}
+	}
+	return d
+}
+
+// SetDoneFunc sets the handler called when the view is closed with Escape
+func (d *DiffView) SetDoneFunc(handler func()) *DiffView {
+	d.done = handler
+	return d
+}
+
+// SetOpenedFunc sets the handler called with a file and a zero-based line
+// when Enter is pressed on a row
+func (d *DiffView) SetOpenedFunc(handler func(path string, line int)) *DiffView {
+	d.opened = handler
+	return d
+}
+
+// rowCount returns the number of rows of the current layout
+func (d *DiffView) rowCount() int {
+	if d.sideBySide {
+		return len(d.pairs)
+	}
+	return len(d.rows)
+}
+
+// rowAt returns the diff row shown at index i of the current layout,
+// preferring the new side
+func (d *DiffView) rowAt(i int) *diffRow {
+	if i < 0 || i >= d.rowCount() {
+		return nil
+	}
+	if !d.sideBySide {
+		return &d.rows[i]
+	}
+	pair := d.pairs[i]
+	switch {
+	case pair.header != nil:
+		return pair.header
+	case pair.right != nil:
+		return pair.right
+	}
+	return pair.left
+}
+
+// topRow returns the diff row at the top of the view
+func (d *DiffView) topRow() *diffRow {
+	return d.rowAt(d.offset)
+}
+
+// scroll moves the view by delta rows
+func (d *DiffView) scroll(delta int) {
+	d.offset += delta
+	if last := d.rowCount() - d.height; d.offset > last {
+		d.offset = last
+	}
+	if d.offset < 0 {
+		d.offset = 0
+	}
+}
+
+// hunkAtTop returns the hunk the top row of the view belongs to, or the
+// first hunk of the file whose header is at the top, or nil
+func (d *DiffView) hunkAtTop() *diffRow {
+	if row := d.rowAt(d.offset); row != nil && row.kind == diffFile {
+		if next := d.rowAt(d.offset + 1); next != nil && next.kind == diffHunk {
+			return next
+		}
+	}
+	for i := d.offset; i >= 0; i-- {
+		row := d.rowAt(i)
+		if row == nil || row.kind == diffFile {
+			return nil
+		}
+		if row.kind == diffHunk {
+			return row
+		}
+	}
+	return nil
+}
+
+// nextHunk scrolls the next (or, with a negative delta, previous) hunk to
+// the top of the view
+func (d *DiffView) nextHunk(delta int) {
+	for i := d.offset + delta; i >= 0 && i < d.rowCount(); i += delta {
+		if row := d.rowAt(i); row.kind == diffHunk {
+			d.offset = i
+			return
+		}
+	}
+}
+
+// Draw draws the diff
+func (d *DiffView) Draw(screen tcell.Screen) {
+	d.Box.DrawForSubclass(screen, d)
+	x, y, width, height := d.GetInnerRect()
+	d.height = height
+	d.scroll(0)
+	digits := len(strconv.Itoa(d.maxLine()))
+	for row := 0; row < height; row++ {
+		i := d.offset + row
+		if i >= d.rowCount() {
+			break
+		}
+		if !d.sideBySide {
+			d.drawUnified(screen, &d.rows[i], x, y+row, width, digits)
+			continue
+		}
+		pair := d.pairs[i]
+		if pair.header != nil {
+			d.drawHeader(screen, pair.header, x, y+row, width)
+			continue
+		}
+		half := (width - 1) / 2
+		d.drawSide(screen, pair.left, true, x, y+row, half, digits)
+		screen.SetContent(x+half, y+row, tview.Borders.Vertical, nil, d.numberStyle)
+		d.drawSide(screen, pair.right, false, x+half+1, y+row, width-half-1, digits)
+	}
+}
+
+// maxLine returns the highest line number in the diff
+func (d *DiffView) maxLine() int {
+	n := 0
+	for _, row := range d.rows {
+		if row.oldLine > n {
+			n = row.oldLine
+		}
+		if row.newLine > n {
+			n = row.newLine
+		}
+	}
+	return n
+}
+
+// drawHeader draws a file or hunk header, or a note, across the width
+func (d *DiffView) drawHeader(screen tcell.Screen, row *diffRow, x, y, width int) {
+	style := d.textStyle.Foreground(theme.Secondary)
+	switch row.kind {
+	case diffFile:
+		style = d.textStyle.Foreground(theme.Accent).Bold(true)
+	case diffNote:
+		style = d.textStyle
+	}
+	for i := 0; i < width; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+	printText(screen, string(row.text), x, y, width, style)
+}
+
+// drawUnified draws a row of the unified layout: both line numbers, the
+// sign, and the text
+func (d *DiffView) drawUnified(screen tcell.Screen, row *diffRow, x, y, width, digits int) {
+	if row.kind == diffFile || row.kind == diffHunk || row.kind == diffNote {
+		d.drawHeader(screen, row, x, y, width)
+		return
+	}
+	style, sign := d.rowStyle(row)
+	numbers := lineNumberText(row.oldLine, digits) + " " + lineNumberText(row.newLine, digits) + " "
+	x = printText(screen, numbers, x, y, width, d.numberStyle)
+	width -= len(numbers)
+	d.drawText(screen, row, sign, x, y, width, style)
+}
+
+// drawSide draws the old or the new side of a row of the side-by-side
+// layout. A nil row is drawn as filler where the other side has lines.
+func (d *DiffView) drawSide(screen tcell.Screen, row *diffRow, old bool, x, y, width, digits int) {
+	if row == nil {
+		for i := 0; i < width; i++ {
+			screen.SetContent(x+i, y, ' ', nil, tcell.StyleDefault.Background(theme.DiffFill))
+		}
+		return
+	}
+	line := row.newLine
+	if old {
+		line = row.oldLine
+	}
+	style, sign := d.rowStyle(row)
+	number := lineNumberText(line, digits) + " "
+	x = printText(screen, number, x, y, width, d.numberStyle)
+	d.drawText(screen, row, sign, x, y, width-len(number), style)
+}
+
+// rowStyle returns the background style and sign of a changed or context row
+func (d *DiffView) rowStyle(row *diffRow) (tcell.Style, rune) {
+	switch row.kind {
+	case diffAdded:
+		return tcell.StyleDefault.Background(theme.DiffAdded).Foreground(theme.Text), '+'
+	case diffRemoved:
+		return tcell.StyleDefault.Background(theme.DiffRemoved).Foreground(theme.Text), '-'
+	}
+	return d.textStyle, ' '
+}
+
+// drawText draws the sign and the syntax colored text of a row, filling the
+// rest of the width with the row's background
+func (d *DiffView) drawText(screen tcell.Screen, row *diffRow, sign rune, x, y, width int, style tcell.Style) {
+	if width <= 0 {
+		return
+	}
+	end := x + width
+	screen.SetContent(x, y, sign, nil, style)
+	col, token := x+2, 0
+	for i, r := range row.text {
+		for token < len(row.tokens) && row.tokens[token].End <= i {
+			token++
+		}
+		runeStyle := style
+		if token < len(row.tokens) && row.tokens[token].Start <= i {
+			if color, ok := theme.Tokens[row.tokens[token].Kind]; ok {
+				runeStyle = style.Foreground(color)
+			}
+		}
+		w := runewidth.RuneWidth(r)
+		if r == '\t' {
+			r, w = ' ', 4-(col-x-2)%4
+		}
+		for ; w > 0 && col < end; w-- {
+			screen.SetContent(col, y, r, nil, runeStyle)
+			col++
+			r = ' '
+		}
+	}
+	for ; col < end; col++ {
+		screen.SetContent(col, y, ' ', nil, style)
+	}
+	screen.SetContent(x+1, y, ' ', nil, style)
+}
+
+// lineNumberText formats a line number right-aligned, or blanks for none
+func lineNumberText(n, digits int) string {
+	if n == 0 {
+		return strings.Repeat(" ", digits)
+	}
+	return fmt.Sprintf("%*d", digits, n)
+}
+
+// InputHandler handles scrolling, hunk navigation, and the layout switch
+func (d *DiffView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return d.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		switch event.Key() {
+		case tcell.KeyUp:
+			d.scroll(-1)
+		case tcell.KeyDown:
+			d.scroll(1)
+		case tcell.KeyPgUp:
+			d.scroll(-d.height)
+		case tcell.KeyPgDn:
+			d.scroll(d.height)
+		case tcell.KeyHome:
+			d.offset = 0
+		case tcell.KeyEnd:
+			d.scroll(d.rowCount())
+		case tcell.KeyEnter:
+			if row := d.topRow(); row != nil && row.path != "" && d.opened != nil {
+				line := row.newLine
+				if line == 0 {
+					line = row.oldLine
+				}
+				if line > 0 {
+					line--
+				}
+				d.opened(row.path, line)
+			}
+		case tcell.KeyEscape:
+			if d.done != nil {
+				d.done()
+			}
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case 'j':
+				d.scroll(1)
+			case 'k':
+				d.scroll(-1)
+			case ' ':
+				d.scroll(d.height)
+			case 'n', ']':
+				d.nextHunk(1)
+			case 'p', '[':
+				d.nextHunk(-1)
+			case 's':
+				d.SetSideBySide(!d.sideBySide)
+			case 'q':
+				if d.done != nil {
+					d.done()
+				}
+			}
+		}
+	})
+}
+
+// MouseHandler scrolls the diff with the mouse wheel
+func (d *DiffView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return d.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !d.InRect(event.Position()) {
+			return false, nil
+		}
+		switch action {
+		case tview.MouseLeftClick:
+			setFocus(d)
+		case tview.MouseScrollUp:
+			d.scroll(-3)
+		case tview.MouseScrollDown:
+			d.scroll(3)
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// diffHeaderLine matches the first line of the diff of one file
+var diffHeaderLine = regexp.MustCompile(`^diff --git a/(.*) b/(.*)$`)
+
+// parseDiff parses the output of git diff into rows, syntax coloring the
+// lines of each file with its lexer. Paths are made workspace paths with
+// resolve.
+func parseDiff(out string, resolve func(repoPath string) string) []diffRow {
+	var rows []diffRow
+	var path string
+	var lexer editor.Lexer
+	var oldLine, newLine, oldState, newState int
+	lex := func(text []rune, state *int) []editor.Token {
+		if lexer == nil {
+			return nil
+		}
+		tokens, next := lexer.Lex(text, *state)
+		*state = next
+		return tokens
+	}
+	// The raw lines of the file header and of the current hunk make up the
+	// hunk's patch
+	var header, hunk []string
+	hunkRow := -1
+	endHunk := func() {
+		if hunkRow >= 0 {
+			rows[hunkRow].patch = strings.Join(header, "\n") + "\n" + strings.Join(hunk, "\n") + "\n"
+			hunkRow, hunk = -1, nil
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "diff --git "):
+			endHunk()
+			header = []string{line}
+		case strings.HasPrefix(line, "@@"):
+			endHunk()
+			hunkRow, hunk = len(rows), []string{line}
+		case hunkRow >= 0:
+			hunk = append(hunk, line)
+		default:
+			header = append(header, line)
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			match := diffHeaderLine.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			path = resolve(match[2])
+			oldLine, newLine = 0, 0
+			lexer = editor.LexerForPath(path)
+			title := match[2]
+			if match[1] != match[2] {
+				title = match[1] + " → " + match[2]
+			}
+			rows = append(rows, diffRow{kind: diffFile, path: path, text: []rune(title)})
+		case strings.HasPrefix(line, "@@"):
+			match := diffHunkHeader.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			oldLine, _ = strconv.Atoi(match[1])
+			newLine, _ = strconv.Atoi(match[3])
+			oldState, newState = 0, 0
+			rows = append(rows, diffRow{kind: diffHunk, path: path, newLine: newLine, text: []rune(line)})
+		case oldLine == 0 && newLine == 0:
+			// Extended headers before the first hunk of a file
+			if strings.HasPrefix(line, "Binary files") {
+				rows = append(rows, diffRow{kind: diffHunk, path: path, text: []rune(line)})
+			}
+		case strings.HasPrefix(line, "+"):
+			text := []rune(line[1:])
+			rows = append(rows, diffRow{kind: diffAdded, path: path, newLine: newLine, text: text, tokens: lex(text, &newState)})
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			text := []rune(line[1:])
+			rows = append(rows, diffRow{kind: diffRemoved, path: path, oldLine: oldLine, text: text, tokens: lex(text, &oldState)})
+			oldLine++
+		case strings.HasPrefix(line, " "):
+			text := []rune(line[1:])
+			lex(text, &oldState)
+			rows = append(rows, diffRow{kind: diffContext, path: path, oldLine: oldLine, newLine: newLine, text: text, tokens: lex(text, &newState)})
+			oldLine++
+			newLine++
+		}
+	}
+	endHunk()
+	return rows
+}
+
+// pairDiffRows lays rows out side by side: removed lines are matched with
+// the lines added in their place
+func pairDiffRows(rows []diffRow) []diffPair {
+	var pairs []diffPair
+	for i := 0; i < len(rows); {
+		row := &rows[i]
+		switch row.kind {
+		case diffFile, diffHunk, diffNote:
+			pairs = append(pairs, diffPair{header: row})
+			i++
+		case diffContext:
+			pairs = append(pairs, diffPair{left: row, right: row})
+			i++
+		default:
+			var removed, added []*diffRow
+			for ; i < len(rows) && rows[i].kind == diffRemoved; i++ {
+				removed = append(removed, &rows[i])
+			}
+			for ; i < len(rows) && rows[i].kind == diffAdded; i++ {
+				added = append(added, &rows[i])
+			}
+			for j := 0; j < len(removed) || j < len(added); j++ {
+				var pair diffPair
+				if j < len(removed) {
+					pair.left = removed[j]
+				}
+				if j < len(added) {
+					pair.right = added[j]
+				}
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	return pairs
+}
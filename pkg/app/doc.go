@@ -0,0 +1,158 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// docViewer shows Go documentation, from gopls for the symbol at the cursor
+// or from go doc for a package or symbol asked for
+type docViewer struct {
+	view  *tview.TextView
+	query string // the last package or symbol asked for
+	runs  int    // number of lookups started, to drop the results of replaced ones
+}
+
+var docs docViewer
+
+// createDocPanel creates and returns the documentation component
+func createDocPanel() *tview.TextView {
+	docs.view = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	docs.view.SetBorder(true).SetTitle("Documentation")
+	docs.view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			showPanel("output")
+			ui.app.SetFocus(ui.editor)
+			return nil
+		}
+		return event
+	})
+	return docs.view
+}
+
+// show puts the documentation text of a symbol in the panel and focuses it,
+// with the lines declaring something standing out
+func (d *docViewer) show(title, text string) {
+	d.view.Clear().SetTitle("Documentation: " + title)
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if isDeclaration(line) {
+			fmt.Fprintf(d.view, "%s%s[-]\n", colorTag(theme.Accent), tview.Escape(line))
+		} else {
+			fmt.Fprintf(d.view, "%s\n", tview.Escape(line))
+		}
+	}
+	d.view.ScrollToBeginning()
+	showPanel("doc")
+	ui.app.SetFocus(d.view)
+}
+
+// isDeclaration reports whether a line of documentation declares something
+func isDeclaration(line string) bool {
+	for _, keyword := range []string{"package ", "func ", "type ", "var ", "const ", "field ", "method "} {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// showAtCursor shows the documentation of the identifier at the cursor,
+// asking gopls for it in the files it serves and go doc otherwise
+func (d *docViewer) showAtCursor() {
+	buf := buffers.current()
+	if buf == nil || buf.Path() == "" {
+		notify(noticeError, "Error showing documentation: no file loaded")
+		return
+	}
+	symbol := qualifiedIdentifier(buf)
+	if symbol == "" {
+		ui.output.message("No identifier at the cursor")
+		return
+	}
+	dir := filepath.Dir(buf.Path())
+	if !gopls.serves(buf) {
+		d.lookUp(dir, symbol)
+		return
+	}
+	d.runs++
+	run := d.runs
+	gopls.request("textDocument/hover", nil, func(buf *Buffer, result json.RawMessage) {
+		if d.runs != run {
+			return
+		}
+		var hover lspHover
+		if err := json.Unmarshal(result, &hover); err != nil || strings.TrimSpace(hover.Contents.Value) == "" {
+			d.lookUp(dir, symbol)
+			return
+		}
+		d.show(symbol, hover.Contents.Value)
+	})
+}
+
+// qualifiedIdentifier returns the identifier at the cursor of a buffer,
+// with the package or value it is selected from, if any, as in fmt.Println
+func qualifiedIdentifier(buf *Buffer) string {
+	from, to := ui.editor.WordBounds(buf.Cursor())
+	if from == to {
+		return ""
+	}
+	line := buf.Lines()[from.Line]
+	start := from.Col
+	if start > 1 && line[start-1] == '.' && editor.IsIdentPart(line[start-2]) {
+		start--
+		for start > 0 && editor.IsIdentPart(line[start-1]) {
+			start--
+		}
+	}
+	return string(line[start:to.Col])
+}
+
+// promptDoc asks for a package or symbol, as go doc takes them, and shows
+// its documentation
+func promptDoc() {
+	showPrompt("go doc: ", docs.query, func(text string) {
+		if text = strings.TrimSpace(text); text == "" {
+			return
+		}
+		docs.query = text
+		docs.lookUp(workspaceRoot, strings.Fields(text)...)
+	})
+}
+
+// lookUp runs go doc with args in dir and shows what it prints
+func (d *docViewer) lookUp(dir string, args ...string) {
+	d.runs++
+	run := d.runs
+	title := "go doc " + strings.Join(args, " ")
+	go func() {
+		defer recoverPanic()
+		cmd := exec.Command("go", append([]string{"doc"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		ui.app.QueueUpdateDraw(func() {
+			if d.runs != run {
+				return
+			}
+			if err != nil {
+				message := strings.TrimSpace(string(output))
+				if message == "" {
+					message = err.Error()
+				}
+				notify(noticeError, fmt.Sprintf("Error running %s: %s", title, message))
+				showPanel("output")
+				return
+			}
+			d.show(title, string(output))
+		})
+	}()
+}
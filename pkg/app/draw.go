@@ -0,0 +1,33 @@
+package app
+
+import (
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// fillRow paints width cells starting at x with the style's background
+func fillRow(screen tcell.Screen, x, y, width int, style tcell.Style) {
+	for i := 0; i < width; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+}
+
+// printText prints plain text clipped to width and returns the column
+// following the last printed cell
+func printText(screen tcell.Screen, text string, x, y, width int, style tcell.Style) int {
+	end := x + width
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			r = ' '
+		}
+		w := runewidth.RuneWidth(r)
+		if x+w > end {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	return x
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// textEncoding is a character encoding files are read and written in. The
+// text of buffers is always UTF-8; files are transcoded on load and save.
+type textEncoding struct {
+	name     string
+	encoding encoding.Encoding // nil for UTF-8
+	bom      []byte            // byte order mark at the start of the file
+}
+
+var (
+	encodingUTF8     = &textEncoding{name: "UTF-8"}
+	encodingLatin1   = &textEncoding{name: "ISO-8859-1", encoding: charmap.ISO8859_1}
+	encodingWindows  = &textEncoding{name: "Windows-1252", encoding: charmap.Windows1252}
+	encodingShiftJIS = &textEncoding{name: "Shift_JIS", encoding: japanese.ShiftJIS}
+	encodingUTF16LE  = &textEncoding{name: "UTF-16 LE", encoding: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), bom: []byte{0xFF, 0xFE}}
+	encodingUTF16BE  = &textEncoding{name: "UTF-16 BE", encoding: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), bom: []byte{0xFE, 0xFF}}
+
+	// textEncodings are the encodings offered by the change-encoding command
+	textEncodings = []*textEncoding{
+		encodingUTF8,
+		{name: "UTF-8 with BOM", bom: []byte{0xEF, 0xBB, 0xBF}},
+		encodingUTF16LE,
+		encodingUTF16BE,
+		encodingLatin1,
+		encodingWindows,
+		encodingShiftJIS,
+	}
+)
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "encoding", Order: 30, Right: true, Text: editorInfo(func(buf *Buffer) string {
+		if buf.hex != nil {
+			return ""
+		}
+		return buf.encoding.name
+	})})
+}
+
+// detectEncoding guesses the encoding of a file's content from its byte
+// order mark, or else from the bytes it holds. Content that is neither
+// UTF-16 nor UTF-8 nor Shift_JIS is taken to be Latin-1.
+func detectEncoding(content []byte) *textEncoding {
+	for _, e := range textEncodings {
+		if e.bom != nil && bytes.HasPrefix(content, e.bom) {
+			return e
+		}
+	}
+	if e := detectUTF16(content); e != nil {
+		// Saved without a byte order mark, as it was found
+		return &textEncoding{name: e.name, encoding: e.encoding}
+	}
+	switch {
+	case utf8.Valid(content):
+		return encodingUTF8
+	case looksLikeShiftJIS(content):
+		return encodingShiftJIS
+	case hasC1Controls(content):
+		// Control characters in Latin-1, but quotes, dashes, and the euro
+		// sign in Windows-1252
+		return encodingWindows
+	}
+	return encodingLatin1
+}
+
+// detectUTF16 recognizes UTF-16 text without a byte order mark by the zero
+// bytes of its ASCII characters, which fall on every other byte
+func detectUTF16(content []byte) *textEncoding {
+	if len(content) < 2 || len(content)%2 != 0 {
+		return nil
+	}
+	var even, odd int
+	for i := 0; i < len(content); i += 2 {
+		if content[i] == 0 {
+			even++
+		}
+		if content[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := len(content) / 2
+	switch {
+	case odd*10 >= pairs*4 && even*20 < pairs:
+		return encodingUTF16LE
+	case even*10 >= pairs*4 && odd*20 < pairs:
+		return encodingUTF16BE
+	}
+	return nil
+}
+
+// hasC1Controls reports whether content has bytes from 0x80 to 0x9F
+func hasC1Controls(content []byte) bool {
+	for _, b := range content {
+		if b >= 0x80 && b <= 0x9F {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeShiftJIS reports whether content is made of valid Shift_JIS
+// sequences and has a character whose lead byte is a control character in
+// Latin-1, as kana and Japanese punctuation have
+func looksLikeShiftJIS(content []byte) bool {
+	kana := false
+	for i := 0; i < len(content); i++ {
+		switch b := content[i]; {
+		case b < 0x80 || (b >= 0xA1 && b <= 0xDF):
+			// ASCII or half-width katakana
+		case (b >= 0x81 && b <= 0x9F) || (b >= 0xE0 && b <= 0xEF):
+			if i+1 == len(content) {
+				return false
+			}
+			if trail := content[i+1]; trail < 0x40 || trail == 0x7F || trail > 0xFC {
+				return false
+			}
+			kana = kana || b <= 0x9F
+			i++
+		default:
+			return false
+		}
+	}
+	return kana
+}
+
+// decode converts a file's content in this encoding to text
+func (e *textEncoding) decode(content []byte) (string, error) {
+	content = bytes.TrimPrefix(content, e.bom)
+	if e.encoding == nil {
+		return string(content), nil
+	}
+	text, err := e.encoding.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the file as %s: %w", e.name, err)
+	}
+	return string(text), nil
+}
+
+// encode converts text to a file's content in this encoding
+func (e *textEncoding) encode(text string) ([]byte, error) {
+	content := []byte(text)
+	if e.encoding != nil {
+		var err error
+		if content, err = e.encoding.NewEncoder().Bytes(content); err != nil {
+			return nil, fmt.Errorf("the text cannot be written in %s: %w", e.name, err)
+		}
+	}
+	return append(append([]byte{}, e.bom...), content...), nil
+}
+
+// changeEncoding picks an encoding for the file in the editor, then either
+// reopens the file in it or saves the file in it
+func changeEncoding() {
+	buf := buffers.current()
+	if buf == nil {
+		ui.output.message("No file loaded")
+		return
+	}
+	if buf.onDisk() {
+		ui.output.message("Encodings cannot be changed for large and binary files")
+		return
+	}
+	names := make([]string, len(textEncodings))
+	current := 0
+	for i, e := range textEncodings {
+		names[i] = e.name
+		if e.name == buf.encoding.name {
+			current = i
+		}
+	}
+	showPicker("Encoding", names, current, func(index int) {
+		enc := textEncodings[index]
+		question := fmt.Sprintf("Reopen %s as %s, or save it in %s?", buf.Name(), enc.name, enc.name)
+		chooseAction(question, []string{"Reopen", "Save", "Cancel"}, func(choice int) {
+			switch choice {
+			case 0:
+				if buf.dirty {
+					notify(noticeError, fmt.Sprintf("Error reopening file: %s has unsaved changes", buf.Name()))
+					return
+				}
+				previous := buf.encoding
+				buf.encoding = enc
+				if err := buffers.reload(buf); err != nil {
+					buf.encoding = previous
+					notify(noticeError, fmt.Sprintf("Error reopening file: %s", err))
+					return
+				}
+				ui.output.message(fmt.Sprintf("Reopened %s as %s", buf.Path(), enc.name))
+			case 1:
+				switch {
+				case buffers.current() != buf:
+					return
+				case buf.ReadOnly():
+					notify(noticeError, fmt.Sprintf("Error saving file: %s is read-only", buf.Path()))
+					return
+				case buf.changedOnDisk():
+					notify(noticeError, fmt.Sprintf("Error saving file: %s changed on disk since it was loaded; save or reload it first", buf.Path()))
+					return
+				}
+				previous := buf.encoding
+				buf.encoding = enc
+				if err := writeFile(buf); err != nil {
+					buf.encoding = previous
+					notify(noticeError, fmt.Sprintf("Error saving file: %s", err))
+				}
+			}
+		})
+	})
+}
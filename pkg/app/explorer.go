@@ -0,0 +1,359 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"gotui/pkg/explorer"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// fileFilter decides which entries the file explorer hides
+type fileFilter struct {
+	showHidden bool                  // also show dotfiles and entries matched by .gitignore
+	ignores    map[string]*gitignore // the rules of each workspace root
+}
+
+var explorerFilter fileFilter
+
+// hides reports whether the explorer leaves out the entry at path
+func (f fileFilter) hides(path string, isDir bool) bool {
+	if f.showHidden {
+		return false
+	}
+	root := rootOf(path)
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if strings.HasPrefix(parts[len(parts)-1], ".") {
+		return true
+	}
+	ignore := f.ignores[root]
+	return ignore != nil && ignore.ignoredEntry(parts, isDir)
+}
+
+// spinnerFrames animate work in progress, such as a running job
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// createFileExplorer creates and returns the file explorer component. The
+// roots of the workspace are the top level of the tree.
+func createFileExplorer() (*explorer.Explorer, error) {
+	info, err := os.Stat(workspaceRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", workspaceRoot)
+	}
+	explorerFilter = fileFilter{showHidden: config.Explorer.ShowHidden, ignores: rootIgnoreRules()}
+
+	tree := explorer.New(func(update func()) { ui.app.QueueUpdateDraw(update) }).
+		SetColors(theme.Directory, theme.Text, theme.Success).
+		SetFilterFunc(explorerFilter.hides).
+		SetOpenFunc(func(path string) {
+			if err := loadFile(path); err != nil {
+				notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+			}
+		}).
+		SetLoadedFunc(watcher.watch).
+		SetErrorFunc(func(err error) {
+			notify(noticeError, fmt.Sprintf("Error reading directory: %s", err))
+		}).
+		SetPanicFunc(func(p interface{}) { crash(p, debug.Stack()) })
+
+	tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if !runCommand(bindings.lookup(scopeExplorer, event)) {
+			return event
+		}
+		return nil
+	})
+	tree.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action != tview.MouseRightClick || !tree.InRect(event.Position()) {
+			return action, event
+		}
+		_, y := event.Position()
+		if node := tree.NodeAt(y); node != nil {
+			tree.SetCurrentNode(node)
+			ui.app.SetFocus(tree)
+			showExplorerMenu()
+		}
+		return action, nil
+	})
+
+	tree.SetRoots([]string{workspaceRoot}, rootName)
+	return tree, nil
+}
+
+// setExplorerFilter applies changed filter rules to the tree
+func setExplorerFilter(filter fileFilter) error {
+	explorerFilter = filter
+	ui.fileExplorer.SetFilterFunc(explorerFilter.hides)
+	return ui.fileExplorer.Refresh()
+}
+
+// toggleHidden shows or hides dotfiles and entries matched by .gitignore
+func toggleHidden() {
+	filter := explorerFilter
+	filter.showHidden = !filter.showHidden
+	if err := setExplorerFilter(filter); err != nil {
+		notify(noticeError, fmt.Sprintf("Error reading directory: %s", err))
+		return
+	}
+	if explorerFilter.showHidden {
+		ui.output.message("Showing dotfiles and ignored files")
+	} else {
+		ui.output.message("Hiding dotfiles and ignored files")
+	}
+}
+
+// reloadIgnoreRules forgets the cached .gitignore rules, after one of the
+// files changed, and applies the new rules to the tree
+func reloadIgnoreRules() error {
+	filter := explorerFilter
+	filter.ignores = rootIgnoreRules()
+	if filter.showHidden {
+		explorerFilter = filter
+		ui.fileExplorer.SetFilterFunc(explorerFilter.hides)
+		return nil
+	}
+	return setExplorerFilter(filter)
+}
+
+// relativePath returns path relative to the workspace, for messages. Paths
+// in an added folder start with the folder's name.
+func relativePath(path string) string {
+	if root := rootOf(path); root != workspaceRoot {
+		if rel, err := filepath.Rel(root, absPath(path)); err == nil {
+			return filepath.Join(filepath.Base(root), rel)
+		}
+	}
+	if rel, err := filepath.Rel(workspaceRoot, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// checkEntryName rejects names that do not name a new entry
+func checkEntryName(name string) error {
+	if name == "" {
+		return fmt.Errorf("no name given")
+	}
+	if filepath.IsAbs(name) {
+		return fmt.Errorf("%s is not a relative path", name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return fmt.Errorf("%s leaves the directory", name)
+		}
+	}
+	return nil
+}
+
+// promptNewEntry asks for the name of a file or directory to create in the
+// selected directory. The name may include subdirectories, which are created
+// as needed.
+func promptNewEntry(isDir bool) {
+	parent := ui.fileExplorer.SelectedDir()
+	label := "New file in "
+	if isDir {
+		label = "New directory in "
+	}
+	showPrompt(label+relativePath(parent)+": ", "", func(name string) {
+		path, err := createEntry(parent, strings.TrimSpace(name), isDir)
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error creating %s: %s", name, err))
+			return
+		}
+		if !isDir {
+			if err := loadFile(path); err != nil {
+				notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+				return
+			}
+			ui.app.SetFocus(ui.editor)
+		}
+		ui.output.message(fmt.Sprintf("Created %s", relativePath(path)))
+	})
+}
+
+// createEntry creates a file or directory named name inside parent and
+// shows it in the tree
+func createEntry(parent, name string, isDir bool) (string, error) {
+	if err := checkEntryName(name); err != nil {
+		return "", err
+	}
+	path := filepath.Join(parent, name)
+	if _, err := os.Lstat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", relativePath(path))
+	}
+	if isDir {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return "", fmt.Errorf("failed to create file: %w", err)
+		}
+		file.Close()
+	}
+	if err := ui.fileExplorer.RefreshDir(parent); err != nil {
+		return path, err
+	}
+	return path, ui.fileExplorer.Reveal(path)
+}
+
+// promptRename asks for a new name for the selected file or directory. A
+// name with subdirectories moves the entry below its current directory.
+func promptRename() {
+	node := ui.fileExplorer.GetCurrentNode()
+	if node == nil || ui.fileExplorer.IsRoot(node) {
+		ui.output.message("A workspace root cannot be renamed")
+		return
+	}
+	from := ui.fileExplorer.Path(node)
+	showPrompt("Rename "+relativePath(from)+" to: ", filepath.Base(from), func(name string) {
+		to, err := renameEntry(from, strings.TrimSpace(name))
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error renaming %s: %s", relativePath(from), err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Renamed %s to %s", relativePath(from), relativePath(to)))
+	})
+}
+
+// renameEntry renames a file or directory, updating the tree and the buffers
+// of the files it affects
+func renameEntry(from, name string) (string, error) {
+	if err := checkEntryName(name); err != nil {
+		return "", err
+	}
+	to := filepath.Join(filepath.Dir(from), name)
+	if to == from {
+		return to, nil
+	}
+	if withinPath(to, from) {
+		return "", fmt.Errorf("cannot move a directory into itself")
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return "", fmt.Errorf("%s already exists", relativePath(to))
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(from, to); err != nil {
+		return "", fmt.Errorf("failed to rename: %w", err)
+	}
+	buffers.renamed(from, to)
+	if err := ui.fileExplorer.RefreshDir(filepath.Dir(from)); err != nil {
+		return to, err
+	}
+	if err := ui.fileExplorer.RefreshDir(filepath.Dir(to)); err != nil {
+		return to, err
+	}
+	return to, ui.fileExplorer.Reveal(to)
+}
+
+// confirmDelete asks before deleting the selected file or directory
+func confirmDelete() {
+	node := ui.fileExplorer.GetCurrentNode()
+	if node == nil || ui.fileExplorer.IsRoot(node) {
+		ui.output.message("A workspace root cannot be deleted")
+		return
+	}
+	path := ui.fileExplorer.Path(node)
+	question := fmt.Sprintf("Delete the file %s?", relativePath(path))
+	if ui.fileExplorer.IsDir(node) {
+		question = fmt.Sprintf("Delete the directory %s and everything in it?", relativePath(path))
+	}
+	confirmAction(question, "Delete", func() {
+		if err := deleteEntry(path); err != nil {
+			notify(noticeError, fmt.Sprintf("Error deleting %s: %s", relativePath(path), err))
+		}
+	})
+}
+
+// deleteEntry removes a file or directory and everything in it, closing the
+// buffers of deleted files
+func deleteEntry(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+	parent := filepath.Dir(path)
+	if err := ui.fileExplorer.RefreshDir(parent); err != nil {
+		return err
+	}
+	if err := ui.fileExplorer.Reveal(parent); err != nil {
+		return err
+	}
+	if kept := buffers.removed(path); kept > 0 {
+		ui.output.message(fmt.Sprintf("Deleted %s; %d open files with unsaved changes were kept", relativePath(path), kept))
+		return nil
+	}
+	ui.output.message(fmt.Sprintf("Deleted %s", relativePath(path)))
+	return nil
+}
+
+// openTerminalHere starts a shell in a new terminal tab in the selected
+// directory, or in the directory of the selected file
+func openTerminalHere() {
+	dir := ui.fileExplorer.SelectedDir()
+	if err := terminals.spawnIn(dir); err != nil {
+		notify(noticeError, fmt.Sprintf("Error starting terminal: %s", err))
+		return
+	}
+	showPane(terminalPane)
+	ui.app.SetFocus(ui.terminal)
+}
+
+// searchSelectedDir opens the search panel to search the selected
+// directory, or the directory of the selected file
+func searchSelectedDir() {
+	projectSearch.openIn(ui.fileExplorer.SelectedDir())
+}
+
+// showExplorerMenu shows the file operations for the selected node
+func showExplorerMenu() {
+	node := ui.fileExplorer.GetCurrentNode()
+	if node == nil {
+		return
+	}
+	var labels []string
+	var actions []func()
+	add := func(label string, action func()) {
+		labels = append(labels, label)
+		actions = append(actions, action)
+	}
+	add("New File", func() { promptNewEntry(false) })
+	add("New Directory", func() { promptNewEntry(true) })
+	add("Open Terminal Here", openTerminalHere)
+	add("Find in Folder", searchSelectedDir)
+	if !ui.fileExplorer.IsRoot(node) {
+		add("Rename", promptRename)
+		add("Delete", confirmDelete)
+	}
+	toggle := "Show Hidden Files"
+	if explorerFilter.showHidden {
+		toggle = "Hide Hidden Files"
+	}
+	if git.root != "" {
+		add("Show Changes", func() { showFileDiff(ui.fileExplorer.Path(node)) })
+	}
+	add(toggle, toggleHidden)
+	add("Add Folder to Workspace", promptAddWorkspaceFolder)
+	if ui.fileExplorer.IsRoot(node) && ui.fileExplorer.Path(node) != workspaceRoot {
+		add("Remove Folder from Workspace", pickRemoveWorkspaceFolder)
+	}
+	showPicker(tview.Escape(node.GetText()), labels, 0, func(index int) {
+		actions[index]()
+	})
+}
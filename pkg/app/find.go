@@ -0,0 +1,195 @@
+package app
+
+import (
+	"fmt"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// findBar is the in-editor find and replace bar
+type findBar struct {
+	bar     *tview.Flex
+	query   *tview.InputField
+	replace *tview.InputField
+	status  *tview.TextView
+
+	visible bool
+	origin  editor.Position // cursor position when the search started
+	matches []editor.Range
+	current int // index of the selected match, -1 if none
+}
+
+var finder findBar
+
+// matchStyle is applied to every match except the selected one
+func matchStyle(style tcell.Style) tcell.Style {
+	return style.Background(theme.MatchBackground).Foreground(theme.MatchText)
+}
+
+// createFindBar creates and returns the find and replace bar component
+func createFindBar() *tview.Flex {
+	finder.query = tview.NewInputField().
+		SetLabel("Find: ").
+		SetChangedFunc(func(text string) {
+			finder.update(true)
+		})
+	finder.replace = tview.NewInputField().
+		SetLabel(" Replace: ")
+	finder.status = tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignRight)
+
+	finder.query.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEnter, tcell.KeyDown:
+			finder.step(1)
+		case tcell.KeyUp:
+			finder.step(-1)
+		case tcell.KeyTab, tcell.KeyBacktab:
+			ui.app.SetFocus(finder.replace)
+		case tcell.KeyEscape:
+			finder.hide()
+		default:
+			return event
+		}
+		return nil
+	})
+	finder.replace.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEnter:
+			finder.replaceCurrent()
+		case tcell.KeyCtrlA:
+			finder.replaceAll()
+		case tcell.KeyDown:
+			finder.step(1)
+		case tcell.KeyUp:
+			finder.step(-1)
+		case tcell.KeyTab, tcell.KeyBacktab:
+			ui.app.SetFocus(finder.query)
+		case tcell.KeyEscape:
+			finder.hide()
+		default:
+			return event
+		}
+		return nil
+	})
+
+	finder.bar = tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(finder.query, 0, 1, true).
+		AddItem(finder.replace, 0, 1, false).
+		AddItem(finder.status, 14, 0, false)
+	return finder.bar
+}
+
+// show opens the find bar, seeding the query with the selected text
+func (f *findBar) show() {
+	if !f.visible {
+		f.visible = true
+		ui.editorPane.ResizeItem(f.bar, 1, 0)
+	}
+	jumps.push()
+	from, to := ui.editor.Selection()
+	f.origin = from
+	if from != to && from.Line == to.Line {
+		f.query.SetText(ui.editor.SelectedText())
+	}
+	f.update(true)
+	ui.app.SetFocus(f.query)
+}
+
+// hide closes the find bar and returns focus to the editor
+func (f *findBar) hide() {
+	f.visible = false
+	f.matches = nil
+	ui.editor.ClearDecorations("search")
+	ui.editorPane.ResizeItem(f.bar, 0, 0)
+	ui.app.SetFocus(ui.editor)
+}
+
+// update recomputes the matches; with jump set, the first match after the
+// search origin is selected in the editor
+func (f *findBar) update(jump bool) {
+	if !f.visible {
+		return
+	}
+	f.matches = ui.editor.FindAll(f.query.GetText())
+	ui.editor.SetDecorations("search", f.matches, matchStyle)
+
+	anchor := ui.editor.Cursor()
+	if jump {
+		anchor = f.origin
+	}
+	f.current = -1
+	for i, match := range f.matches {
+		if !match.From.Less(anchor) {
+			f.current = i
+			break
+		}
+	}
+	if f.current < 0 && len(f.matches) > 0 {
+		f.current = 0
+	}
+	if jump && f.current >= 0 {
+		f.selectCurrent()
+	}
+	f.refreshStatus()
+}
+
+// step moves the selection to the next (1) or previous (-1) match
+func (f *findBar) step(delta int) {
+	if len(f.matches) == 0 {
+		return
+	}
+	f.current = ((f.current+delta)%len(f.matches) + len(f.matches)) % len(f.matches)
+	f.selectCurrent()
+	f.refreshStatus()
+}
+
+// selectCurrent selects the current match in the editor
+func (f *findBar) selectCurrent() {
+	match := f.matches[f.current]
+	ui.editor.Select(match.From, match.To)
+	f.origin = match.From
+}
+
+// replaceCurrent replaces the selected match and moves on to the next one
+func (f *findBar) replaceCurrent() {
+	if f.current < 0 || f.current >= len(f.matches) {
+		return
+	}
+	match := f.matches[f.current]
+	end := ui.editor.Replace(match.From, match.To, f.replace.GetText())
+	f.origin = end
+	f.update(true)
+}
+
+// replaceAll replaces every match as a single undoable edit
+func (f *findBar) replaceAll() {
+	matches := f.matches
+	if len(matches) == 0 {
+		return
+	}
+	replacement := f.replace.GetText()
+	ui.editor.Transaction(func() {
+		for i := len(matches) - 1; i >= 0; i-- {
+			ui.editor.Replace(matches[i].From, matches[i].To, replacement)
+		}
+	})
+	f.update(false)
+	ui.output.message(fmt.Sprintf("Replaced %d occurrences", len(matches)))
+}
+
+// refreshStatus updates the match counter
+func (f *findBar) refreshStatus() {
+	switch {
+	case f.query.GetText() == "":
+		f.status.SetText("")
+	case len(f.matches) == 0:
+		f.status.SetText(colorTag(theme.Error) + "No results ")
+	default:
+		f.status.SetText(fmt.Sprintf("%d of %d ", f.current+1, len(f.matches)))
+	}
+}
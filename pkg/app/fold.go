@@ -0,0 +1,8 @@
+package app
+
+// foldCurrent folds or unfolds the block around the cursor in the editor
+func foldCurrent() {
+	if !ui.editor.ToggleFold(ui.editor.Cursor().Line) {
+		ui.output.message("Nothing to fold at the cursor")
+	}
+}
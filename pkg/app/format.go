@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gotui/pkg/editor"
+)
+
+// formatters maps file extensions to the commands that format them on save.
+// The first command found in PATH is used; each reads the source on stdin
+// and writes the formatted source to stdout.
+var formatters = map[string][][]string{}
+
+func init() {
+	RegisterFormatter(".go", []string{"goimports"}, []string{"gofmt"})
+}
+
+// RegisterFormatter sets the format-on-save commands for a file extension,
+// in order of preference. Registering no commands turns formatting off.
+func RegisterFormatter(ext string, commands ...[]string) {
+	if len(commands) == 0 {
+		delete(formatters, ext)
+		return
+	}
+	formatters[ext] = commands
+}
+
+// formatText runs the formatter registered for path over text. It returns
+// text unchanged when no formatter applies or none is installed.
+func formatText(path, text string) (string, error) {
+	for _, command := range formatters[strings.ToLower(filepath.Ext(path))] {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Dir = filepath.Dir(path)
+		cmd.Stdin = strings.NewReader(text)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				return text, fmt.Errorf("%s: %s", command[0], strings.ReplaceAll(message, "<standard input>", filepath.Base(path)))
+			}
+			return text, fmt.Errorf("failed to run %s: %w", command[0], err)
+		}
+		return stdout.String(), nil
+	}
+	return text, nil
+}
+
+// applyFormatted replaces the editor text with its formatted version as a
+// single undo step, touching only the span that differs. A cursor inside
+// that span keeps its line and column.
+func applyFormatted(formatted string) {
+	old, text := []rune(ui.editor.GetText()), []rune(formatted)
+	prefix := 0
+	for prefix < len(old) && prefix < len(text) && old[prefix] == text[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(text)-prefix && old[len(old)-1-suffix] == text[len(text)-1-suffix] {
+		suffix++
+	}
+	if prefix == len(old) && prefix == len(text) {
+		return
+	}
+	from, to := offsetPosition(old, prefix), offsetPosition(old, len(old)-suffix)
+	cursor := ui.editor.Cursor()
+	ui.editor.Transaction(func() {
+		end := ui.editor.Replace(from, to, string(text[prefix:len(text)-suffix]))
+		if from.Less(cursor) && cursor.Less(to) {
+			if end.Less(cursor) {
+				cursor = end
+			}
+			ui.editor.SetCursor(cursor)
+		}
+	})
+}
+
+// offsetPosition converts a rune offset into text into a position
+func offsetPosition(text []rune, offset int) editor.Position {
+	var pos editor.Position
+	for _, r := range text[:offset] {
+		if r == '\n' {
+			pos.Line++
+			pos.Col = 0
+		} else {
+			pos.Col++
+		}
+	}
+	return pos
+}
@@ -0,0 +1,538 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// gitChange is one entry of git status
+type gitChange struct {
+	path     string // workspace path of the file
+	repoPath string // path relative to the repository root, as git prints it
+	staged   byte   // status in the index, ' ' if unchanged
+	unstaged byte   // status in the working tree, ' ' if unchanged
+}
+
+// gitEntry is a file listed in the Git panel
+type gitEntry struct {
+	gitChange
+	inIndex bool // listed under the staged changes
+}
+
+// untracked reports whether git does not know the file yet
+func (c gitChange) untracked() bool {
+	return c.staged == '?'
+}
+
+// gitState follows the git repository of the workspace and shows its status
+// in the Git panel and the gutter of the editor
+type gitState struct {
+	tree    *tview.TreeView
+	root    string // absolute path of the repository, "" outside of one
+	branch  string // checked out branch, or the abbreviated commit if detached
+	changes []gitChange
+	marks   map[string]map[int]editor.GutterMark // gutter marks by workspace path
+	updates int                                  // number of status reads started, to drop stale ones
+}
+
+var git = gitState{marks: map[string]map[int]editor.GutterMark{}}
+
+// Gutter marks of lines that differ from the last commit
+func gitAddedMark() editor.GutterMark    { return editor.GutterMark{Rune: '│', Color: theme.Success} }
+func gitModifiedMark() editor.GutterMark { return editor.GutterMark{Rune: '│', Color: theme.Warning} }
+func gitDeletedMark() editor.GutterMark  { return editor.GutterMark{Rune: '▁', Color: theme.Error} }
+
+func init() {
+	RegisterStatusSegment(StatusSegment{
+		Name:  "branch",
+		Order: 10,
+		Text:  func() string { return git.branch },
+	})
+}
+
+// gitCommand returns a git command run in the workspace
+func gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workspaceRoot
+	return cmd
+}
+
+// createGitPanel creates and returns the Git panel listing changed files
+func createGitPanel() *tview.TreeView {
+	git.tree = tview.NewTreeView().
+		SetRoot(tview.NewTreeNode("")).
+		SetTopLevel(1)
+	git.tree.SetBorder(true).SetTitle("Git")
+	git.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		entry, ok := node.GetReference().(gitEntry)
+		if !ok {
+			node.SetExpanded(!node.IsExpanded())
+			return
+		}
+		if entry.staged == 'D' || entry.unstaged == 'D' {
+			ui.output.message(fmt.Sprintf("%s is deleted", entry.path))
+			return
+		}
+		if err := openFileAt(entry.path, editor.Position{}, editor.Position{}); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	})
+	git.tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		entry, selected := git.tree.GetCurrentNode().GetReference().(gitEntry)
+		switch event.Rune() {
+		case 'd':
+			if selected {
+				showEntryDiff(entry)
+			}
+		case 's':
+			if selected {
+				runGit("Staged "+entry.path, "", "add", "--", entry.path)
+			}
+		case 'u':
+			if selected {
+				unstage(entry)
+			}
+		case 'S':
+			runGit("Staged all changes", "", "add", "--all")
+		case 'c':
+			showCommitEditor(false)
+		case 'A':
+			showCommitEditor(true)
+		case 'b':
+			showBranches()
+		case 'l':
+			showLog()
+		default:
+			return event
+		}
+		return nil
+	})
+	git.tree.SetDoneFunc(func(key tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return git.tree
+}
+
+// toggleGit shows the Git panel, or hides it if it is showing
+func toggleGit() {
+	if name, _ := ui.panels.GetFrontPage(); name == "git" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	git.refresh()
+	showPanel("git")
+	ui.app.SetFocus(git.tree)
+}
+
+// refresh reads the status of the repository in the background, then
+// updates the panel and the gutter of the active buffer
+func (g *gitState) refresh() {
+	g.updates++
+	update := g.updates
+	go func() {
+		defer recoverPanic()
+		root, branch, changes, err := readGitStatus()
+		ui.app.QueueUpdateDraw(func() {
+			if g.updates != update {
+				return
+			}
+			if err != nil {
+				notify(noticeError, fmt.Sprintf("Error reading git status: %s", err))
+				return
+			}
+			if root != g.root && root != "" {
+				// Changes to the index and HEAD show up as changes in .git
+				watcher.watch(filepath.Join(root, ".git"))
+			}
+			g.root, g.branch, g.changes = root, branch, changes
+			g.render()
+			g.decorate()
+			blame.changed()
+		})
+	}()
+}
+
+// readGitStatus returns the root of the workspace's repository, the branch
+// checked out, and the changes git reports in it. Outside of a repository
+// all are empty.
+func readGitStatus() (string, string, []gitChange, error) {
+	out, err := gitCommand("rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		// Not a repository, or git is not installed
+		return "", "", nil, nil
+	}
+	root := strings.TrimSpace(string(out))
+	out, err = gitCommand("status", "--porcelain", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to run git status: %w", err)
+	}
+	return root, readGitBranch(), parseGitStatus(root, out), nil
+}
+
+// readGitBranch returns the name of the branch checked out, or the
+// abbreviated commit when HEAD is detached
+func readGitBranch() string {
+	out, err := gitCommand("symbolic-ref", "--short", "-q", "HEAD").Output()
+	if err != nil || len(out) == 0 {
+		out, _ = gitCommand("rev-parse", "--short", "HEAD").Output()
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// parseGitStatus parses the output of git status --porcelain -z
+func parseGitStatus(root string, out []byte) []gitChange {
+	var changes []gitChange
+	fields := bytes.Split(out, []byte{0})
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 4 {
+			continue
+		}
+		change := gitChange{
+			repoPath: string(field[3:]),
+			staged:   field[0],
+			unstaged: field[1],
+		}
+		change.path = workspacePath(filepath.Join(root, filepath.FromSlash(change.repoPath)))
+		if change.staged == 'R' || change.staged == 'C' {
+			// The original path follows as a field of its own
+			i++
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes
+}
+
+// render fills the Git panel with the changes, grouped as git status does
+func (g *gitState) render() {
+	if g.tree == nil {
+		return
+	}
+	selected := ""
+	if current := g.tree.GetCurrentNode(); current != nil {
+		selected = current.GetText()
+	}
+	root := g.tree.GetRoot().ClearChildren()
+	if g.root == "" {
+		g.tree.SetTitle("Git")
+		root.AddChild(tview.NewTreeNode("Not a git repository").SetSelectable(false))
+		return
+	}
+	groups := []struct {
+		title  string
+		staged bool
+		status func(gitChange) byte
+	}{
+		{"Staged Changes", true, func(c gitChange) byte {
+			if c.untracked() {
+				return ' '
+			}
+			return c.staged
+		}},
+		{"Changes", false, func(c gitChange) byte {
+			if c.untracked() {
+				return ' '
+			}
+			return c.unstaged
+		}},
+		{"Untracked Files", false, func(c gitChange) byte {
+			if c.untracked() {
+				return '?'
+			}
+			return ' '
+		}},
+	}
+	for _, group := range groups {
+		node := tview.NewTreeNode("").SetColor(theme.Text)
+		count := 0
+		for _, change := range g.changes {
+			status := group.status(change)
+			if status == ' ' {
+				continue
+			}
+			count++
+			node.AddChild(tview.NewTreeNode(fmt.Sprintf("%c %s", status, change.path)).
+				SetReference(gitEntry{gitChange: change, inIndex: group.staged}).
+				SetColor(theme.statusColor(status)))
+		}
+		if count > 0 {
+			root.AddChild(node.SetText(fmt.Sprintf("%s (%d)", group.title, count)))
+		}
+	}
+	if len(root.GetChildren()) == 0 {
+		root.AddChild(tview.NewTreeNode("No changes").SetSelectable(false))
+	}
+	// Keep the selection on the same entry when it is still listed
+	g.tree.SetCurrentNode(root.GetChildren()[0])
+	root.Walk(func(node, parent *tview.TreeNode) bool {
+		if node != root && node.GetText() == selected {
+			g.tree.SetCurrentNode(node)
+			return false
+		}
+		return true
+	})
+	g.tree.SetTitle(fmt.Sprintf("Git (%d)", len(g.changes)))
+}
+
+// change returns the status of a file, if git reports one
+func (g *gitState) change(path string) (gitChange, bool) {
+	for _, change := range g.changes {
+		if change.path == path {
+			return change, true
+		}
+	}
+	return gitChange{}, false
+}
+
+// decorate marks the lines of the active buffer that differ from the last
+// commit. The marks known from before are shown while git computes the
+// current ones.
+func (g *gitState) decorate() {
+	if ui.editor == nil {
+		return
+	}
+	buf := buffers.current()
+	if buf == nil || g.root == "" {
+		ui.editor.SetGutterMarks("git", nil)
+		return
+	}
+	path := buf.Path()
+	ui.editor.SetGutterMarks("git", g.marks[path])
+	if change, ok := g.change(path); ok && change.untracked() {
+		marks := make(map[int]editor.GutterMark, len(buf.Lines()))
+		for line := range buf.Lines() {
+			marks[line] = gitAddedMark()
+		}
+		g.setMarks(path, marks)
+		return
+	}
+	go func() {
+		defer recoverPanic()
+		out, err := gitCommand("diff", "-U0", "--no-color", "--no-ext-diff", "HEAD", "--", path).Output()
+		if err != nil {
+			// A repository without commits, or a file outside of it
+			out = nil
+		}
+		marks := parseDiffMarks(out)
+		ui.app.QueueUpdateDraw(func() {
+			g.setMarks(path, marks)
+		})
+	}()
+}
+
+// setMarks stores the gutter marks of a file and shows them if it is the
+// active one
+func (g *gitState) setMarks(path string, marks map[int]editor.GutterMark) {
+	g.marks[path] = marks
+	if buf := buffers.current(); buf != nil && buf.Path() == path {
+		ui.editor.SetGutterMarks("git", marks)
+	}
+}
+
+// diffHunkHeader matches the header of a hunk of a unified diff
+var diffHunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseDiffMarks turns the hunks of a diff without context lines into
+// gutter marks for the new version of the file
+func parseDiffMarks(diff []byte) map[int]editor.GutterMark {
+	marks := map[int]editor.GutterMark{}
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	for _, line := range strings.Split(string(diff), "\n") {
+		match := diffHunkHeader.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		removed := count(match[2])
+		start, _ := strconv.Atoi(match[3])
+		added := count(match[4])
+		if added == 0 {
+			// Lines were deleted after line start; mark the line above the gap
+			if start > 0 {
+				marks[start-1] = gitDeletedMark()
+			} else {
+				marks[0] = editor.GutterMark{Rune: '▔', Color: theme.Error}
+			}
+			continue
+		}
+		for i := 0; i < added; i++ {
+			mark := gitAddedMark()
+			if i < removed {
+				mark = gitModifiedMark()
+			}
+			marks[start-1+i] = mark
+		}
+	}
+	return marks
+}
+
+// diffSideBySide remembers the layout last chosen in the diff view
+var diffSideBySide bool
+
+// showFileDiff shows the changes of a file since the last commit
+func showFileDiff(path string) {
+	if git.root == "" {
+		ui.output.message("Not a git repository")
+		return
+	}
+	if change, ok := git.change(path); ok && change.untracked() {
+		showDiff("Diff: "+path+" (untracked)", diffNoStaging, "diff", "--no-index", "--", os.DevNull, path)
+		return
+	}
+	showDiff("Diff: HEAD → "+path, diffNoStaging, "diff", "HEAD", "--", path)
+}
+
+// promptDiffRevisions asks for one or two revisions and shows the changes
+// between them, or between one and the working tree
+func promptDiffRevisions() {
+	if git.root == "" {
+		ui.output.message("Not a git repository")
+		return
+	}
+	showPrompt("Diff revisions (working tree if one): ", "HEAD", func(text string) {
+		revisions := strings.Fields(text)
+		switch len(revisions) {
+		case 1:
+			showDiff("Diff: "+revisions[0]+" → working tree", diffNoStaging, "diff", revisions[0], "--")
+		case 2:
+			showDiff("Diff: "+revisions[0]+" → "+revisions[1], diffNoStaging, "diff", revisions[0], revisions[1], "--")
+		default:
+			ui.output.message("Enter one or two revisions")
+		}
+	})
+}
+
+// diffStaging tells which hunk operations a diff view offers
+type diffStaging int
+
+const (
+	diffNoStaging    diffStaging = iota // the diff is not against the index
+	diffStageHunks                      // working tree against the index
+	diffUnstageHunks                    // index against HEAD
+)
+
+// showDiff runs git diff with args in the background and opens the result
+// in the diff view
+func showDiff(title string, staging diffStaging, args ...string) {
+	go func() {
+		defer recoverPanic()
+		rows, err := loadDiff(args)
+		ui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				notify(noticeError, fmt.Sprintf("Error running git diff: %s", err))
+				return
+			}
+			if len(rows) == 0 {
+				ui.output.message("No changes")
+				return
+			}
+			openDiffView(title, rows, staging, args)
+		})
+	}()
+}
+
+// loadDiff runs git diff with args and parses its output
+func loadDiff(args []string) ([]diffRow, error) {
+	args = append([]string{args[0], "--no-color", "--no-ext-diff", "-M"}, args[1:]...)
+	var stderr bytes.Buffer
+	cmd := gitCommand(args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) > 0 {
+		// git diff --no-index exits with 1 when the files differ
+		err = nil
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, errors.New(message)
+		}
+		return nil, err
+	}
+	return parseDiff(string(out), func(repoPath string) string {
+		if filepath.IsAbs(repoPath) {
+			return workspacePath(repoPath)
+		}
+		return workspacePath(filepath.Join(git.root, filepath.FromSlash(repoPath)))
+	}), nil
+}
+
+// openDiffView shows diff rows over the whole screen until Escape is
+// pressed. Where the diff is against the index, hunks can be staged or
+// unstaged from it, and the diff of args is read again afterwards.
+func openDiffView(title string, rows []diffRow, staging diffStaging, args []string) {
+	view := NewDiffView().SetDiff(rows).SetSideBySide(diffSideBySide)
+	keys := "n/p: hunks, s: side by side, Enter: open, Esc: close"
+	switch staging {
+	case diffStageHunks:
+		keys = "a: stage hunk, " + keys
+	case diffUnstageHunks:
+		keys = "u: unstage hunk, " + keys
+	}
+	view.SetBorder(true).SetTitle(title + "  [" + keys + "]")
+	view.SetDoneFunc(func() {
+		diffSideBySide = view.sideBySide
+		closeDialog()
+	})
+	view.SetOpenedFunc(func(path string, line int) {
+		diffSideBySide = view.sideBySide
+		closeDialogs()
+		pos := editor.Position{Line: line}
+		jumps.push()
+		if err := openFileAt(path, pos, pos); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	})
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch {
+		case event.Rune() == 'a' && staging == diffStageHunks:
+		case event.Rune() == 'u' && staging == diffUnstageHunks:
+		default:
+			return event
+		}
+		hunk := view.hunkAtTop()
+		if hunk == nil || hunk.patch == "" {
+			view.SetTitle(title + "  [no hunk at the top of the view]")
+			return nil
+		}
+		applyHunk(hunk.patch, staging == diffUnstageHunks, func() {
+			// Show what is left to stage, or return once nothing is
+			rows, err := loadDiff(args)
+			if err != nil || len(rows) == 0 {
+				diffSideBySide = view.sideBySide
+				closeDialog()
+				return
+			}
+			view.SetDiff(rows)
+		})
+		return nil
+	})
+	showDialog(view, 0, 0)
+}
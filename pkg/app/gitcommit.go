@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// commitHelp is appended to the message being edited; git drops comment
+// lines when committing
+const commitHelp = `
+# Write the commit message above. Lines starting with "#" are ignored,
+# and an empty message aborts the commit.`
+
+// gitRun runs git with args in the background, feeding it stdin, and calls
+// done with its output on the UI goroutine. The error carries what git
+// printed to stderr. Commands that change the repository refresh the status
+// themselves.
+func gitRun(stdin string, args []string, done func(out string, err error)) {
+	go func() {
+		defer recoverPanic()
+		var stdout, stderr bytes.Buffer
+		cmd := gitCommand(args...)
+		cmd.Stdin = strings.NewReader(stdin)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		if err != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				err = errors.New(message)
+			}
+			err = fmt.Errorf("git %s: %w", args[0], err)
+		}
+		ui.app.QueueUpdateDraw(func() {
+			done(stdout.String(), err)
+		})
+	}()
+}
+
+// runGit runs a git command changing the repository, reporting message or
+// the error in the output pane
+func runGit(message, stdin string, args ...string) {
+	gitRun(stdin, args, func(out string, err error) {
+		git.refresh()
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		ui.output.message(message)
+	})
+}
+
+// unstage removes the staged changes of a file from the index
+func unstage(entry gitEntry) {
+	if !entry.inIndex {
+		ui.output.message(fmt.Sprintf("%s has no staged changes", entry.path))
+		return
+	}
+	runGit("Unstaged "+entry.path, "", "reset", "-q", "--", entry.path)
+}
+
+// showEntryDiff shows the changes of a file of the Git panel: the staged
+// ones against HEAD, where hunks can be unstaged, or the others against the
+// index, where hunks can be staged
+func showEntryDiff(entry gitEntry) {
+	switch {
+	case entry.untracked():
+		showFileDiff(entry.path)
+	case entry.inIndex:
+		showDiff("Staged: "+entry.path, diffUnstageHunks, "diff", "--cached", "--", entry.path)
+	default:
+		showDiff("Unstaged: "+entry.path, diffStageHunks, "diff", "--", entry.path)
+	}
+}
+
+// applyHunk stages the hunk of a patch, or unstages it if reverse is set,
+// and calls done once the index is updated
+func applyHunk(patch string, reverse bool, done func()) {
+	args := []string{"apply", "--cached", "--whitespace=nowarn"}
+	message := "Staged hunk"
+	if reverse {
+		args = append(args, "--reverse")
+		message = "Unstaged hunk"
+	}
+	args = append(args, "-")
+	gitRun(patch, args, func(out string, err error) {
+		git.refresh()
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		ui.output.message(message)
+		done()
+	})
+}
+
+// commitDialog is the open commit dialog
+type commitDialog struct {
+	editor *editor.Editor // message editor, nil while no dialog is open
+	submit func()         // commits with the message
+}
+
+var commit commitDialog
+
+// focused reports whether the commit dialog has the focus, so the save key
+// commits instead
+func (c *commitDialog) focused() bool {
+	return c.editor != nil && ui.app.GetFocus() == c.editor
+}
+
+// showCommitEditor opens a dialog to write the message of a new commit, or
+// to edit the last one when amending
+func showCommitEditor(amend bool) {
+	if git.root == "" {
+		ui.output.message("Not a git repository")
+		return
+	}
+	message := ""
+	if amend {
+		out, err := gitCommand("log", "-1", "--format=%B").Output()
+		if err != nil {
+			notify(noticeError, "Error running git log: there is no commit to amend")
+			return
+		}
+		message = strings.TrimRight(string(out), "\n")
+	}
+	input := editor.NewEditor().SetColors(editorColors(theme)).SetText(message + "\n" + commitHelp).SetLineNumbers(false)
+	title := "Commit"
+	if amend {
+		title = "Amend Commit"
+	}
+	status := tview.NewTextView().SetDynamicColors(true).
+		SetText(colorTag(theme.Muted) + "Ctrl+S: " + strings.ToLower(title) + "   Esc: cancel[-]")
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 0, 1, true).
+		AddItem(status, 1, 0, false)
+	layout.SetBorder(true).SetTitle(title)
+
+	closeEditor := func() {
+		commit.editor, commit.submit = nil, nil
+		closeDialog()
+	}
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			closeEditor()
+			return nil
+		}
+		return event
+	})
+	commit.editor = input
+	commit.submit = func() {
+		args := []string{"commit", "--cleanup=strip", "-F", "-"}
+		if amend {
+			args = append(args, "--amend")
+		}
+		status.SetText(colorTag(theme.Accent) + "Committing…[-]")
+		gitRun(input.GetText(), args, func(out string, err error) {
+			git.refresh()
+			if err != nil {
+				status.SetText(colorTag(theme.Error) + tview.Escape(strings.SplitN(err.Error(), "\n", 2)[0]) + "[-]")
+				notify(noticeError, fmt.Sprintf("Error running %s", err))
+				return
+			}
+			closeEditor()
+			ui.output.message(strings.TrimSpace(out))
+		})
+	}
+	showDialog(layout, 76, 16)
+}
+
+// showCommit opens a commit, its message followed by its changes, in the
+// diff view
+func showCommit(sha string) {
+	format := "--format=commit %H%nAuthor: %an <%ae>%nDate:   %ad%n%n%w(0,4,4)%B"
+	args := []string{"show", "--no-color", "--no-ext-diff", "-M", format, sha}
+	gitRun("", args, func(out string, err error) {
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		message, changes := out, ""
+		if i := strings.Index(out, "\ndiff --git "); i >= 0 {
+			message, changes = out[:i], out[i+1:]
+		}
+		var rows []diffRow
+		for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+			rows = append(rows, diffRow{kind: diffNote, text: []rune(line)})
+		}
+		rows = append(rows, diffRow{kind: diffNote})
+		rows = append(rows, parseDiff(changes, func(repoPath string) string {
+			return workspacePath(filepath.Join(git.root, filepath.FromSlash(repoPath)))
+		})...)
+		openDiffView(fmt.Sprintf("Commit %.7s", sha), rows, diffNoStaging, nil)
+	})
+}
@@ -0,0 +1,179 @@
+package app
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool // the pattern contains a slash and matches relative to its directory
+	re       *regexp.Regexp
+}
+
+// gitignore matches workspace paths against the .gitignore files found in
+// the tree. Rules are loaded lazily per directory and cached.
+type gitignore struct {
+	root string
+
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // keyed by slash-separated directory, "" for the root
+}
+
+// newGitignore returns a matcher for the tree rooted at root
+func newGitignore(root string) *gitignore {
+	return &gitignore{root: root, rules: make(map[string][]ignoreRule)}
+}
+
+// dirRules returns the rules of the .gitignore in dir, loading it on first use
+func (g *gitignore) dirRules(dir string) []ignoreRule {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if rules, ok := g.rules[dir]; ok {
+		return rules
+	}
+	rules := parseGitignore(filepath.Join(g.root, filepath.FromSlash(dir), ".gitignore"))
+	g.rules[dir] = rules
+	return rules
+}
+
+// parseGitignore reads the rules of one .gitignore file; a missing or
+// unreadable file yields no rules
+func parseGitignore(file string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		re, err := regexp.Compile("^" + globToRegexp(line) + "$")
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// globToRegexp translates a gitignore glob into a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case glob[i:] == "/**":
+			// Everything inside, but not the directory itself
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**"):
+			b.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// matchRules applies the rules of one .gitignore to a path relative to its
+// directory and returns whether it decided, and if so whether it ignores
+func matchRules(rules []ignoreRule, rel string, isDir bool) (decided, ignored bool) {
+	name := path.Base(rel)
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		subject := name
+		if rule.anchored {
+			subject = rel
+		}
+		if rule.re.MatchString(subject) {
+			decided, ignored = true, !rule.negate
+		}
+	}
+	return decided, ignored
+}
+
+// ignored reports whether a slash-separated path relative to the root is
+// excluded, either directly or because one of its parent directories is
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	rel = path.Clean(rel)
+	if rel == "." || rel == "" {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		if g.ignoredEntry(parts[:i+1], isDir || i < len(parts)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredEntry checks a single path against the .gitignore files of all of
+// its ancestors, where the deepest file has the final say
+func (g *gitignore) ignoredEntry(parts []string, isDir bool) bool {
+	if parts[len(parts)-1] == ".git" {
+		return true
+	}
+	ignored := false
+	for depth := 0; depth < len(parts); depth++ {
+		dir := strings.Join(parts[:depth], "/")
+		rel := strings.Join(parts[depth:], "/")
+		if decided, result := matchRules(g.dirRules(dir), rel, isDir); decided {
+			ignored = result
+		}
+	}
+	return ignored
+}
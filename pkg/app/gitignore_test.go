@@ -0,0 +1,57 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"?.txt", "a.txt", true},
+		{"[ab].txt", "c.txt", false},
+		{"[!ab].txt", "c.txt", true},
+		{"**/build", "build", true},
+		{"**/build", "a/b/build", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"build/**", "build/out", true},
+		{"build/**", "build/out/main.o", true},
+		{"build/**", "build", false},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile("^" + globToRegexp(tt.glob) + "$")
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("%q matches %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestGitignoreTrailingDoubleStar(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("build/**\n!build/keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g := newGitignore(root)
+	tests := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"build", true, false},
+		{"build/out", false, true},
+		{"build/keep", false, false},
+	}
+	for _, tt := range tests {
+		if got := g.ignored(tt.rel, tt.isDir); got != tt.ignored {
+			t.Errorf("ignored(%q) = %v, want %v", tt.rel, got, tt.ignored)
+		}
+	}
+}
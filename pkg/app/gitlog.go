@@ -0,0 +1,239 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// logLimit is the number of commits the log viewer reads
+const logLimit = 1000
+
+// gitBranch is a local branch
+type gitBranch struct {
+	name    string
+	current bool
+	age     string // of its last commit
+	subject string
+}
+
+// readBranches returns the local branches, sorted by name
+func readBranches(done func(branches []gitBranch, err error)) {
+	format := "--format=%(HEAD)%09%(refname:short)%09%(committerdate:relative)%09%(subject)"
+	gitRun("", []string{"for-each-ref", format, "refs/heads"}, func(out string, err error) {
+		var branches []gitBranch
+		for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+			fields := strings.SplitN(line, "\t", 4)
+			if len(fields) < 4 {
+				continue
+			}
+			branches = append(branches, gitBranch{
+				name:    fields[1],
+				current: fields[0] == "*",
+				age:     fields[2],
+				subject: fields[3],
+			})
+		}
+		done(branches, err)
+	})
+}
+
+// showBranches opens the branch picker: Enter checks out the selected
+// branch, n creates one from HEAD, and d deletes the selected one
+func showBranches() {
+	if git.root == "" {
+		ui.output.message("Not a git repository")
+		return
+	}
+	readBranches(func(branches []gitBranch, err error) {
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		labels := make([]string, len(branches))
+		current := 0
+		for i, branch := range branches {
+			marker := "  "
+			if branch.current {
+				marker = colorTag(theme.Success) + "*[-] "
+				current = i
+			}
+			labels[i] = fmt.Sprintf("%s%s %s%s · %s[-]", marker, tview.Escape(branch.name), colorTag(theme.Muted),
+				tview.Escape(branch.age), tview.Escape(branch.subject))
+		}
+		title := "Branches  [Enter: checkout, n: new, d: delete, Esc: close]"
+		list := showPicker(title, labels, current, func(index int) {
+			if branch := branches[index]; !branch.current {
+				runGit("Switched to "+branch.name, "", "checkout", branch.name)
+			}
+		})
+		selected := func() (gitBranch, bool) {
+			index := list.GetCurrentItem()
+			if index < 0 || index >= len(branches) {
+				return gitBranch{}, false
+			}
+			return branches[index], true
+		}
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() != tcell.KeyRune {
+				return event
+			}
+			switch event.Rune() {
+			case 'n':
+				closeDialog()
+				showPrompt("New branch: ", "", func(name string) {
+					if name = strings.TrimSpace(name); name != "" {
+						runGit("Created and switched to "+name, "", "checkout", "-b", name)
+					}
+				})
+			case 'd':
+				branch, ok := selected()
+				if !ok {
+					return nil
+				}
+				if branch.current {
+					ui.output.message("The current branch cannot be deleted")
+					return nil
+				}
+				closeDialog()
+				confirmAction(fmt.Sprintf("Delete branch %s?", branch.name), "Delete", func() {
+					deleteBranch(branch.name)
+				})
+			default:
+				return event
+			}
+			return nil
+		})
+	})
+}
+
+// deleteBranch deletes a local branch, asking again before deleting one
+// whose commits are not merged
+func deleteBranch(name string) {
+	gitRun("", []string{"branch", "-d", name}, func(out string, err error) {
+		git.refresh()
+		if err == nil {
+			ui.output.message("Deleted branch " + name)
+			return
+		}
+		if !strings.Contains(err.Error(), "not fully merged") {
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		confirmAction(fmt.Sprintf("%s is not fully merged. Delete it anyway?", name), "Delete", func() {
+			runGit("Deleted branch "+name, "", "branch", "-D", name)
+		})
+	})
+}
+
+// logEntry is one line of the log graph; connecting lines have no commit
+type logEntry struct {
+	graph   string
+	sha     string
+	author  string
+	age     string
+	subject string
+	refs    string
+}
+
+// parseLog parses git log --graph output whose commit lines carry fields
+// separated by the unit separator
+func parseLog(out string) []logEntry {
+	var entries []logEntry
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.Split(line, "\x1f")
+		entry := logEntry{graph: strings.TrimRight(fields[0], " ")}
+		if len(fields) == 6 {
+			entry.sha, entry.author, entry.age, entry.subject = fields[1], fields[2], fields[3], fields[4]
+			entry.refs = strings.TrimSpace(fields[5])
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// showLog opens the commit log of all branches over the whole screen.
+// Enter or d shows the selected commit, and c checks it out.
+func showLog() {
+	if git.root == "" {
+		ui.output.message("Not a git repository")
+		return
+	}
+	format := "--format=\x1f%H\x1f%an\x1f%ar\x1f%s\x1f%d"
+	args := []string{"log", "--graph", "--all", "--no-color", fmt.Sprintf("-n%d", logLimit), format}
+	gitRun("", args, func(out string, err error) {
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error running %s", err))
+			return
+		}
+		entries := parseLog(out)
+		table := tview.NewTable().SetSelectable(true, false)
+		for row, entry := range entries {
+			table.SetCell(row, 0, tview.NewTableCell(entry.graph).SetTextColor(theme.Secondary))
+			if entry.sha == "" {
+				continue
+			}
+			subject := tview.Escape(entry.subject)
+			if entry.refs != "" {
+				subject = colorTag(theme.Accent) + tview.Escape(entry.refs) + "[-] " + subject
+			}
+			table.SetCell(row, 1, tview.NewTableCell(entry.sha[:7]).SetTextColor(theme.Accent))
+			table.SetCell(row, 2, tview.NewTableCell(subject).SetExpansion(1).SetMaxWidth(80))
+			table.SetCell(row, 3, tview.NewTableCell(tview.Escape(entry.author)).SetTextColor(theme.Muted))
+			table.SetCell(row, 4, tview.NewTableCell(entry.age).SetTextColor(theme.Muted).SetAlign(tview.AlignRight))
+		}
+		commitAt := func(row int) (logEntry, bool) {
+			if row < 0 || row >= len(entries) || entries[row].sha == "" {
+				return logEntry{}, false
+			}
+			return entries[row], true
+		}
+		table.SetSelectedFunc(func(row, column int) {
+			if entry, ok := commitAt(row); ok {
+				showCommit(entry.sha)
+			}
+		})
+		table.SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEscape {
+				closeDialog()
+			}
+		})
+		table.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() != tcell.KeyRune {
+				return event
+			}
+			row, _ := table.GetSelection()
+			entry, ok := commitAt(row)
+			switch event.Rune() {
+			case 'd':
+				if ok {
+					showCommit(entry.sha)
+				}
+			case 'c':
+				if ok {
+					question := fmt.Sprintf("Check out %.7s %s? This detaches HEAD from its branch.", entry.sha, entry.subject)
+					confirmAction(question, "Check Out", func() {
+						closeDialog()
+						runGit(fmt.Sprintf("Checked out %.7s", entry.sha), "", "checkout", "--detach", entry.sha)
+					})
+				}
+			case 'q':
+				closeDialog()
+			default:
+				return event
+			}
+			return nil
+		})
+		// Start on the first commit rather than a connecting line
+		for row := range entries {
+			if _, ok := commitAt(row); ok {
+				table.Select(row, 0)
+				break
+			}
+		}
+		table.SetBorder(true).SetTitle("Log  [Enter/d: show commit, c: check out, Esc: close]")
+		showDialog(table, 0, 0)
+	})
+}
@@ -0,0 +1,522 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gotui/pkg/editor"
+)
+
+// Language server states
+const (
+	lspStopped = iota
+	lspStarting
+	lspReady
+	lspUnavailable
+)
+
+// languageServer connects the editor to a gopls process for the workspace
+type languageServer struct {
+	client   *lspClient
+	state    int
+	versions map[string]int // open documents and their versions, by path
+}
+
+var gopls = languageServer{versions: make(map[string]int)}
+
+// isGoFile reports whether path is handled by gopls
+func isGoFile(path string) bool {
+	return filepath.Ext(path) == ".go"
+}
+
+// ensureStarted launches gopls the first time a Go file is opened
+func (s *languageServer) ensureStarted() {
+	if s.state != lspStopped {
+		return
+	}
+	if _, err := exec.LookPath("gopls"); err != nil {
+		s.state = lspUnavailable
+		ui.output.message("gopls not found in PATH; Go language features are disabled")
+		return
+	}
+	client, err := startLSPClient(workspaceRoot, s.handleNotification, "gopls")
+	if err != nil {
+		s.state = lspUnavailable
+		notify(noticeError, fmt.Sprintf("Error starting gopls: %s", err))
+		return
+	}
+	s.client = client
+	s.state = lspStarting
+
+	var folders []map[string]string
+	for _, root := range workspaceRoots() {
+		folders = append(folders, lspWorkspaceFolder(root))
+	}
+	params := map[string]interface{}{
+		"processId":        os.Getpid(),
+		"rootUri":          pathToURI(workspaceRoot),
+		"workspaceFolders": folders,
+		"capabilities": map[string]interface{}{
+			"workspace": map[string]interface{}{"workspaceFolders": true},
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{"didSave": true},
+				"completion":         map[string]interface{}{"completionItem": map[string]interface{}{"snippetSupport": false}},
+				"hover":              map[string]interface{}{"contentFormat": []string{"plaintext"}},
+				"definition":         map[string]interface{}{"linkSupport": false},
+				"publishDiagnostics": map[string]interface{}{},
+			},
+		},
+	}
+	client.call("initialize", params, func(result json.RawMessage, err error) {
+		ui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				s.state = lspUnavailable
+				notify(noticeError, fmt.Sprintf("Error initializing gopls: %s", err))
+				return
+			}
+			client.notify("initialized", struct{}{})
+			s.state = lspReady
+			for _, buf := range buffers.buffers {
+				s.didOpen(buf)
+			}
+		})
+	})
+}
+
+// lspWorkspaceFolder returns the LSP description of a workspace root
+func lspWorkspaceFolder(root string) map[string]string {
+	return map[string]string{"uri": pathToURI(root), "name": filepath.Base(absPath(root))}
+}
+
+// foldersChanged tells gopls about folders added to or removed from the
+// workspace, so it loads their packages too. Before it is ready, the folders
+// are sent when it starts.
+func (s *languageServer) foldersChanged(added, removed []string) {
+	if s.state != lspReady {
+		return
+	}
+	event := map[string][]map[string]string{"added": {}, "removed": {}}
+	for _, folder := range added {
+		event["added"] = append(event["added"], lspWorkspaceFolder(folder))
+	}
+	for _, folder := range removed {
+		event["removed"] = append(event["removed"], lspWorkspaceFolder(folder))
+	}
+	s.client.notify("workspace/didChangeWorkspaceFolders", map[string]interface{}{"event": event})
+}
+
+// shutdown stops gopls if it is running
+func (s *languageServer) shutdown() {
+	if s.client != nil {
+		s.client.close()
+		s.client = nil
+	}
+	s.state = lspStopped
+}
+
+// handleNotification processes notifications from gopls. It runs on the
+// client's reader goroutine, so UI work is queued onto the event loop.
+func (s *languageServer) handleNotification(method string, params json.RawMessage) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var report struct {
+			URI         string          `json:"uri"`
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(params, &report); err != nil {
+			return
+		}
+		ui.app.QueueUpdateDraw(func() {
+			path := uriToPath(report.URI)
+			problems.set("gopls", path, convertDiagnostics(path, report.Diagnostics))
+		})
+	case "window/showMessage":
+		var message struct {
+			Type    int    `json:"type"`
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(params, &message); err != nil || message.Type > 2 {
+			return
+		}
+		ui.app.QueueUpdateDraw(func() {
+			ui.output.message(fmt.Sprintf("gopls: %s", message.Message))
+		})
+	}
+}
+
+// textDocument returns the document identifier for a buffer
+func textDocument(buf *Buffer) map[string]interface{} {
+	return map[string]interface{}{"uri": pathToURI(buf.Path())}
+}
+
+// toLSPPosition converts a position in lines into an LSP position
+func toLSPPosition(lines [][]rune, pos editor.Position) lspPosition {
+	if pos.Line >= len(lines) {
+		return lspPosition{Line: pos.Line}
+	}
+	return lspPosition{Line: pos.Line, Character: utf16Column(lines[pos.Line], pos.Col)}
+}
+
+// fromLSPPosition converts an LSP position into a position in lines
+func fromLSPPosition(lines [][]rune, pos lspPosition) editor.Position {
+	if pos.Line >= len(lines) {
+		return editor.Position{Line: pos.Line, Col: pos.Character}
+	}
+	return editor.Position{Line: pos.Line, Col: runeColumn(lines[pos.Line], pos.Character)}
+}
+
+// positionParams builds the parameters of a request about the cursor
+func positionParams(buf *Buffer) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": textDocument(buf),
+		"position":     toLSPPosition(buf.Lines(), buf.Cursor()),
+	}
+}
+
+// didOpen tells gopls about a newly opened Go buffer
+func (s *languageServer) didOpen(buf *Buffer) {
+	if !isGoFile(buf.Path()) || buf.onDisk() {
+		return
+	}
+	s.ensureStarted()
+	if s.state != lspReady {
+		return
+	}
+	if _, ok := s.versions[buf.Path()]; ok {
+		return
+	}
+	s.versions[buf.Path()] = 1
+	s.client.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        pathToURI(buf.Path()),
+			"languageId": "go",
+			"version":    1,
+			"text":       buf.Text(),
+		},
+	})
+}
+
+// didChange sends the full new content of a modified buffer
+func (s *languageServer) didChange(buf *Buffer) {
+	version, ok := s.versions[buf.Path()]
+	if !ok || s.state != lspReady {
+		return
+	}
+	version++
+	s.versions[buf.Path()] = version
+	s.client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": pathToURI(buf.Path()), "version": version},
+		"contentChanges": []map[string]string{{"text": buf.Text()}},
+	})
+}
+
+// didSave notifies gopls that a buffer was written to disk
+func (s *languageServer) didSave(buf *Buffer) {
+	if _, ok := s.versions[buf.Path()]; ok && s.state == lspReady {
+		s.client.notify("textDocument/didSave", map[string]interface{}{"textDocument": textDocument(buf)})
+	}
+}
+
+// didClose notifies gopls that a buffer was closed
+func (s *languageServer) didClose(buf *Buffer) {
+	if _, ok := s.versions[buf.Path()]; !ok || s.state != lspReady {
+		return
+	}
+	delete(s.versions, buf.Path())
+	s.client.notify("textDocument/didClose", map[string]interface{}{"textDocument": textDocument(buf)})
+}
+
+// request sends a request about the cursor position of the active buffer,
+// with any extra parameters, and runs handler on the event loop provided the
+// buffer is still active
+func (s *languageServer) request(method string, extra map[string]interface{}, handler func(buf *Buffer, result json.RawMessage)) {
+	buf := buffers.current()
+	if buf == nil || !s.serves(buf) {
+		return
+	}
+	params := positionParams(buf)
+	for key, value := range extra {
+		params[key] = value
+	}
+	s.client.call(method, params, func(result json.RawMessage, err error) {
+		ui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				ui.output.message(fmt.Sprintf("gopls: %s", err))
+				return
+			}
+			if buffers.current() == buf {
+				handler(buf, result)
+			}
+		})
+	})
+}
+
+// serves reports whether the server is ready and has the buffer open
+func (s *languageServer) serves(buf *Buffer) bool {
+	_, ok := s.versions[buf.Path()]
+	return ok && s.state == lspReady
+}
+
+// complete requests completions at the cursor and shows them in a popup,
+// followed by the snippets of the file. Files the server does not know get
+// the snippets alone.
+func (s *languageServer) complete() {
+	if buf := buffers.current(); buf != nil && !s.serves(buf) {
+		from, _ := ui.editor.WordBounds(buf.Cursor())
+		ui.editor.ShowCompletions(from, snippetCompletions(buf))
+		return
+	}
+	s.request("textDocument/completion", nil, func(buf *Buffer, result json.RawMessage) {
+		var list lspCompletionList
+		if err := json.Unmarshal(result, &list); err != nil {
+			_ = json.Unmarshal(result, &list.Items)
+		}
+		from, _ := ui.editor.WordBounds(buf.Cursor())
+		items := make([]editor.CompletionItem, 0, len(list.Items))
+		for _, item := range list.Items {
+			insert := item.InsertText
+			if item.TextEdit != nil {
+				insert = item.TextEdit.NewText
+				from = fromLSPPosition(buf.Lines(), item.TextEdit.Range.Start)
+			}
+			items = append(items, editor.CompletionItem{
+				Label:  item.Label,
+				Detail: item.Detail,
+				Filter: item.FilterText,
+				Insert: insert,
+			})
+		}
+		ui.editor.ShowCompletions(from, append(items, snippetCompletions(buf)...))
+	})
+}
+
+// hover shows the documentation of the symbol at the cursor, together with
+// any diagnostics reported there
+func (s *languageServer) hover() {
+	buf := buffers.current()
+	if buf == nil {
+		return
+	}
+	var notes []string
+	for _, diagnostic := range problems.forPath(buf.Path()) {
+		if buf.Cursor().Line >= diagnostic.Range.From.Line && buf.Cursor().Line <= diagnostic.Range.To.Line {
+			notes = append(notes, fmt.Sprintf("%s: %s", severityName(diagnostic.Severity), diagnostic.Message))
+		}
+	}
+	if len(notes) > 0 {
+		ui.editor.ShowInfo(strings.Join(notes, "\n"))
+	}
+
+	s.request("textDocument/hover", nil, func(buf *Buffer, result json.RawMessage) {
+		var hover lspHover
+		if err := json.Unmarshal(result, &hover); err != nil || hover.Contents.Value == "" {
+			return
+		}
+		text := strings.TrimSpace(hover.Contents.Value)
+		if len(notes) > 0 {
+			text = strings.Join(notes, "\n") + "\n\n" + text
+		}
+		ui.editor.ShowInfo(text)
+	})
+}
+
+// definition jumps to the declaration of the symbol at the cursor
+func (s *languageServer) definition() {
+	s.request("textDocument/definition", nil, func(buf *Buffer, result json.RawMessage) {
+		locations := parseLocations(result)
+		if len(locations) == 0 {
+			ui.output.message("No definition found")
+			return
+		}
+		if err := gotoLocation(locations[0]); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	})
+}
+
+// references lists every use of the symbol at the cursor, jumping straight
+// to it when there is only one
+func (s *languageServer) references() {
+	extra := map[string]interface{}{"context": map[string]bool{"includeDeclaration": true}}
+	s.request("textDocument/references", extra, func(buf *Buffer, result json.RawMessage) {
+		from, to := ui.editor.WordBounds(buf.Cursor())
+		symbol := ui.editor.TextRange(from, to)
+		locations := parseLocations(result)
+		switch len(locations) {
+		case 0:
+			ui.output.message(fmt.Sprintf("No references to %s found", symbol))
+		case 1:
+			if err := gotoLocation(locations[0]); err != nil {
+				notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+			}
+		default:
+			references.show(symbol, locations)
+		}
+	})
+}
+
+// rename asks for a new name for the identifier at the cursor and applies
+// the resulting edits across the workspace
+func (s *languageServer) rename() {
+	buf := buffers.current()
+	if buf == nil || s.state != lspReady {
+		return
+	}
+	from, to := ui.editor.WordBounds(buf.Cursor())
+	symbol := ui.editor.TextRange(from, to)
+	if symbol == "" {
+		ui.output.message("No identifier under the cursor")
+		return
+	}
+	showPrompt("Rename "+symbol+" to: ", symbol, func(name string) {
+		if name == "" || name == symbol {
+			return
+		}
+		s.request("textDocument/rename", map[string]interface{}{"newName": name}, func(buf *Buffer, result json.RawMessage) {
+			var edit lspWorkspaceEdit
+			if err := json.Unmarshal(result, &edit); err != nil {
+				notify(noticeError, fmt.Sprintf("Error renaming %s: %s", symbol, err))
+				return
+			}
+			files, err := s.applyWorkspaceEdit(edit)
+			if err != nil {
+				notify(noticeError, fmt.Sprintf("Error renaming %s: %s", symbol, err))
+				return
+			}
+			ui.output.message(fmt.Sprintf("Renamed %s to %s in %d files", symbol, name, files))
+		})
+	})
+}
+
+// applyWorkspaceEdit applies the edits of a workspace edit to their buffers,
+// opening files as needed, and returns the number of files changed. Each
+// file's edits form a single undo step and leave its buffer unsaved.
+func (s *languageServer) applyWorkspaceEdit(edit lspWorkspaceEdit) (int, error) {
+	type fileEdits struct {
+		path    string
+		version *int
+		edits   []lspTextEdit
+	}
+	var files []fileEdits
+	for _, change := range edit.DocumentChanges {
+		files = append(files, fileEdits{uriToPath(change.TextDocument.URI), change.TextDocument.Version, change.Edits})
+	}
+	if len(files) == 0 {
+		for uri, edits := range edit.Changes {
+			files = append(files, fileEdits{path: uriToPath(uri), edits: edits})
+		}
+	}
+	// Check every version first so a stale edit is not half applied
+	for _, file := range files {
+		if version, ok := s.versions[file.path]; ok && file.version != nil && *file.version != version {
+			return 0, fmt.Errorf("%s changed while the edit was computed", file.path)
+		}
+	}
+
+	if active := buffers.current(); active != nil {
+		defer func() {
+			buffers.switchTo(buffers.find(active.Path()))
+		}()
+	}
+	for _, file := range files {
+		if err := buffers.open(file.path); err != nil {
+			return 0, err
+		}
+		buf := buffers.current()
+		ranges := make([]editor.Range, len(file.edits))
+		for i, edit := range file.edits {
+			ranges[i] = editor.Range{From: fromLSPPosition(buf.Lines(), edit.Range.Start), To: fromLSPPosition(buf.Lines(), edit.Range.End)}
+		}
+		order := make([]int, len(file.edits))
+		for i := range order {
+			order[i] = i
+		}
+		// Edits are applied from the end of the file so earlier ranges stay valid
+		sort.SliceStable(order, func(i, j int) bool {
+			return ranges[order[j]].From.Less(ranges[order[i]].From)
+		})
+		ui.editor.Transaction(func() {
+			for _, i := range order {
+				ui.editor.Replace(ranges[i].From, ranges[i].To, file.edits[i].NewText)
+			}
+		})
+	}
+	return len(files), nil
+}
+
+// parseLocations decodes a result holding a Location, a list of them, or a
+// list of LocationLinks
+func parseLocations(result json.RawMessage) []lspLocation {
+	var locations []lspLocation
+	if err := json.Unmarshal(result, &locations); err != nil {
+		var location lspLocation
+		if err := json.Unmarshal(result, &location); err == nil && location.URI != "" {
+			locations = []lspLocation{location}
+		}
+	}
+	if len(locations) > 0 && locations[0].URI == "" {
+		var links []struct {
+			TargetURI            string   `json:"targetUri"`
+			TargetSelectionRange lspRange `json:"targetSelectionRange"`
+		}
+		locations = nil
+		if err := json.Unmarshal(result, &links); err == nil {
+			for _, link := range links {
+				locations = append(locations, lspLocation{URI: link.TargetURI, Range: link.TargetSelectionRange})
+			}
+		}
+	}
+	return locations
+}
+
+// gotoLocation opens the file of an LSP location and moves the cursor there,
+// recording the previous location in the jump list
+func gotoLocation(location lspLocation) error {
+	jumps.push()
+	path := uriToPath(location.URI)
+	if err := loadFile(path); err != nil {
+		return err
+	}
+	buf := buffers.current()
+	pos := fromLSPPosition(buf.Lines(), location.Range.Start)
+	ui.editor.SetCursor(pos)
+	ui.app.SetFocus(ui.editor)
+	return nil
+}
+
+// convertDiagnostics converts the diagnostics gopls published for a file,
+// mapping UTF-16 columns to rune columns when the file is open
+func convertDiagnostics(path string, reported []lspDiagnostic) []Diagnostic {
+	buf := &Buffer{}
+	if index := buffers.find(path); index >= 0 {
+		buf = buffers.buffers[index]
+	}
+	diagnostics := make([]Diagnostic, 0, len(reported))
+	for _, diagnostic := range reported {
+		source := diagnostic.Source
+		if source == "" {
+			source = "gopls"
+		}
+		severity := diagnostic.Severity
+		if severity == 0 {
+			severity = SeverityError
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Path: path,
+			Range: editor.Range{
+				From: fromLSPPosition(buf.Lines(), diagnostic.Range.Start),
+				To:   fromLSPPosition(buf.Lines(), diagnostic.Range.End),
+			},
+			Severity: severity,
+			Source:   source,
+			Message:  diagnostic.Message,
+		})
+	}
+	sortDiagnostics(diagnostics)
+	return diagnostics
+}
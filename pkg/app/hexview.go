@@ -0,0 +1,414 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	binarySniffSize = 8000    // bytes looked at to tell binary files from text
+	hexSearchChunk  = 1 << 20 // bytes searched at a time
+)
+
+// hexFile is a binary file shown in the hex viewer. Like a large file, it
+// is read from disk as it comes into view.
+type hexFile struct {
+	path string
+	file *os.File
+	size int64
+
+	cursor   int64 // offset of the byte under the cursor
+	top      int64 // offset of the first visible byte
+	match    int   // length of the match at the cursor, 0 if none
+	pattern  []byte
+	searches int // number of searches started, to drop the results of earlier ones
+}
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "binary", Order: 5, Text: editorInfo(func(buf *Buffer) string {
+		if buf.hex == nil {
+			return ""
+		}
+		return fmt.Sprintf("Binary, %d bytes", buf.hex.size)
+	})})
+}
+
+// isBinaryFile reports whether the file at path holds binary data, going by
+// the NUL bytes at its start that text has none of, except for UTF-16
+func isBinaryFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	sample := make([]byte, binarySniffSize)
+	n, _ := io.ReadFull(file, sample)
+	sample = sample[:n]
+	if bytes.IndexByte(sample, 0) < 0 {
+		return false
+	}
+	switch detectEncoding(sample[:n&^1]).name {
+	case encodingUTF16LE.name, encodingUTF16BE.name:
+		return false
+	}
+	return true
+}
+
+// NewHexBuffer returns a read-only buffer showing the file at path in the
+// hex viewer
+func NewHexBuffer(path string) (*Buffer, error) {
+	b := NewBuffer("", "")
+	b.SetPath(path)
+	b.hex = &hexFile{path: path}
+	if err := b.hex.load(); err != nil {
+		return nil, err
+	}
+	b.modTime = fileModTime(path)
+	return b, nil
+}
+
+// load opens the file again, keeping the cursor where it was as far as
+// possible
+func (f *hexFile) load() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	f.close()
+	f.file, f.size = file, info.Size()
+	f.moveTo(f.cursor, 0, 0)
+	return nil
+}
+
+// close closes the file and drops the results of running searches
+func (f *hexFile) close() {
+	f.searches++
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// moveTo puts the cursor on a byte, keeping its row among the visible rows.
+// Rows are perRow bytes long and height rows are visible; with no rows
+// known yet, the visible bytes stay as they are.
+func (f *hexFile) moveTo(offset int64, perRow, height int) {
+	if offset >= f.size {
+		offset = f.size - 1
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	f.cursor = offset
+	if perRow <= 0 || height <= 0 {
+		return
+	}
+	row := int64(perRow)
+	if f.top -= f.top % row; f.top < 0 {
+		f.top = 0
+	}
+	if f.cursor < f.top {
+		f.top = f.cursor - f.cursor%row
+	}
+	if last := f.top + row*int64(height); f.cursor >= last {
+		f.top = f.cursor - f.cursor%row - row*int64(height-1)
+	}
+}
+
+// parseBytePattern reads what to search for: hex digits, which may be
+// separated by spaces, or text in double quotes
+func parseBytePattern(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		unquoted, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid text %s: %w", text, err)
+		}
+		return []byte(unquoted), nil
+	}
+	digits := strings.Join(strings.Fields(strings.TrimPrefix(text, "0x")), "")
+	pattern, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex bytes %s", text)
+	}
+	return pattern, nil
+}
+
+// search looks for the pattern after the cursor in the background, going
+// around to the start of the file, and moves the cursor to the next match
+func (f *hexFile) search(pattern []byte, view *HexView) {
+	if len(pattern) == 0 || f.file == nil {
+		return
+	}
+	f.pattern = pattern
+	f.searches++
+	searches, file, size, from := f.searches, f.file, f.size, f.cursor+1
+	ui.output.message("Searching…")
+	go func() {
+		defer recoverPanic()
+		found := int64(-1)
+		if found = searchBytes(file, pattern, from, size); found < 0 {
+			found = searchBytes(file, pattern, 0, from+int64(len(pattern))-1)
+		}
+		ui.app.QueueUpdateDraw(func() {
+			if f.searches != searches {
+				return
+			}
+			if found < 0 {
+				ui.output.message(fmt.Sprintf("Bytes not found: % x", pattern))
+				return
+			}
+			view.moveTo(found)
+			f.match = len(pattern)
+			ui.output.message(fmt.Sprintf("Found % x at offset 0x%X", pattern, found))
+		})
+	}()
+}
+
+// searchBytes returns the offset of the first match of pattern in file that
+// lies between from and to, or -1 if there is none
+func searchBytes(file *os.File, pattern []byte, from, to int64) int64 {
+	chunk := make([]byte, hexSearchChunk+len(pattern)-1)
+	for offset := from; offset < to; offset += hexSearchChunk {
+		n, err := file.ReadAt(chunk, offset)
+		if offset+int64(n) > to {
+			n = int(to - offset)
+		}
+		if i := bytes.Index(chunk[:n], pattern); i >= 0 {
+			return offset + int64(i)
+		}
+		if err != nil {
+			break
+		}
+	}
+	return -1
+}
+
+// promptHexSearch asks for bytes to search the binary file in the editor for
+func promptHexSearch() {
+	buf := buffers.current()
+	if buf == nil || buf.hex == nil {
+		return
+	}
+	previous := ""
+	if buf.hex.pattern != nil {
+		previous = fmt.Sprintf("% x", buf.hex.pattern)
+	}
+	showPrompt(`Find bytes (hex, or "text"): `, previous, func(text string) {
+		pattern, err := parseBytePattern(text)
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error: %s", err))
+			return
+		}
+		buf.hex.search(pattern, ui.hexView)
+	})
+}
+
+// promptGoToOffset asks for an offset in the binary file in the editor,
+// decimal or hex with 0x, and moves the cursor there
+func promptGoToOffset() {
+	buf := buffers.current()
+	label := fmt.Sprintf("Go to offset (0-0x%X): ", buf.hex.size)
+	showPrompt(label, "", func(text string) {
+		offset, err := strconv.ParseInt(strings.TrimSpace(text), 0, 64)
+		if err != nil || offset < 0 {
+			ui.output.message(fmt.Sprintf("Invalid offset: %s", text))
+			return
+		}
+		ui.hexView.moveTo(offset)
+		ui.app.SetFocus(ui.hexView)
+	})
+}
+
+// HexView shows a binary file in place of the editor, as rows of offsets,
+// hex bytes, and the bytes as ASCII
+type HexView struct {
+	*tview.Box
+
+	file   *hexFile
+	perRow int // bytes per row at the last draw
+	height int // rows shown at the last draw
+
+	offsetStyle tcell.Style
+	textStyle   tcell.Style
+	cursorStyle tcell.Style
+	matchStyle  tcell.Style
+}
+
+// NewHexView returns a view showing no file
+func NewHexView() *HexView {
+	v := &HexView{Box: tview.NewBox(), perRow: 16}
+	v.applyTheme(theme)
+	return v
+}
+
+// applyTheme sets the view's styles from a theme
+func (v *HexView) applyTheme(t *Theme) {
+	base := tcell.StyleDefault.Background(t.Background)
+	v.SetBackgroundColor(t.Background)
+	v.offsetStyle = base.Foreground(t.Muted)
+	v.textStyle = base.Foreground(t.Text)
+	v.cursorStyle = tcell.StyleDefault.Background(t.Text).Foreground(t.Background)
+	v.matchStyle = tcell.StyleDefault.Background(t.Accent).Foreground(t.AccentText)
+}
+
+// SetFile switches the view to another file
+func (v *HexView) SetFile(f *hexFile) *HexView {
+	v.file = f
+	return v
+}
+
+// moveTo moves the cursor to an offset, dropping the match shown there
+func (v *HexView) moveTo(offset int64) {
+	v.file.moveTo(offset, v.perRow, v.height)
+	v.file.match = 0
+}
+
+// Draw draws this primitive onto the screen
+func (v *HexView) Draw(screen tcell.Screen) {
+	v.Box.DrawForSubclass(screen, v)
+	x, y, width, height := v.GetInnerRect()
+	if width <= 0 || height <= 0 || v.file == nil || v.file.file == nil {
+		return
+	}
+	f := v.file
+	// Offset, hex bytes with a gap after each eight, and ASCII
+	v.perRow = 16
+	if width < 10+16*3+1+16 {
+		v.perRow = 8
+	}
+	v.height = height
+	f.moveTo(f.cursor, v.perRow, height)
+	data := make([]byte, v.perRow*height)
+	n, _ := f.file.ReadAt(data, f.top)
+	data = data[:n]
+	for row := 0; row*v.perRow < len(data); row++ {
+		offset := f.top + int64(row*v.perRow)
+		printText(screen, fmt.Sprintf("%08X", offset), x, y+row, width, v.offsetStyle)
+		hexX, asciiX := x+10, x+10+v.perRow*3+v.perRow/8
+		for i := 0; i < v.perRow && row*v.perRow+i < len(data); i++ {
+			b := data[row*v.perRow+i]
+			style := v.textStyle
+			switch at := offset + int64(i); {
+			case at == f.cursor:
+				style = v.cursorStyle
+			case at > f.cursor && at < f.cursor+int64(f.match):
+				style = v.matchStyle
+			}
+			cellX := hexX + i*3 + i/8
+			printText(screen, fmt.Sprintf("%02x", b), cellX, y+row, x+width-cellX, style)
+			char := '.'
+			if b >= 0x20 && b < 0x7F {
+				char = rune(b)
+			}
+			if asciiX+i < x+width {
+				screen.SetContent(asciiX+i, y+row, char, nil, style)
+			}
+		}
+	}
+}
+
+// InputHandler moves the cursor and searches
+func (v *HexView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return v.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		f := v.file
+		if f == nil {
+			return
+		}
+		row := int64(v.perRow)
+		page := row * int64(v.height)
+		ctrl := event.Modifiers()&tcell.ModCtrl != 0
+		switch event.Key() {
+		case tcell.KeyLeft:
+			v.moveTo(f.cursor - 1)
+		case tcell.KeyRight:
+			v.moveTo(f.cursor + 1)
+		case tcell.KeyUp:
+			v.moveTo(f.cursor - row)
+		case tcell.KeyDown:
+			v.moveTo(f.cursor + row)
+		case tcell.KeyPgUp:
+			f.top -= page
+			v.moveTo(f.cursor - page)
+		case tcell.KeyPgDn:
+			if f.top+page < f.size {
+				f.top += page
+			}
+			v.moveTo(f.cursor + page)
+		case tcell.KeyHome:
+			if ctrl {
+				v.moveTo(0)
+			} else {
+				v.moveTo(f.cursor - f.cursor%row)
+			}
+		case tcell.KeyEnd:
+			if ctrl {
+				v.moveTo(f.size - 1)
+			} else {
+				v.moveTo(f.cursor - f.cursor%row + row - 1)
+			}
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case '/':
+				promptHexSearch()
+			case 'n':
+				f.search(f.pattern, v)
+			}
+		}
+	})
+}
+
+// MouseHandler scrolls with the wheel and moves the cursor on clicks
+func (v *HexView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return v.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !v.InRect(event.Position()) || v.file == nil {
+			return false, nil
+		}
+		f := v.file
+		row := int64(v.perRow)
+		switch action {
+		case tview.MouseLeftClick:
+			setFocus(v)
+			x, y, _, _ := v.GetInnerRect()
+			mx, my := event.Position()
+			// Three columns a byte, and a gap after each eight
+			col := -1
+			if rest := mx - x - 10; rest >= 0 {
+				col = rest/25*8 + rest%25/3
+				if rest%25 == 24 {
+					col--
+				}
+			}
+			if asciiX := x + 10 + v.perRow*3 + v.perRow/8; mx >= asciiX {
+				col = mx - asciiX
+			}
+			if col >= 0 && col < v.perRow {
+				v.moveTo(f.top + int64(my-y)*row + int64(col))
+			}
+		case tview.MouseScrollUp:
+			f.top -= 3 * row
+			v.moveTo(f.cursor - 3*row)
+		case tview.MouseScrollDown:
+			if f.top+3*row < f.size {
+				f.top += 3 * row
+			}
+			v.moveTo(f.cursor + 3*row)
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+}
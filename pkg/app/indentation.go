@@ -0,0 +1,60 @@
+package app
+
+import (
+	"fmt"
+
+	"gotui/pkg/editor"
+)
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "indentation", Order: 25, Right: true, Text: editorInfo(func(buf *Buffer) string {
+		if buf.onDisk() || buf.Buffer != ui.editor.Buffer() {
+			return ""
+		}
+		if ui.editor.InsertSpaces() {
+			return fmt.Sprintf("Spaces: %d", ui.editor.TabWidth())
+		}
+		return fmt.Sprintf("Tab Size: %d", ui.editor.TabWidth())
+	})})
+}
+
+// indentSettings returns the tab width of a file and whether it is indented
+// with spaces: the editor settings, overridden by those of its file type
+func indentSettings(path string) (int, bool) {
+	width, spaces := config.Editor.TabWidth, config.Editor.InsertSpaces
+	if filetype, ok := config.Filetypes[editor.FileLanguage(path)]; ok && path != "" {
+		if filetype.TabWidth > 0 {
+			width = filetype.TabWidth
+		}
+		if filetype.InsertSpaces != nil {
+			spaces = *filetype.InsertSpaces
+		}
+	}
+	return width, spaces
+}
+
+// applyIndentSettings gives the editor the indentation settings of the
+// file it shows
+func applyIndentSettings() {
+	width, spaces := indentSettings(ui.editor.Buffer().Path())
+	ui.editor.SetTabWidth(width).SetInsertSpaces(spaces)
+}
+
+// reindent rewrites the indentation of every line of the editor's buffer in
+// the current style, keeping its width
+func reindent() {
+	buf := buffers.current()
+	if buf == nil {
+		return
+	}
+	if buf.ReadOnly() {
+		ui.output.message(fmt.Sprintf("%s is read-only", buf.Name()))
+		return
+	}
+	changed := ui.editor.Reindent()
+	style := "tabs"
+	if ui.editor.InsertSpaces() {
+		style = "spaces"
+	}
+	ui.output.message(fmt.Sprintf("Reindented %d lines with %s", changed, style))
+}
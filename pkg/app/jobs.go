@@ -0,0 +1,147 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// cancelGrace is how long a canceled command has to exit after being
+// interrupted before it is killed
+const cancelGrace = 3 * time.Second
+
+// job is a long-running command: a build or run, the tests, or a task
+type job struct {
+	name     string
+	cmd      *exec.Cmd
+	started  time.Time
+	ended    time.Time
+	canceled bool   // set once the command was asked to stop
+	rerun    func() // starts the command again
+}
+
+// jobTracker tracks the running jobs, showing the latest one with a spinner
+// and its elapsed time in the status bar, and cancels them
+type jobTracker struct {
+	jobs      []*job // oldest first
+	last      *job   // the job started last, kept once it ended
+	frame     int    // frame of the spinner
+	animating bool   // set while the spinner is redrawn
+}
+
+var jobs jobTracker
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "jobs", Order: 30, Text: jobs.status})
+}
+
+// add tracks a command that was started, animating the status bar until it
+// is removed. rerun starts the command again, for watch mode.
+func (t *jobTracker) add(name string, cmd *exec.Cmd, rerun func()) *job {
+	j := &job{name: name, cmd: cmd, started: time.Now(), rerun: rerun}
+	applog.log(logDebug, "Command started", "command", name)
+	t.jobs = append(t.jobs, j)
+	t.last = j
+	if !t.animating {
+		t.animating = true
+		go t.animate()
+	}
+	return j
+}
+
+// remove stops tracking a job that ended or was replaced
+func (t *jobTracker) remove(j *job) {
+	for i, running := range t.jobs {
+		if running == j {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			j.ended = time.Now()
+			applog.log(logDebug, "Command ended", "command", j.name, "elapsed", j.ended.Sub(j.started).Round(time.Millisecond), "canceled", j.canceled)
+			if j == t.last {
+				watchMode.finished()
+			}
+			return
+		}
+	}
+}
+
+// running reports whether a job is still tracked
+func (t *jobTracker) running(j *job) bool {
+	for _, running := range t.jobs {
+		if running == j {
+			return true
+		}
+	}
+	return false
+}
+
+// animate redraws the status bar while jobs are running
+func (t *jobTracker) animate() {
+	defer recoverPanic()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for range ticker.C {
+		done := make(chan bool, 1)
+		ui.app.QueueUpdateDraw(func() {
+			t.frame++
+			t.animating = len(t.jobs) > 0
+			done <- !t.animating
+		})
+		if <-done {
+			return
+		}
+	}
+}
+
+// status shows the latest job with a spinner and how long it has been
+// running, and how many others are running
+func (t *jobTracker) status() string {
+	if len(t.jobs) == 0 {
+		return ""
+	}
+	j := t.jobs[len(t.jobs)-1]
+	text := fmt.Sprintf("%c %s %s", spinnerFrames[t.frame%len(spinnerFrames)], j.name, formatElapsed(time.Since(j.started)))
+	if j.canceled {
+		text += " (canceling)"
+	}
+	if len(t.jobs) > 1 {
+		text += fmt.Sprintf(" (+%d more)", len(t.jobs)-1)
+	}
+	return text
+}
+
+// cancel cancels the latest job
+func (t *jobTracker) cancel() {
+	if len(t.jobs) == 0 {
+		ui.output.message("No command is running")
+		return
+	}
+	t.jobs[len(t.jobs)-1].cancel()
+}
+
+// cancel interrupts the job's command and the programs it started, and kills
+// them if they are still running after cancelGrace or when canceled again
+func (j *job) cancel() {
+	if j.canceled {
+		killProcessGroup(j.cmd)
+		return
+	}
+	j.canceled = true
+	interruptProcessGroup(j.cmd)
+	time.AfterFunc(cancelGrace, func() {
+		ui.app.QueueUpdateDraw(func() {
+			if jobs.running(j) {
+				killProcessGroup(j.cmd)
+			}
+		})
+	})
+}
+
+// formatElapsed formats a duration in whole seconds, with minutes from one
+// minute on
+func formatElapsed(d time.Duration) string {
+	seconds := int(d / time.Second)
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm%02ds", seconds/60, seconds%60)
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+
+	"gotui/pkg/editor"
+)
+
+// jumpListLimit bounds the number of remembered locations
+const jumpListLimit = 100
+
+// jumpLocation is a cursor position in a file
+type jumpLocation struct {
+	path string
+	pos  editor.Position
+}
+
+// jumpList remembers where the cursor was before each jump, such as going
+// to a definition, a search match, or another file, so it can be returned
+// to and left again like the pages of a browser's history
+type jumpList struct {
+	entries []jumpLocation
+	// index is the entry the cursor was taken back to, or len(entries)
+	// while no location has been gone back to since the last jump
+	index int
+	// navigating is set while moving through the list, so that the jump
+	// itself is not recorded
+	navigating bool
+}
+
+var jumps jumpList
+
+// push records the cursor location of the active buffer before a jump.
+// Locations gone back past are dropped.
+func (j *jumpList) push() {
+	if j.navigating {
+		return
+	}
+	if j.index < len(j.entries) {
+		j.entries = j.entries[:j.index+1]
+	}
+	j.record()
+	j.index = len(j.entries)
+}
+
+// record appends the cursor location of the active buffer unless it is the
+// last one already, reporting whether there is an active buffer
+func (j *jumpList) record() bool {
+	buf := buffers.current()
+	if buf == nil {
+		return false
+	}
+	location := jumpLocation{path: buf.Path(), pos: buf.Cursor()}
+	if n := len(j.entries); n == 0 || j.entries[n-1] != location {
+		j.entries = append(j.entries, location)
+	}
+	if len(j.entries) > jumpListLimit {
+		j.entries = j.entries[len(j.entries)-jumpListLimit:]
+	}
+	return true
+}
+
+// back returns to the location recorded before the current one. The first
+// step back records where the cursor is, so that forward can return to it.
+func (j *jumpList) back() error {
+	if j.index == len(j.entries) && j.record() {
+		j.index = len(j.entries) - 1
+	}
+	if j.index == 0 {
+		return fmt.Errorf("no earlier location")
+	}
+	return j.goTo(j.index - 1)
+}
+
+// forward undoes the last step back
+func (j *jumpList) forward() error {
+	if j.index >= len(j.entries)-1 {
+		return fmt.Errorf("no later location")
+	}
+	return j.goTo(j.index + 1)
+}
+
+// goTo moves the cursor to the entry at index
+func (j *jumpList) goTo(index int) error {
+	j.index = index
+	location := j.entries[index]
+	j.navigating = true
+	defer func() { j.navigating = false }()
+	return openFileAt(location.path, location.pos, location.pos)
+}
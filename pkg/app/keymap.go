@@ -0,0 +1,10 @@
+package app
+
+import "github.com/gdamore/tcell/v2"
+
+// keymapReserves reports whether the focused editor's keymap takes over a
+// key from the global bindings
+func keymapReserves(event *tcell.EventKey) bool {
+	keymap := ui.editor.Keymap()
+	return ui.app.GetFocus() == ui.editor && keymap != nil && keymap.Reserved(event)
+}
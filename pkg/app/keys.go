@@ -0,0 +1,279 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+)
+
+// keyChord is a normalized key press: a special key or a rune, with the
+// modifiers that are not already implied by the key itself
+type keyChord struct {
+	key tcell.Key
+	r   rune
+	mod tcell.ModMask
+}
+
+// Binding scopes decide where an action's keys are active
+const (
+	scopeGlobal   = "global"
+	scopeEditor   = "editor"
+	scopeTerminal = "terminal"
+	scopeExplorer = "explorer"
+)
+
+// keyBindings maps key chords to actions, per scope
+type keyBindings struct {
+	profile string // editor keymap: "default", "vim", or "emacs"
+	scopes  map[string]map[keyChord]string
+	keys    map[string][]string // key specs of each action, for display
+}
+
+var bindings keyBindings
+
+// keysFile is the layout of the keys file
+type keysFile struct {
+	Profile  string                 `toml:"profile"`
+	Bindings map[string]interface{} `toml:"bindings"`
+}
+
+// keysPath returns the location of the keys file
+func keysPath() (string, error) {
+	return configFilePath("keys.toml")
+}
+
+// loadKeyBindings builds the bindings from the defaults, the [keys] section
+// of the config file, the keys file, if there is one, and the init script,
+// each overriding the one before. Conflicting or unknown bindings are an
+// error.
+func loadKeyBindings() (keyBindings, error) {
+	specs := defaultKeySpecs()
+	profile := "default"
+
+	if path, err := configPath(); err == nil {
+		if err := config.Keys.merge(path, specs, &profile); err != nil {
+			return keyBindings{}, err
+		}
+	}
+	path, err := keysPath()
+	if err != nil {
+		return keyBindings{}, err
+	}
+	var file keysFile
+	if _, err := toml.DecodeFile(path, &file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return keyBindings{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := file.merge(path, specs, &profile); err != nil {
+		return keyBindings{}, err
+	}
+	for action, keys := range scripts.keys {
+		specs[action] = keys
+	}
+	return buildKeyBindings(profile, specs)
+}
+
+// merge overrides the profile and the key specs with the ones of the file
+// at path
+func (f *keysFile) merge(path string, specs map[string][]string, profile *string) error {
+	if f.Profile != "" {
+		if f.Profile != "default" && f.Profile != "vim" && f.Profile != "emacs" {
+			return fmt.Errorf("%s: unknown profile %q", path, f.Profile)
+		}
+		*profile = f.Profile
+	}
+	for action, value := range f.Bindings {
+		if _, ok := commands[action]; !ok && !isPluginCommand(action) {
+			return fmt.Errorf("%s: unknown action %q", path, action)
+		}
+		switch value := value.(type) {
+		case string:
+			specs[action] = []string{value}
+		case []interface{}:
+			keys := make([]string, 0, len(value))
+			for _, key := range value {
+				s, ok := key.(string)
+				if !ok {
+					return fmt.Errorf("%s: keys of %s must be strings", path, action)
+				}
+				keys = append(keys, s)
+			}
+			specs[action] = keys
+		default:
+			return fmt.Errorf("%s: keys of %s must be a string or a list of strings", path, action)
+		}
+	}
+	return nil
+}
+
+// buildKeyBindings parses the key specs of every action, rejecting keys that
+// would trigger two actions in the same scope
+func buildKeyBindings(profile string, specs map[string][]string) (keyBindings, error) {
+	b := keyBindings{profile: profile, keys: map[string][]string{}, scopes: map[string]map[keyChord]string{
+		scopeGlobal:   {},
+		scopeEditor:   {},
+		scopeTerminal: {},
+		scopeExplorer: {},
+	}}
+	actions := make([]string, 0, len(specs))
+	for action := range specs {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	var conflicts []string
+	for _, action := range actions {
+		command, ok := commands[action]
+		if !ok {
+			// A plugin command that is not registered yet
+			continue
+		}
+		scope := command.Scope
+		for _, spec := range specs[action] {
+			if strings.TrimSpace(spec) == "" {
+				continue
+			}
+			chord, err := parseKey(spec)
+			if err != nil {
+				return keyBindings{}, fmt.Errorf("invalid key for %s: %w", action, err)
+			}
+			// Global keys are seen before any scoped key, so they clash with
+			// every scope
+			for name, chords := range b.scopes {
+				if name != scope && scope != scopeGlobal && name != scopeGlobal {
+					continue
+				}
+				if other, ok := chords[chord]; ok {
+					conflicts = append(conflicts, fmt.Sprintf("%s is bound to both %s and %s", spec, other, action))
+				}
+			}
+			b.scopes[scope][chord] = action
+			b.keys[action] = append(b.keys[action], spec)
+		}
+	}
+	if len(conflicts) > 0 {
+		return keyBindings{}, fmt.Errorf("conflicting key bindings: %s", strings.Join(conflicts, "; "))
+	}
+	return b, nil
+}
+
+// keyNames maps lower-case key names to special keys
+var keyNames = func() map[string]tcell.Key {
+	names := map[string]tcell.Key{
+		"escape": tcell.KeyEscape,
+		"del":    tcell.KeyDelete,
+		"pageup": tcell.KeyPgUp, "pagedown": tcell.KeyPgDn,
+	}
+	for key, name := range tcell.KeyNames {
+		if !strings.HasPrefix(name, "Ctrl-") {
+			names[strings.ToLower(name)] = key
+		}
+	}
+	return names
+}()
+
+// parseKey parses a key description such as "Ctrl+S", "Shift+F12",
+// "Alt+Left", or "Ctrl+/"
+func parseKey(spec string) (keyChord, error) {
+	parts := strings.Split(spec, "+")
+	name := parts[len(parts)-1]
+	if name == "" && len(parts) > 1 {
+		// "Ctrl++" names the plus key
+		name, parts = "+", parts[:len(parts)-1]
+	}
+	var mod tcell.ModMask
+	for _, part := range parts[:len(parts)-1] {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case "ctrl", "control":
+			mod |= tcell.ModCtrl
+		case "alt", "meta", "option":
+			mod |= tcell.ModAlt
+		case "shift":
+			mod |= tcell.ModShift
+		default:
+			return keyChord{}, fmt.Errorf("unknown modifier %q in %q", part, spec)
+		}
+	}
+
+	if strings.EqualFold(name, "space") {
+		name = " "
+	}
+	runes := []rune(name)
+	if len(runes) == 1 {
+		if mod&tcell.ModCtrl != 0 {
+			// Control characters carry Ctrl in the key code itself
+			if key, ok := ctrlKey(runes[0]); ok {
+				return normalizeChord(keyChord{key: key, mod: mod}), nil
+			}
+		}
+		// "Alt+R" means the r key; Shift selects the upper-case letter
+		r := unicode.ToLower(runes[0])
+		if mod&tcell.ModShift != 0 {
+			r = unicode.ToUpper(r)
+		}
+		return normalizeChord(keyChord{key: tcell.KeyRune, r: r, mod: mod}), nil
+	}
+	if key, ok := keyNames[strings.ToLower(name)]; ok {
+		return normalizeChord(keyChord{key: key, mod: mod}), nil
+	}
+	return keyChord{}, fmt.Errorf("unknown key %q in %q", name, spec)
+}
+
+// ctrlKey returns the control key sent for Ctrl and r
+func ctrlKey(r rune) (tcell.Key, bool) {
+	r = unicode.ToLower(r)
+	switch {
+	case r >= 'a' && r <= 'z':
+		return tcell.KeyCtrlA + tcell.Key(r-'a'), true
+	case r == ' ':
+		return tcell.KeyCtrlSpace, true
+	case r == '/' || r == '_':
+		return tcell.KeyCtrlUnderscore, true
+	case r == ']':
+		return tcell.KeyCtrlRightSq, true
+	case r == '[':
+		return tcell.KeyCtrlLeftSq, true
+	case r == '\\':
+		return tcell.KeyCtrlBackslash, true
+	}
+	return 0, false
+}
+
+// chordOf returns the chord of a key event
+func chordOf(event *tcell.EventKey) keyChord {
+	return normalizeChord(keyChord{key: event.Key(), r: event.Rune(), mod: event.Modifiers()})
+}
+
+// normalizeChord folds the different ways terminals report the same key
+// press into one chord
+func normalizeChord(c keyChord) keyChord {
+	if c.mod&tcell.ModMeta != 0 {
+		c.mod = c.mod&^tcell.ModMeta | tcell.ModAlt
+	}
+	switch {
+	case c.key == tcell.KeyRune:
+		// Shift is already reflected in the rune
+		c.mod &^= tcell.ModShift
+		return c
+	case c.key == tcell.KeyBacktab:
+		c.key, c.mod = tcell.KeyTab, c.mod|tcell.ModShift
+	case c.key >= tcell.KeyF13 && c.key <= tcell.KeyF24:
+		// Terminals without modifier reporting send F13-F24 for Shift+F1-F12
+		c.key, c.mod = c.key-12, c.mod|tcell.ModShift
+	case c.key <= tcell.KeyCtrlUnderscore && c.key != tcell.KeyTab && c.key != tcell.KeyEnter &&
+		c.key != tcell.KeyEscape && c.key != tcell.KeyBackspace:
+		c.mod &^= tcell.ModCtrl
+	}
+	c.r = 0
+	return c
+}
+
+// lookup returns the action bound to a key in a scope, or ""
+func (b *keyBindings) lookup(scope string, event *tcell.EventKey) string {
+	return b.scopes[scope][chordOf(event)]
+}
@@ -0,0 +1,384 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+const (
+	largeFileChunk = 1 << 20  // bytes read at a time while indexing
+	largeFileBatch = 16       // chunks indexed between updates of the view
+	largeLineLimit = 64 << 10 // bytes of a line that are read and shown
+)
+
+// largeFile is a file too big to load into the editor. Its line starts are
+// indexed in the background and each line is read from disk when it comes
+// into view, so only the visible lines are ever held in memory.
+type largeFile struct {
+	path    string
+	file    *os.File
+	size    int64   // size of the file when it was indexed
+	starts  []int64 // offsets of the line starts indexed so far
+	scanned int64   // bytes indexed so far
+	indexed bool    // the whole file is indexed
+	// generation is bumped by each load and by close, so the batches of an
+	// earlier indexing are dropped
+	generation int
+
+	cursor    int // line of the cursor
+	top       int // first visible line
+	colOffset int // first visible display column
+}
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "large-file", Order: 5, Text: editorInfo(func(buf *Buffer) string {
+		f := buf.large
+		switch {
+		case f == nil:
+			return ""
+		case !f.indexed && f.size > 0:
+			return fmt.Sprintf("Large file, indexing %d%%", f.scanned*100/f.size)
+		}
+		return fmt.Sprintf("Large file, %d lines", f.lineCount())
+	})})
+}
+
+// isLargeFile reports whether a file is too big for the editor, going by
+// the large_file_size setting
+func isLargeFile(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && info.Size() >= int64(config.Editor.LargeFileSize)<<20
+}
+
+// NewLargeBuffer returns a read-only buffer showing the file at path in
+// large-file mode. Its text stays empty, which leaves highlighting, the
+// language server, and the other features working on the text idle.
+func NewLargeBuffer(path string) (*Buffer, error) {
+	b := NewBuffer("", "")
+	b.SetPath(path)
+	b.large = &largeFile{path: path}
+	if err := b.large.load(); err != nil {
+		return nil, err
+	}
+	b.modTime = fileModTime(path)
+	return b, nil
+}
+
+// load opens the file and indexes it again in the background. The cursor
+// stays where it was as far as possible. A file that grew, such as a log
+// being written, is taken to have been appended to, so only the new part
+// is indexed.
+func (f *largeFile) load() error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	var from int64
+	if f.indexed && info.Size() >= f.size {
+		from = f.size
+	} else {
+		f.starts = []int64{0}
+	}
+	f.close()
+	f.file, f.size, f.scanned, f.indexed = file, info.Size(), from, false
+	go f.index(file, from, f.size, f.generation)
+	return nil
+}
+
+// close closes the file and stops its indexing
+func (f *largeFile) close() {
+	f.generation++
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// index finds the line starts of a file from offset from on, handing them
+// to the view in batches so the first lines can be read while the rest is
+// indexed
+func (f *largeFile) index(file *os.File, from, size int64, generation int) {
+	defer recoverPanic()
+	reader := io.NewSectionReader(file, from, size-from)
+	chunk := make([]byte, largeFileChunk)
+	var starts []int64
+	offset := from
+	for chunks := 1; ; chunks++ {
+		n, err := reader.Read(chunk)
+		for i := 0; i < n; {
+			newline := bytes.IndexByte(chunk[i:n], '\n')
+			if newline < 0 {
+				break
+			}
+			i += newline + 1
+			starts = append(starts, offset+int64(i))
+		}
+		offset += int64(n)
+		if err == nil && chunks%largeFileBatch != 0 {
+			continue
+		}
+		batch, scanned := starts, offset
+		starts = nil
+		ui.app.QueueUpdateDraw(func() {
+			if f.generation != generation {
+				return
+			}
+			f.starts = append(f.starts, batch...)
+			f.scanned = scanned
+			switch {
+			case err == io.EOF:
+				f.indexed = true
+			case err != nil:
+				f.indexed = true
+				notify(noticeError, fmt.Sprintf("Error reading file: %s", err))
+			}
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// lineCount returns the number of lines indexed so far. While indexing, the
+// last line start found begins a line whose end is not known yet.
+func (f *largeFile) lineCount() int {
+	if f.indexed {
+		return len(f.starts)
+	}
+	return len(f.starts) - 1
+}
+
+// line reads line n from the file without its line ending. Lines longer
+// than largeLineLimit are cut off.
+func (f *largeFile) line(n int) []rune {
+	if f.file == nil || n < 0 || n >= f.lineCount() {
+		return nil
+	}
+	start, end := f.starts[n], f.size
+	if n+1 < len(f.starts) {
+		end = f.starts[n+1]
+	}
+	if end-start > largeLineLimit {
+		end = start + largeLineLimit
+	}
+	data := make([]byte, end-start)
+	read, _ := f.file.ReadAt(data, start)
+	data = bytes.TrimSuffix(bytes.TrimSuffix(data[:read], []byte("\n")), []byte("\r"))
+	return []rune(string(data))
+}
+
+// crlf reports whether the first line of the file ends with CRLF
+func (f *largeFile) crlf() bool {
+	if f.file == nil || f.lineCount() < 2 {
+		return false
+	}
+	end := f.starts[1]
+	data := make([]byte, 2)
+	_, err := f.file.ReadAt(data, end-2)
+	return err == nil && data[0] == '\r'
+}
+
+// moveTo puts the cursor on a line, keeping it among the height visible
+// lines
+func (f *largeFile) moveTo(line, height int) {
+	if count := f.lineCount(); line >= count {
+		line = count - 1
+	}
+	if line < 0 {
+		line = 0
+	}
+	f.cursor = line
+	if f.cursor < f.top {
+		f.top = f.cursor
+	}
+	if height > 0 && f.cursor >= f.top+height {
+		f.top = f.cursor - height + 1
+	}
+}
+
+// scroll moves the visible lines and the cursor by delta lines
+func (f *largeFile) scroll(delta, height int) {
+	f.top += delta
+	if last := f.lineCount() - height; f.top > last {
+		f.top = last
+	}
+	if f.top < 0 {
+		f.top = 0
+	}
+	f.moveTo(f.cursor+delta, height)
+}
+
+// LargeFileView shows a large file in place of the editor. It moves a line
+// cursor through the file and scrolls sideways but cannot edit.
+type LargeFileView struct {
+	*tview.Box
+
+	file   *largeFile
+	height int // rows shown at the last draw
+
+	textStyle   tcell.Style
+	cursorStyle tcell.Style
+	numberStyle tcell.Style
+}
+
+// NewLargeFileView returns a view showing no file
+func NewLargeFileView() *LargeFileView {
+	v := &LargeFileView{Box: tview.NewBox()}
+	v.applyTheme(theme)
+	return v
+}
+
+// applyTheme sets the view's styles from a theme
+func (v *LargeFileView) applyTheme(t *Theme) {
+	base := tcell.StyleDefault.Background(t.Background)
+	v.SetBackgroundColor(t.Background)
+	v.textStyle = base.Foreground(t.Text)
+	v.cursorStyle = tcell.StyleDefault.Background(t.Contrast).Foreground(t.Text)
+	v.numberStyle = base.Foreground(t.Muted)
+}
+
+// SetFile switches the view to another file
+func (v *LargeFileView) SetFile(f *largeFile) *LargeFileView {
+	v.file = f
+	return v
+}
+
+// goToLine moves the cursor to a zero-based line and scrolls it to the
+// middle of the view
+func (v *LargeFileView) goToLine(line int) {
+	f := v.file
+	f.cursor = line
+	if f.top = line - v.height/2; f.top < 0 {
+		f.top = 0
+	}
+}
+
+// Draw draws this primitive onto the screen
+func (v *LargeFileView) Draw(screen tcell.Screen) {
+	v.Box.DrawForSubclass(screen, v)
+	x, y, width, height := v.GetInnerRect()
+	if width <= 0 || height <= 0 || v.file == nil {
+		return
+	}
+	v.height = height
+	f := v.file
+	count := f.lineCount()
+	if count <= 0 {
+		printText(screen, "Indexing "+workspacePath(f.path)+"…", x, y, width, v.numberStyle)
+		return
+	}
+	if f.cursor < count || f.indexed {
+		// While the file is indexed, the cursor waits for its line
+		f.moveTo(f.cursor, height)
+	}
+	gutter := len(strconv.Itoa(count)) + 1
+	for row := 0; row < height && f.top+row < count; row++ {
+		n := f.top + row
+		style := v.textStyle
+		if n == f.cursor {
+			style = v.cursorStyle
+			fillRow(screen, x+gutter, y+row, width-gutter, style)
+		}
+		number := strconv.Itoa(n + 1)
+		printText(screen, number, x+gutter-1-len(number), y+row, len(number), v.numberStyle)
+		v.drawLine(screen, f.line(n), x+gutter, y+row, width-gutter, style)
+	}
+}
+
+// drawLine draws the visible part of a line, expanding tabs
+func (v *LargeFileView) drawLine(screen tcell.Screen, line []rune, x, y, width int, style tcell.Style) {
+	tabWidth := config.Editor.TabWidth
+	cx := 0
+	for _, r := range line {
+		w := runewidth.RuneWidth(r)
+		if r == '\t' {
+			w = tabWidth - cx%tabWidth
+		}
+		sx := cx - v.file.colOffset
+		cx += w
+		switch {
+		case sx+w <= 0 || w == 0:
+			continue
+		case sx >= width:
+			return
+		case r == '\t' || sx < 0 || sx+w > width:
+			for i := 0; i < w; i++ {
+				if sx+i >= 0 && sx+i < width {
+					screen.SetContent(x+sx+i, y, ' ', nil, style)
+				}
+			}
+		default:
+			screen.SetContent(x+sx, y, r, nil, style)
+		}
+	}
+}
+
+// InputHandler moves the cursor and scrolls
+func (v *LargeFileView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return v.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		f := v.file
+		if f == nil {
+			return
+		}
+		switch event.Key() {
+		case tcell.KeyUp:
+			f.moveTo(f.cursor-1, v.height)
+		case tcell.KeyDown:
+			f.moveTo(f.cursor+1, v.height)
+		case tcell.KeyPgUp:
+			f.scroll(-v.height, v.height)
+		case tcell.KeyPgDn:
+			f.scroll(v.height, v.height)
+		case tcell.KeyHome:
+			f.colOffset = 0
+			if event.Modifiers()&tcell.ModCtrl != 0 {
+				f.moveTo(0, v.height)
+			}
+		case tcell.KeyEnd:
+			if event.Modifiers()&tcell.ModCtrl != 0 {
+				f.moveTo(f.lineCount()-1, v.height)
+			}
+		case tcell.KeyLeft:
+			if f.colOffset > 0 {
+				f.colOffset--
+			}
+		case tcell.KeyRight:
+			f.colOffset++
+		}
+	})
+}
+
+// MouseHandler scrolls with the wheel and moves the cursor on clicks
+func (v *LargeFileView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return v.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !v.InRect(event.Position()) || v.file == nil {
+			return false, nil
+		}
+		f := v.file
+		switch action {
+		case tview.MouseLeftClick:
+			setFocus(v)
+			_, y, _, _ := v.GetInnerRect()
+			_, my := event.Position()
+			f.moveTo(f.top+my-y, v.height)
+		case tview.MouseScrollUp:
+			f.scroll(-3, v.height)
+		case tview.MouseScrollDown:
+			f.scroll(3, v.height)
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+}
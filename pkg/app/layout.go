@@ -0,0 +1,336 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	resizeStep    = 2 // columns or rows a resize command moves a border
+	minPaneWidth  = 10
+	minPaneHeight = 3
+)
+
+// splitter is a border between panes that is being dragged
+type splitter int
+
+const (
+	noSplitter       splitter = iota
+	explorerSplitter          // between the file explorer and the panes right of it
+	panelsSplitter            // between the editor and the panels
+	terminalSplitter          // between the panels and the terminal
+)
+
+// dragging is the splitter following the mouse
+var dragging splitter
+
+// pane is an area of the window that can be hidden or zoomed
+type pane int
+
+const (
+	noPane pane = iota
+	explorerPane
+	editorPane
+	panelsPane
+	terminalPane
+)
+
+var (
+	hiddenPanes = map[pane]bool{}
+	zoomedPane  = noPane // the pane filling the window, if any
+)
+
+// applyLayout sizes the panes from the config, leaving out hidden panes and
+// all but the zoomed one
+func applyLayout() {
+	shown := func(p pane) bool {
+		if zoomedPane != noPane {
+			return p == zoomedPane
+		}
+		return !hiddenPanes[p]
+	}
+	if zoomedPane == explorerPane {
+		ui.content.ResizeItem(ui.fileExplorer, 0, 1)
+		ui.content.ResizeItem(ui.rightPanel, 0, 0)
+	} else {
+		width := 0
+		if shown(explorerPane) {
+			width = config.Layout.ExplorerWidth
+		}
+		ui.content.ResizeItem(ui.fileExplorer, width, 0)
+		ui.content.ResizeItem(ui.rightPanel, 0, 1)
+	}
+	for _, item := range []struct {
+		pane   pane
+		item   tview.Primitive
+		weight int
+	}{
+		{editorPane, ui.editorPane, config.Layout.EditorHeight},
+		{panelsPane, ui.panels, config.Layout.PanelHeight},
+		{terminalPane, ui.terminalPane, config.Layout.TerminalHeight},
+	} {
+		if !shown(item.pane) {
+			item.weight = 0
+		}
+		ui.rightPanel.ResizeItem(item.item, 0, item.weight)
+	}
+}
+
+// focusedPane returns the pane with the focus, taking the editor when a
+// dialog or the menu bar has it
+func focusedPane() pane {
+	switch {
+	case ui.fileExplorer.HasFocus():
+		return explorerPane
+	case ui.panels.HasFocus():
+		return panelsPane
+	case ui.terminalPane.HasFocus():
+		return terminalPane
+	}
+	return editorPane
+}
+
+// togglePane hides a pane, or shows it again. Hiding the pane with the
+// focus moves the focus to the editor.
+func togglePane(p pane) {
+	if hiddenPanes[p] && zoomedPane == noPane {
+		showPane(p)
+		return
+	}
+	if focusedPane() == p {
+		ui.app.SetFocus(ui.editor)
+	}
+	zoomedPane = noPane
+	hiddenPanes[p] = true
+	applyLayout()
+}
+
+// showPane makes a pane visible, ending the zoom of another one
+func showPane(p pane) {
+	if !hiddenPanes[p] && (zoomedPane == noPane || zoomedPane == p) {
+		return
+	}
+	delete(hiddenPanes, p)
+	if zoomedPane != p {
+		zoomedPane = noPane
+	}
+	applyLayout()
+}
+
+// toggleZoom lets the focused pane fill the window, or restores the layout
+func toggleZoom() {
+	if zoomedPane != noPane {
+		zoomedPane = noPane
+	} else {
+		zoomedPane = focusedPane()
+	}
+	applyLayout()
+}
+
+// layoutPath returns the location of the file keeping the pane sizes
+// between sessions
+func layoutPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "goui", "layout.toml"), nil
+}
+
+// loadLayout applies the pane sizes of the last session on top of the
+// config file's. A missing or invalid file changes nothing.
+func loadLayout() {
+	path, err := layoutPath()
+	if err != nil {
+		return
+	}
+	saved := config
+	if _, err := toml.DecodeFile(path, &saved.Layout); err != nil {
+		return
+	}
+	if saved.validate() == nil {
+		config.Layout = saved.Layout
+	}
+}
+
+// saveLayout remembers the pane sizes for the next session
+func saveLayout() error {
+	path, err := layoutPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer file.Close()
+	return toml.NewEncoder(file).Encode(config.Layout)
+}
+
+// setExplorerWidth resizes the file explorer, leaving the panes right of it
+// at least minPaneWidth columns
+func setExplorerWidth(width int) {
+	_, _, total, _ := ui.content.GetRect()
+	limit := total - minPaneWidth
+	if limit > 200 {
+		// The most the config file allows
+		limit = 200
+	}
+	width = clamp(width, minPaneWidth, limit)
+	if width == config.Layout.ExplorerWidth {
+		return
+	}
+	config.Layout.ExplorerWidth = width
+	applyLayout()
+}
+
+// setPaneHeights sizes the editor, the panels, and the terminal by giving
+// them their height in rows as weight
+func setPaneHeights(editor, panels, terminal int) {
+	for _, height := range []int{editor, panels, terminal} {
+		if height < minPaneHeight || height > 1000 {
+			return
+		}
+	}
+	config.Layout.EditorHeight = editor
+	config.Layout.PanelHeight = panels
+	config.Layout.TerminalHeight = terminal
+	applyLayout()
+}
+
+// paneHeights returns the current heights of the editor, the panels, and
+// the terminal in rows
+func paneHeights() (int, int, int) {
+	_, _, _, editor := ui.editorPane.GetRect()
+	_, _, _, panels := ui.panels.GetRect()
+	_, _, _, terminal := ui.terminalPane.GetRect()
+	return editor, panels, terminal
+}
+
+// resizeExplorer widens the file explorer by delta columns
+func resizeExplorer(delta int) {
+	setExplorerWidth(config.Layout.ExplorerWidth + delta)
+	saveLayoutOrReport()
+}
+
+// resizeFocusedPane makes the pane with the focus taller by delta rows,
+// taking them from the taller of the other two. The editor grows when none
+// of the three has the focus.
+func resizeFocusedPane(delta int) {
+	heights := [3]int{}
+	heights[0], heights[1], heights[2] = paneHeights()
+	focused := 0
+	switch {
+	case ui.panels.HasFocus():
+		focused = 1
+	case ui.terminalPane.HasFocus():
+		focused = 2
+	}
+	other := (focused + 1) % 3
+	if next := (focused + 2) % 3; heights[next] > heights[other] {
+		other = next
+	}
+	if delta > 0 && heights[other]-delta < minPaneHeight {
+		delta = heights[other] - minPaneHeight
+	}
+	if delta < 0 && heights[focused]+delta < minPaneHeight {
+		delta = minPaneHeight - heights[focused]
+	}
+	heights[focused] += delta
+	heights[other] -= delta
+	setPaneHeights(heights[0], heights[1], heights[2])
+	saveLayoutOrReport()
+}
+
+// saveLayoutOrReport saves the pane sizes, showing an error in the output
+// window
+func saveLayoutOrReport() {
+	if err := saveLayout(); err != nil {
+		notify(noticeError, fmt.Sprintf("Error saving pane sizes: %s", err))
+	}
+}
+
+// clamp limits n to the range min..max, preferring min if the range is empty
+func clamp(n, min, max int) int {
+	if n > max {
+		n = max
+	}
+	if n < min {
+		n = min
+	}
+	return n
+}
+
+// splitterAt returns the splitter at a screen position. The explorer's
+// splitter is its last column, the others are the top borders of the
+// panels and the terminal. Splitters only move while every pane is shown.
+func splitterAt(x, y int) splitter {
+	if zoomedPane != noPane || len(hiddenPanes) > 0 {
+		return noSplitter
+	}
+	ex, ey, ew, eh := ui.fileExplorer.GetRect()
+	if x == ex+ew-1 && y >= ey && y < ey+eh {
+		return explorerSplitter
+	}
+	rx, _, rw, _ := ui.rightPanel.GetRect()
+	if x < rx || x >= rx+rw {
+		return noSplitter
+	}
+	if _, py, _, _ := ui.panels.GetRect(); y == py {
+		return panelsSplitter
+	}
+	if _, ty, _, _ := ui.terminalPane.GetRect(); y == ty {
+		return terminalSplitter
+	}
+	return noSplitter
+}
+
+// dragSplitter moves the dragged splitter to a screen position
+func dragSplitter(x, y int) {
+	editor, panels, terminal := paneHeights()
+	switch dragging {
+	case explorerSplitter:
+		ex, _, _, _ := ui.fileExplorer.GetRect()
+		setExplorerWidth(x - ex + 1)
+	case panelsSplitter:
+		_, top, _, _ := ui.editorPane.GetRect()
+		editor, panels = y-top, editor+panels-(y-top)
+		setPaneHeights(editor, panels, terminal)
+	case terminalSplitter:
+		_, top, _, _ := ui.panels.GetRect()
+		panels, terminal = y-top, panels+terminal-(y-top)
+		setPaneHeights(editor, panels, terminal)
+	}
+}
+
+// captureSplitterMouse lets the borders between the panes be dragged with
+// the mouse. It sees every mouse event of the main layout first.
+func captureSplitterMouse(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+	if len(dialogs) > 0 {
+		return action, event
+	}
+	x, y := event.Position()
+	switch {
+	case action == tview.MouseLeftDown && dragging == noSplitter:
+		dragging = splitterAt(x, y)
+		if dragging == noSplitter {
+			return action, event
+		}
+	case dragging == noSplitter:
+		return action, event
+	case action == tview.MouseMove:
+		dragSplitter(x, y)
+	case action == tview.MouseLeftUp:
+		dragging = noSplitter
+		saveLayoutOrReport()
+	}
+	return tview.MouseConsumed, nil
+}
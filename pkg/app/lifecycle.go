@@ -0,0 +1,66 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// stopSignals make goui quit as it does when asked to, ending what it
+// started, rather than die and leave its child processes behind
+var stopSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// stoppedBy is the signal that stopped goui, if one did
+var stoppedBy os.Signal
+
+// handleStopSignals stops the application on one of stopSignals
+func handleStopSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, stopSignals...)
+	go func() {
+		defer recoverPanic()
+		sig := <-signals
+		applog.log(logWarn, "Stopping on signal", "signal", sig)
+		ui.app.QueueUpdate(func() {
+			stoppedBy = sig
+			ui.app.Stop()
+		})
+	}()
+}
+
+// shutdown ends what goui started and saves what it keeps, once the UI has
+// stopped: the child processes first, so their ending is logged, and the
+// log last. runErr is the error the UI stopped with, if any.
+func shutdown(runErr error) {
+	builds.stop()
+	tests.stop()
+	tasks.stop()
+	lint.stop()
+	delve.shutdown()
+	gopls.shutdown()
+	terminals.shutdown()
+	plugins.stop()
+	saveErr := bookmarks.save()
+	applog.release()
+	if saveErr != nil {
+		log.Printf("Error saving bookmarks: %v", saveErr)
+	}
+	if runErr != nil {
+		// The recovery copies stay for the next start
+		log.Fatalf("Error running application: %v", runErr)
+	}
+	if stoppedBy != nil {
+		// Nobody was asked about the unsaved changes, so they are kept
+		saved, failed := saveRecoveryCopies()
+		if saved > 0 {
+			fmt.Fprintf(os.Stderr, "Stopped (%s). Unsaved changes of %s were kept and will be offered back at the next start.\n", stoppedBy, plural(saved, "file"))
+		}
+		for _, failure := range failed {
+			fmt.Fprintf(os.Stderr, "Error keeping unsaved changes: %s\n", failure)
+		}
+		return
+	}
+	recovery.stop()
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Line endings of files. The lines of buffers never hold a carriage return;
+// it is removed on load and written back on save.
+const (
+	lineEndingLF   = "\n"
+	lineEndingCRLF = "\r\n"
+)
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "line-endings", Order: 40, Right: true, Text: editorInfo(lineEndingName)})
+}
+
+// splitLineEndings removes the carriage returns of a file's text and
+// returns the line ending the file should be saved with, the one most of
+// its lines end with. A file with mixed line endings is normalized to it.
+func splitLineEndings(text string) (string, string) {
+	crlf := strings.Count(text, lineEndingCRLF)
+	if crlf == 0 {
+		return text, lineEndingLF
+	}
+	text = strings.ReplaceAll(text, lineEndingCRLF, lineEndingLF)
+	if crlf*2 > strings.Count(text, lineEndingLF) {
+		return text, lineEndingCRLF
+	}
+	return text, lineEndingLF
+}
+
+// fileText returns the content of the buffer with the line ending of its
+// file, as it is saved
+func (b *Buffer) fileText() string {
+	text := b.Text()
+	if b.lineEnding == lineEndingCRLF {
+		text = strings.ReplaceAll(text, lineEndingLF, lineEndingCRLF)
+	}
+	return text
+}
+
+// lineEndingName names the line endings of a buffer
+func lineEndingName(buf *Buffer) string {
+	if buf.hex != nil {
+		return ""
+	}
+	crlf := buf.lineEnding == lineEndingCRLF
+	if buf.large != nil {
+		crlf = buf.large.crlf()
+	}
+	if crlf {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// changeLineEndings picks the line ending the file in the editor is saved
+// with. Changing it is a change to the buffer that needs saving.
+func changeLineEndings() {
+	buf := buffers.current()
+	if buf == nil {
+		ui.output.message("No file loaded")
+		return
+	}
+	if buf.ReadOnly() {
+		ui.output.message(fmt.Sprintf("%s is read-only", buf.Name()))
+		return
+	}
+	endings := []string{lineEndingLF, lineEndingCRLF}
+	current := 0
+	if buf.lineEnding == lineEndingCRLF {
+		current = 1
+	}
+	showPicker("Line Endings", []string{"LF (Unix, macOS)", "CRLF (Windows)"}, current, func(index int) {
+		if buffers.current() != buf || buf.lineEnding == endings[index] {
+			return
+		}
+		buf.lineEnding = endings[index]
+		// The text did not change, so undoing edits never gets back to the
+		// saved file
+		buf.ForgetSavePoint()
+		buffers.setDirty(true)
+		buf.unswapped = true
+		autosave.changed()
+		ui.output.message(fmt.Sprintf("%s is saved with %s line endings", buf.Name(), lineEndingName(buf)))
+	})
+}
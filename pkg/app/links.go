@@ -0,0 +1,278 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gotui/pkg/editor"
+	"gotui/pkg/terminal"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// fileLocationPattern matches file:line and file:line:col locations, with
+// paths relative, absolute, or starting with a Windows drive
+var fileLocationPattern = regexp.MustCompile(`((?:[A-Za-z]:)?[\w.~/\\-]*\.\w+):(\d+)(?::(\d+))?`)
+
+// textLink is a link found in text, which can be opened or copied
+type textLink interface {
+	open()
+	String() string // the text copying the link copies
+}
+
+// fileLink is a location found in text that exists on disk
+type fileLink struct {
+	path string
+	line int // counted from 1
+	col  int // byte column counted from 1, 0 if none
+}
+
+// urlLink is a URL found in text, opened with the program the desktop
+// associates with it, such as the web browser
+type urlLink string
+
+// findFileLinks returns the locations in text naming files that exist, with
+// relative paths taken from dir, and the byte ranges they span
+func findFileLinks(text, dir string) ([]fileLink, [][]int) {
+	var links []fileLink
+	var spans [][]int
+	for _, match := range fileLocationPattern.FindAllStringSubmatchIndex(text, -1) {
+		path := text[match[2]:match[3]]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = workspacePath(abs)
+		}
+		line, _ := strconv.Atoi(text[match[4]:match[5]])
+		col := 0
+		if match[6] >= 0 {
+			col, _ = strconv.Atoi(text[match[6]:match[7]])
+		}
+		links = append(links, fileLink{path: path, line: line, col: col})
+		spans = append(spans, match[:2])
+	}
+	return links, spans
+}
+
+// findLinks returns the URLs and the file locations in text, with relative
+// paths taken from dir, and the byte ranges they span. Locations inside a
+// URL are part of it.
+func findLinks(text, dir string) ([]textLink, [][]int) {
+	var links []textLink
+	var spans [][]int
+	urls := terminal.FindURLs(text)
+	files, fileSpans := findFileLinks(text, dir)
+	for len(urls) > 0 || len(files) > 0 {
+		if len(files) == 0 || len(urls) > 0 && urls[0][0] <= fileSpans[0][0] {
+			links = append(links, urlLink(text[urls[0][0]:urls[0][1]]))
+			spans = append(spans, urls[0])
+			end := urls[0][1]
+			urls = urls[1:]
+			for len(files) > 0 && fileSpans[0][0] < end {
+				files, fileSpans = files[1:], fileSpans[1:]
+			}
+			continue
+		}
+		links = append(links, files[0])
+		spans = append(spans, fileSpans[0])
+		files, fileSpans = files[1:], fileSpans[1:]
+	}
+	return links, spans
+}
+
+// String returns the location as tools print it
+func (l fileLink) String() string {
+	if l.col > 0 {
+		return fmt.Sprintf("%s:%d:%d", l.path, l.line, l.col)
+	}
+	return fmt.Sprintf("%s:%d", l.path, l.line)
+}
+
+// open opens the URL
+func (l urlLink) open() {
+	openURL(string(l))
+}
+
+// String returns the URL
+func (l urlLink) String() string {
+	return string(l)
+}
+
+// openURL opens a URL with the program the desktop associates with it
+func openURL(uri string) {
+	cmd := openerCommand(uri)
+	if err := cmd.Start(); err != nil {
+		notify(noticeError, fmt.Sprintf("Error opening link: %s", err))
+		return
+	}
+	ui.output.message(fmt.Sprintf("Opened %s", uri))
+	go func() {
+		defer recoverPanic()
+		_ = cmd.Wait()
+	}()
+}
+
+// copyLink copies the text of a link to the clipboard
+func copyLink(link textLink) {
+	if err := copyToClipboard(link.String()); err != nil {
+		notify(noticeError, fmt.Sprintf("Error copying to clipboard: %s", err))
+		return
+	}
+	ui.output.message(fmt.Sprintf("Copied %s to the clipboard", link))
+}
+
+// open opens the file of the link at its location. Unlike loadFile it
+// leaves the output alone, so the other links in it can still be followed.
+func (l fileLink) open() {
+	jumps.push()
+	if err := buffers.open(l.path); err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		return
+	}
+	showPane(editorPane)
+	pos := editor.Position{Line: l.line - 1}
+	if pos.Line < 0 {
+		pos.Line = 0
+	}
+	if buf := buffers.current(); buf != nil && pos.Line < len(buf.Lines()) && l.col > 0 {
+		// Tools report byte columns
+		pos.Col = byteToRuneColumn(buf.Lines()[pos.Line], l.col-1)
+	}
+	ui.editor.Select(pos, pos)
+	ui.app.SetFocus(ui.editor)
+}
+
+// outputView is a channel of the Output pane. The URLs and file locations
+// in the text it is given become regions that open them when clicked, or
+// selected with Tab and opened with Enter or copied with y.
+type outputView struct {
+	*tview.TextView
+	links   []textLink
+	current int // index of the selected link, -1 if none
+
+	// selecting is set while the selection is moved from code, so only
+	// clicks on a link open it
+	selecting bool
+}
+
+// newOutputView creates an output channel showing title in the pane border
+func newOutputView(title string) *outputView {
+	o := &outputView{current: -1}
+	o.TextView = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWordWrap(true).
+		SetMaxLines(5000)
+	o.TextView.SetTitle(title)
+	o.TextView.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if o.selecting || len(added) == 0 {
+			return
+		}
+		var index int
+		if _, err := fmt.Sscanf(added[0], "l%d", &index); err == nil && index < len(o.links) {
+			// Clear the highlight so the link can be clicked again
+			o.highlightLink(-1)
+			o.links[index].open()
+		}
+	})
+	o.TextView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyTab:
+			o.moveSelection(1)
+		case tcell.KeyBacktab:
+			o.moveSelection(-1)
+		case tcell.KeyEnter:
+			if o.current >= 0 {
+				o.links[o.current].open()
+			}
+		case tcell.KeyEscape:
+			ui.app.SetFocus(ui.editor)
+		case tcell.KeyRune:
+			if event.Rune() != 'y' || o.current < 0 {
+				return event
+			}
+			copyLink(o.links[o.current])
+		default:
+			return event
+		}
+		return nil
+	})
+	return o
+}
+
+// SetText replaces the text, linking the URLs and file locations in it
+func (o *outputView) SetText(text string) *tview.TextView {
+	o.links, o.current = nil, -1
+	return o.TextView.SetText(o.link(text))
+}
+
+// message appends a line of text with the time it was shown, keeping the
+// earlier messages above it
+func (o *outputView) message(text string) {
+	fmt.Fprintf(o, "%s%s[-] %s\n", colorTag(theme.Muted), time.Now().Format("15:04:05"), tview.Escape(text))
+}
+
+// Write appends text, linking the URLs and file locations in it
+func (o *outputView) Write(p []byte) (int, error) {
+	if _, err := o.TextView.Write([]byte(o.link(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Clear removes the text and its links
+func (o *outputView) Clear() *tview.TextView {
+	o.links, o.current = nil, -1
+	return o.TextView.Clear()
+}
+
+// link underlines the URLs and file locations in text and wraps them in
+// regions, numbered after the links already in the view
+func (o *outputView) link(text string) string {
+	links, spans := findLinks(text, workspaceRoot)
+	if len(links) == 0 {
+		return text
+	}
+	var b []byte
+	last := 0
+	for i, span := range spans {
+		b = append(b, text[last:span[0]]...)
+		b = append(b, fmt.Sprintf(`["l%d"][::u]%s[::U][""]`, len(o.links)+i, text[span[0]:span[1]])...)
+		last = span[1]
+	}
+	o.links = append(o.links, links...)
+	return string(append(b, text[last:]...))
+}
+
+// highlightLink highlights the link at index, or none for -1
+func (o *outputView) highlightLink(index int) {
+	o.current = index
+	o.selecting = true
+	if index < 0 {
+		o.Highlight()
+	} else {
+		o.Highlight(fmt.Sprintf("l%d", index)).ScrollToHighlight()
+	}
+	o.selecting = false
+}
+
+// moveSelection selects the link delta positions away, wrapping around
+func (o *outputView) moveSelection(delta int) {
+	if len(o.links) == 0 {
+		return
+	}
+	index := o.current + delta
+	if o.current < 0 && delta < 0 {
+		index = len(o.links) - 1
+	}
+	o.highlightLink((index%len(o.links) + len(o.links)) % len(o.links))
+}
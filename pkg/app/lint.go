@@ -0,0 +1,152 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// lintSource is the source of the diagnostics golangci-lint reports
+const lintSource = "golangci-lint"
+
+// lintIssue is a finding in the JSON output of golangci-lint
+type lintIssue struct {
+	FromLinter string
+	Text       string
+	Severity   string
+	Pos        struct {
+		Filename string
+		Line     int
+		Column   int
+	}
+}
+
+// linter runs golangci-lint on the workspace and reports its findings as
+// diagnostics
+type linter struct {
+	cmd     *exec.Cmd
+	args    []string // the default arguments, once the version was looked up
+	argsFor string   // the command args were looked up for
+	runs    int      // number of runs started, to drop the findings of replaced ones
+}
+
+var lint linter
+
+// command returns the golangci-lint program to run
+func (l *linter) command() string {
+	if config.Lint.Command != "" {
+		return expandHome(config.Lint.Command)
+	}
+	return "golangci-lint"
+}
+
+// arguments returns the arguments of golangci-lint: the configured ones, or
+// those printing JSON for the whole workspace, which differ from version 2 on
+func (l *linter) arguments() []string {
+	if config.Lint.Args != nil {
+		return config.Lint.Args
+	}
+	if l.args == nil || l.argsFor != l.command() {
+		l.argsFor = l.command()
+		output, _ := exec.Command(l.command(), "--version").Output()
+		if strings.Contains(string(output), "version v2.") || strings.Contains(string(output), "version 2.") {
+			l.args = []string{"run", "--output.json.path=stdout", "./..."}
+		} else {
+			l.args = []string{"run", "--out-format=json", "./..."}
+		}
+	}
+	return l.args
+}
+
+// run lints the workspace in the background, replacing a run that has not
+// finished. The findings replace the earlier ones in the Problems panel.
+func (l *linter) run() {
+	if _, err := exec.LookPath(l.command()); err != nil {
+		notify(noticeError, fmt.Sprintf("Error running golangci-lint: %s not found; install it from https://golangci-lint.run", l.command()))
+		return
+	}
+	l.stop()
+	l.runs++
+	run := l.runs
+	ui.output.message("Running golangci-lint…")
+	cmd := exec.Command(l.command(), l.arguments()...)
+	cmd.Dir = workspaceRoot
+	setProcessGroup(cmd)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	l.cmd = cmd
+	go func() {
+		defer recoverPanic()
+		err := cmd.Run()
+		ui.app.QueueUpdateDraw(func() {
+			if l.runs != run {
+				return
+			}
+			l.cmd = nil
+			diagnostics, parsed := parseLintOutput(stdout.Bytes())
+			if !parsed && err != nil {
+				// golangci-lint exits with 1 when it finds something, but
+				// without findings to read it failed
+				message := strings.TrimSpace(stderr.String())
+				if message == "" {
+					message = err.Error()
+				}
+				notify(noticeError, fmt.Sprintf("Error running golangci-lint: %s", message))
+				return
+			}
+			problems.replace(lintSource, diagnostics)
+			ui.output.message(fmt.Sprintf("golangci-lint: %d issues", len(diagnostics)))
+		})
+	}()
+}
+
+// stop kills a run that has not finished
+func (l *linter) stop() {
+	if l.cmd != nil {
+		killProcessGroup(l.cmd)
+		l.cmd = nil
+	}
+}
+
+// saved lints the workspace after a Go file was saved, if lint on_save is set
+func (l *linter) saved(buf *Buffer) {
+	if config.Lint.OnSave && isGoFile(buf.Path()) {
+		l.run()
+	}
+}
+
+// parseLintOutput reads the findings golangci-lint printed: a JSON report,
+// or file:line:col: lines when the arguments ask for text. It reports
+// whether the output held findings or a report.
+func parseLintOutput(output []byte) ([]Diagnostic, bool) {
+	var diagnostics []Diagnostic
+	var report struct {
+		Issues []lintIssue
+	}
+	trimmed := bytes.TrimSpace(output)
+	if bytes.HasPrefix(trimmed, []byte("{")) && json.NewDecoder(bytes.NewReader(trimmed)).Decode(&report) == nil {
+		for _, issue := range report.Issues {
+			severity := SeverityWarning
+			if issue.Severity == "error" {
+				severity = SeverityError
+			}
+			message := strings.TrimSpace(issue.Text)
+			if issue.FromLinter != "" {
+				message = issue.FromLinter + ": " + message
+			}
+			diagnostics = append(diagnostics, locationDiagnostic(workspaceRoot, issue.Pos.Filename, issue.Pos.Line, issue.Pos.Column, severity, lintSource, message))
+		}
+		return diagnostics, true
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if diagnostic, ok := parseLocationLine(workspaceRoot, scanner.Text(), lintSource); ok {
+			diagnostic.Severity = SeverityWarning
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	return diagnostics, len(diagnostics) > 0
+}
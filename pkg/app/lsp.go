@@ -0,0 +1,367 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// lspShutdownTimeout bounds each step of shutting down a language server
+const lspShutdownTimeout = 2 * time.Second
+
+// lspMessage is a JSON-RPC 2.0 request, response, or notification
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+// lspError is the error object of a failed JSON-RPC call
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Protocol types, limited to the fields goui uses
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes         map[string][]lspTextEdit `json:"changes"`
+	DocumentChanges []struct {
+		TextDocument struct {
+			URI     string `json:"uri"`
+			Version *int   `json:"version"`
+		} `json:"textDocument"`
+		Edits []lspTextEdit `json:"edits"`
+	} `json:"documentChanges"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label      string       `json:"label"`
+	Detail     string       `json:"detail"`
+	FilterText string       `json:"filterText"`
+	InsertText string       `json:"insertText"`
+	TextEdit   *lspTextEdit `json:"textEdit"`
+}
+
+type lspCompletionList struct {
+	Items []lspCompletionItem `json:"items"`
+}
+
+type lspHover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// lspClient speaks the Language Server Protocol with a server process over
+// its standard input and output
+type lspClient struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	queue chan []byte
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]func(json.RawMessage, error)
+
+	// handle is called from the reader goroutine for every notification
+	handle func(method string, params json.RawMessage)
+	done   chan struct{}
+}
+
+// startLSPClient launches a language server and starts exchanging messages
+func startLSPClient(dir string, handle func(string, json.RawMessage), name string, args ...string) (*lspClient, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdout: %w", err)
+	}
+	// What the server prints besides the protocol goes to the log
+	cmd.Stderr = applog.writer(logDebug, name)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	applog.log(logInfo, "Language server started", "program", name, "dir", dir, "pid", cmd.Process.Pid)
+
+	c := &lspClient{
+		cmd:     cmd,
+		stdin:   stdin,
+		queue:   make(chan []byte, 256),
+		pending: make(map[int]func(json.RawMessage, error)),
+		handle:  handle,
+		done:    make(chan struct{}),
+	}
+	go c.writeLoop()
+	go c.readLoop(bufio.NewReader(stdout))
+	return c, nil
+}
+
+// writeLoop sends queued messages in order so callers never block on the pipe
+func (c *lspClient) writeLoop() {
+	defer recoverPanic()
+	for {
+		select {
+		case body := <-c.queue:
+			header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(body))
+			if _, err := io.WriteString(c.stdin, header); err != nil {
+				return
+			}
+			if _, err := c.stdin.Write(body); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop decodes incoming messages until the server exits
+func (c *lspClient) readLoop(r *bufio.Reader) {
+	defer recoverPanic()
+	defer close(c.done)
+	headers := textproto.NewReader(r)
+	for {
+		mime, err := headers.ReadMIMEHeader()
+		if err != nil {
+			c.failPending(fmt.Errorf("language server exited: %w", err))
+			return
+		}
+		length, err := strconv.Atoi(mime.Get("Content-Length"))
+		if err != nil {
+			c.failPending(fmt.Errorf("invalid message header: %w", err))
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			c.failPending(fmt.Errorf("failed to read message: %w", err))
+			return
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+		c.dispatch(&msg)
+	}
+}
+
+// dispatch routes a decoded message to its response handler or to handle
+func (c *lspClient) dispatch(msg *lspMessage) {
+	switch {
+	case msg.Method != "" && msg.ID != nil:
+		c.reply(msg)
+	case msg.Method != "":
+		if c.handle != nil {
+			c.handle(msg.Method, msg.Params)
+		}
+	case msg.ID != nil:
+		var id int
+		if err := json.Unmarshal(*msg.ID, &id); err != nil {
+			return
+		}
+		c.mu.Lock()
+		handler := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		if handler == nil {
+			return
+		}
+		if msg.Error != nil {
+			handler(nil, msg.Error)
+		} else {
+			handler(msg.Result, nil)
+		}
+	}
+}
+
+// reply answers requests sent by the server. goui does not implement any
+// server-to-client request, so each is acknowledged with an empty result.
+func (c *lspClient) reply(msg *lspMessage) {
+	var result interface{}
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		result = make([]interface{}, len(params.Items))
+	}
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+}
+
+// failPending reports err to every call still waiting for a response
+func (c *lspClient) failPending(err error) {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[int]func(json.RawMessage, error))
+	c.mu.Unlock()
+	for _, handler := range pending {
+		handler(nil, err)
+	}
+}
+
+// send encodes and queues a message
+func (c *lspClient) send(msg interface{}) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	select {
+	case <-c.done:
+	case c.queue <- body:
+	}
+}
+
+// call sends a request; handler runs on the reader goroutine with the result
+func (c *lspClient) call(method string, params interface{}, handler func(json.RawMessage, error)) {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = handler
+	c.mu.Unlock()
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+}
+
+// notify sends a notification
+func (c *lspClient) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// close asks the server to shut down and waits for it to exit, killing it
+// if it does not respond in time
+func (c *lspClient) close() {
+	acknowledged := make(chan struct{})
+	c.call("shutdown", nil, func(json.RawMessage, error) {
+		close(acknowledged)
+	})
+	select {
+	case <-acknowledged:
+	case <-c.done:
+	case <-time.After(lspShutdownTimeout):
+	}
+	c.notify("exit", nil)
+
+	exited := make(chan struct{})
+	go func() {
+		defer recoverPanic()
+		_ = c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(lspShutdownTimeout):
+		_ = c.cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// pathToURI converts a file path into a file:// URI
+func pathToURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	slashed := filepath.ToSlash(abs)
+	if filepath.VolumeName(abs) != "" && !strings.HasPrefix(slashed, "/") {
+		// C:/Users is file:///C:/Users, not a host named C:
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+// uriToPath converts a file:// URI into a path relative to the workspace
+// when possible
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	slashed := u.Path
+	if len(slashed) > 1 && slashed[0] == '/' && filepath.VolumeName(slashed[1:]) != "" {
+		// The path of file:///C:/Users is C:/Users
+		slashed = slashed[1:]
+	}
+	path := filepath.FromSlash(slashed)
+	if root, err := filepath.Abs(workspaceRoot); err == nil {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.Join(workspaceRoot, rel)
+		}
+	}
+	return path
+}
+
+// utf16Column converts a rune column into the UTF-16 offset used by LSP
+func utf16Column(line []rune, col int) int {
+	n := 0
+	for i := 0; i < col && i < len(line); i++ {
+		n += len(utf16.Encode([]rune{line[i]}))
+	}
+	return n
+}
+
+// runeColumn converts a UTF-16 offset into a rune column
+func runeColumn(line []rune, offset int) int {
+	n := 0
+	for i, r := range line {
+		if n >= offset {
+			return i
+		}
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// macroRecorder records the keys pressed between starting and stopping a
+// recording, to press them again on request
+type macroRecorder struct {
+	recording bool
+	keys      []*tcell.EventKey // the recording being made
+	macro     []*tcell.EventKey // the last recording finished
+	playing   bool
+}
+
+var macros macroRecorder
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "macro", Order: 5, Right: true, Text: func() string {
+		if !macros.recording {
+			return ""
+		}
+		return fmt.Sprintf("Recording macro (%d keys)", len(macros.keys))
+	}})
+}
+
+// toggle starts a recording, or stops the one being made and keeps it as
+// the macro to play
+func (m *macroRecorder) toggle() {
+	if !m.recording {
+		m.recording, m.keys = true, nil
+		ui.output.message("Recording macro; Alt+Q stops recording")
+		return
+	}
+	m.recording = false
+	if len(m.keys) == 0 {
+		ui.output.message("Macro not recorded: no keys were pressed")
+		return
+	}
+	m.macro = m.keys
+	ui.output.message(fmt.Sprintf("Recorded a macro of %d keys", len(m.macro)))
+}
+
+// record adds a key pressed to the recording being made. The keys of the
+// macro commands themselves are left out.
+func (m *macroRecorder) record(event *tcell.EventKey) {
+	if !m.recording || m.playing {
+		return
+	}
+	if action := bindings.lookup(scopeGlobal, event); action == "record-macro" || strings.HasPrefix(action, "play-macro") {
+		return
+	}
+	m.keys = append(m.keys, tcell.NewEventKey(event.Key(), event.Rune(), event.Modifiers()))
+}
+
+// play presses the keys of the macro times times, as if they were typed
+func (m *macroRecorder) play(times int) error {
+	switch {
+	case m.recording:
+		return fmt.Errorf("stop recording first")
+	case len(m.macro) == 0:
+		return fmt.Errorf("no macro recorded")
+	case m.playing:
+		return nil
+	}
+	m.playing = true
+	defer func() { m.playing = false }()
+	for i := 0; i < times; i++ {
+		for _, event := range m.macro {
+			pressKey(tcell.NewEventKey(event.Key(), event.Rune(), event.Modifiers()))
+		}
+	}
+	return nil
+}
+
+// promptPlayMacro asks how many times to play the macro and plays it
+func promptPlayMacro() {
+	showPrompt("Play macro how many times: ", "1", func(text string) {
+		times, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || times < 1 {
+			ui.output.message(fmt.Sprintf("Invalid count: %s", text))
+			return
+		}
+		if err := macros.play(times); err != nil {
+			notify(noticeError, fmt.Sprintf("Error playing macro: %s", err))
+		}
+	})
+}
+
+// pressKey handles a key event the way the application handles one typed:
+// through the global bindings, then by the focused widget
+func pressKey(event *tcell.EventKey) {
+	if capture := ui.app.GetInputCapture(); capture != nil {
+		if event = capture(event); event == nil {
+			return
+		}
+	}
+	if focus := ui.app.GetFocus(); focus != nil {
+		if handler := focus.InputHandler(); handler != nil {
+			handler(event, func(p tview.Primitive) { ui.app.SetFocus(p) })
+		}
+	}
+}
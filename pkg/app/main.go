@@ -0,0 +1,885 @@
+package app
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gotui/pkg/editor"
+	"gotui/pkg/explorer"
+	"gotui/pkg/terminal"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// UI represents the main UI components
+type UI struct {
+	app          *tview.Application
+	root         *tview.Flex
+	menuBar      *tview.TextView
+	statusBar    *statusBar
+	fileExplorer *explorer.Explorer
+	editor       *editor.Editor
+	editorPane   *tview.Flex
+	editorArea   *tview.Pages   // the editor, or the large-file view in its place
+	largeView    *LargeFileView // view of the active buffer in large-file mode
+	hexView      *HexView       // view of the active buffer if it is binary
+	content      *tview.Flex    // explorer and right panel, side by side
+	rightPanel   *tview.Flex    // editor, panels, and terminal, top to bottom
+	output       *outputView
+	panels       *tview.Pages
+	terminalPane *tview.Flex
+	terminal     *terminal.View // view of the active terminal session
+}
+
+var (
+	ui UI
+
+	// workspaceRoot is the directory shown in the file explorer and searched
+	// by workspace-wide features, along with the workspaceFolders added to it
+	workspaceRoot = "."
+)
+
+// Main runs goui with the arguments of the command line and returns once
+// it quits
+func Main() {
+	defer recoverPanic()
+	terminalOverrides = registerTerminalFlags(flag.CommandLine)
+	readOnly := flag.Bool("readonly", false, "open files read-only")
+	line := flag.Int("line", 0, "line to put the cursor on in the file given as argument")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file or directory]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var configErr error
+	config, configErr = loadConfig()
+	loadLayout()
+	if err := setTheme(config.Theme); err != nil {
+		log.Fatalf("Invalid theme: %v", err)
+	}
+	if err := terminalOverrides.apply(&config.Terminal); err != nil {
+		log.Fatalf("Invalid terminal settings: %v", err)
+	}
+	file, err := openArgs(flag.Args(), *line)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ui.app = tview.NewApplication()
+	watchErr := watcher.start()
+	defer watcher.close()
+
+	if err = createUI(); err != nil {
+		log.Fatalf("Failed to create UI: %v", err)
+	}
+	if configErr == nil {
+		configErr = applog.configure(config.Log)
+	}
+	if configErr != nil {
+		notify(noticeError, fmt.Sprintf("Error loading config: %s", configErr))
+	} else if watchErr != nil {
+		notify(noticeWarning, fmt.Sprintf("Changes made outside the editor will not be noticed: %s", watchErr))
+	}
+
+	// Commands of the init script can be bound in the keys files
+	scriptErr := scripts.load()
+	defer scripts.close()
+
+	if err = setupKeyBindings(); err != nil {
+		log.Fatalf("Failed to set up key bindings: %v", err)
+	}
+
+	ui.app.SetRoot(ui.root, true)
+	buffers.readOnly = *readOnly
+	plugins.start()
+	if err := loadWorkspaceFolders(); err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading workspace folders: %s", err))
+	}
+	if err := bookmarks.load(); err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading bookmarks: %s", err))
+	}
+	if file != "" {
+		if err := openStartFile(file, *line); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	}
+	if scriptErr != nil {
+		notify(noticeError, fmt.Sprintf("Error running init script: %s", scriptErr))
+	}
+	if err := autosave.loadProjectSetting(); err != nil {
+		notify(noticeWarning, fmt.Sprintf("Autosave is off: %s", err))
+	}
+	if err := recovery.start(); err != nil {
+		notify(noticeWarning, fmt.Sprintf("Unsaved changes cannot be recovered after a crash: %s", err))
+	}
+	recovery.offerRestore()
+	git.refresh()
+	ui.app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		notifications.draw(screen)
+		plugins.checkFocus()
+	})
+
+	applog.capture()
+	handleStopSignals()
+	err = ui.app.EnableMouse(true).EnablePaste(true).Run()
+	waitIfCrashing()
+	shutdown(err)
+}
+
+// openArgs handles the command line arguments. A directory becomes the
+// workspace, and a file is returned to be opened once the UI is up.
+func openArgs(args []string, line int) (string, error) {
+	if len(args) > 1 {
+		return "", fmt.Errorf("expected one file or directory, got %d arguments", len(args))
+	}
+	if line < 0 {
+		return "", fmt.Errorf("invalid line number %d", line)
+	}
+	if len(args) == 0 {
+		if line > 0 {
+			return "", fmt.Errorf("-line needs a file to open")
+		}
+		return "", nil
+	}
+	path := args[0]
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		if line > 0 {
+			return "", fmt.Errorf("-line needs a file to open, not a directory")
+		}
+		// Everything works relative to the workspace, terminals included
+		if err := os.Chdir(path); err != nil {
+			return "", fmt.Errorf("failed to open workspace: %w", err)
+		}
+		return "", nil
+	case err == nil:
+		return path, nil
+	case errors.Is(err, os.ErrNotExist):
+		// A new file, created on save
+		if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("cannot create %s: its directory does not exist", path)
+		}
+		return path, nil
+	}
+	return "", fmt.Errorf("failed to open %s: %w", path, err)
+}
+
+// openStartFile opens the file given on the command line, creating an empty
+// buffer if it does not exist, and moves the cursor to line if it is set
+func openStartFile(path string, line int) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		buffers.create(path)
+	} else if err := loadFile(path); err != nil {
+		return err
+	}
+	switch {
+	case line > 0 && buffers.current().large != nil:
+		ui.largeView.goToLine(line - 1)
+	case line > 0:
+		ui.editor.SetCursor(editor.Position{Line: line - 1})
+	}
+	ui.app.SetFocus(ui.editor)
+	return nil
+}
+
+// createUI initializes and sets up the user interface components
+func createUI() error {
+	ui.root = tview.NewFlex().SetDirection(tview.FlexRow)
+
+	ui.menuBar = createMenuBar()
+	ui.root.AddItem(ui.menuBar, 1, 0, false)
+
+	ui.content = tview.NewFlex().SetDirection(tview.FlexColumn)
+
+	var err error
+	ui.fileExplorer, err = createFileExplorer()
+	if err != nil {
+		return fmt.Errorf("failed to create file explorer: %w", err)
+	}
+	ui.content.AddItem(ui.fileExplorer, config.Layout.ExplorerWidth, 0, true)
+
+	ui.rightPanel = tview.NewFlex().SetDirection(tview.FlexRow)
+	ui.editor = createEditor()
+	buffers.tabBar = createTabBar()
+	ui.panels = tview.NewPages()
+	for _, panel := range []struct {
+		name string
+		item tview.Primitive
+	}{
+		{"output", createOutputPane()},
+		{"search", createSearchPanel()},
+		{"problems", createProblemsPanel()},
+		{"references", createReferencesPanel()},
+		{"tests", createTestsPanel()},
+		{"git", createGitPanel()},
+		{"doc", createDocPanel()},
+		{"modules", createModulesPanel()},
+		{"variables", createVariablesPanel()},
+		{"stack", createStackPanel()},
+		{"console", createConsolePanel()},
+		{"coverage", createCoveragePanel()},
+		{"notifications", createNotificationsPanel()},
+	} {
+		ui.panels.AddPage(panel.name, panel.item, true, panel.name == "output")
+		pageItems[ui.panels] = append(pageItems[ui.panels], panel.item)
+	}
+	ui.terminalPane, err = createTerminalPane()
+	if err != nil {
+		return fmt.Errorf("failed to create terminal: %w", err)
+	}
+	ui.largeView = NewLargeFileView()
+	ui.hexView = NewHexView()
+	ui.editorArea = tview.NewPages().
+		AddPage("editor", ui.editor, true, true).
+		AddPage("large", ui.largeView, true, false).
+		AddPage("hex", ui.hexView, true, false)
+	pageItems[ui.editorArea] = []tview.Primitive{ui.editor, ui.largeView, ui.hexView}
+	ui.editorPane = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(buffers.tabBar, 1, 0, false).
+		AddItem(ui.editorArea, 0, 1, false).
+		AddItem(createFindBar(), 0, 0, false)
+	ui.rightPanel.AddItem(ui.editorPane, 0, config.Layout.EditorHeight, false)
+	ui.rightPanel.AddItem(ui.panels, 0, config.Layout.PanelHeight, false)
+	ui.rightPanel.AddItem(ui.terminalPane, 0, config.Layout.TerminalHeight, false)
+
+	ui.content.AddItem(ui.rightPanel, 0, 1, false)
+
+	ui.root.AddItem(ui.content, 0, 1, true)
+	ui.statusBar = createStatusBar()
+	ui.root.AddItem(ui.statusBar, 1, 0, false)
+	ui.root.SetMouseCapture(captureSplitterMouse)
+
+	return nil
+}
+
+// setupKeyBindings loads the key bindings and configures the global ones
+// for the application
+func setupKeyBindings() error {
+	if err := reloadKeyBindings(); err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading key bindings: %s", err))
+	}
+	ui.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		macros.record(event)
+		if keymapReserves(event) {
+			return forwardKey(event)
+		}
+		action := bindings.lookup(scopeGlobal, event)
+		if action == "" && ui.app.GetFocus() == ui.terminal {
+			action = bindings.lookup(scopeTerminal, event)
+		}
+		if !runCommand(action) {
+			return forwardKey(event)
+		}
+		return nil
+	})
+	return nil
+}
+
+// forwardKey returns a key event to pass on to the focused widget. tview
+// stops the application on Ctrl+C unless it gets a copy of the event, which
+// would skip asking about unsaved changes and keep Ctrl+C from terminals.
+func forwardKey(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyCtrlC {
+		return tcell.NewEventKey(event.Key(), event.Rune(), event.Modifiers())
+	}
+	return event
+}
+
+// reloadKeyBindings loads the keys file and applies it, keeping the current
+// bindings if it is invalid
+func reloadKeyBindings() error {
+	loaded, err := loadKeyBindings()
+	if err != nil {
+		if bindings.scopes == nil {
+			bindings, _ = buildKeyBindings("default", defaultKeySpecs())
+		}
+		return err
+	}
+	bindings = loaded
+	if ui.menuBar != nil {
+		ui.menuBar.SetText(menuText())
+	}
+	profile := bindings.profile
+	if env := os.Getenv("GOUI_KEYMAP"); env != "" {
+		profile = env
+	}
+	switch profile {
+	case "vim":
+		if _, ok := ui.editor.Keymap().(*editor.VimKeymap); !ok {
+			ui.editor.SetKeymap(editor.NewVimKeymap(runExCommand, runVimAction))
+		}
+	case "emacs":
+		if _, ok := ui.editor.Keymap().(*editor.EmacsKeymap); !ok {
+			ui.editor.SetKeymap(editor.NewEmacsKeymap(runEmacsAction))
+		}
+	default:
+		ui.editor.SetKeymap(nil)
+	}
+	return nil
+}
+
+// menuItems are the commands of the menu bar, with their labels
+var menuItems = [][2]string{
+	{"save", "Save"}, {"quit", "Quit"}, {"focus-terminal", "Terminal"},
+	{"focus-editor", "Editor"}, {"focus-explorer", "Files"},
+	{"customize-terminal", "Customize Terminal"}, {"command-palette", "Commands"},
+}
+
+// createMenuBar creates and returns the menu bar component, whose items run
+// their command when clicked
+func createMenuBar() *tview.TextView {
+	menuBar := tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(false)
+
+	menuBar.SetText(menuText())
+	menuBar.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		menuBar.Highlight()
+		runCommand(added[0])
+	})
+	// The widget with the focus keeps it, so that the commands act on it
+	menuBar.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseLeftDown {
+			return action, nil
+		}
+		return action, event
+	})
+
+	return menuBar
+}
+
+// menuText returns the text of the menu bar, with the keys bound to each
+// command in the accent color
+func menuText() string {
+	var b strings.Builder
+	for _, item := range menuItems {
+		if b.Len() > 0 {
+			b.WriteString("   ")
+		}
+		fmt.Fprintf(&b, `["%s"]`, item[0])
+		if keys := bindings.keys[item[0]]; len(keys) > 0 {
+			fmt.Fprintf(&b, "%s%s[-] ", colorTag(theme.Accent), tview.Escape(keys[0]))
+		}
+		fmt.Fprintf(&b, `%s[""]`, item[1])
+	}
+	return b.String()
+}
+
+// createEditor creates and returns the text editor component
+func createEditor() *editor.Editor {
+	e := editor.NewEditor().
+		SetTabWidth(config.Editor.TabWidth).
+		SetInsertSpaces(config.Editor.InsertSpaces).
+		SetAutoIndent(config.Editor.AutoIndent).
+		SetAutoClose(config.Editor.AutoClose).
+		SetWordHighlight(config.Editor.HighlightOccurrences).
+		SetInvisibles(config.Editor.ShowInvisibles).
+		SetWrap(config.Editor.SoftWrap).
+		SetFolding(true).
+		SetPlaceholder("No file loaded.").
+		SetColors(editorColors(theme)).
+		SetSnippetsFunc(snippetsFor).
+		SetChangedFunc(func() {
+			buf := buffers.shown()
+			buffers.setDirty(buf.Modified())
+			buf.unswapped = true
+			finder.update(false)
+			gopls.didChange(buf)
+			blame.changed()
+			autosave.changed()
+		}).
+		SetGutterClickFunc(func(line int, annotation bool) {
+			if annotation {
+				blame.showCommit(line)
+				return
+			}
+			if err := delve.toggleBreakpoint(line); err != nil {
+				notify(noticeError, fmt.Sprintf("Error setting breakpoint: %s", err))
+			}
+		}).
+		SetReplacedFunc(func(from, to, end editor.Position) {
+			path := ui.editor.Buffer().Path()
+			shiftBookmarks(path, from, to, end)
+			shiftBreakpoints(path, from, to, end)
+			shiftCoverage(path, from, to, end)
+		}).
+		SetRefusedFunc(func() {
+			ui.output.message(fmt.Sprintf("%s is read-only", ui.editor.Buffer().Name()))
+		}).
+		// Coverage comes first among the gutter marks so the others win
+		SetGutterMarks("coverage", nil)
+
+	buffers.scratch = NewBuffer("", "")
+	e.SetBuffer(buffers.scratch.Buffer)
+	e.SetBlurFunc(autosave.focusLost)
+	e.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if keymap := e.Keymap(); keymap != nil && keymap.Reserved(event) {
+			return event
+		}
+		switch action := bindings.lookup(scopeEditor, event); {
+		case runCommand(action):
+		case event.Key() == tcell.KeyEnter && blame.shown() && !e.Completing():
+			blame.showCommit(e.Cursor().Line)
+		case event.Key() == tcell.KeyRune && event.Rune() == '.' && event.Modifiers() == 0 && e.Inserting():
+			// Member completion pops up as soon as a selector is typed
+			e.InsertText(".")
+			e.FilterCompletions()
+			gopls.complete()
+		default:
+			return event
+		}
+		return nil
+	})
+
+	return e
+}
+
+// runExCommand runs the ':' commands of the Vim keymap
+func runExCommand(command string) error {
+	switch command {
+	case "w":
+		return saveFile()
+	case "q":
+		closeTab()
+		return nil
+	case "q!":
+		return buffers.discard()
+	case "wq", "x":
+		if err := saveFile(); err != nil {
+			return err
+		}
+		return buffers.close()
+	case "qa", "qall":
+		quit()
+		return nil
+	case "qa!", "qall!":
+		ui.app.Stop()
+		return nil
+	}
+	return fmt.Errorf("not an editor command: %s", command)
+}
+
+// runVimAction runs the application actions bound to Vim keys
+func runVimAction(action string) error {
+	switch action {
+	case "jump-back":
+		return jumps.back()
+	case "jump-forward":
+		return jumps.forward()
+	}
+	return nil
+}
+
+// runEmacsAction runs the application actions bound to Emacs chords
+func runEmacsAction(action string) error {
+	switch action {
+	case "save":
+		return saveFile()
+	case "quit":
+		quit()
+	case "close":
+		closeTab()
+	case "next-buffer":
+		buffers.cycle(1)
+	case "find-file":
+		ui.app.SetFocus(ui.fileExplorer)
+	case "find":
+		finder.show()
+	case "goto-line":
+		promptGoToLine()
+	case "complete":
+		gopls.complete()
+	}
+	return nil
+}
+
+// copySelection copies the editor selection to the clipboard, optionally
+// deleting it. Without a selection the current line is copied.
+func copySelection(cut bool) {
+	if ui.editor.Cursors() > 1 {
+		// Each cursor's text goes on a line of its own, so that pasting
+		// it back at as many cursors gives each one its own line
+		if err := copyToClipboard(strings.Join(ui.editor.SelectedTexts(), "\n")); err != nil {
+			notify(noticeError, fmt.Sprintf("Error copying to clipboard: %s", err))
+		}
+		if cut {
+			ui.editor.DeleteSelections()
+		}
+		return
+	}
+	from, to := ui.editor.Selection()
+	if from == to {
+		line := ui.editor.Cursor().Line
+		from, to = editor.Position{Line: line}, editor.Position{Line: line + 1}
+		if line == ui.editor.LineCount()-1 {
+			to = editor.Position{Line: line, Col: len([]rune(ui.editor.Line(line)))}
+		}
+	}
+	text := ui.editor.TextRange(from, to)
+	if text == "" {
+		return
+	}
+	if err := copyToClipboard(text); err != nil {
+		notify(noticeError, fmt.Sprintf("Error copying to clipboard: %s", err))
+	}
+	if cut {
+		ui.editor.Replace(from, to, "")
+		ui.editor.SetCursor(from)
+	}
+}
+
+// pasteClipboard inserts the clipboard content at the cursor, replacing the
+// selection
+func pasteClipboard() {
+	text, err := readClipboard()
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error reading clipboard: %s", err))
+		return
+	}
+	ui.editor.Paste(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// pasteToTerminal pastes the editor selection, or the current line when
+// nothing is selected, into the active terminal
+func pasteToTerminal() {
+	text := ui.editor.SelectedText()
+	if text == "" {
+		text = ui.editor.Line(ui.editor.Cursor().Line)
+	}
+	terminals.current().paste(text)
+	ui.app.SetFocus(ui.terminal)
+}
+
+// customizeTerminal creates and displays a form for customizing the terminal colors
+func customizeTerminal() {
+	bgInput := tview.NewInputField().SetLabel("Background Color")
+	textInput := tview.NewInputField().SetLabel("Text Color")
+
+	form := tview.NewForm().
+		AddFormItem(bgInput).
+		AddFormItem(textInput).
+		AddButton("Save", func() {
+			bgColor := bgInput.GetText()
+			textColor := textInput.GetText()
+			closeDialog()
+			terminals.setColors(tcell.GetColor(bgColor), tcell.GetColor(textColor))
+		}).
+		AddButton("Cancel", closeDialog)
+	form.SetCancelFunc(closeDialog)
+	form.SetBorder(true).SetTitle("Customize Terminal")
+	showDialog(form, 40, 10)
+}
+
+// loadFile opens a file in a new editor tab, or switches to its tab if it is
+// already open
+func loadFile(path string) error {
+	jumps.push()
+	if err := buffers.open(path); err != nil {
+		return err
+	}
+	showPane(editorPane)
+	ui.output.message(fmt.Sprintf("Loaded file: %s", path))
+	return nil
+}
+
+// openFileAt opens a file and selects the range between from and to
+func openFileAt(path string, from, to editor.Position) error {
+	if err := loadFile(path); err != nil {
+		return err
+	}
+	ui.editor.Select(from, to)
+	ui.app.SetFocus(ui.editor)
+	return nil
+}
+
+// promptGoToLine asks for a line number, optionally followed by ":column",
+// and moves the cursor there
+func promptGoToLine() {
+	buf := buffers.current()
+	if buf == nil {
+		return
+	}
+	if buf.hex != nil {
+		promptGoToOffset()
+		return
+	}
+	lines := ui.editor.LineCount()
+	if buf.large != nil {
+		lines = buf.large.lineCount()
+	}
+	label := fmt.Sprintf("Go to line (1-%d): ", lines)
+	showPrompt(label, "", func(text string) {
+		var col int
+		parts := strings.SplitN(strings.TrimSpace(text), ":", 2)
+		line, err := strconv.Atoi(parts[0])
+		if err == nil && len(parts) == 2 {
+			col, err = strconv.Atoi(parts[1])
+		}
+		if err != nil || line < 1 || col < 0 {
+			ui.output.message(fmt.Sprintf("Invalid line number: %s", text))
+			return
+		}
+		if col > 0 {
+			col--
+		}
+		if buf.large != nil {
+			ui.largeView.goToLine(line - 1)
+			ui.app.SetFocus(ui.largeView)
+			return
+		}
+		jumps.push()
+		ui.editor.SetCursor(editor.Position{Line: line - 1, Col: col})
+		ui.app.SetFocus(ui.editor)
+	})
+}
+
+// showPanel brings the named panel to the front of the panel area
+func showPanel(name string) {
+	ui.panels.SwitchToPage(name)
+	showPane(panelsPane)
+}
+
+// saveFile saves the content of the editor to the current file, asking
+// first if the file changed on disk since it was loaded
+func saveFile() error {
+	buf := buffers.current()
+	if buf == nil {
+		return fmt.Errorf("no file loaded")
+	}
+	if buf.ReadOnly() {
+		return fmt.Errorf("%s is read-only", buf.Path())
+	}
+	if buf.changedOnDisk() {
+		question := fmt.Sprintf("%s changed on disk since it was loaded. Overwrite the changes made there?", buf.Path())
+		chooseAction(question, []string{"Overwrite", "Reload", "Show Diff", "Cancel"}, func(index int) {
+			if buffers.current() != buf {
+				return
+			}
+			switch index {
+			case 0:
+				if err := writeFile(buf); err != nil {
+					notify(noticeError, fmt.Sprintf("Error saving file: %s", err))
+				}
+			case 1:
+				if err := buffers.reload(buf); err != nil {
+					notify(noticeError, fmt.Sprintf("Error reloading file: %s", err))
+					return
+				}
+				ui.output.message(fmt.Sprintf("Reloaded %s, dropping your changes", buf.Path()))
+			case 2:
+				showDiskDiff(buf)
+			}
+		})
+		return nil
+	}
+	return writeFile(buf)
+}
+
+// writeTempText writes text to a new temporary file named with the
+// extension ext and returns its path
+func writeTempText(ext, text string) (string, error) {
+	temp, err := os.CreateTemp("", "goui-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	_, err = temp.WriteString(text)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	return temp.Name(), err
+}
+
+// showDiskDiff shows how the text of a buffer differs from its file on disk
+func showDiskDiff(buf *Buffer) {
+	unsaved, err := writeTempText(filepath.Ext(buf.Path()), buf.Text())
+	if err != nil {
+		os.Remove(unsaved)
+		notify(noticeError, fmt.Sprintf("Error writing the unsaved text: %s", err))
+		return
+	}
+	saved := buf.Path()
+	if buf.encoding != encodingUTF8 || buf.lineEnding != lineEndingLF {
+		// git compares bytes, so a file in another encoding or with CRLF
+		// line endings is compared after reading it like the buffer's text
+		content, err := os.ReadFile(buf.Path())
+		var text string
+		if err == nil {
+			text, err = buf.encoding.decode(content)
+		}
+		if err == nil {
+			text, _ = splitLineEndings(text)
+			saved, err = writeTempText(filepath.Ext(buf.Path()), text)
+		}
+		if err != nil {
+			os.Remove(unsaved)
+			notify(noticeError, fmt.Sprintf("Error reading the file on disk: %s", err))
+			return
+		}
+	}
+	go func() {
+		defer recoverPanic()
+		defer os.Remove(unsaved)
+		if saved != buf.Path() {
+			defer os.Remove(saved)
+		}
+		rows, err := loadDiff([]string{"diff", "--no-index", "--", saved, unsaved})
+		ui.app.QueueUpdateDraw(func() {
+			if err != nil {
+				notify(noticeError, fmt.Sprintf("Error running git diff: %s", err))
+				return
+			}
+			if len(rows) == 0 {
+				ui.output.message("No changes")
+				return
+			}
+			for i := range rows {
+				// Lines open in the buffer rather than in the copy
+				rows[i].path = buf.Path()
+				if rows[i].kind == diffFile {
+					rows[i].text = []rune(buf.Path() + " on disk → unsaved")
+				}
+			}
+			openDiffView("Diff: "+buf.Path()+" on disk → unsaved", rows, diffNoStaging, nil)
+		})
+	}()
+}
+
+// saveAll writes every buffer with unsaved changes, stopping at the first
+// one that cannot be written, which is left active. Files changed on disk
+// since they were loaded are not overwritten.
+func saveAll() error {
+	for i, buf := range buffers.buffers {
+		if !buf.dirty {
+			continue
+		}
+		buffers.switchTo(i)
+		if buf.changedOnDisk() {
+			return fmt.Errorf("%s changed on disk since it was loaded; save it on its own to overwrite it", buf.Path())
+		}
+		if err := writeFile(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeTab closes the active tab, asking whether to save or discard its
+// unsaved changes
+func closeTab() {
+	buf := buffers.current()
+	if buf == nil || !buf.dirty {
+		if err := buffers.close(); err != nil {
+			notify(noticeError, fmt.Sprintf("Error closing file: %s", err))
+		}
+		return
+	}
+	question := fmt.Sprintf("%s has unsaved changes.", buf.Name())
+	chooseAction(question, []string{"Save", "Discard", "Cancel"}, func(index int) {
+		if buffers.current() != buf {
+			return
+		}
+		if index == 1 {
+			_ = buffers.discard()
+			return
+		}
+		if index != 0 {
+			return
+		}
+		if err := saveFile(); err != nil {
+			notify(noticeError, fmt.Sprintf("Error saving file: %s", err))
+			return
+		}
+		// Still dirty if saving waits for overwriting a changed file to be
+		// confirmed
+		if !buf.dirty {
+			_ = buffers.close()
+		}
+	})
+}
+
+// quit stops the application, asking first whether to save or discard
+// unsaved changes
+func quit() {
+	dirty := buffers.dirtyBuffers()
+	if len(dirty) == 0 {
+		ui.app.Stop()
+		return
+	}
+	question := fmt.Sprintf("%s has unsaved changes.", dirty[0].Name())
+	if len(dirty) > 1 {
+		question = fmt.Sprintf("%d files have unsaved changes.", len(dirty))
+	}
+	chooseAction(question, []string{"Save All", "Discard", "Cancel"}, func(index int) {
+		if index == 0 {
+			if err := saveAll(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error saving file: %s", err))
+				return
+			}
+		}
+		if index <= 1 {
+			ui.app.Stop()
+		}
+	})
+}
+
+// writeFile formats buf, the current buffer, and writes it to its file
+func writeFile(buf *Buffer) error {
+	content := ui.editor.GetText()
+	cleaned := cleanWhitespace(content)
+	formatted, formatErr := formatText(buf.Path(), cleaned)
+	if formatErr != nil {
+		formatted = cleaned
+	}
+	if formatted != content {
+		applyFormatted(formatted)
+	}
+	if err := writeBuffer(buf); err != nil {
+		return err
+	}
+	if formatErr != nil {
+		ui.output.message(fmt.Sprintf("File saved without formatting: %s\n%s", buf.Path(), formatErr))
+	} else {
+		ui.output.message(fmt.Sprintf("File saved: %s", buf.Path()))
+	}
+	// Hooks run last, so their messages are not overwritten
+	scripts.fileSaved(buf)
+	return nil
+}
+
+// writeBuffer writes a buffer as it is to its file, in the buffer's
+// encoding, and tells the rest of the editor
+func writeBuffer(buf *Buffer) error {
+	content, err := buf.encoding.encode(buf.fileText())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(buf.Path(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	buf.setDiskState(content)
+	buf.MarkSaved()
+	buf.dirty = false
+	buffers.refresh()
+	gopls.didSave(buf)
+	plugins.fileSaved(buf)
+	git.refresh()
+	lint.saved(buf)
+	watchMode.saved(buf)
+	if isGoFile(buf.Path()) && gopls.state == lspUnavailable {
+		runVet()
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// dependency is a module required by the workspace's go.mod
+type dependency struct {
+	path     string
+	version  string
+	latest   string // the newest version, if newer than version
+	indirect bool
+}
+
+// moduleManager lists the dependencies of the workspace's module with the
+// versions they could be updated to, and runs go get and go mod on them
+type moduleManager struct {
+	list     *tview.List
+	module   string
+	deps     []dependency
+	loaded   bool   // set once the panel was shown, to follow go.mod
+	checking bool   // set while the latest versions are looked up
+	checkErr string // why the latest versions are unknown, if they are
+	checks   int    // number of lookups started, to drop replaced ones
+}
+
+var modules moduleManager
+
+// createModulesPanel creates and returns the Dependencies list component
+func createModulesPanel() *tview.List {
+	m := &modules
+	m.list = tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true)
+	m.list.SetBorder(true).SetTitle("Dependencies")
+	m.list.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		m.update(index)
+	})
+	m.list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'u':
+			m.update(m.list.GetCurrentItem())
+		case 'a':
+			m.run("go get -u ./...")
+		case 't':
+			m.run("go mod tidy")
+		case 'v':
+			m.run("go mod vendor")
+		case 'r':
+			m.load()
+		default:
+			return event
+		}
+		return nil
+	})
+	m.list.SetDoneFunc(func() {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return m.list
+}
+
+// toggleModules shows the Dependencies panel, or hides it if it is showing
+func toggleModules() {
+	if name, _ := ui.panels.GetFrontPage(); name == "modules" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	if !modules.loaded {
+		modules.load()
+	}
+	showPanel("modules")
+	ui.app.SetFocus(modules.list)
+}
+
+// parseGoMod returns the module path and the requirements of a go.mod file
+func parseGoMod(content string) (string, []dependency) {
+	var module string
+	var deps []dependency
+	inRequire := false
+	for _, line := range strings.Split(content, "\n") {
+		indirect := false
+		if i := strings.Index(line, "//"); i >= 0 {
+			indirect = strings.TrimSpace(line[i+2:]) == "indirect"
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inRequire:
+			if fields[0] == ")" {
+				inRequire = false
+				continue
+			}
+		case fields[0] == "module" && len(fields) == 2:
+			module = strings.Trim(fields[1], `"`)
+			continue
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inRequire = true
+			continue
+		case fields[0] == "require":
+			fields = fields[1:]
+		default:
+			continue
+		}
+		if len(fields) == 2 {
+			deps = append(deps, dependency{path: strings.Trim(fields[0], `"`), version: fields[1], indirect: indirect})
+		}
+	}
+	return module, deps
+}
+
+// load reads the workspace's go.mod and looks up the latest versions of
+// its dependencies in the background
+func (m *moduleManager) load() {
+	m.loaded = true
+	m.checks++
+	m.module, m.deps, m.checking, m.checkErr = "", nil, false, ""
+	content, err := os.ReadFile(filepath.Join(workspaceRoot, "go.mod"))
+	if errors.Is(err, os.ErrNotExist) {
+		m.render()
+		return
+	}
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error reading go.mod: %s", err))
+		m.render()
+		return
+	}
+	m.module, m.deps = parseGoMod(string(content))
+	if len(m.deps) > 0 {
+		m.checking = true
+		m.checkLatest()
+	}
+	m.render()
+}
+
+// listedModule is a module as go list -m -json prints it
+type listedModule struct {
+	Path    string
+	Version string
+	Update  *struct{ Version string }
+}
+
+// checkLatest runs go list to find the dependencies that have newer
+// versions, which needs the network or the module cache
+func (m *moduleManager) checkLatest() {
+	check := m.checks
+	args := []string{"list", "-m", "-u", "-json"}
+	for _, dep := range m.deps {
+		args = append(args, dep.path)
+	}
+	go func() {
+		defer recoverPanic()
+		cmd := exec.Command("go", args...)
+		cmd.Dir = workspaceRoot
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		latest := map[string]string{}
+		decoder := json.NewDecoder(bytes.NewReader(output))
+		for {
+			var listed listedModule
+			if decodeErr := decoder.Decode(&listed); decodeErr != nil {
+				if decodeErr != io.EOF && err == nil {
+					err = decodeErr
+				}
+				break
+			}
+			if listed.Update != nil {
+				latest[listed.Path] = listed.Update.Version
+			}
+		}
+		ui.app.QueueUpdateDraw(func() {
+			if m.checks != check {
+				return
+			}
+			m.checking = false
+			if err != nil {
+				if m.checkErr = strings.TrimSpace(stderr.String()); m.checkErr == "" {
+					m.checkErr = err.Error()
+				}
+			}
+			for i := range m.deps {
+				m.deps[i].latest = latest[m.deps[i].path]
+			}
+			m.render()
+		})
+	}()
+}
+
+// render fills the list with the dependencies
+func (m *moduleManager) render() {
+	current := m.list.GetCurrentItem()
+	m.list.Clear()
+	if m.module == "" {
+		m.list.SetTitle("Dependencies")
+		m.list.AddItem("No go.mod in the workspace", "", 0, nil)
+		return
+	}
+	width := 0
+	for _, dep := range m.deps {
+		if len(dep.path) > width {
+			width = len(dep.path)
+		}
+	}
+	updates := 0
+	for _, dep := range m.ordered() {
+		item := tview.Escape(fmt.Sprintf("%-*s  %s", width, dep.path, dep.version))
+		if dep.indirect {
+			item = colorTag(theme.Muted) + item + "[-]"
+		}
+		switch {
+		case dep.latest != "":
+			item += fmt.Sprintf("  %s→ %s[-]", colorTag(theme.Warning), tview.Escape(dep.latest))
+			updates++
+		case m.checking:
+			item += fmt.Sprintf("  %schecking…[-]", colorTag(theme.Muted))
+		}
+		if dep.indirect {
+			item += fmt.Sprintf("  %s// indirect[-]", colorTag(theme.Muted))
+		}
+		m.list.AddItem(item, "", 0, nil)
+	}
+	title := fmt.Sprintf("Dependencies of %s (%d)", m.module, len(m.deps))
+	switch {
+	case m.checking:
+	case m.checkErr != "":
+		title += " - latest versions unknown"
+		notify(noticeError, fmt.Sprintf("Error checking for updates: %s", m.checkErr))
+	case updates > 0:
+		title += fmt.Sprintf(" - %d updates", updates)
+	default:
+		title += " - up to date"
+	}
+	m.list.SetTitle(title)
+	m.list.SetCurrentItem(current)
+}
+
+// ordered returns the dependencies in the order they are listed: direct
+// ones first
+func (m *moduleManager) ordered() []dependency {
+	var deps []dependency
+	for _, indirect := range []bool{false, true} {
+		for _, dep := range m.deps {
+			if dep.indirect == indirect {
+				deps = append(deps, dep)
+			}
+		}
+	}
+	return deps
+}
+
+// update runs go get -u for the dependency at an index of the list
+func (m *moduleManager) update(index int) {
+	deps := m.ordered()
+	if index < 0 || index >= len(deps) {
+		return
+	}
+	m.run("go get -u " + deps[index].path)
+}
+
+// run runs a go command for the module as a task, with its output in the
+// Output pane. The list follows the changes it makes to go.mod.
+func (m *moduleManager) run(command string) {
+	if _, err := os.Stat(filepath.Join(workspaceRoot, "go.mod")); err != nil {
+		notify(noticeError, "Error managing dependencies: no go.mod in the workspace")
+		return
+	}
+	tasks.start(task{name: command, command: command})
+}
+
+// goModChanged reloads the list after go.mod changed, once it was shown
+func (m *moduleManager) goModChanged() {
+	if m.loaded {
+		m.load()
+	}
+}
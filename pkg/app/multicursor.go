@@ -0,0 +1,12 @@
+package app
+
+import "fmt"
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "cursors", Order: 15, Right: true, Text: editorInfo(func(buf *Buffer) string {
+		if buf.Cursors() == 1 {
+			return ""
+		}
+		return fmt.Sprintf("%d cursors", buf.Cursors())
+	})})
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// Notification levels
+const (
+	noticeInfo = iota
+	noticeWarning
+	noticeError
+)
+
+const (
+	toastWidth     = 50 // widest a toast gets, border included
+	toastMaxShown  = 5  // toasts stacked at once; older ones are dismissed
+	toastMaxHeight = 4  // lines of text of a toast; the rest is cut
+)
+
+// toastDuration is how long a toast of each level stays up
+var toastDuration = map[int]time.Duration{
+	noticeInfo:    4 * time.Second,
+	noticeWarning: 6 * time.Second,
+	noticeError:   8 * time.Second,
+}
+
+// noticeLogLevels are the levels notifications are logged at
+var noticeLogLevels = map[int]int{noticeInfo: logInfo, noticeWarning: logWarn, noticeError: logError}
+
+// notice is a notification shown in a toast and kept in the history
+type notice struct {
+	level int
+	text  string
+	at    time.Time
+	count int // times it was repeated while its toast was up
+	shown int // number of the toast showing it, to expire the right one
+}
+
+// notificationCenter shows notifications as toasts stacked in the bottom
+// right corner, which go away by themselves, and keeps them in the
+// Notifications panel. It may be called from any goroutine.
+type notificationCenter struct {
+	mu      sync.Mutex
+	toasts  []*notice // oldest first
+	history *tview.TextView
+	shown   int // number of toasts shown, to tell them apart
+}
+
+var notifications notificationCenter
+
+// notify shows a notification in a toast and adds it to the history. A
+// notification repeating the last one still up counts on its toast instead
+// of stacking a new one.
+func notify(level int, text string) {
+	notifications.add(level, text)
+}
+
+// add shows a notification and archives it
+func (n *notificationCenter) add(level int, text string) {
+	now := time.Now()
+	n.mu.Lock()
+	n.shown++
+	shown := n.shown
+	if last := len(n.toasts) - 1; last >= 0 && n.toasts[last].level == level && n.toasts[last].text == text {
+		n.toasts[last].count++
+		n.toasts[last].shown = shown
+	} else {
+		n.toasts = append(n.toasts, &notice{level: level, text: text, at: now, count: 1, shown: shown})
+		if len(n.toasts) > toastMaxShown {
+			n.toasts = n.toasts[len(n.toasts)-toastMaxShown:]
+		}
+	}
+	n.mu.Unlock()
+
+	applog.log(noticeLogLevels[level], text)
+	if n.history != nil {
+		fmt.Fprintf(n.history, "%s%s[-] %s%-7s[-] %s\n", colorTag(theme.Muted), now.Format("15:04:05"),
+			colorTag(noticeColor(level)), noticeName(level), tview.Escape(text))
+	}
+	time.AfterFunc(toastDuration[level], func() {
+		ui.app.QueueUpdateDraw(func() {
+			n.expire(shown)
+		})
+	})
+}
+
+// expire dismisses the toast shown as number shown, unless it was repeated
+// since
+func (n *notificationCenter) expire(shown int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, toast := range n.toasts {
+		if toast.shown == shown {
+			n.toasts = append(n.toasts[:i], n.toasts[i+1:]...)
+			return
+		}
+	}
+}
+
+// dismiss takes every toast down
+func (n *notificationCenter) dismiss() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.toasts = nil
+}
+
+// draw draws the toasts over the window, newest at the bottom, just above
+// the status bar
+func (n *notificationCenter) draw(screen tcell.Screen) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	screenWidth, screenHeight := screen.Size()
+	width := toastWidth
+	if width > screenWidth-2 {
+		width = screenWidth - 2
+	}
+	if width < 10 {
+		return
+	}
+	background := tcell.StyleDefault.Background(theme.PopupBackground)
+	y := screenHeight - 1 // the status bar
+	for i := len(n.toasts) - 1; i >= 0; i-- {
+		toast := n.toasts[i]
+		text := toast.text
+		if toast.count > 1 {
+			text += fmt.Sprintf(" (×%d)", toast.count)
+		}
+		lines := wrapText(text, width-4)
+		if len(lines) > toastMaxHeight {
+			lines = append(lines[:toastMaxHeight-1], lines[toastMaxHeight-1]+"…")
+		}
+		y -= len(lines)
+		if y < 1 {
+			return
+		}
+		x := screenWidth - width - 1
+		accent := background.Foreground(noticeColor(toast.level))
+		for row, line := range lines {
+			fillRow(screen, x, y+row, width, background)
+			screen.SetContent(x, y+row, '▌', nil, accent)
+			style := background.Foreground(theme.PopupText)
+			printText(screen, line, x+2, y+row, width-3, style)
+		}
+		y-- // a gap between toasts
+	}
+}
+
+// wrapText breaks text into lines of at most width cells at spaces, and
+// inside words longer than a line
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line, lineWidth := "", 0
+		for _, word := range strings.Fields(paragraph) {
+			wordWidth := runewidth.StringWidth(word)
+			if lineWidth > 0 && lineWidth+1+wordWidth > width {
+				lines = append(lines, line)
+				line, lineWidth = "", 0
+			}
+			for wordWidth > width {
+				head := runewidth.Truncate(word, width, "")
+				lines = append(lines, head)
+				word = word[len(head):]
+				wordWidth = runewidth.StringWidth(word)
+			}
+			if lineWidth > 0 {
+				line += " "
+				lineWidth++
+			}
+			line += word
+			lineWidth += wordWidth
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// noticeName returns the label of a notification level
+func noticeName(level int) string {
+	switch level {
+	case noticeError:
+		return "error"
+	case noticeWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+// noticeColor returns the color a notification level is shown in
+func noticeColor(level int) tcell.Color {
+	switch level {
+	case noticeError:
+		return theme.Error
+	case noticeWarning:
+		return theme.Warning
+	}
+	return theme.Info
+}
+
+// createNotificationsPanel creates and returns the Notifications panel,
+// listing every notification shown since the start
+func createNotificationsPanel() *tview.TextView {
+	notifications.history = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetMaxLines(5000)
+	notifications.history.SetBorder(true).SetTitle("Notifications")
+	notifications.history.SetChangedFunc(func() {
+		ui.app.QueueUpdateDraw(func() {
+			notifications.history.ScrollToEnd()
+		})
+	})
+	notifications.history.SetDoneFunc(func(key tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return notifications.history
+}
+
+// toggleNotifications shows the Notifications panel, or hides it if it is
+// showing
+func toggleNotifications() {
+	if name, _ := ui.panels.GetFrontPage(); name == "notifications" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	notifications.dismiss()
+	showPanel("notifications")
+	ui.app.SetFocus(notifications.history)
+}
@@ -0,0 +1,15 @@
+package app
+
+import "fmt"
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "occurrences", Order: 12, Right: true, Text: editorInfo(func(buf *Buffer) string {
+		if buf.Buffer != ui.editor.Buffer() {
+			return ""
+		}
+		if occurrences := ui.editor.Occurrences(); len(occurrences) > 1 {
+			return fmt.Sprintf("%d occurrences", len(occurrences))
+		}
+		return ""
+	})})
+}
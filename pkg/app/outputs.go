@@ -0,0 +1,191 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// outputChannel is one tab of the Output pane
+type outputChannel struct {
+	name   string // used to show the channel from code
+	label  string // shown in the tab bar
+	view   *tview.TextView
+	clear  func() // empties the view and what it keeps about its text
+	unread bool   // the text changed while another channel was showing
+	follow bool   // new text scrolls the channel to its end
+}
+
+// outputPane shows the application messages and the output of builds and
+// runs, tests, and tasks as channels switched with a tab bar, so none of
+// them overwrites another
+type outputPane struct {
+	channels []*outputChannel
+	active   int
+	pane     *tview.Flex
+	tabBar   *tview.TextView
+	pages    *tview.Pages
+}
+
+var outputs outputPane
+
+// createOutputPane creates the Output pane with its channels, the messages
+// one in front
+func createOutputPane() *tview.Flex {
+	ui.output = newOutputView("Messages")
+	tests.output = newOutputView("Tests")
+	tasks.view = newOutputView("Tasks")
+	outputs.add("messages", "Messages", ui.output.TextView, func() { ui.output.Clear() })
+	outputs.add("build", "Build", createRunPanel(), builds.clear)
+	outputs.add("tests", "Tests", tests.output.TextView, func() { tests.output.Clear() })
+	outputs.add("tasks", "Tasks", tasks.view.TextView, func() { tasks.view.Clear() })
+	outputs.add("log", "Log", createLogView(), func() { applog.view.Clear() })
+
+	outputs.tabBar = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(false)
+	outputs.tabBar.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		if index, err := strconv.Atoi(added[0]); err == nil {
+			outputs.switchTo(index)
+			ui.app.SetFocus(outputs.channels[index].view)
+		}
+		outputs.tabBar.Highlight()
+	})
+	outputs.pages = tview.NewPages()
+	for i, channel := range outputs.channels {
+		outputs.pages.AddPage(channel.name, channel.view, true, i == 0)
+		pageItems[outputs.pages] = append(pageItems[outputs.pages], channel.view)
+	}
+
+	outputs.pane = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(outputs.tabBar, 1, 0, false).
+		AddItem(outputs.pages, 0, 1, true)
+	outputs.pane.SetBorder(true)
+	outputs.pane.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case ']':
+			outputs.cycle(1)
+		case '[':
+			outputs.cycle(-1)
+		case 'f':
+			outputs.toggleFollow()
+		case 'c':
+			outputs.copy()
+		case 'x':
+			outputs.clear()
+		default:
+			return event
+		}
+		return nil
+	})
+	outputs.refresh()
+	return outputs.pane
+}
+
+// add adds a channel, following its end and marking it unread when its text
+// changes in the back
+func (p *outputPane) add(name, label string, view *tview.TextView, clear func()) {
+	channel := &outputChannel{name: name, label: label, view: view, clear: clear, follow: true}
+	p.channels = append(p.channels, channel)
+	view.SetChangedFunc(func() {
+		ui.app.QueueUpdateDraw(func() {
+			if channel.follow {
+				channel.view.ScrollToEnd()
+			}
+			if p.channels[p.active] != channel && !channel.unread {
+				channel.unread = true
+				p.refresh()
+			}
+		})
+	})
+}
+
+// show brings the named channel to the front of the Output pane, and the
+// pane to the front of the panel area
+func (p *outputPane) show(name string) {
+	for i, channel := range p.channels {
+		if channel.name == name {
+			p.switchTo(i)
+		}
+	}
+	showPanel("output")
+}
+
+// switchTo makes the channel at index the visible one
+func (p *outputPane) switchTo(index int) {
+	if index < 0 || index >= len(p.channels) {
+		return
+	}
+	p.active = index
+	p.channels[index].unread = false
+	p.pages.SwitchToPage(p.channels[index].name)
+	p.refresh()
+}
+
+// cycle shows the channel delta positions away, wrapping around, keeping the
+// focus in the pane if it was there
+func (p *outputPane) cycle(delta int) {
+	focused := p.channels[p.active].view.HasFocus()
+	p.switchTo(((p.active+delta)%len(p.channels) + len(p.channels)) % len(p.channels))
+	showPanel("output")
+	if focused {
+		ui.app.SetFocus(p.channels[p.active].view)
+	}
+}
+
+// refresh redraws the tab bar and shows the title of the visible channel in
+// the pane border
+func (p *outputPane) refresh() {
+	var b strings.Builder
+	for i, channel := range p.channels {
+		colors := highlightTag(theme.Text, tcell.ColorDefault)
+		if i == p.active {
+			colors = highlightTag(theme.AccentText, theme.Accent)
+		}
+		marker := ""
+		if channel.unread {
+			marker = " •"
+		}
+		fmt.Fprintf(&b, `["%d"]%s %s%s [-:-:-][""] `, i, colors, channel.label, marker)
+	}
+	p.tabBar.SetText(b.String())
+	p.pane.SetTitle(p.channels[p.active].view.GetTitle())
+}
+
+// toggleFollow turns following the end of the visible channel off, so it
+// stays where it was scrolled to, or on
+func (p *outputPane) toggleFollow() {
+	channel := p.channels[p.active]
+	channel.follow = !channel.follow
+	if channel.follow {
+		channel.view.ScrollToEnd()
+		ui.output.message(fmt.Sprintf("%s output scrolls to the end on new text", channel.label))
+		return
+	}
+	// Scrolling to where it is keeps the view from tracking the end
+	channel.view.ScrollTo(channel.view.GetScrollOffset())
+	ui.output.message(fmt.Sprintf("%s output keeps its scroll position on new text", channel.label))
+}
+
+// clear empties the visible channel
+func (p *outputPane) clear() {
+	p.channels[p.active].clear()
+}
+
+// copy copies the text of the visible channel to the clipboard
+func (p *outputPane) copy() {
+	channel := p.channels[p.active]
+	text := strings.TrimRight(channel.view.GetText(true), "\n")
+	if err := copyToClipboard(text); err != nil {
+		notify(noticeError, fmt.Sprintf("Error copying to clipboard: %s", err))
+		return
+	}
+	ui.output.message(fmt.Sprintf("Copied %s output to the clipboard", channel.label))
+}
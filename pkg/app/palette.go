@@ -0,0 +1,177 @@
+package app
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// paletteHeight is the number of commands the palette shows at once
+const paletteHeight = 14
+
+// paletteCommand is an action offered by the command palette
+type paletteCommand struct {
+	action string
+	title  string
+	keys   string // keys bound to the action, for display
+}
+
+// paletteMatch is a command that matches the typed text, with the positions
+// of the matched runes in its title
+type paletteMatch struct {
+	paletteCommand
+	score     int
+	positions []int
+}
+
+// paletteCommands returns the actions that can run from the focused
+// widget: the global ones and those of the widget's scope, sorted by title
+func paletteCommands(focus tview.Primitive) []paletteCommand {
+	scope := ""
+	switch focus {
+	case ui.editor:
+		scope = scopeEditor
+	case ui.terminal:
+		scope = scopeTerminal
+	case ui.fileExplorer:
+		scope = scopeExplorer
+	}
+	var offered []paletteCommand
+	for _, c := range commands {
+		if c.Name == "command-palette" || (c.Scope != scopeGlobal && c.Scope != scope) {
+			continue
+		}
+		offered = append(offered, paletteCommand{
+			action: c.Name,
+			title:  c.Title,
+			keys:   strings.Join(bindings.keys[c.Name], ", "),
+		})
+	}
+	sort.Slice(offered, func(i, j int) bool { return offered[i].title < offered[j].title })
+	return offered
+}
+
+// fuzzyMatch reports whether the runes of pattern appear in text in order,
+// ignoring case. Matches score higher when their runes are adjacent or
+// start words.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	var positions []int
+	score, next := 0, 0
+	for i := 0; i < len(t) && next < len(p); i++ {
+		if unicode.IsSpace(p[next]) {
+			next++
+			if next == len(p) {
+				break
+			}
+		}
+		if t[i] != p[next] {
+			continue
+		}
+		score++
+		if i == 0 || t[i-1] == ' ' {
+			score += 8
+		}
+		if len(positions) > 0 && positions[len(positions)-1] == i-1 {
+			score += 5
+		}
+		positions = append(positions, i)
+		next++
+	}
+	if next < len(p) {
+		return 0, nil, false
+	}
+	// Prefer short titles among equally good matches
+	return score*100 - len(t), positions, true
+}
+
+// filterCommands returns the commands matching pattern, best first
+func filterCommands(offered []paletteCommand, pattern string) []paletteMatch {
+	var matches []paletteMatch
+	for _, command := range offered {
+		if score, positions, ok := fuzzyMatch(pattern, command.title); ok {
+			matches = append(matches, paletteMatch{command, score, positions})
+		}
+	}
+	if strings.TrimSpace(pattern) != "" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	}
+	return matches
+}
+
+// paletteLabel returns the list text of a match, with the matched runes in
+// the accent color and the keys right after the title
+func paletteLabel(match paletteMatch) string {
+	var b strings.Builder
+	matched := map[int]bool{}
+	for _, i := range match.positions {
+		matched[i] = true
+	}
+	for i, r := range []rune(match.title) {
+		if matched[i] {
+			b.WriteString(colorTag(theme.Accent) + tview.Escape(string(r)) + "[-]")
+		} else {
+			b.WriteString(tview.Escape(string(r)))
+		}
+	}
+	if match.keys != "" {
+		b.WriteString("  " + colorTag(theme.Muted) + tview.Escape(match.keys) + "[-]")
+	}
+	return b.String()
+}
+
+// showCommandPalette opens a list of every command that can run from the
+// focused widget, narrowed down by fuzzy matching what is typed
+func showCommandPalette() {
+	focus := ui.app.GetFocus()
+	offered := paletteCommands(focus)
+	var matches []paletteMatch
+
+	input := tview.NewInputField().SetLabel("> ").SetFieldBackgroundColor(theme.Background)
+	list := tview.NewList().ShowSecondaryText(false).SetHighlightFullLine(true)
+	update := func(pattern string) {
+		matches = filterCommands(offered, pattern)
+		list.Clear()
+		for _, match := range matches {
+			list.AddItem(paletteLabel(match), "", 0, nil)
+		}
+	}
+	run := func() {
+		index := list.GetCurrentItem()
+		if index < 0 || index >= len(matches) {
+			return
+		}
+		action := matches[index].action
+		closeDialog()
+		runCommand(action)
+	}
+	input.SetChangedFunc(update)
+	list.SetSelectedFunc(func(int, string, string, rune) {
+		run()
+	})
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			// The list moves while the text keeps the focus
+			list.InputHandler()(event, nil)
+		case tcell.KeyEnter:
+			run()
+		case tcell.KeyEscape:
+			closeDialog()
+		default:
+			return event
+		}
+		return nil
+	})
+	update("")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	layout.SetBorder(true).SetTitle("Commands")
+	showDialog(layout, 64, paletteHeight+3)
+}
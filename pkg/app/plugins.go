@@ -0,0 +1,236 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// plugin is an external program that extends goui. It speaks JSON-RPC over
+// its standard input and output, framed like the Language Server Protocol.
+type plugin struct {
+	name   string
+	client *lspClient
+	panels map[string]*tview.TextView // by panel name
+}
+
+// pluginHost runs the plugins of the config file and tells them about
+// events in the editor
+type pluginHost struct {
+	plugins []*plugin
+	focus   string // widget last reported as focused
+}
+
+var plugins pluginHost
+
+// isPluginCommand reports whether action names a command of a configured
+// plugin, such as "wordcount.count"; plugins register their commands only
+// once they are running
+func isPluginCommand(action string) bool {
+	name, _, ok := strings.Cut(action, ".")
+	_, configured := config.Plugins[name]
+	return ok && configured
+}
+
+// start launches every plugin of the config file
+func (h *pluginHost) start() {
+	names := make([]string, 0, len(config.Plugins))
+	for name := range config.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed []string
+	for _, name := range names {
+		p := &plugin{name: name, panels: map[string]*tview.TextView{}}
+		settings := config.Plugins[name]
+		client, err := startLSPClient(workspaceRoot, p.handleNotification, settings.Command, settings.Args...)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		p.client = client
+		h.plugins = append(h.plugins, p)
+		client.notify("initialize", map[string]string{"name": name, "root": absPath(workspaceRoot)})
+	}
+	if len(failed) > 0 {
+		notify(noticeError, fmt.Sprintf("Error starting plugins:\n%s", strings.Join(failed, "\n")))
+	}
+}
+
+// stop shuts every plugin down, waiting for them together
+func (h *pluginHost) stop() {
+	var wg sync.WaitGroup
+	for _, p := range h.plugins {
+		wg.Add(1)
+		go func(client *lspClient) {
+			defer recoverPanic()
+			defer wg.Done()
+			client.close()
+		}(p.client)
+	}
+	wg.Wait()
+	h.plugins = nil
+}
+
+// notify sends an event to every plugin
+func (h *pluginHost) notify(method string, params interface{}) {
+	for _, p := range h.plugins {
+		p.client.notify(method, params)
+	}
+}
+
+// fileOpened tells the plugins that a file was opened in a new tab
+func (h *pluginHost) fileOpened(buf *Buffer) {
+	h.notify("fileOpened", map[string]string{"path": absPath(buf.Path())})
+}
+
+// fileSaved tells the plugins that a file was written
+func (h *pluginHost) fileSaved(buf *Buffer) {
+	h.notify("fileSaved", map[string]string{"path": absPath(buf.Path())})
+}
+
+// checkFocus tells the plugins which widget has the focus, if it changed
+// since the last call. It runs after every draw, while the application is
+// locked, so it asks the widgets instead of the application.
+func (h *pluginHost) checkFocus() {
+	if len(h.plugins) == 0 {
+		return
+	}
+	focus := "other"
+	switch {
+	case ui.editor.HasFocus():
+		focus = "editor"
+	case ui.terminal.HasFocus():
+		focus = "terminal"
+	case ui.fileExplorer.HasFocus():
+		focus = "explorer"
+	}
+	if focus != h.focus {
+		h.focus = focus
+		h.notify("focusChanged", map[string]string{"widget": focus})
+	}
+}
+
+// absPath returns path as an absolute path, or as is if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// handleNotification processes the messages of a plugin. It runs on the
+// client's reader goroutine, so the work is queued onto the event loop.
+func (p *plugin) handleNotification(method string, params json.RawMessage) {
+	ui.app.QueueUpdateDraw(func() {
+		if err := p.handle(method, params); err != nil {
+			notify(noticeError, fmt.Sprintf("Error in plugin %s: %s", p.name, err))
+		}
+	})
+}
+
+// handle carries out a message of the plugin
+func (p *plugin) handle(method string, params json.RawMessage) error {
+	var args struct {
+		Name  string   `json:"name"`
+		Title string   `json:"title"`
+		Keys  []string `json:"keys"`
+		Text  string   `json:"text"`
+	}
+	if err := json.Unmarshal(params, &args); len(params) > 0 && err != nil {
+		return fmt.Errorf("invalid parameters of %s: %w", method, err)
+	}
+	switch method {
+	case "registerCommand":
+		return p.registerCommand(args.Name, args.Title, args.Keys)
+	case "addPanel":
+		return p.addPanel(args.Name, args.Title)
+	case "setPanelText":
+		view, ok := p.panels[args.Name]
+		if !ok {
+			return fmt.Errorf("unknown panel %q", args.Name)
+		}
+		view.SetText(args.Text)
+		view.ScrollToBeginning()
+	case "showMessage":
+		ui.output.message(args.Text)
+	default:
+		return fmt.Errorf("unknown method %q", method)
+	}
+	return nil
+}
+
+// registerCommand adds a command that the plugin runs, named after the
+// plugin so it cannot clash with other commands
+func (p *plugin) registerCommand(name, title string, keys []string) error {
+	if name == "" {
+		return fmt.Errorf("command without a name")
+	}
+	if _, ok := p.panels[name]; ok {
+		return fmt.Errorf("%q is the name of a panel", name)
+	}
+	if title == "" {
+		title = name
+	}
+	RegisterCommand(Command{
+		Name:  p.name + "." + name,
+		Title: title,
+		Scope: scopeGlobal,
+		Keys:  keys,
+		Run: func() {
+			p.client.notify("runCommand", map[string]string{"name": name})
+		},
+	})
+	// Binds the keys of the command, or those the keys file gives it
+	return reloadKeyBindings()
+}
+
+// addPanel adds a text panel that the plugin fills, along with a command
+// showing and hiding it
+func (p *plugin) addPanel(name, title string) error {
+	if name == "" {
+		return fmt.Errorf("panel without a name")
+	}
+	if _, ok := commands[p.name+"."+name]; ok {
+		return fmt.Errorf("%q is the name of a command or panel", name)
+	}
+	if title == "" {
+		title = name
+	}
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true)
+	view.SetBorder(true).SetTitle(title)
+	view.SetDoneFunc(func(tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	restyle(view)
+	p.panels[name] = view
+
+	page := "plugin:" + p.name + "." + name
+	ui.panels.AddPage(page, view, true, false)
+	pageItems[ui.panels] = append(pageItems[ui.panels], view)
+	RegisterCommand(Command{
+		Name:  p.name + "." + name,
+		Title: fmt.Sprintf("Toggle %s Panel", title),
+		Scope: scopeGlobal,
+		Run: func() {
+			if front, _ := ui.panels.GetFrontPage(); front == page {
+				showPanel("output")
+				ui.app.SetFocus(ui.editor)
+				return
+			}
+			showPanel(page)
+			ui.app.SetFocus(view)
+		},
+	})
+	return reloadKeyBindings()
+}
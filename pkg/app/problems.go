@@ -0,0 +1,280 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// problemSortOrders are the orders of the problems within a group of the
+// Problems panel, each falling back to the location
+var problemSortOrders = []string{"location", "severity", "source"}
+
+// severityGroups are the titles of the groups of the Problems panel when it
+// is grouped by severity, indexed by severity
+var severityGroups = []string{"", "Errors", "Warnings", "Information", "Hints"}
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "problems", Order: 28, Text: problems.status})
+}
+
+// problemsPanel is the Problems panel: a filter, and the diagnostics of
+// every source grouped by file or by severity
+type problemsPanel struct {
+	panel  *tview.Flex
+	tree   *tview.TreeView
+	filter *tview.InputField
+	info   *tview.TextView // how the list is sorted, grouped, and filtered
+
+	sortBy     int             // index in problemSortOrders
+	bySeverity bool            // group by severity rather than by file
+	hidden     map[int]bool    // severities left out of the list
+	collapsed  map[string]bool // groups collapsed by the user, by title
+}
+
+// createProblemsPanel creates and returns the Problems panel
+func createProblemsPanel() *tview.Flex {
+	p := &problems.view
+	p.hidden = map[int]bool{}
+	p.collapsed = map[string]bool{}
+	p.tree = tview.NewTreeView().
+		SetRoot(tview.NewTreeNode("")).
+		SetTopLevel(1)
+	p.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		diagnostic, ok := node.GetReference().(Diagnostic)
+		if !ok {
+			node.SetExpanded(!node.IsExpanded())
+			p.collapsed[node.GetReference().(string)] = !node.IsExpanded()
+			return
+		}
+		if err := openFileAt(diagnostic.Path, diagnostic.Range.From, diagnostic.Range.From); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	})
+	p.tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab {
+			ui.app.SetFocus(p.filter)
+			return nil
+		}
+		if event.Key() != tcell.KeyRune {
+			return event
+		}
+		switch event.Rune() {
+		case '/':
+			ui.app.SetFocus(p.filter)
+		case 's':
+			p.sortBy = (p.sortBy + 1) % len(problemSortOrders)
+		case 'g':
+			p.bySeverity = !p.bySeverity
+		case 'e':
+			p.hidden[SeverityError] = !p.hidden[SeverityError]
+		case 'w':
+			p.hidden[SeverityWarning] = !p.hidden[SeverityWarning]
+		case 'i':
+			p.hidden[SeverityInfo] = !p.hidden[SeverityInfo]
+		case 'h':
+			p.hidden[SeverityHint] = !p.hidden[SeverityHint]
+		default:
+			return event
+		}
+		problems.refresh()
+		return nil
+	})
+	p.tree.SetDoneFunc(func(key tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+
+	p.filter = tview.NewInputField().
+		SetLabel("Filter: ").
+		SetPlaceholder("message, file, or source")
+	p.filter.SetChangedFunc(func(text string) {
+		problems.refresh()
+	})
+	p.filter.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			p.filter.SetText("")
+		}
+		ui.app.SetFocus(p.tree)
+	})
+	p.info = tview.NewTextView().SetTextAlign(tview.AlignRight)
+
+	header := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(p.filter, 0, 1, false).
+		AddItem(p.info, 44, 0, false)
+	p.panel = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(header, 1, 0, false).
+		AddItem(p.tree, 0, 1, true)
+	p.panel.SetBorder(true).SetTitle("Problems")
+	return p.panel
+}
+
+// toggleProblems shows the Problems panel, or hides it if it is showing
+func toggleProblems() {
+	if name, _ := ui.panels.GetFrontPage(); name == "problems" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	showPanel("problems")
+	ui.app.SetFocus(problems.view.tree)
+}
+
+// render lists the diagnostics that pass the filter, grouped and sorted as
+// chosen, keeping the selection on the same one when it is still listed
+func (p *problemsPanel) render(all []Diagnostic) {
+	var selected interface{}
+	if current := p.tree.GetCurrentNode(); current != nil {
+		selected = current.GetReference()
+	}
+	query := strings.ToLower(p.filter.GetText())
+	var shown []Diagnostic
+	for _, diagnostic := range all {
+		if !p.hidden[diagnostic.Severity] && problemMatches(diagnostic, query) {
+			shown = append(shown, diagnostic)
+		}
+	}
+	p.sort(shown)
+
+	root := p.tree.GetRoot().ClearChildren()
+	groups := map[string]*tview.TreeNode{}
+	for _, diagnostic := range shown {
+		path := workspacePath(diagnostic.Path)
+		title := path
+		label := fmt.Sprintf("%s%-7s[-] %d:%d", colorTag(theme.severityColor(diagnostic.Severity)), severityName(diagnostic.Severity),
+			diagnostic.Range.From.Line+1, diagnostic.Range.From.Col+1)
+		if p.bySeverity {
+			title = severityGroups[diagnostic.Severity]
+			label = fmt.Sprintf("%s:%d:%d", tview.Escape(path), diagnostic.Range.From.Line+1, diagnostic.Range.From.Col+1)
+		}
+		group := groups[title]
+		if group == nil {
+			group = tview.NewTreeNode("").
+				SetReference(title).
+				SetColor(theme.Text).
+				SetExpanded(!p.collapsed[title])
+			if p.bySeverity {
+				group.SetColor(theme.severityColor(diagnostic.Severity))
+			}
+			groups[title] = group
+			root.AddChild(group)
+		}
+		group.AddChild(tview.NewTreeNode(fmt.Sprintf("%s %s %s(%s)[-]", label, tview.Escape(diagnostic.Message),
+			colorTag(theme.Muted), tview.Escape(diagnostic.Source))).
+			SetReference(diagnostic).
+			SetColor(theme.Text))
+	}
+	for title, group := range groups {
+		group.SetText(fmt.Sprintf("%s (%d)", tview.Escape(title), len(group.GetChildren())))
+	}
+
+	switch {
+	case len(all) == 0:
+		root.AddChild(tview.NewTreeNode("No problems").SetSelectable(false).SetColor(theme.Muted))
+	case len(shown) == 0:
+		root.AddChild(tview.NewTreeNode("No problems match the filter").SetSelectable(false).SetColor(theme.Muted))
+	}
+	p.tree.SetCurrentNode(root.GetChildren()[0])
+	root.Walk(func(node, parent *tview.TreeNode) bool {
+		if node != root && selected != nil && node.GetReference() == selected {
+			p.tree.SetCurrentNode(node)
+			return false
+		}
+		return true
+	})
+
+	title := fmt.Sprintf("Problems (%d)", len(all))
+	if len(shown) < len(all) {
+		title = fmt.Sprintf("Problems (%d of %d)", len(shown), len(all))
+	}
+	p.panel.SetTitle(title)
+	p.info.SetText(p.describe())
+}
+
+// sort orders the diagnostics of a group as chosen. Grouped by severity, the
+// groups come in order of severity; grouped by file, in order of their first
+// diagnostic, so sorting by severity lists the files with errors first.
+func (p *problemsPanel) sort(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if p.bySeverity && a.Severity != b.Severity {
+			return a.Severity < b.Severity
+		}
+		switch problemSortOrders[p.sortBy] {
+		case "severity":
+			if a.Severity != b.Severity {
+				return a.Severity < b.Severity
+			}
+		case "source":
+			if a.Source != b.Source {
+				return a.Source < b.Source
+			}
+		}
+		return false
+	})
+}
+
+// describe tells how the list is sorted, grouped, and which severities are
+// left out
+func (p *problemsPanel) describe() string {
+	grouping := "file"
+	if p.bySeverity {
+		grouping = "severity"
+	}
+	text := fmt.Sprintf("by %s, sorted by %s", grouping, problemSortOrders[p.sortBy])
+	var hidden []string
+	for severity := SeverityError; severity <= SeverityHint; severity++ {
+		if p.hidden[severity] {
+			hidden = append(hidden, strings.ToLower(severityGroups[severity]))
+		}
+	}
+	if len(hidden) > 0 {
+		text += ", no " + strings.Join(hidden, ", ")
+	}
+	return text
+}
+
+// problemMatches reports whether the message, file, or source of a
+// diagnostic contains query, which is in lower case
+func problemMatches(diagnostic Diagnostic, query string) bool {
+	if query == "" {
+		return true
+	}
+	for _, text := range []string{diagnostic.Message, workspacePath(diagnostic.Path), diagnostic.Source} {
+		if strings.Contains(strings.ToLower(text), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// status counts the errors and warnings of the workspace for the status bar
+func (d *diagnosticStore) status() string {
+	counts := map[int]int{}
+	for _, files := range d.bySource {
+		for _, diagnostics := range files {
+			for _, diagnostic := range diagnostics {
+				counts[diagnostic.Severity]++
+			}
+		}
+	}
+	var parts []string
+	if counts[SeverityError] > 0 {
+		parts = append(parts, plural(counts[SeverityError], "error"))
+	}
+	if counts[SeverityWarning] > 0 {
+		parts = append(parts, plural(counts[SeverityWarning], "warning"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// plural returns a count followed by a noun, adding an s unless it is 1
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
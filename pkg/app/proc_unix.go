@@ -0,0 +1,61 @@
+//go:build !windows
+
+package app
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// exeSuffix ends the names of executables
+const exeSuffix = ""
+
+// setProcessGroup starts the command in its own process group, so the
+// programs it starts can be stopped with it
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup stops a command started with setProcessGroup and every
+// process it started
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// interruptProcessGroup sends SIGINT to a command started with
+// setProcessGroup and every process it started, as Ctrl+C in a terminal does
+func interruptProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGINT); err != nil {
+		_ = cmd.Process.Signal(syscall.SIGINT)
+	}
+}
+
+// processRunning reports whether a process with the given id exists
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// shellCommand returns a command running a command line with the shell
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
+
+// openerCommand returns a command opening a URL with the program the
+// desktop associates with it
+func openerCommand(uri string) *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		return exec.Command("open", uri)
+	}
+	return exec.Command("xdg-open", uri)
+}
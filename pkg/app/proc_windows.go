@@ -0,0 +1,47 @@
+//go:build windows
+
+package app
+
+import (
+	"os"
+	"os/exec"
+)
+
+// exeSuffix ends the names of executables
+const exeSuffix = ".exe"
+
+// setProcessGroup does nothing on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup stops the command; programs it started keep running
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// interruptProcessGroup stops the command, as Windows cannot interrupt it
+func interruptProcessGroup(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+}
+
+// processRunning reports whether a process with the given id exists
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+// shellCommand returns a command running a command line with cmd.exe
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// openerCommand returns a command opening a URL with the program Windows
+// associates with it
+func openerCommand(uri string) *exec.Cmd {
+	return exec.Command("rundll32", "url.dll,FileProtocolHandler", uri)
+}
@@ -0,0 +1,393 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"gotui/pkg/editor"
+	"gotui/pkg/textsearch"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	searchContextLines = 1
+	searchMaxMatches   = 5000
+	searchMaxFileSize  = 4 << 20
+)
+
+// searchHit is one matching line found by the project search
+type searchHit struct {
+	path   string
+	line   int
+	cols   []int
+	before []string // context lines preceding the match
+	text   string
+	after  []string // context lines following the match
+}
+
+// fileHits groups the hits of a single file
+type fileHits struct {
+	path string
+	hits []searchHit
+}
+
+// searchPanel is the "Search in Files" panel
+type searchPanel struct {
+	root    *tview.Flex
+	query   *tview.InputField
+	results *tview.TreeView
+	status  *tview.TextView
+
+	scope      string // the directory searched, or "" for every workspace root
+	cancel     context.CancelFunc
+	generation int
+	needleLen  int
+	matches    int
+	files      int
+}
+
+var projectSearch searchPanel
+
+// createSearchPanel creates and returns the project-wide search panel
+func createSearchPanel() *tview.Flex {
+	p := &projectSearch
+	p.query = tview.NewInputField().
+		SetLabel("Search: ").
+		SetDoneFunc(func(key tcell.Key) {
+			switch key {
+			case tcell.KeyEnter:
+				p.run(p.query.GetText())
+			case tcell.KeyTab, tcell.KeyDown:
+				ui.app.SetFocus(p.results)
+			case tcell.KeyEscape:
+				p.close()
+			}
+		})
+	p.status = tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignRight)
+
+	p.results = tview.NewTreeView().
+		SetRoot(tview.NewTreeNode("")).
+		SetTopLevel(1)
+	p.results.SetSelectedFunc(func(node *tview.TreeNode) {
+		switch ref := node.GetReference().(type) {
+		case *fileHits:
+			node.SetExpanded(!node.IsExpanded())
+		case *searchHit:
+			from := editor.Position{Line: ref.line, Col: ref.cols[0]}
+			to := editor.Position{Line: ref.line, Col: ref.cols[0] + p.needleLen}
+			jumps.push()
+			if err := openFileAt(ref.path, from, to); err != nil {
+				notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+			}
+		}
+	})
+	p.results.SetDoneFunc(func(key tcell.Key) {
+		ui.app.SetFocus(p.query)
+	})
+
+	header := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(p.query, 0, 1, true).
+		AddItem(p.status, 30, 0, false)
+	p.root = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(header, 1, 0, true).
+		AddItem(p.results, 0, 1, false)
+	p.root.SetBorder(true).SetTitle("Search in Files")
+	return p.root
+}
+
+// open shows the search panel to search every root of the workspace,
+// seeding the query with the editor selection
+func (p *searchPanel) open() {
+	p.openIn("")
+}
+
+// openIn shows the search panel to search the files below dir, or the whole
+// workspace if dir is ""
+func (p *searchPanel) openIn(dir string) {
+	p.scope = dir
+	if dir == "" {
+		p.root.SetTitle("Search in Files")
+	} else {
+		name := relativePath(dir)
+		if name == "." {
+			name = filepath.Base(absPath(dir))
+		}
+		p.root.SetTitle("Search in " + tview.Escape(name))
+	}
+	if from, to := ui.editor.Selection(); from != to && from.Line == to.Line {
+		p.query.SetText(ui.editor.SelectedText())
+	}
+	showPanel("search")
+	ui.app.SetFocus(p.query)
+}
+
+// close stops any running search and returns to the output panel
+func (p *searchPanel) close() {
+	p.stop()
+	showPanel("output")
+	ui.app.SetFocus(ui.editor)
+}
+
+// stop cancels the running search, if any
+func (p *searchPanel) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// run starts a new search of the workspace, or of the directory the panel
+// was opened for, replacing previous results.
+// Files are read by a pool of workers and results are streamed into the
+// tree as they arrive.
+func (p *searchPanel) run(query string) {
+	p.stop()
+	p.generation++
+	p.matches, p.files = 0, 0
+	p.results.GetRoot().ClearChildren()
+	needle, fold := textsearch.Needle([]rune(query))
+	p.needleLen = len(needle)
+	if len(needle) == 0 {
+		p.status.SetText("")
+		return
+	}
+	p.status.SetText(colorTag(theme.Accent) + "Searching…")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	generation := p.generation
+
+	paths := make(chan string, 64)
+	results := make(chan fileHits, 16)
+	scope, roots := p.scope, workspaceRoots()
+	go func() {
+		defer recoverPanic()
+		defer close(paths)
+		if scope != "" {
+			walkWorkspace(ctx, rootOf(scope), scope, paths)
+			return
+		}
+		for _, root := range roots {
+			walkWorkspace(ctx, root, root, paths)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer recoverPanic()
+			defer wg.Done()
+			for path := range paths {
+				if ctx.Err() != nil {
+					continue
+				}
+				if hits := searchFile(path, needle, fold); len(hits) > 0 {
+					results <- fileHits{path: path, hits: hits}
+				}
+			}
+		}()
+	}
+	go func() {
+		defer recoverPanic()
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer recoverPanic()
+		for result := range results {
+			result := result
+			ui.app.QueueUpdateDraw(func() {
+				if generation == p.generation {
+					p.addResult(&result)
+				}
+			})
+		}
+		ui.app.QueueUpdateDraw(func() {
+			if generation == p.generation {
+				p.finish(ctx.Err() != nil)
+			}
+		})
+	}()
+}
+
+// addResult adds the hits of one file to the results tree
+func (p *searchPanel) addResult(result *fileHits) {
+	if p.matches >= searchMaxMatches {
+		p.stop()
+		return
+	}
+	count := 0
+	for _, hit := range result.hits {
+		count += len(hit.cols)
+	}
+	p.matches += count
+	p.files++
+
+	fileNode := tview.NewTreeNode(fmt.Sprintf("%s%s[-] (%d)", colorTag(theme.Success), tview.Escape(relativePath(result.path)), count)).
+		SetReference(result).
+		SetSelectable(true)
+	for i := range result.hits {
+		hit := &result.hits[i]
+		for j, text := range hit.before {
+			fileNode.AddChild(contextNode(hit.line-len(hit.before)+j, text))
+		}
+		fileNode.AddChild(tview.NewTreeNode(fmt.Sprintf("%5d: %s", hit.line+1, highlightHit(hit.text, hit.cols, p.needleLen))).
+			SetReference(hit).
+			SetSelectable(true))
+		for j, text := range hit.after {
+			fileNode.AddChild(contextNode(hit.line+1+j, text))
+		}
+	}
+	p.results.GetRoot().AddChild(fileNode)
+	if p.results.GetCurrentNode() == nil {
+		p.results.SetCurrentNode(fileNode)
+	}
+	p.refreshStatus()
+}
+
+// contextNode returns a non-selectable tree node for a context line
+func contextNode(line int, text string) *tview.TreeNode {
+	return tview.NewTreeNode(fmt.Sprintf("%s%5d  %s", colorTag(theme.Muted), line+1, tview.Escape(text))).
+		SetSelectable(false)
+}
+
+// highlightHit escapes a matching line and marks the matched text
+func highlightHit(text string, cols []int, length int) string {
+	runes := []rune(text)
+	var b strings.Builder
+	prev := 0
+	for _, col := range cols {
+		b.WriteString(tview.Escape(string(runes[prev:col])))
+		b.WriteString(highlightTag(theme.AccentText, theme.Accent) + tview.Escape(string(runes[col:col+length])) + "[-:-]")
+		prev = col + length
+	}
+	b.WriteString(tview.Escape(string(runes[prev:])))
+	return b.String()
+}
+
+// finish updates the status once the search has ended
+func (p *searchPanel) finish(canceled bool) {
+	p.cancel = nil
+	p.refreshStatus()
+	if canceled && p.matches >= searchMaxMatches {
+		p.status.SetText(fmt.Sprintf("%s%d+ matches in %d files", colorTag(theme.Accent), p.matches, p.files))
+	}
+}
+
+// refreshStatus shows the match counter
+func (p *searchPanel) refreshStatus() {
+	if p.matches == 0 && p.cancel == nil {
+		p.status.SetText(colorTag(theme.Error) + "No results")
+		return
+	}
+	p.status.SetText(fmt.Sprintf("%d matches in %d files", p.matches, p.files))
+}
+
+// walkWorkspace sends the path of every searchable file under dir, a
+// directory of the workspace root root, skipping anything matched by the
+// root's .gitignore files
+func walkWorkspace(ctx context.Context, root, dir string, paths chan<- string) {
+	ignore := newGitignore(root)
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if ignore.ignoredEntry(strings.Split(filepath.ToSlash(rel), "/"), entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			select {
+			case paths <- path:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
+
+// searchFile returns the matching lines of a file with their context.
+// Binary and very large files are skipped.
+func searchFile(path string, needle []rune, fold bool) []searchHit {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > searchMaxFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || isBinary(content) {
+		return nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), searchMaxFileSize)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	var hits []searchHit
+	for n, line := range lines {
+		cols := textsearch.Find([]rune(line), needle, fold)
+		if len(cols) == 0 {
+			continue
+		}
+		hit := searchHit{path: path, line: n, cols: cols, text: line}
+		start := n - searchContextLines
+		if start < 0 {
+			start = 0
+		}
+		// Context lines shared with the previous hit are shown only once.
+		if len(hits) > 0 {
+			prev := &hits[len(hits)-1]
+			for len(prev.after) > 0 && prev.line+len(prev.after) >= n {
+				prev.after = prev.after[:len(prev.after)-1]
+			}
+			if start <= prev.line+len(prev.after) {
+				start = prev.line + len(prev.after) + 1
+			}
+		}
+		hit.before = lines[start:n]
+		end := n + 1 + searchContextLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+		hit.after = lines[n+1 : end]
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// isBinary reports whether content looks like binary data
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,117 @@
+//go:build !windows
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"github.com/creack/pty"
+)
+
+// unixPTY is a shell running in a pty
+type unixPTY struct {
+	cmd        *exec.Cmd
+	tty        *os.File // the master side
+	foreground int      // process group in the foreground when hung up
+}
+
+// startTerminalProcess starts cmd in a new pty of the given size
+func startTerminalProcess(cmd *exec.Cmd, cols, rows int) (terminalProcess, error) {
+	tty, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if err != nil {
+		return nil, err
+	}
+	return &unixPTY{cmd: cmd, tty: tty}, nil
+}
+
+// Read reads the output of the shell
+func (p *unixPTY) Read(b []byte) (int, error) {
+	return p.tty.Read(b)
+}
+
+// Write sends input to the shell
+func (p *unixPTY) Write(b []byte) (int, error) {
+	return p.tty.Write(b)
+}
+
+// Resize sets the size of the pty, which makes the kernel send SIGWINCH to
+// the foreground process group of the terminal
+func (p *unixPTY) Resize(cols, rows int) error {
+	return pty.Setsize(p.tty, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+}
+
+// Wait waits for the shell to exit
+func (p *unixPTY) Wait() error {
+	return p.cmd.Wait()
+}
+
+// HangUp sends SIGHUP to the shell, which passes it on to its jobs, and
+// closes the pty, which hangs up the program in the foreground
+func (p *unixPTY) HangUp() {
+	p.foreground = p.foregroundProcessGroup()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Signal(syscall.SIGHUP)
+	}
+	p.tty.Close()
+}
+
+// Kill kills the process groups of the shell and of the program in the
+// foreground
+func (p *unixPTY) Kill() {
+	foreground := p.foreground
+	if foreground == 0 {
+		foreground = p.foregroundProcessGroup()
+	}
+	killProcessGroup(p.cmd)
+	if foreground > 1 {
+		_ = syscall.Kill(-foreground, syscall.SIGKILL)
+	}
+}
+
+// Close closes the pty
+func (p *unixPTY) Close() error {
+	return p.tty.Close()
+}
+
+// Dir returns the working directory of the shell from procfs, which
+// Linux has and, among others, macOS has not
+func (p *unixPTY) Dir() string {
+	if p.cmd.Process == nil {
+		return ""
+	}
+	dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", p.cmd.Process.Pid))
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// foregroundProcessGroup returns the process group of the program in the
+// foreground of the pty, or 0 if it cannot be told
+func (p *unixPTY) foregroundProcessGroup() int {
+	conn, err := p.tty.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	// Unlike Fd, Control leaves the file in non-blocking mode
+	var pgid int32
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgid)))
+	}); err != nil || errno != 0 {
+		return 0
+	}
+	return int(pgid)
+}
+
+// defaultShell returns the shell terminals run unless another is configured
+func defaultShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "bash"
+}
@@ -0,0 +1,201 @@
+//go:build windows
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// procUpdateProcThreadAttribute is called directly, as the pseudo console
+// attribute is the handle itself rather than a pointer to it
+var procUpdateProcThreadAttribute = windows.NewLazySystemDLL("kernel32.dll").NewProc("UpdateProcThreadAttribute")
+
+// conPTY is a shell running in a Windows pseudo console (ConPTY). The
+// console reads the shell's input from one pipe and writes its screen, as
+// VT sequences, to another.
+type conPTY struct {
+	console   windows.Handle
+	input     *os.File // write end of the console's input pipe
+	output    *os.File // read end of the console's output pipe
+	process   *os.Process
+	closeOnce sync.Once
+}
+
+// startTerminalProcess starts cmd in a new pseudo console of the given size
+func startTerminalProcess(cmd *exec.Cmd, cols, rows int) (terminalProcess, error) {
+	inputRead, inputWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	outputRead, outputWrite, err := os.Pipe()
+	if err != nil {
+		inputRead.Close()
+		inputWrite.Close()
+		return nil, err
+	}
+	p := &conPTY{input: inputWrite, output: outputRead}
+	err = windows.CreatePseudoConsole(windows.Coord{X: int16(cols), Y: int16(rows)},
+		windows.Handle(inputRead.Fd()), windows.Handle(outputWrite.Fd()), 0, &p.console)
+	// The console keeps its own handles to the ends it uses
+	inputRead.Close()
+	outputWrite.Close()
+	if err != nil {
+		inputWrite.Close()
+		outputRead.Close()
+		return nil, fmt.Errorf("failed to create pseudo console: %w", err)
+	}
+	if err := p.start(cmd); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// start runs cmd attached to the console. exec.Cmd cannot attach a process
+// to a pseudo console, so it only describes the program.
+func (p *conPTY) start(cmd *exec.Cmd) error {
+	attributes, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		return err
+	}
+	defer attributes.Delete()
+	if ok, _, err := procUpdateProcThreadAttribute.Call(uintptr(unsafe.Pointer(attributes.List())), 0,
+		windows.PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE, uintptr(p.console), unsafe.Sizeof(p.console), 0, 0); ok == 0 {
+		return fmt.Errorf("failed to attach pseudo console: %w", err)
+	}
+
+	startup := &windows.StartupInfoEx{ProcThreadAttributeList: attributes.List()}
+	startup.Cb = uint32(unsafe.Sizeof(*startup))
+	// Without standard handles of its own, the shell would inherit those
+	// of goui rather than use the console
+	startup.Flags = windows.STARTF_USESTDHANDLES
+	commandLine, err := windows.UTF16PtrFromString(windows.ComposeCommandLine(append([]string{cmd.Path}, cmd.Args[1:]...)))
+	if err != nil {
+		return err
+	}
+	var dir *uint16
+	if cmd.Dir != "" {
+		if dir, err = windows.UTF16PtrFromString(cmd.Dir); err != nil {
+			return err
+		}
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	var info windows.ProcessInformation
+	err = windows.CreateProcess(nil, commandLine, nil, nil, false,
+		windows.EXTENDED_STARTUPINFO_PRESENT|windows.CREATE_UNICODE_ENVIRONMENT,
+		environmentBlock(env), dir, &startup.StartupInfo, &info)
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+	defer windows.CloseHandle(info.Process)
+	windows.CloseHandle(info.Thread)
+	// Found while its handle is open, so the id cannot be reused meanwhile
+	p.process, err = os.FindProcess(int(info.ProcessId))
+	return err
+}
+
+// environmentBlock returns env as the block CreateProcess takes: each
+// variable followed by a NUL, and a NUL at the end. Of variables set more
+// than once, the last one is kept, as exec.Cmd does.
+func environmentBlock(env []string) *uint16 {
+	last := map[string]int{}
+	for i, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		last[strings.ToUpper(name)] = i
+	}
+	var block []uint16
+	for i, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		if last[strings.ToUpper(name)] == i {
+			block = append(block, utf16.Encode([]rune(variable))...)
+			block = append(block, 0)
+		}
+	}
+	block = append(block, 0)
+	return &block[0]
+}
+
+// Read reads the screen updates of the console
+func (p *conPTY) Read(b []byte) (int, error) {
+	return p.output.Read(b)
+}
+
+// Write sends input to the shell
+func (p *conPTY) Write(b []byte) (int, error) {
+	return p.input.Write(b)
+}
+
+// Resize sets the size of the console, which tells the shell
+func (p *conPTY) Resize(cols, rows int) error {
+	return windows.ResizePseudoConsole(p.console, windows.Coord{X: int16(cols), Y: int16(rows)})
+}
+
+// Wait waits for the shell to exit
+func (p *conPTY) Wait() error {
+	if p.process == nil {
+		return nil
+	}
+	_, err := p.process.Wait()
+	return err
+}
+
+// HangUp closes the console, as closing its window does: the programs
+// attached to it are sent a close event and end
+func (p *conPTY) HangUp() {
+	p.closeConsole()
+}
+
+// Kill stops the shell at once
+func (p *conPTY) Kill() {
+	if p.process != nil {
+		_ = p.process.Kill()
+	}
+	p.closeConsole()
+}
+
+// Close closes the console and its pipes
+func (p *conPTY) Close() error {
+	p.closeConsole()
+	p.input.Close()
+	return p.output.Close()
+}
+
+// Dir returns "", as Windows only lets a process read its own working
+// directory
+func (p *conPTY) Dir() string {
+	return ""
+}
+
+// closeConsole closes the pseudo console, which ends the output pipe once
+// the pending output is read
+func (p *conPTY) closeConsole() {
+	p.closeOnce.Do(func() {
+		windows.ClosePseudoConsole(p.console)
+	})
+}
+
+// defaultShell returns the shell terminals run unless another is
+// configured: PowerShell 7 if it is installed, Windows PowerShell if not,
+// and the command prompt as a last resort
+func defaultShell() string {
+	for _, shell := range []string{"pwsh.exe", "powershell.exe"} {
+		if _, err := exec.LookPath(shell); err == nil {
+			return shell
+		}
+	}
+	if shell := os.Getenv("ComSpec"); shell != "" {
+		return shell
+	}
+	return "cmd.exe"
+}
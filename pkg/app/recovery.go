@@ -0,0 +1,230 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gotui/pkg/editor"
+)
+
+// recoveryInterval is how often recovery copies of changed buffers are
+// brought up to date
+const recoveryInterval = 5 * time.Second
+
+// recoveryCopy is the content of a swap file: the text of a buffer with
+// unsaved changes, kept so the changes survive a crash
+type recoveryCopy struct {
+	Path  string    `json:"path"` // absolute path of the file
+	PID   int       `json:"pid"`  // process of the goui that wrote the copy
+	Saved time.Time `json:"saved"`
+	Text  string    `json:"text"`
+
+	file string // the swap file
+}
+
+// recoveryState writes the swap files of the open buffers and offers to
+// restore those left behind by a goui that did not exit cleanly
+type recoveryState struct {
+	dir  string // "" until started
+	done chan struct{}
+}
+
+var recovery recoveryState
+
+// swapDir returns the directory of the swap files
+func swapDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "goui", "swap"), nil
+}
+
+// swapFile returns the swap file of a buffer, named after its path so a
+// file has one copy however often it is opened
+func (r *recoveryState) swapFile(buf *Buffer) string {
+	sum := sha256.Sum256([]byte(absPath(buf.Path())))
+	return filepath.Join(r.dir, filepath.Base(buf.Path())+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// start creates the swap directory and brings the copies up to date every
+// recoveryInterval
+func (r *recoveryState) start() error {
+	dir, err := swapDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	r.dir = dir
+	r.done = make(chan struct{})
+	go func() {
+		defer recoverPanic()
+		ticker := time.NewTicker(recoveryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ui.app.QueueUpdate(r.sync)
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stop ends the updates and deletes the copies of the open buffers, since
+// goui exits on purpose
+func (r *recoveryState) stop() {
+	if r.dir == "" {
+		return
+	}
+	close(r.done)
+	for _, buf := range buffers.buffers {
+		r.remove(buf)
+	}
+}
+
+// sync writes the copies of buffers changed since their last copy and
+// deletes those of buffers without unsaved changes
+func (r *recoveryState) sync() {
+	for _, buf := range buffers.buffers {
+		switch {
+		case !buf.dirty:
+			r.remove(buf)
+		case buf.unswapped:
+			if err := r.write(buf); err != nil {
+				notify(noticeError, fmt.Sprintf("Error writing recovery copy: %s", err))
+				continue
+			}
+			buf.unswapped, buf.swapped = false, true
+		}
+	}
+}
+
+// write saves the recovery copy of a buffer
+func (r *recoveryState) write(buf *Buffer) error {
+	data, err := json.Marshal(recoveryCopy{Path: absPath(buf.Path()), PID: os.Getpid(), Saved: time.Now(), Text: buf.Text()})
+	if err != nil {
+		return err
+	}
+	// Written next to the copy and renamed, so a crash while writing
+	// leaves the previous copy
+	path := r.swapFile(buf)
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// remove deletes the recovery copy of a buffer, if it has one
+func (r *recoveryState) remove(buf *Buffer) {
+	if r.dir == "" || !buf.swapped {
+		return
+	}
+	_ = os.Remove(r.swapFile(buf))
+	buf.swapped = false
+}
+
+// leftBehind returns the recovery copies of goui processes that are no
+// longer running, oldest first
+func (r *recoveryState) leftBehind() []recoveryCopy {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+	var copies []recoveryCopy
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		file := filepath.Join(r.dir, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var c recoveryCopy
+		if json.Unmarshal(data, &c) != nil || c.Path == "" {
+			continue
+		}
+		if c.PID == os.Getpid() || processRunning(c.PID) {
+			// Another goui is editing the file
+			continue
+		}
+		c.file = file
+		copies = append(copies, c)
+	}
+	sort.Slice(copies, func(i, j int) bool { return copies[i].Saved.Before(copies[j].Saved) })
+	return copies
+}
+
+// offerRestore asks whether to restore the changes of recovery copies left
+// behind by a crash. Deleted copies are gone for good; copies kept for later
+// are offered again at the next start.
+func (r *recoveryState) offerRestore() {
+	if r.dir == "" {
+		return
+	}
+	copies := r.leftBehind()
+	if len(copies) == 0 {
+		return
+	}
+	question := fmt.Sprintf("goui did not exit cleanly. Restore the unsaved changes of %s?", workspacePath(copies[0].Path))
+	if len(copies) > 1 {
+		question = fmt.Sprintf("goui did not exit cleanly. Restore the unsaved changes of %d files?", len(copies))
+	}
+	chooseAction(question, []string{"Restore", "Delete", "Later"}, func(index int) {
+		switch index {
+		case 0:
+			var failed []string
+			for _, c := range copies {
+				if err := r.restore(c); err != nil {
+					failed = append(failed, err.Error())
+				}
+			}
+			if len(failed) > 0 {
+				notify(noticeError, fmt.Sprintf("Error restoring unsaved changes:\n%s", strings.Join(failed, "\n")))
+			} else {
+				ui.output.message(fmt.Sprintf("Restored the unsaved changes of %d files; save them to keep them", len(copies)))
+			}
+		case 1:
+			for _, c := range copies {
+				_ = os.Remove(c.file)
+			}
+		}
+	})
+}
+
+// restore opens the file of a recovery copy and replaces its text with the
+// copy's, as an edit that can be undone
+func (r *recoveryState) restore(c recoveryCopy) error {
+	path := workspacePath(c.Path)
+	if _, err := os.Stat(c.Path); errors.Is(err, os.ErrNotExist) {
+		buffers.create(path)
+	} else if err := loadFile(path); err != nil {
+		return err
+	}
+	buf := buffers.current()
+	if buf.Text() == c.Text {
+		_ = os.Remove(c.file)
+		return nil
+	}
+	if buf.ReadOnly() {
+		return fmt.Errorf("%s is read-only", path)
+	}
+	ui.editor.Replace(editor.Position{}, ui.editor.LastPosition(), c.Text)
+	ui.editor.SetCursor(editor.Position{})
+	// The copy is replaced by that of this session
+	buf.swapped = true
+	return nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/rivo/tview"
+)
+
+// referencesPanel lists the references found for a symbol
+type referencesPanel struct {
+	list      *tview.List
+	locations []jumpLocation
+}
+
+var references referencesPanel
+
+// createReferencesPanel creates and returns the references list component
+func createReferencesPanel() *tview.List {
+	r := &references
+	r.list = tview.NewList().
+		ShowSecondaryText(false).
+		SetHighlightFullLine(true)
+	r.list.SetBorder(true).SetTitle("References")
+	r.list.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
+		if index >= len(r.locations) {
+			return
+		}
+		location := r.locations[index]
+		jumps.push()
+		if err := openFileAt(location.path, location.pos, location.pos); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+		}
+	})
+	r.list.SetDoneFunc(func() {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+	return r.list
+}
+
+// show fills the panel with LSP locations and focuses it
+func (r *referencesPanel) show(symbol string, locations []lspLocation) {
+	r.list.Clear()
+	r.locations = r.locations[:0]
+	files := make(map[string][][]rune)
+	for _, location := range locations {
+		path := uriToPath(location.URI)
+		lines, ok := files[path]
+		if !ok {
+			lines = fileLines(path)
+			files[path] = lines
+		}
+		pos := fromLSPPosition(lines, location.Range.Start)
+		text := ""
+		if pos.Line < len(lines) {
+			text = string(lines[pos.Line])
+		}
+		r.locations = append(r.locations, jumpLocation{path: path, pos: pos})
+		r.list.AddItem(fmt.Sprintf("%s%s[-]:%d:%d  %s", colorTag(theme.Success), tview.Escape(path), pos.Line+1, pos.Col+1,
+			tview.Escape(strings.TrimLeft(text, " \t"))), "", 0, nil)
+	}
+	r.list.SetTitle(fmt.Sprintf("References to %s (%d)", symbol, len(locations)))
+	showPanel("references")
+	ui.app.SetFocus(r.list)
+}
+
+// fileLines returns the lines of a file, preferring the content of an open
+// buffer over the file on disk
+func fileLines(path string) [][]rune {
+	if index := buffers.find(path); index >= 0 {
+		return buffers.buffers[index].Lines()
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return editor.SplitLines(string(content))
+}
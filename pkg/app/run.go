@@ -0,0 +1,260 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// buildRunner runs go build and go run for the workspace and shows their
+// output, with the error locations it contains linked to the source
+type buildRunner struct {
+	view    *tview.TextView
+	cmd     *exec.Cmd
+	job     *job
+	errors  []Diagnostic   // locations found in the output, in order
+	colors  ansiTranslator // style of the output, carried from line to line
+	binary  string         // the program built for a run configuration, removed once it ends
+	dir     string         // the directory the command runs in, which error locations are relative to
+	current int            // index of the selected error, -1 if none
+	runs    int            // number of commands started, to drop output of replaced ones
+
+	// selecting is set while the selection is moved from code, so only
+	// clicks on an error jump to it
+	selecting bool
+}
+
+var builds = buildRunner{current: -1}
+
+// createRunPanel creates and returns the Build channel of the Output pane,
+// showing the output of builds and runs
+func createRunPanel() *tview.TextView {
+	builds.view = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWordWrap(true).
+		SetMaxLines(5000)
+	builds.view.SetTitle("Build")
+	builds.view.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if builds.selecting || len(added) == 0 {
+			return
+		}
+		var index int
+		if _, err := fmt.Sscanf(added[0], "e%d", &index); err == nil {
+			builds.selectError(index)
+			builds.jump()
+		}
+	})
+	builds.view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyDown || event.Rune() == 'j':
+			builds.moveSelection(1)
+		case event.Key() == tcell.KeyUp || event.Rune() == 'k':
+			builds.moveSelection(-1)
+		case event.Key() == tcell.KeyEnter:
+			builds.jump()
+		case event.Key() == tcell.KeyEscape:
+			ui.app.SetFocus(ui.editor)
+		default:
+			return event
+		}
+		return nil
+	})
+	return builds.view
+}
+
+// start runs the go tool with args in the workspace root of the file in the
+// editor, replacing any command that is still running
+func (b *buildRunner) start(args ...string) {
+	b.startIn(activeRoot(), args...)
+}
+
+// startIn runs the go tool with args in dir, replacing any command that is
+// still running
+func (b *buildRunner) startIn(dir string, args ...string) {
+	command := "go " + strings.Join(args, " ")
+	b.reset("Run: " + command)
+	b.dir = dir
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	b.launch(command, cmd, func() { b.startIn(dir, args...) }, nil)
+}
+
+// reset replaces any command that is still running and clears the panel
+// for a new one
+func (b *buildRunner) reset(title string) {
+	b.stop()
+	b.errors, b.current = nil, -1
+	b.dir = workspaceRoot
+	b.colors = ansiTranslator{}
+	b.view.Clear().SetTitle(title)
+	outputs.show("build")
+}
+
+// launch runs cmd, streaming its output into the panel. rerun starts it
+// over for watch mode; then, if set, runs once cmd succeeded.
+func (b *buildRunner) launch(command string, cmd *exec.Cmd, rerun func(), then func()) {
+	b.runs++
+	run := b.runs
+	fmt.Fprintf(b.view, "%s$ %s[-]\n", colorTag(theme.Accent), tview.Escape(command))
+
+	setProcessGroup(cmd)
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(b.view, "%s%s[-]\n", colorTag(theme.Error), tview.Escape(fmt.Sprintf("Failed to start %s: %s", command, err)))
+		b.removeBinary()
+		return
+	}
+	b.cmd = cmd
+	b.job = jobs.add(command, cmd, rerun)
+	started := time.Now()
+
+	scanned := make(chan struct{})
+	go func() {
+		defer recoverPanic()
+		defer close(scanned)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			ui.app.QueueUpdateDraw(func() {
+				if b.runs == run {
+					b.appendLine(line)
+				}
+			})
+		}
+		// Keep draining so the command never blocks on a full pipe
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+	go func() {
+		defer recoverPanic()
+		err := cmd.Wait()
+		writer.Close()
+		<-scanned
+		elapsed := time.Since(started).Round(10 * time.Millisecond)
+		ui.app.QueueUpdateDraw(func() {
+			if b.runs != run {
+				return
+			}
+			b.finish(command, err, elapsed)
+			if then != nil && err == nil && !b.job.canceled {
+				then()
+				return
+			}
+			b.removeBinary()
+		})
+	}()
+}
+
+// stop kills the running command, if any
+func (b *buildRunner) stop() {
+	if b.cmd != nil {
+		killProcessGroup(b.cmd)
+		jobs.remove(b.job)
+		b.cmd = nil
+	}
+	b.removeBinary()
+}
+
+// removeBinary deletes the program built for a run configuration, if any
+func (b *buildRunner) removeBinary() {
+	if b.binary != "" {
+		os.Remove(b.binary)
+		b.binary = ""
+	}
+}
+
+// appendLine adds a line of output, turning error locations into regions
+// that can be selected
+func (b *buildRunner) appendLine(line string) {
+	tagged, plain := b.colors.translate(line)
+	diagnostic, ok := parseLocationLine(b.dir, plain, "go build")
+	if !ok {
+		fmt.Fprintf(b.view, "%s\n", tagged)
+		return
+	}
+	fmt.Fprintf(b.view, `["e%d"]%s%s[-][""]`+"\n", len(b.errors), colorTag(theme.Error), tview.Escape(plain))
+	b.errors = append(b.errors, diagnostic)
+}
+
+// finish reports how the command ended and publishes the errors it printed
+// to the Problems panel
+func (b *buildRunner) finish(command string, err error, elapsed time.Duration) {
+	b.cmd = nil
+	jobs.remove(b.job)
+	var exitErr *exec.ExitError
+	switch {
+	case b.job.canceled:
+		fmt.Fprintf(b.view, "%s%s canceled after %s[-]\n", colorTag(theme.Error), tview.Escape(command), elapsed)
+	case err == nil:
+		fmt.Fprintf(b.view, "%s%s finished in %s[-]\n", colorTag(theme.Success), tview.Escape(command), elapsed)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		fmt.Fprintf(b.view, "%s%s failed with exit status %d after %s[-]\n", colorTag(theme.Error), tview.Escape(command), exitErr.ExitCode(), elapsed)
+	default:
+		fmt.Fprintf(b.view, "%s%s[-]\n", colorTag(theme.Error), tview.Escape(fmt.Sprintf("%s stopped: %s", command, err)))
+	}
+	problems.replace("go build", b.errors)
+}
+
+// clear empties the Build channel. The errors of the last build stay, so
+// they can still be gone through with nextError.
+func (b *buildRunner) clear() {
+	b.current = -1
+	b.view.Clear()
+}
+
+// selectError highlights the error at index
+func (b *buildRunner) selectError(index int) {
+	if index < 0 || index >= len(b.errors) {
+		return
+	}
+	b.current = index
+	b.selecting = true
+	b.view.Highlight(fmt.Sprintf("e%d", index)).ScrollToHighlight()
+	b.selecting = false
+}
+
+// moveSelection selects the error delta positions away, wrapping around
+func (b *buildRunner) moveSelection(delta int) {
+	if len(b.errors) == 0 {
+		return
+	}
+	index := b.current + delta
+	if b.current < 0 && delta < 0 {
+		index = len(b.errors) - 1
+	}
+	b.selectError((index%len(b.errors) + len(b.errors)) % len(b.errors))
+}
+
+// jump opens the selected error in the editor
+func (b *buildRunner) jump() {
+	if b.current < 0 || b.current >= len(b.errors) {
+		return
+	}
+	diagnostic := b.errors[b.current]
+	jumps.push()
+	if err := openFileAt(diagnostic.Path, diagnostic.Range.From, diagnostic.Range.From); err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+	}
+}
+
+// nextError moves to the next (or, with a negative delta, previous) error of
+// the last build or run and opens it
+func (b *buildRunner) nextError(delta int) {
+	if len(b.errors) == 0 {
+		outputs.show("messages")
+		ui.output.message("No build errors")
+		return
+	}
+	outputs.show("build")
+	b.moveSelection(delta)
+	b.jump()
+}
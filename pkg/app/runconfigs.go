@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// program returns the absolute directory of the configuration's package
+func (c runConfig) program() (string, error) {
+	return resolveConfigPath(c.Program)
+}
+
+// dir returns the absolute working directory of the program
+func (c runConfig) dir() (string, error) {
+	return resolveConfigPath(c.Cwd)
+}
+
+// resolveConfigPath returns the absolute path of a path in the project
+// config file, which is taken from the workspace unless it is absolute
+func resolveConfigPath(path string) (string, error) {
+	path = expandHome(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workspaceRoot, path)
+	}
+	return filepath.Abs(path)
+}
+
+// packageName returns the package as the configuration names it
+func (c runConfig) packageName() string {
+	if c.Program == "" {
+		return "."
+	}
+	return c.Program
+}
+
+// environ returns the environment of the program: the editor's, with the
+// configuration's variables added
+func (c runConfig) environ() []string {
+	env := os.Environ()
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+c.Env[name])
+	}
+	return env
+}
+
+// commandLine describes how the configuration runs its program
+func (c runConfig) commandLine() string {
+	return strings.TrimSpace(c.packageName() + " " + strings.Join(c.Args, " "))
+}
+
+// launchArguments returns the arguments of the DAP launch request that has
+// dlv debug the program as the configuration says
+func (c runConfig) launchArguments() (map[string]interface{}, error) {
+	program, err := c.program()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := c.dir()
+	if err != nil {
+		return nil, err
+	}
+	launch := map[string]interface{}{
+		"request":    "launch",
+		"mode":       "debug",
+		"program":    program,
+		"cwd":        dir,
+		"outputMode": "remote",
+	}
+	if len(c.Args) > 0 {
+		launch["args"] = c.Args
+	}
+	if len(c.Env) > 0 {
+		launch["env"] = c.Env
+	}
+	return launch, nil
+}
+
+// showRunConfigs lists the run configurations of the project config file;
+// choosing one runs it, or debugs it if debug is set
+func showRunConfigs(debug bool) {
+	project, err := loadProjectConfig()
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading run configurations: %s", err))
+		return
+	}
+	if len(project.Run) == 0 {
+		ui.output.message(fmt.Sprintf("No run configurations: add a [run.<name>] section to %s", projectConfigFile))
+		return
+	}
+	names := make([]string, 0, len(project.Run))
+	for name := range project.Run {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	labels := make([]string, len(names))
+	for i, name := range names {
+		labels[i] = fmt.Sprintf("%s %s(%s)[-]", tview.Escape(name), colorTag(theme.Muted), tview.Escape(project.Run[name].commandLine()))
+	}
+	title := "Run Configuration"
+	if debug {
+		title = "Debug Configuration"
+	}
+	showPicker(title, labels, 0, func(index int) {
+		name := names[index]
+		if debug {
+			delve.debug(project.Run[name])
+			return
+		}
+		runConfiguration(name, project.Run[name])
+	})
+}
+
+// runConfiguration builds the program of a run configuration and runs it
+// with its arguments, environment, and working directory, both in the Run
+// panel. The program is built first because go run would run it in the
+// directory it builds in.
+func runConfiguration(name string, c runConfig) {
+	program, err := c.program()
+	if err == nil {
+		_, err = c.dir()
+	}
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error running %s: %s", name, err))
+		return
+	}
+	binary, err := os.CreateTemp("", "goui-run-*"+exeSuffix)
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error running %s: %s", name, err))
+		return
+	}
+	binary.Close()
+
+	builds.reset("Run: " + name)
+	builds.binary = binary.Name()
+	rerun := func() { runConfiguration(name, c) }
+	build := exec.Command("go", "build", "-o", binary.Name(), program)
+	build.Dir = workspaceRoot
+	builds.launch("go build "+c.packageName(), build, rerun, func() {
+		dir, _ := c.dir()
+		cmd := exec.Command(binary.Name(), c.Args...)
+		cmd.Dir = dir
+		cmd.Env = c.environ()
+		builds.launch(strings.TrimSpace(name+" "+strings.Join(c.Args, " ")), cmd, rerun, nil)
+	})
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// scriptEvents are the events init scripts can hook with goui.on
+var scriptEvents = map[string]bool{"open": true, "save": true}
+
+// scriptHost runs the user's init.lua, which can define commands, bind
+// keys, and hook editor events through the goui module
+type scriptHost struct {
+	state *lua.LState
+	path  string
+	hooks map[string][]*lua.LFunction // by event
+	keys  map[string][]string         // key specs set with goui.keymap
+}
+
+var scripts = scriptHost{hooks: map[string][]*lua.LFunction{}, keys: map[string][]string{}}
+
+// scriptPath returns the location of the init script
+func scriptPath() (string, error) {
+	return configFilePath("init.lua")
+}
+
+// load runs the init script, if there is one. Commands it defines before
+// an error stay defined.
+func (s *scriptHost) load() error {
+	path, err := scriptPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	s.path = path
+	s.state = lua.NewState()
+	module := s.state.NewTable()
+	s.state.SetFuncs(module, map[string]lua.LGFunction{
+		"command":      s.luaCommand,
+		"keymap":       s.luaKeymap,
+		"on":           s.luaOn,
+		"run":          s.luaRun,
+		"message":      s.luaMessage,
+		"open":         s.luaOpen,
+		"current_file": s.luaCurrentFile,
+		"insert":       s.luaInsert,
+	})
+	s.state.SetGlobal("goui", module)
+	return s.state.DoFile(path)
+}
+
+// close releases the script runtime
+func (s *scriptHost) close() {
+	if s.state != nil {
+		s.state.Close()
+	}
+}
+
+// call runs a script function, showing its errors in the output window
+func (s *scriptHost) call(fn *lua.LFunction, args ...lua.LValue) {
+	if err := s.state.CallByParam(lua.P{Fn: fn, Protect: true}, args...); err != nil {
+		notify(noticeError, fmt.Sprintf("Error in %s: %s", s.path, err))
+	}
+}
+
+// fire runs the hooks of an event with the absolute path of the file
+func (s *scriptHost) fire(event string, buf *Buffer) {
+	for _, fn := range s.hooks[event] {
+		s.call(fn, lua.LString(absPath(buf.Path())))
+	}
+}
+
+// fileOpened runs the hooks of files opened in a new tab
+func (s *scriptHost) fileOpened(buf *Buffer) {
+	s.fire("open", buf)
+}
+
+// fileSaved runs the hooks of written files
+func (s *scriptHost) fileSaved(buf *Buffer) {
+	s.fire("save", buf)
+}
+
+// luaCommand implements goui.command{name=, title=, keys=, run=}, which
+// defines a command for the key bindings and the command palette
+func (s *scriptHost) luaCommand(L *lua.LState) int {
+	spec := L.CheckTable(1)
+	name, ok := spec.RawGetString("name").(lua.LString)
+	if !ok || name == "" {
+		L.ArgError(1, "name must be a string")
+	}
+	if _, exists := commands[string(name)]; exists {
+		L.ArgError(1, fmt.Sprintf("command %q already exists", name))
+	}
+	run, ok := spec.RawGetString("run").(*lua.LFunction)
+	if !ok {
+		L.ArgError(1, "run must be a function")
+	}
+	title := string(name)
+	if t, ok := spec.RawGetString("title").(lua.LString); ok {
+		title = string(t)
+	}
+	keys, err := luaStrings(spec.RawGetString("keys"))
+	if err != nil {
+		L.ArgError(1, fmt.Sprintf("keys %s", err))
+	}
+	RegisterCommand(Command{
+		Name:  string(name),
+		Title: title,
+		Scope: scopeGlobal,
+		Keys:  keys,
+		Run: func() {
+			s.call(run)
+		},
+	})
+	return 0
+}
+
+// luaKeymap implements goui.keymap(command, keys), which binds a command to
+// a key or a list of keys, overriding the keys files
+func (s *scriptHost) luaKeymap(L *lua.LState) int {
+	name := L.CheckString(1)
+	if _, ok := commands[name]; !ok {
+		L.ArgError(1, fmt.Sprintf("unknown command %q", name))
+	}
+	keys, err := luaStrings(L.CheckAny(2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+	}
+	s.keys[name] = keys
+	return 0
+}
+
+// luaOn implements goui.on(event, fn), which runs fn with the path of the
+// file when the event happens
+func (s *scriptHost) luaOn(L *lua.LState) int {
+	event := L.CheckString(1)
+	if !scriptEvents[event] {
+		L.ArgError(1, fmt.Sprintf("unknown event %q, expected \"open\" or \"save\"", event))
+	}
+	s.hooks[event] = append(s.hooks[event], L.CheckFunction(2))
+	return 0
+}
+
+// luaRun implements goui.run(command), which runs any command by name
+func (s *scriptHost) luaRun(L *lua.LState) int {
+	name := L.CheckString(1)
+	if !runCommand(name) {
+		L.ArgError(1, fmt.Sprintf("unknown command %q", name))
+	}
+	return 0
+}
+
+// luaMessage implements goui.message(text), which shows text in the output
+// window
+func (s *scriptHost) luaMessage(L *lua.LState) int {
+	ui.output.message(L.CheckString(1))
+	return 0
+}
+
+// luaOpen implements goui.open(path), which opens a file in a tab
+func (s *scriptHost) luaOpen(L *lua.LState) int {
+	if err := loadFile(L.CheckString(1)); err != nil {
+		L.RaiseError("%s", err)
+	}
+	return 0
+}
+
+// luaCurrentFile implements goui.current_file(), which returns the path of
+// the file in the editor, or nil
+func (s *scriptHost) luaCurrentFile(L *lua.LState) int {
+	if buf := buffers.current(); buf != nil {
+		L.Push(lua.LString(absPath(buf.Path())))
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 1
+}
+
+// luaInsert implements goui.insert(text), which types text at the cursor
+func (s *scriptHost) luaInsert(L *lua.LState) int {
+	if buffers.current() == nil {
+		L.RaiseError("no file loaded")
+	}
+	ui.editor.InsertText(L.CheckString(1))
+	return 0
+}
+
+// luaStrings converts a string or a list of strings, or nil for none
+func luaStrings(value lua.LValue) ([]string, error) {
+	switch value := value.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LString:
+		return []string{string(value)}, nil
+	case *lua.LTable:
+		var list []string
+		for i := 1; i <= value.Len(); i++ {
+			s, ok := value.RawGetInt(i).(lua.LString)
+			if !ok {
+				return nil, fmt.Errorf("must be strings")
+			}
+			list = append(list, string(s))
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("must be a string or a list of strings")
+}
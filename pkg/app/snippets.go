@@ -0,0 +1,114 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gotui/pkg/editor"
+
+	"github.com/BurntSushi/toml"
+)
+
+// builtinSnippets are the snippets of each language that snippet files add
+// to or override, by language
+var builtinSnippets = map[string][]editor.Snippet{
+	"go": {
+		{Prefix: "iferr", Description: "Return the error if it is not nil", Body: "if err != nil {\n\treturn ${1:err}\n}\n$0"},
+		{Prefix: "main", Description: "Main package", Body: "package main\n\nfunc main() {\n\t$0\n}"},
+		{Prefix: "fn", Description: "Function", Body: "func ${1:name}($2) $3{\n\t$0\n}"},
+		{Prefix: "forr", Description: "Loop over a range", Body: "for ${1:_}, ${2:v} := range ${3:values} {\n\t$0\n}"},
+		{Prefix: "fori", Description: "Loop over indexes", Body: "for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t$0\n}"},
+		{Prefix: "errorf", Description: "Wrap an error", Body: "fmt.Errorf(\"${1:failed to do something}: %w\", ${2:err})$0"},
+		{Prefix: "test", Description: "Test function", Body: "func Test${1:Name}(t *testing.T) {\n\t$0\n}"},
+		{Prefix: "struct", Description: "Struct type", Body: "type ${1:Name} struct {\n\t$0\n}"},
+	},
+}
+
+// snippetFile is a user's snippet file of a language as last read
+type snippetFile struct {
+	modTime  time.Time
+	snippets []editor.Snippet
+}
+
+// snippetFiles caches the snippet files read, by path
+var snippetFiles = map[string]*snippetFile{}
+
+// snippetsFor returns the snippets available in a file, sorted by prefix:
+// the built-in ones of its language and those of the snippet file
+// snippets/<language>.toml in the config directory, read again when it
+// changes
+func snippetsFor(path string) ([]editor.Snippet, error) {
+	if path == "" {
+		return nil, nil
+	}
+	language := editor.FileLanguage(path)
+	byPrefix := map[string]editor.Snippet{}
+	for _, s := range builtinSnippets[language] {
+		byPrefix[s.Prefix] = s
+	}
+	file, err := configFilePath(filepath.Join("snippets", language+".toml"))
+	if err == nil {
+		var user []editor.Snippet
+		user, err = readSnippetFile(file)
+		for _, s := range user {
+			byPrefix[s.Prefix] = s
+		}
+	}
+	snippets := make([]editor.Snippet, 0, len(byPrefix))
+	for _, s := range byPrefix {
+		snippets = append(snippets, s)
+	}
+	sort.Slice(snippets, func(i, j int) bool { return snippets[i].Prefix < snippets[j].Prefix })
+	return snippets, err
+}
+
+// readSnippetFile reads a snippet file, a table for each snippet named by
+// its prefix with a body and an optional description. A missing file has
+// no snippets.
+func readSnippetFile(path string) ([]editor.Snippet, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := snippetFiles[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.snippets, nil
+	}
+	var tables map[string]struct {
+		Description string `toml:"description"`
+		Body        string `toml:"body"`
+	}
+	if _, err := toml.DecodeFile(path, &tables); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var snippets []editor.Snippet
+	for prefix, table := range tables {
+		if table.Body == "" {
+			return nil, fmt.Errorf("%s: snippet %s has no body", path, prefix)
+		}
+		snippets = append(snippets, editor.Snippet{Prefix: prefix, Description: table.Description, Body: table.Body})
+	}
+	snippetFiles[path] = &snippetFile{modTime: info.ModTime(), snippets: snippets}
+	return snippets, nil
+}
+
+// snippetCompletions returns the snippets of a buffer as completion items
+func snippetCompletions(buf *Buffer) []editor.CompletionItem {
+	snippets, _ := snippetsFor(buf.Path())
+	items := make([]editor.CompletionItem, len(snippets))
+	for i := range snippets {
+		s := snippets[i]
+		detail := "snippet"
+		if s.Description != "" {
+			detail += ": " + s.Description
+		}
+		items[i] = editor.CompletionItem{Label: s.Prefix, Detail: detail, Snippet: &s}
+	}
+	return items
+}
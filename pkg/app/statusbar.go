@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// statusSeparator goes between the fields of the status bar
+const statusSeparator = " │ "
+
+// StatusSegment is a field of the status bar, filled in by the subsystem
+// that registered it
+type StatusSegment struct {
+	Name  string
+	Order int  // fields are laid out by increasing order on each side
+	Right bool // the field is on the right side of the bar
+	// Text returns the field's text, or "" to leave the field out. It runs
+	// on every draw, so it must be quick.
+	Text func() string
+}
+
+var statusSegments []StatusSegment
+
+// RegisterStatusSegment adds a field to the status bar, replacing the one
+// with the same name
+func RegisterStatusSegment(s StatusSegment) {
+	for i := range statusSegments {
+		if statusSegments[i].Name == s.Name {
+			statusSegments[i] = s
+			return
+		}
+	}
+	statusSegments = append(statusSegments, s)
+	sort.SliceStable(statusSegments, func(i, j int) bool { return statusSegments[i].Order < statusSegments[j].Order })
+}
+
+func init() {
+	for _, s := range []StatusSegment{
+		{Name: "file", Order: 0, Text: statusFile},
+		{Name: "mode", Order: 0, Right: true, Text: statusMode},
+		{Name: "selection", Order: 10, Right: true, Text: statusSelection},
+		{Name: "position", Order: 20, Right: true, Text: statusPosition},
+	} {
+		RegisterStatusSegment(s)
+	}
+}
+
+// editorInfo returns a segment text function that describes the buffer in
+// the editor, leaving the field out when there is none
+func editorInfo(describe func(buf *Buffer) string) func() string {
+	return func() string {
+		buf := buffers.current()
+		if buf == nil {
+			return ""
+		}
+		return describe(buf)
+	}
+}
+
+// statusFile describes the file in the editor
+func statusFile() string {
+	buf := buffers.current()
+	if buf == nil {
+		return ""
+	}
+	name := buf.Name()
+	if buf.Path() != "" {
+		name = workspacePath(buf.Path())
+	}
+	switch {
+	case buf.ReadOnly():
+		name += " [read-only]"
+	case buf.dirty:
+		name += " [modified]"
+	}
+	return name
+}
+
+// statusMode names the mode of the editor's keymap
+func statusMode() string {
+	if ui.editor.Keymap() == nil {
+		return ""
+	}
+	return ui.editor.Keymap().Mode()
+}
+
+// statusPosition gives the line and column of the cursor, counted from 1
+func statusPosition() string {
+	buf := buffers.current()
+	if buf == nil {
+		return ""
+	}
+	switch {
+	case buf.large != nil:
+		return fmt.Sprintf("Ln %d", buf.large.cursor+1)
+	case buf.hex != nil:
+		return fmt.Sprintf("Offset 0x%X", buf.hex.cursor)
+	}
+	cursor := ui.editor.Cursor()
+	return fmt.Sprintf("Ln %d, Col %d", cursor.Line+1, cursor.Col+1)
+}
+
+// statusSelection gives the size of the selection
+func statusSelection() string {
+	if buffers.current() == nil || !ui.editor.HasSelection() {
+		return ""
+	}
+	from, to := ui.editor.Selection()
+	chars := len([]rune(ui.editor.TextRange(from, to)))
+	if lines := to.Line - from.Line + 1; lines > 1 {
+		return fmt.Sprintf("%d lines, %d chars selected", lines, chars)
+	}
+	return fmt.Sprintf("%d chars selected", chars)
+}
+
+// statusBar is the line at the bottom of the window showing the registered
+// segments
+type statusBar struct {
+	*tview.Box
+	style tcell.Style
+}
+
+// createStatusBar creates and returns the status bar component
+func createStatusBar() *statusBar {
+	s := &statusBar{Box: tview.NewBox()}
+	s.applyTheme(theme)
+	return s
+}
+
+// applyTheme sets the bar's style from a theme
+func (s *statusBar) applyTheme(t *Theme) {
+	s.style = tcell.StyleDefault.Background(t.Contrast).Foreground(t.Text)
+}
+
+// Draw draws this primitive onto the screen
+func (s *statusBar) Draw(screen tcell.Screen) {
+	x, y, width, height := s.GetRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	fillRow(screen, x, y, width, s.style)
+	var left, right []string
+	for _, segment := range statusSegments {
+		text := segment.Text()
+		switch {
+		case text == "":
+		case segment.Right:
+			right = append(right, text)
+		default:
+			left = append(left, text)
+		}
+	}
+	printText(screen, " "+strings.Join(left, statusSeparator), x, y, width, s.style)
+	// The right side goes over the left one when they overlap, keeping the
+	// cursor position readable
+	text := " " + strings.Join(right, statusSeparator) + " "
+	if w := runewidth.StringWidth(text); w <= width {
+		printText(screen, text, x+width-w, y, w, s.style)
+	}
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// task is a command that can be picked from the task list
+type task struct {
+	name    string
+	command string
+	source  string // where the task was found, shown next to its name
+}
+
+// taskRunner runs one task at a time, streaming its output into the Tasks
+// channel of the Output pane
+type taskRunner struct {
+	view *outputView
+	cmd  *exec.Cmd
+	job  *job
+	name string
+	runs int // number of tasks started, to drop output of replaced ones
+}
+
+var tasks taskRunner
+
+// makefileNames are the files make reads, in the order it looks for them
+var makefileNames = []string{"GNUmakefile", "makefile", "Makefile"}
+
+// makeRuleLine matches the targets of a rule, leaving out variable
+// assignments such as "A := b"
+var makeRuleLine = regexp.MustCompile(`^([A-Za-z0-9_][^:=#]*?)\s*::?(?:[^=]|$)`)
+
+// makeTargets returns the explicit targets of the workspace's makefile in
+// the order they are defined, or nil if there is none
+func makeTargets() ([]string, error) {
+	for _, name := range makefileNames {
+		content, err := os.ReadFile(filepath.Join(workspaceRoot, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		var targets []string
+		seen := map[string]bool{}
+		for _, line := range strings.Split(string(content), "\n") {
+			match := makeRuleLine.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			for _, target := range strings.Fields(match[1]) {
+				// Pattern rules and computed names cannot be run by name
+				if strings.ContainsAny(target, "%$") || seen[target] {
+					continue
+				}
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+		return targets, nil
+	}
+	return nil, nil
+}
+
+// findTasks returns the tasks of the project config file, sorted by name,
+// followed by the makefile targets
+func findTasks() ([]task, error) {
+	project, err := loadProjectConfig()
+	if err != nil {
+		return nil, err
+	}
+	var found []task
+	for name, command := range project.Tasks {
+		found = append(found, task{name: name, command: command, source: projectConfigFile})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].name < found[j].name })
+	targets, err := makeTargets()
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		found = append(found, task{name: target, command: "make " + target, source: "make"})
+	}
+	return found, nil
+}
+
+// showTasks opens the list of tasks; choosing one runs it
+func showTasks() {
+	found, err := findTasks()
+	if err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading tasks: %s", err))
+		return
+	}
+	if len(found) == 0 {
+		ui.output.message(fmt.Sprintf("No tasks: add a Makefile or a [tasks] section to %s", projectConfigFile))
+		return
+	}
+	labels := make([]string, len(found))
+	for i, t := range found {
+		labels[i] = fmt.Sprintf("%s %s(%s)[-]", tview.Escape(t.name), colorTag(theme.Muted), t.source)
+	}
+	showPicker("Tasks", labels, 0, func(index int) {
+		tasks.start(found[index])
+	})
+}
+
+// start runs a task, replacing the one that is still running
+func (r *taskRunner) start(t task) {
+	r.stop()
+	r.runs++
+	run := r.runs
+	r.name = t.name
+	r.view.Clear().SetTitle("Task: " + t.name)
+	outputs.show("tasks")
+	fmt.Fprintf(r.view, "%s$ %s[-]\n", colorTag(theme.Accent), tview.Escape(t.command))
+
+	cmd := shellCommand(t.command)
+	cmd.Dir = workspaceRoot
+	setProcessGroup(cmd)
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(r.view, "%s%s[-]\n", colorTag(theme.Error), tview.Escape(fmt.Sprintf("Failed to start %s: %s", t.name, err)))
+		return
+	}
+	r.cmd = cmd
+	r.job = jobs.add(t.name, cmd, func() { r.start(t) })
+	started := time.Now()
+
+	scanned := make(chan struct{})
+	colors := &ansiTranslator{}
+	go func() {
+		defer recoverPanic()
+		defer close(scanned)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			ui.app.QueueUpdateDraw(func() {
+				if r.runs == run {
+					tagged, _ := colors.translate(line)
+					fmt.Fprintf(r.view, "%s\n", tagged)
+				}
+			})
+		}
+		// Keep draining so the task never blocks on a full pipe
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+	go func() {
+		defer recoverPanic()
+		err := cmd.Wait()
+		writer.Close()
+		<-scanned
+		elapsed := time.Since(started).Round(10 * time.Millisecond)
+		ui.app.QueueUpdateDraw(func() {
+			if r.runs == run {
+				r.finish(err, elapsed)
+			}
+		})
+	}()
+}
+
+// stop kills the running task, if any
+func (r *taskRunner) stop() {
+	if r.cmd != nil {
+		killProcessGroup(r.cmd)
+		jobs.remove(r.job)
+		r.cmd = nil
+	}
+}
+
+// cancel interrupts the running task at the user's request, killing it if
+// it does not exit
+func (r *taskRunner) cancel() {
+	if r.cmd == nil {
+		ui.output.message("No task is running")
+		return
+	}
+	r.job.cancel()
+}
+
+// finish reports how the task ended
+func (r *taskRunner) finish(err error, elapsed time.Duration) {
+	r.cmd = nil
+	jobs.remove(r.job)
+	var exitErr *exec.ExitError
+	switch {
+	case r.job.canceled:
+		fmt.Fprintf(r.view, "%s%s canceled after %s[-]\n", colorTag(theme.Error), tview.Escape(r.name), elapsed)
+	case err == nil:
+		fmt.Fprintf(r.view, "%s%s finished in %s[-]\n", colorTag(theme.Success), tview.Escape(r.name), elapsed)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		fmt.Fprintf(r.view, "%s%s failed with exit status %d after %s[-]\n", colorTag(theme.Error), tview.Escape(r.name), exitErr.ExitCode(), elapsed)
+	default:
+		fmt.Fprintf(r.view, "%s%s[-]\n", colorTag(theme.Error), tview.Escape(fmt.Sprintf("%s stopped: %s", r.name, err)))
+	}
+}
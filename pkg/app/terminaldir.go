@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "terminal-dir", Order: 1, Text: statusTerminalDir})
+}
+
+// workingDir returns the current directory of the session's shell: the one
+// it reported with OSC 7, or the one procfs tells, or else the one it
+// started in
+func (s *terminalSession) workingDir() string {
+	if dir := s.view.WorkingDir(); dir != "" {
+		return dir
+	}
+	if dir := s.pty.Dir(); dir != "" {
+		return dir
+	}
+	if s.cmd.Dir != "" {
+		return s.cmd.Dir
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// terminalDir returns the current directory of the active terminal, or of
+// the editor if there is none
+func terminalDir() string {
+	if session := terminals.current(); session != nil {
+		return session.workingDir()
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// statusTerminalDir shows the current directory of the terminal while it
+// is focused
+func statusTerminalDir() string {
+	// GetFocus would wait for the draw in progress
+	if ui.terminal == nil || !ui.terminal.HasFocus() {
+		return ""
+	}
+	session := terminals.current()
+	if session == nil {
+		return ""
+	}
+	return "Terminal: " + abbreviateHome(session.workingDir())
+}
+
+// abbreviateHome replaces the home directory at the start of path with "~",
+// the reverse of expandHome
+func abbreviateHome(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// promptOpenFromTerminal asks for a file to open, with relative paths taken
+// from the current directory of the terminal. A file that does not exist is
+// created, so new files can be added where the shell is.
+func promptOpenFromTerminal() {
+	dir := terminalDir()
+	showPrompt("Open file in "+abbreviateHome(dir)+": ", "", func(name string) {
+		name = expandHome(strings.TrimSpace(name))
+		if name == "" {
+			return
+		}
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		// Files in the workspace are named as the explorer names them
+		path = workspacePath(path)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// Outside the workspace the file is created, but not revealed
+			created, err := createEntry(filepath.Dir(path), filepath.Base(path), false)
+			if created == "" {
+				notify(noticeError, fmt.Sprintf("Error creating %s: %s", name, err))
+				return
+			}
+			ui.output.message(fmt.Sprintf("Created %s", relativePath(created)))
+		}
+		if err := loadFile(path); err != nil {
+			notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+			return
+		}
+		ui.app.SetFocus(ui.editor)
+	})
+}
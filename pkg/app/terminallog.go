@@ -0,0 +1,125 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// scriptTime is the format of the times in the header and footer of
+// session logs, the one script(1) uses
+const scriptTime = "2006-01-02 15:04:05-07:00"
+
+// terminalLogDir returns the directory session logs are written to
+func terminalLogDir() (string, error) {
+	if config.Terminal.LogDir != "" {
+		return expandHome(config.Terminal.LogDir), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "goui", "terminal-logs"), nil
+}
+
+// startLog starts writing everything the session's programs write to the
+// terminal to a new file named after the time, as script(1) does: the raw
+// output, escape sequences included, between a header and a footer telling
+// when logging started and ended. It returns the path of the file.
+func (s *terminalSession) startLog() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil {
+		return s.logPath, nil
+	}
+	dir, err := terminalLogDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("terminal-%s-%d.log", now.Format("20060102-150405"), s.number))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return "", err
+	}
+	cols, rows := s.view.Size()
+	header := fmt.Sprintf("Script started on %s [COMMAND=%q TERM=\"xterm-256color\" COLUMNS=\"%d\" LINES=\"%d\"]\n",
+		now.Format(scriptTime), strings.Join(s.cmd.Args, " "), cols, rows)
+	if _, err := file.WriteString(header); err != nil {
+		file.Close()
+		return "", err
+	}
+	s.log, s.logPath = file, path
+	return path, nil
+}
+
+// stopLog ends the session log, if one is written, and returns its path
+func (s *terminalSession) stopLog() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeLog()
+}
+
+// closeLog writes the footer of the session log and closes it. s.mu must
+// be held.
+func (s *terminalSession) closeLog() (string, error) {
+	if s.log == nil {
+		return "", nil
+	}
+	file, path := s.log, s.logPath
+	s.log, s.logPath = nil, ""
+	_, err := fmt.Fprintf(file, "\nScript done on %s\n", time.Now().Format(scriptTime))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return path, err
+}
+
+// writeLog adds output to the session log. A log that cannot be written is
+// closed rather than left with gaps. s.mu must be held.
+func (s *terminalSession) writeLog(p []byte) {
+	if s.log == nil {
+		return
+	}
+	if _, err := s.log.Write(p); err != nil {
+		path, _ := s.closeLog()
+		s.logLost = true
+		notify(noticeError, fmt.Sprintf("Error writing terminal log %s: %s", path, err))
+	}
+}
+
+// logging reports whether the session's output is logged
+func (s *terminalSession) logging() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log != nil
+}
+
+// toggleTerminalLog starts or stops logging the active session
+func toggleTerminalLog() {
+	session := terminals.current()
+	if session == nil {
+		return
+	}
+	if session.logging() {
+		path, err := session.stopLog()
+		if err != nil {
+			notify(noticeError, fmt.Sprintf("Error closing terminal log: %s", err))
+		} else {
+			ui.output.message(fmt.Sprintf("Stopped logging %s to %s", session.name, path))
+		}
+	} else if session.exited {
+		ui.output.message(fmt.Sprintf("%s has exited", session.name))
+		return
+	} else if path, err := session.startLog(); err != nil {
+		notify(noticeError, fmt.Sprintf("Error starting terminal log: %s", err))
+	} else {
+		ui.output.message(fmt.Sprintf("Logging %s to %s", session.name, path))
+	}
+	terminals.refresh()
+}
@@ -0,0 +1,549 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gotui/pkg/terminal"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// terminalExitTimeout is how long a shell is given to exit once hung up
+// before it is killed, and how long its pending output is waited for
+const terminalExitTimeout = 2 * time.Second
+
+const (
+	terminalFrameInterval = 16 * time.Millisecond // least time between redraws for output
+	terminalFrameBytes    = 64 << 10              // most output written to the view per redraw
+	terminalPendingBytes  = 1 << 20               // output the reader gets ahead of the view by
+)
+
+// terminalProcess is a shell running in a pseudo terminal: a pty on Unix,
+// and a pseudo console on Windows. Reading it returns the output of the
+// shell, and writing it sends the shell input.
+type terminalProcess interface {
+	io.ReadWriter
+	// Resize tells the shell the new size of its terminal
+	Resize(cols, rows int) error
+	// Wait waits for the shell to exit
+	Wait() error
+	// HangUp ends the shell and its programs as closing the terminal
+	// window does, giving them the chance to exit by themselves
+	HangUp()
+	// Kill ends the shell and the program in its foreground at once
+	Kill()
+	// Close closes the terminal
+	Close() error
+	// Dir returns the working directory of the shell, or "" if the system
+	// does not tell
+	Dir() string
+}
+
+// terminalSession is a shell running in a pseudo terminal, shown in a
+// terminal tab
+type terminalSession struct {
+	name   string
+	number int // in the order sessions were started
+	view   *terminal.View
+	pty    terminalProcess
+	cmd    *exec.Cmd // describes the shell; on Windows it is not started
+	exited bool
+
+	mu      sync.Mutex
+	pending []byte        // output read but not yet written to the view
+	ended   bool          // the shell exited once pending is written
+	ready   chan struct{} // signaled when output or the end is pending
+	drained chan struct{} // signaled when pending output is taken
+	closing chan struct{} // closed when the session ends, to drop output
+	done    chan struct{} // closed once the shell is reaped and reading ended
+	log     *os.File      // nil unless the output is logged
+	logPath string
+	logLost bool // the log was closed on an error, which the tab should show
+}
+
+// terminalManager tracks the terminal sessions and renders them as tabs
+type terminalManager struct {
+	sessions []*terminalSession
+	active   int
+	started  int // number of sessions ever started, for naming them
+	tabBar   *tview.TextView
+	pages    *tview.Pages
+
+	// Colors chosen with customizeTerminal, applied to new sessions too
+	background, text tcell.Color
+}
+
+var terminals = terminalManager{active: -1}
+
+// createTerminalPane creates the terminal pane with its tab bar and starts
+// the first session
+func createTerminalPane() (*tview.Flex, error) {
+	terminals.tabBar = tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetWrap(false)
+	terminals.tabBar.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		if index, err := strconv.Atoi(added[0]); err == nil {
+			terminals.switchTo(index)
+			ui.app.SetFocus(ui.terminal)
+		}
+		terminals.tabBar.Highlight()
+	})
+	terminals.pages = tview.NewPages()
+
+	pane := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(terminals.tabBar, 1, 0, false).
+		AddItem(terminals.pages, 0, 1, true)
+	pane.SetBorder(true).SetTitle("Terminal")
+
+	if err := terminals.spawn(); err != nil {
+		return nil, err
+	}
+	return pane, nil
+}
+
+// current returns the active session
+func (m *terminalManager) current() *terminalSession {
+	if m.active < 0 {
+		return nil
+	}
+	return m.sessions[m.active]
+}
+
+// spawn starts a new shell in its own tab and switches to it
+func (m *terminalManager) spawn() error {
+	return m.spawnIn("")
+}
+
+// spawnIn starts a new shell in dir, or in the configured directory if dir
+// is empty, in its own tab and switches to it
+func (m *terminalManager) spawnIn(dir string) error {
+	m.started++
+	session, err := startTerminal(m.started, dir)
+	if err != nil {
+		return err
+	}
+	if m.background != 0 {
+		session.view.SetBackgroundColor(m.background)
+	}
+	if m.text != 0 {
+		session.view.SetTextColor(m.text)
+	}
+	m.sessions = append(m.sessions, session)
+	m.pages.AddPage(session.name, session.view, true, false)
+	m.switchTo(len(m.sessions) - 1)
+	return nil
+}
+
+// switchTo makes the session at index the active one
+func (m *terminalManager) switchTo(index int) {
+	if index < 0 || index >= len(m.sessions) {
+		return
+	}
+	focused := ui.terminal != nil && ui.app.GetFocus() == ui.terminal
+	m.active = index
+	ui.terminal = m.sessions[index].view
+	m.pages.SwitchToPage(m.sessions[index].name)
+	if focused {
+		ui.app.SetFocus(ui.terminal)
+	}
+	m.refresh()
+}
+
+// cycle moves the active tab by delta positions, wrapping around
+func (m *terminalManager) cycle(delta int) {
+	if len(m.sessions) == 0 {
+		return
+	}
+	m.switchTo(((m.active+delta)%len(m.sessions) + len(m.sessions)) % len(m.sessions))
+}
+
+// close ends the active session. Closing the last session starts a new
+// shell, so the pane is never empty.
+func (m *terminalManager) close() error {
+	session := m.current()
+	if session == nil {
+		return nil
+	}
+	focused := ui.app.GetFocus() == ui.terminal
+	session.stop()
+	m.pages.RemovePage(session.name)
+	m.sessions = append(m.sessions[:m.active], m.sessions[m.active+1:]...)
+	if len(m.sessions) == 0 {
+		m.active = -1
+		if err := m.spawn(); err != nil {
+			return err
+		}
+	} else {
+		if m.active >= len(m.sessions) {
+			m.active = len(m.sessions) - 1
+		}
+		m.switchTo(m.active)
+	}
+	if focused {
+		ui.app.SetFocus(ui.terminal)
+	}
+	return nil
+}
+
+// shutdown ends every session as closing the terminal windows would, once
+// the UI has stopped, and returns when their shells have exited
+func (m *terminalManager) shutdown() {
+	var wg sync.WaitGroup
+	for _, session := range m.sessions {
+		wg.Add(1)
+		go func(session *terminalSession) {
+			defer recoverPanic()
+			defer wg.Done()
+			session.hangUp()
+		}(session)
+	}
+	wg.Wait()
+	m.sessions = nil
+	m.active = -1
+}
+
+// setColors changes the colors of every session
+func (m *terminalManager) setColors(background, text tcell.Color) {
+	m.background, m.text = background, text
+	for _, session := range m.sessions {
+		session.view.SetBackgroundColor(background)
+		session.view.SetTextColor(text)
+	}
+}
+
+// applyTheme gives the sessions the theme's colors, except for the colors
+// chosen with customizeTerminal
+func (m *terminalManager) applyTheme(t *Theme) {
+	background, text := m.background, m.text
+	if background == 0 {
+		background = t.Background
+	}
+	if text == 0 {
+		text = t.Text
+	}
+	for _, session := range m.sessions {
+		session.view.SetBackgroundColor(background)
+		session.view.SetTextColor(text)
+		session.view.SetIndicatorStyle(tcell.StyleDefault.Background(t.Accent).Foreground(t.AccentText))
+		session.view.SetMatchStyle(tcell.StyleDefault.Background(t.MatchBackground).Foreground(t.MatchText),
+			tcell.StyleDefault.Background(t.Accent).Foreground(t.AccentText))
+	}
+}
+
+// refresh redraws the tab bar
+func (m *terminalManager) refresh() {
+	var b strings.Builder
+	for i, session := range m.sessions {
+		colors := highlightTag(theme.Text, tcell.ColorDefault)
+		if i == m.active {
+			colors = highlightTag(theme.AccentText, theme.Accent)
+		}
+		marker := ""
+		if session.exited {
+			marker = " (exited)"
+		} else if session.logging() {
+			marker = " (logging)"
+		}
+		fmt.Fprintf(&b, `["%d"]%s %s%s [-:-:-][""] `, i, colors, tview.Escape(session.name), marker)
+	}
+	m.tabBar.SetText(b.String())
+}
+
+// startTerminal starts a shell in a new pseudo terminal, in dir unless it
+// is empty
+func startTerminal(number int, dir string) (*terminalSession, error) {
+	view := terminal.NewView().
+		SetIndicatorStyle(tcell.StyleDefault.Background(theme.Accent).Foreground(theme.AccentText)).
+		SetMatchStyle(tcell.StyleDefault.Background(theme.MatchBackground).Foreground(theme.MatchText),
+			tcell.StyleDefault.Background(theme.Accent).Foreground(theme.AccentText))
+	session := &terminalSession{name: fmt.Sprintf("%d: shell", number), number: number, view: view, cmd: config.Terminal.command()}
+	if dir != "" {
+		// Named after the directory, to tell it from shells started elsewhere
+		session.name = fmt.Sprintf("%d: %s", number, filepath.Base(dir))
+		session.cmd.Dir = dir
+	}
+
+	var err error
+	cols, rows := view.Size()
+	session.pty, err = startTerminalProcess(session.cmd, cols, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start terminal: %w", err)
+	}
+	view.SetResizedFunc(func(cols, rows int) {
+		if err := session.pty.Resize(cols, rows); err != nil && !session.exited {
+			applog.log(logError, "Error resizing pty", "terminal", session.name, "err", err)
+		}
+	})
+	view.SetReplyFunc(func(response []byte) {
+		_, _ = session.pty.Write(response)
+	})
+	view.SetMouseReportFunc(func(report []byte) {
+		if !session.exited {
+			_, _ = session.pty.Write(report)
+		}
+	})
+
+	session.ready = make(chan struct{}, 1)
+	session.drained = make(chan struct{}, 1)
+	session.closing = make(chan struct{})
+	session.done = make(chan struct{})
+	if config.Terminal.Log {
+		if _, err := session.startLog(); err != nil {
+			notify(noticeError, fmt.Sprintf("Error starting terminal log: %s", err))
+		}
+	}
+	go func() {
+		defer recoverPanic()
+		defer close(session.done)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := session.pty.Read(buf)
+			if n > 0 {
+				session.queueOutput(buf[:n])
+			}
+			if err != nil {
+				// Linux reports EIO once the shell has exited
+				if err != io.EOF && !errors.Is(err, syscall.EIO) && !errors.Is(err, os.ErrClosed) {
+					applog.log(logError, "Error reading from pty", "terminal", session.name, "err", err)
+				}
+				_ = session.pty.Wait()
+				session.mu.Lock()
+				session.ended = true
+				if _, err := session.closeLog(); err != nil {
+					applog.log(logError, "Error closing terminal log", "terminal", session.name, "err", err)
+				}
+				session.mu.Unlock()
+				poke(session.ready)
+				return
+			}
+		}
+	}()
+	go session.drawOutput()
+
+	view.SetCopyFunc(func(text string) {
+		if err := copyToClipboard(text); err != nil {
+			notify(noticeError, fmt.Sprintf("Error copying to clipboard: %s", err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Copied %d lines to the clipboard", strings.Count(text, "\n")+1))
+	})
+	view.SetPasteFunc(session.paste)
+	view.SetClickFunc(session.click)
+	view.SetLinkFunc(openURL)
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if view.CopyMode() {
+			view.HandleCopyKey(event)
+			return nil
+		}
+		view.ScrollToBottom()
+		session.handleInput(event)
+		return nil
+	})
+
+	return session, nil
+}
+
+// queueOutput adds output for the view. While too much is pending it
+// waits, which keeps a program flooding the terminal from getting ahead of
+// what is drawn.
+func (s *terminalSession) queueOutput(p []byte) {
+	s.mu.Lock()
+	s.writeLog(p)
+	s.pending = append(s.pending, p...)
+	full := len(s.pending) >= terminalPendingBytes
+	s.mu.Unlock()
+	poke(s.ready)
+	for full {
+		select {
+		case <-s.drained:
+		case <-s.closing:
+			return
+		}
+		s.mu.Lock()
+		full = len(s.pending) >= terminalPendingBytes
+		s.mu.Unlock()
+	}
+}
+
+// drawOutput writes the pending output to the view, at most
+// terminalFrameBytes once a frame, rather than redrawing for every read.
+// Output after a pause is drawn at once, so typing echoes without delay.
+func (s *terminalSession) drawOutput() {
+	defer recoverPanic()
+	var last time.Time
+	for {
+		select {
+		case <-s.ready:
+		case <-s.closing:
+			return
+		}
+		for more := true; more; {
+			if wait := terminalFrameInterval - time.Since(last); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-s.closing:
+					return
+				}
+			}
+			s.mu.Lock()
+			n := len(s.pending)
+			if n > terminalFrameBytes {
+				n = terminalFrameBytes
+			}
+			chunk := append([]byte(nil), s.pending[:n]...)
+			s.pending = append(s.pending[:0], s.pending[n:]...)
+			more = len(s.pending) > 0
+			ended := s.ended && !more
+			logLost := s.logLost
+			s.logLost = false
+			s.mu.Unlock()
+			poke(s.drained)
+
+			last = time.Now()
+			if len(chunk) > 0 {
+				ui.app.QueueUpdateDraw(func() {
+					s.view.Write(chunk)
+					if logLost {
+						terminals.refresh()
+					}
+				})
+			}
+			if ended {
+				ui.app.QueueUpdateDraw(s.finished)
+				return
+			}
+		}
+	}
+}
+
+// poke signals a channel with room for one signal, unless it is signaled
+// already
+func poke(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// finished marks the session as ended once its shell has exited
+func (s *terminalSession) finished() {
+	if s.exited {
+		return
+	}
+	s.exited = true
+	s.view.Write([]byte("\r\n[Process exited]\r\n"))
+	terminals.refresh()
+}
+
+// stop ends the shell of the session, leaving the reader goroutine to reap
+// it
+func (s *terminalSession) stop() {
+	s.exited = true
+	close(s.closing)
+	s.pty.Kill()
+	s.pty.Close()
+}
+
+// hangUp ends the shell as closing its window would, so it can exit by
+// itself; on Unix it is sent SIGHUP, which it passes on to its jobs. If the
+// shell or the program in the foreground is still running after
+// terminalExitTimeout, they are killed. hangUp returns once the reader
+// goroutine has reaped the shell and ended.
+func (s *terminalSession) hangUp() {
+	close(s.closing)
+	defer s.pty.Close()
+	s.pty.HangUp()
+	select {
+	case <-s.done:
+		return
+	case <-time.After(terminalExitTimeout):
+	}
+	applog.log(logWarn, "Killing programs that did not exit on hangup", "terminal", s.name)
+	s.pty.Kill()
+	select {
+	case <-s.done:
+	case <-time.After(terminalExitTimeout):
+		// A background job that left the shell's process group still
+		// has the terminal open
+		applog.log(logWarn, "Gave up waiting for the terminal to close", "terminal", s.name)
+	}
+}
+
+// paste sends text to the program as pasted input
+func (s *terminalSession) paste(text string) {
+	if s.exited || text == "" {
+		return
+	}
+	s.view.ExitCopyMode()
+	s.view.ScrollToBottom()
+	_, _ = s.pty.Write(s.view.EncodePaste(text))
+}
+
+// click opens the file location clicked in the terminal, with relative
+// paths taken from the current directory of the shell
+func (s *terminalSession) click(line string, index int) {
+	runes := []rune(line)
+	if index > len(runes) {
+		return
+	}
+	offset := len(string(runes[:index]))
+	links, spans := findFileLinks(line, s.workingDir())
+	for i, span := range spans {
+		if offset >= span[0] && offset < span[1] {
+			links[i].open()
+			return
+		}
+	}
+}
+
+// pickTerminalLink lists the links in the active terminal, the most recent
+// first, to open or copy one
+func pickTerminalLink() {
+	uris := ui.terminal.Links()
+	if len(uris) == 0 {
+		ui.output.message("No links in the terminal")
+		return
+	}
+	labels := make([]string, len(uris))
+	for i, uri := range uris {
+		labels[i] = tview.Escape(uri)
+	}
+	list := showPicker("Links  [Enter: open, y: copy, Esc: close]", labels, 0, func(index int) {
+		openURL(uris[index])
+	})
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() != tcell.KeyRune || event.Rune() != 'y' {
+			return event
+		}
+		if index := list.GetCurrentItem(); index >= 0 && index < len(uris) {
+			closeDialog()
+			copyLink(urlLink(uris[index]))
+		}
+		return nil
+	})
+}
+
+// handleInput sends a key press to the program in the terminal
+func (s *terminalSession) handleInput(event *tcell.EventKey) {
+	if s.exited {
+		return
+	}
+	if seq := s.view.EncodeKey(event); len(seq) > 0 {
+		_, _ = s.pty.Write(seq)
+	}
+}
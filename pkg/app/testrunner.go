@@ -0,0 +1,596 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gotui/pkg/editor"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// testEvent is one line of go test -json output
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// testResult is the state of one test, subtest, or package
+type testResult struct {
+	name    string
+	status  string // "", "run", "pass", "fail", or "skip"
+	elapsed float64
+	output  []string
+	node    *tview.TreeNode
+}
+
+// testPackage is a package and the results of its tests
+type testPackage struct {
+	testResult
+	dir   string
+	tests map[string]*testResult // keyed by full test name
+}
+
+// testRunner runs go test and shows the result of every test in the Tests
+// panel
+type testRunner struct {
+	panel  *tview.Flex
+	tree   *tview.TreeView
+	detail *tview.TextView
+	root   *tview.TreeNode
+	output *outputView // everything go test printed, in the Output pane
+
+	packages map[string]*testPackage // keyed by import path
+	log      []string                // output that belongs to no package
+	cmd      *exec.Cmd
+	job      *job
+	profile  string // the coverage profile the running tests write, if any
+	dir      string // the directory of the last run, where its packages are found
+	runs     int    // number of runs started, to drop output of replaced ones
+}
+
+var tests = testRunner{packages: map[string]*testPackage{}}
+
+// testFuncLine matches the declaration of a function go test can run
+var testFuncLine = regexp.MustCompile(`^func ((?:Test|Example|Fuzz)\w*)\(`)
+
+// testLocationLine matches a file location printed by t.Error and friends
+var testLocationLine = regexp.MustCompile(`^\s+([^\s:]+\.go):(\d+): `)
+
+// createTestsPanel creates and returns the Tests panel: the test tree and
+// the output of the selected test
+func createTestsPanel() *tview.Flex {
+	tests.root = tview.NewTreeNode("Tests").SetSelectable(true)
+	tests.tree = tview.NewTreeView().
+		SetRoot(tests.root).
+		SetCurrentNode(tests.root)
+	tests.detail = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true)
+	tests.detail.SetBorder(true).SetTitle("Test Output")
+
+	tests.tree.SetChangedFunc(func(node *tview.TreeNode) {
+		tests.showDetail(node)
+	})
+	tests.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		if result, ok := node.GetReference().(*testResult); ok {
+			tests.jumpTo(result)
+			return
+		}
+		node.SetExpanded(!node.IsExpanded())
+	})
+	tests.tree.SetDoneFunc(func(key tcell.Key) {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+	})
+
+	tests.panel = tview.NewFlex().
+		AddItem(tests.tree, 0, 1, true).
+		AddItem(tests.detail, 0, 1, false)
+	tests.panel.SetBorder(true).SetTitle("Tests")
+	return tests.panel
+}
+
+// toggleTests shows the Tests panel, or hides it if it is showing
+func toggleTests() {
+	if name, _ := ui.panels.GetFrontPage(); name == "tests" {
+		showPanel("output")
+		ui.app.SetFocus(ui.editor)
+		return
+	}
+	showPanel("tests")
+	ui.app.SetFocus(tests.tree)
+}
+
+// runAll runs every test of the workspace root of the file in the editor
+func (r *testRunner) runAll() {
+	r.start(activeRoot(), []string{"./..."}, "", false)
+}
+
+// runCoverage runs every test of the workspace root of the file in the
+// editor and shows which statements they cover
+func (r *testRunner) runCoverage() {
+	r.start(activeRoot(), []string{"./..."}, "", true)
+}
+
+// runAtCursor runs the test function the editor cursor is in
+func (r *testRunner) runAtCursor() {
+	buf := buffers.current()
+	if buf == nil || !strings.HasSuffix(buf.Path(), "_test.go") {
+		ui.output.message("The cursor is not in a test file")
+		return
+	}
+	name := ""
+	for line := ui.editor.Cursor().Line; line >= 0 && name == ""; line-- {
+		if match := testFuncLine.FindStringSubmatch(ui.editor.Line(line)); match != nil {
+			name = match[1]
+		}
+	}
+	if name == "" {
+		ui.output.message("The cursor is not in a test function")
+		return
+	}
+	r.start(rootOf(buf.Path()), []string{packagePattern(filepath.Dir(buf.Path()))}, "^"+name+"$", false)
+}
+
+// runFailed runs the tests that failed in the last run again
+func (r *testRunner) runFailed() {
+	var patterns, names []string
+	seen := map[string]bool{}
+	for _, path := range r.sortedPackages() {
+		pkg := r.packages[path]
+		failed := false
+		for name, result := range pkg.tests {
+			if result.status != "fail" || strings.Contains(name, "/") {
+				continue
+			}
+			failed = true
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, regexp.QuoteMeta(name))
+			}
+		}
+		if failed {
+			patterns = append(patterns, pkg.name)
+		}
+	}
+	if len(patterns) == 0 {
+		ui.output.message("No failed tests to run")
+		return
+	}
+	sort.Strings(names)
+	r.start(r.dir, patterns, "^("+strings.Join(names, "|")+")$", false)
+}
+
+// packagePattern returns the go tool pattern of the package in dir
+func packagePattern(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return "./" + filepath.ToSlash(filepath.Clean(dir))
+}
+
+// start runs go test -json in dir for the packages, limited to the tests matching
+// run if it is set, and writing a coverage profile if cover is set. The
+// packages are listed first, so every package shows up in the tree even
+// before its tests are run.
+func (r *testRunner) start(dir string, packages []string, run string, cover bool) {
+	r.stop()
+	r.runs++
+	r.dir = dir
+	id := r.runs
+	r.packages = map[string]*testPackage{}
+	r.log = nil
+	r.output.Clear()
+	r.root.ClearChildren().SetText("Tests: running").SetColor(theme.Muted)
+	r.tree.SetCurrentNode(r.root)
+	r.showDetail(r.root)
+	showPanel("tests")
+
+	args := append([]string{"test", "-json"}, packages...)
+	if run != "" {
+		args = append(args, "-run", run)
+	}
+	if cover {
+		profile, err := os.CreateTemp("", "goui-*.cover")
+		if err != nil {
+			r.appendLog(fmt.Sprintf("Failed to create the coverage profile: %s", err))
+			r.finish()
+			return
+		}
+		profile.Close()
+		r.profile = profile.Name()
+		args = append(args, "-coverprofile="+r.profile)
+	}
+	r.appendLog("$ go " + strings.Join(args, " "))
+
+	listArgs := append([]string{"list", "-f", "{{.ImportPath}}\t{{.Dir}}"}, packages...)
+	list := exec.Command("go", listArgs...)
+	list.Dir = dir
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	r.cmd = cmd
+	r.job = jobs.add("go test", cmd, func() { r.start(dir, packages, run, cover) })
+
+	update := func(fn func()) {
+		ui.app.QueueUpdateDraw(func() {
+			if r.runs == id {
+				fn()
+			}
+		})
+	}
+	go func() {
+		defer recoverPanic()
+		if out, err := list.Output(); err == nil {
+			update(func() { r.addPackages(out) })
+		}
+		if err := cmd.Start(); err != nil {
+			update(func() {
+				r.appendLog(fmt.Sprintf("Failed to start go test: %s", err))
+				r.finish()
+			})
+			return
+		}
+		scanned := make(chan struct{})
+		go func() {
+			defer recoverPanic()
+			defer close(scanned)
+			scanner := bufio.NewScanner(reader)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				line := scanner.Text()
+				update(func() { r.handleLine(line) })
+			}
+			_, _ = io.Copy(io.Discard, reader)
+		}()
+		_ = cmd.Wait()
+		writer.Close()
+		<-scanned
+		update(r.finish)
+	}()
+}
+
+// stop kills the running tests, if any
+func (r *testRunner) stop() {
+	if r.cmd != nil {
+		killProcessGroup(r.cmd)
+		r.cmd = nil
+	}
+	jobs.remove(r.job)
+	r.job = nil
+	if r.profile != "" {
+		os.Remove(r.profile)
+		r.profile = ""
+	}
+}
+
+// addPackages adds the packages printed by go list to the tree
+func (r *testRunner) addPackages(out []byte) {
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		path, dir, ok := strings.Cut(line, "\t")
+		if ok {
+			r.pkg(path).dir = dir
+		}
+	}
+}
+
+// pkg returns the package with the import path, adding it to the tree if
+// it is new
+func (r *testRunner) pkg(path string) *testPackage {
+	if pkg, ok := r.packages[path]; ok {
+		return pkg
+	}
+	pkg := &testPackage{testResult: testResult{name: path}, tests: map[string]*testResult{}}
+	pkg.node = tview.NewTreeNode("").SetReference(pkg).SetExpanded(false)
+	r.packages[path] = pkg
+	r.root.SetChildren(nil)
+	for _, path := range r.sortedPackages() {
+		r.root.AddChild(r.packages[path].node)
+	}
+	r.render(&pkg.testResult)
+	return pkg
+}
+
+// sortedPackages returns the import paths of the packages in order
+func (r *testRunner) sortedPackages() []string {
+	paths := make([]string, 0, len(r.packages))
+	for path := range r.packages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// test returns the result of a test, adding it below its parent test or
+// package if it is new
+func (r *testRunner) test(pkg *testPackage, name string) *testResult {
+	if result, ok := pkg.tests[name]; ok {
+		return result
+	}
+	result := &testResult{name: name}
+	result.node = tview.NewTreeNode("").SetReference(result).SetExpanded(false)
+	parent := pkg.node
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		parent = r.test(pkg, name[:i]).node
+	}
+	parent.AddChild(result.node)
+	pkg.tests[name] = result
+	r.render(result)
+	return result
+}
+
+// handleLine applies one line of go test output. Lines that are not JSON,
+// such as build errors, go to the general log.
+func (r *testRunner) handleLine(line string) {
+	var event testEvent
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+		r.appendLog(line)
+		return
+	}
+	if event.Package == "" {
+		// Newer go versions report build output with its own actions
+		if event.Output != "" {
+			r.appendLog(strings.TrimRight(event.Output, "\n"))
+		}
+		return
+	}
+	pkg := r.pkg(event.Package)
+	result := &pkg.testResult
+	if event.Test != "" {
+		result = r.test(pkg, event.Test)
+	}
+	switch event.Action {
+	case "run":
+		result.status = "run"
+	case "pass", "fail", "skip":
+		result.status = event.Action
+		result.elapsed = event.Elapsed
+		if result.node != nil && event.Action == "fail" {
+			result.node.Expand()
+			pkg.node.Expand()
+		}
+	case "output":
+		result.output = append(result.output, strings.TrimRight(event.Output, "\n"))
+		r.write(strings.TrimRight(event.Output, "\n"))
+	}
+	r.render(result)
+	if r.tree.GetCurrentNode() == result.node {
+		r.showDetail(result.node)
+	}
+}
+
+// appendLog adds a line to the output that belongs to no package
+func (r *testRunner) appendLog(line string) {
+	r.log = append(r.log, line)
+	r.write(line)
+	if r.tree.GetCurrentNode() == r.root {
+		r.showDetail(r.root)
+	}
+}
+
+// render updates the label of a test or package node from its state
+func (r *testRunner) render(result *testResult) {
+	name := result.name
+	if i := strings.LastIndex(name, "/"); i >= 0 && result.node != nil && isSubtest(result) {
+		name = name[i+1:]
+	}
+	var mark string
+	var color tcell.Color
+	switch result.status {
+	case "pass":
+		mark, color = "✓", theme.Success
+	case "fail":
+		mark, color = "✗", theme.Error
+	case "skip":
+		mark, color = "–", theme.Warning
+	case "run":
+		mark, color = "…", theme.Text
+	default:
+		mark, color = " ", theme.Muted
+	}
+	text := mark + " " + name
+	if result.status == "pass" || result.status == "fail" {
+		text += fmt.Sprintf(" (%.2fs)", result.elapsed)
+	}
+	result.node.SetText(text).SetColor(color)
+}
+
+// isSubtest reports whether a result belongs to a test rather than a package
+func isSubtest(result *testResult) bool {
+	_, ok := result.node.GetReference().(*testResult)
+	return ok
+}
+
+// finish updates the summary once go test has exited
+func (r *testRunner) finish() {
+	r.cmd = nil
+	counts := map[string]int{}
+	for _, pkg := range r.packages {
+		for _, result := range pkg.tests {
+			counts[result.status]++
+		}
+	}
+	summary := fmt.Sprintf("Tests: %d passed, %d failed, %d skipped", counts["pass"], counts["fail"], counts["skip"])
+	if r.job != nil && r.job.canceled {
+		summary += " (canceled)"
+	}
+	jobs.remove(r.job)
+	r.job = nil
+	r.root.SetText(summary).SetColor(r.summaryColor())
+	r.appendLog(summary)
+	if r.profile != "" {
+		if err := coverage.load(r.profile, r.packages); err != nil {
+			r.appendLog(fmt.Sprintf("Failed to read the coverage profile: %s", err))
+		} else {
+			r.appendLog(coverage.root.GetText())
+		}
+		os.Remove(r.profile)
+		r.profile = ""
+	}
+}
+
+// summaryColor returns the color of the summary: red once a test or a build
+// has failed
+func (r *testRunner) summaryColor() tcell.Color {
+	if r.cmd != nil {
+		return theme.Muted
+	}
+	for _, pkg := range r.packages {
+		if pkg.status == "fail" {
+			return theme.Error
+		}
+		for _, result := range pkg.tests {
+			if result.status == "fail" {
+				return theme.Error
+			}
+		}
+	}
+	if r.buildFailed() {
+		return theme.Error
+	}
+	return theme.Success
+}
+
+// recolor renders every node again, after the theme changed
+func (r *testRunner) recolor() {
+	if r.root == nil || len(r.packages) == 0 {
+		return
+	}
+	for _, pkg := range r.packages {
+		r.render(&pkg.testResult)
+		for _, result := range pkg.tests {
+			r.render(result)
+		}
+	}
+	r.root.SetColor(r.summaryColor())
+}
+
+// buildFailed reports whether a package failed without a failing test, as
+// when it does not compile
+func (r *testRunner) buildFailed() bool {
+	for _, pkg := range r.packages {
+		if pkg.status == "fail" {
+			return true
+		}
+	}
+	return false
+}
+
+// showDetail shows the output of the test or package of a node
+func (r *testRunner) showDetail(node *tview.TreeNode) {
+	lines := r.log
+	title := "Test Output"
+	switch result := node.GetReference().(type) {
+	case *testResult:
+		lines, title = result.output, result.name
+	case *testPackage:
+		lines, title = result.output, result.name
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(colorTestLine(line))
+		b.WriteByte('\n')
+	}
+	r.detail.SetText(b.String()).ScrollToEnd().SetTitle(title)
+}
+
+// write adds a line to the Tests channel of the Output pane
+func (r *testRunner) write(line string) {
+	fmt.Fprintf(r.output, "%s\n", colorTestLine(line))
+}
+
+// colorTestLine escapes a line of go test output, coloring the lines that
+// report a result
+func colorTestLine(line string) string {
+	color := ""
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(line), "--- FAIL"), strings.HasPrefix(line, "FAIL"):
+		color = colorTag(theme.Error)
+	case strings.HasPrefix(strings.TrimSpace(line), "--- PASS"), strings.HasPrefix(line, "ok"):
+		color = colorTag(theme.Success)
+	case strings.HasPrefix(strings.TrimSpace(line), "--- SKIP"):
+		color = colorTag(theme.Warning)
+	}
+	if color == "" {
+		return tview.Escape(line)
+	}
+	return color + tview.Escape(line) + "[-]"
+}
+
+// jumpTo opens the location where a test failed, or its declaration
+func (r *testRunner) jumpTo(result *testResult) {
+	var pkg *testPackage
+	for _, p := range r.packages {
+		if p.tests[result.name] == result {
+			pkg = p
+		}
+	}
+	if pkg == nil || pkg.dir == "" {
+		return
+	}
+	dir := workspacePath(pkg.dir)
+	for _, line := range result.output {
+		if match := testLocationLine.FindStringSubmatch(line); match != nil {
+			n, _ := strconv.Atoi(match[2])
+			r.open(filepath.Join(dir, match[1]), n-1)
+			return
+		}
+	}
+	top := result.name
+	if i := strings.Index(top, "/"); i >= 0 {
+		top = top[:i]
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for n, line := range bytes.Split(content, []byte("\n")) {
+			if match := testFuncLine.FindSubmatch(line); match != nil && string(match[1]) == top {
+				r.open(file, n)
+				return
+			}
+		}
+	}
+}
+
+// open shows a line of a file in the editor
+func (r *testRunner) open(path string, line int) {
+	pos := editor.Position{Line: line}
+	jumps.push()
+	if err := openFileAt(path, pos, pos); err != nil {
+		notify(noticeError, fmt.Sprintf("Error loading file: %s", err))
+	}
+}
+
+// workspacePath returns an absolute path relative to the workspace when it
+// is inside it, matching the paths of open buffers
+func workspacePath(path string) string {
+	root, err := filepath.Abs(workspaceRoot)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.Join(workspaceRoot, rel)
+}
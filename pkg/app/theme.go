@@ -0,0 +1,462 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gotui/pkg/editor"
+	"gotui/pkg/explorer"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Theme is a color scheme for the whole UI
+type Theme struct {
+	Name string
+
+	Background tcell.Color
+	Text       tcell.Color
+	Muted      tcell.Color // line numbers, hints, and other secondary text
+	Border     tcell.Color
+	Title      tcell.Color
+	Accent     tcell.Color // key names, the active tab, and the selected item
+	AccentText tcell.Color // text on the accent color
+	Secondary  tcell.Color // diff hunk headers and the log graph
+	Directory  tcell.Color
+
+	Success tcell.Color
+	Warning tcell.Color
+	Error   tcell.Color
+	Info    tcell.Color
+	Special tcell.Color // merge conflicts
+
+	// Input fields and buttons
+	Contrast     tcell.Color
+	ContrastText tcell.Color
+
+	MatchBackground tcell.Color
+	MatchText       tcell.Color
+
+	PopupBackground tcell.Color
+	PopupText       tcell.Color
+	PopupDetail     tcell.Color
+
+	DiffAdded   tcell.Color // background of added lines
+	DiffRemoved tcell.Color // background of removed lines
+	DiffFill    tcell.Color // background of the missing side of a change
+
+	ExecutionLine tcell.Color // background of the line the debugger is paused at
+
+	Tokens map[editor.TokenKind]tcell.Color
+}
+
+// themes are the built-in color schemes by name
+var themes = map[string]*Theme{
+	"dark": {
+		Name:       "dark",
+		Background: tcell.ColorBlack,
+		Text:       tcell.ColorWhite,
+		Muted:      tcell.ColorGray,
+		Border:     tcell.ColorWhite,
+		Title:      tcell.ColorWhite,
+		Accent:     tcell.ColorYellow,
+		AccentText: tcell.ColorBlack,
+		Secondary:  tcell.ColorDarkCyan,
+		Directory:  tcell.ColorGreen,
+
+		Success: tcell.ColorGreen,
+		Warning: tcell.ColorYellow,
+		Error:   tcell.ColorRed,
+		Info:    tcell.ColorLightSkyBlue,
+		Special: tcell.ColorFuchsia,
+
+		Contrast:     tcell.ColorBlue,
+		ContrastText: tcell.ColorNavy,
+
+		MatchBackground: tcell.ColorOlive,
+		MatchText:       tcell.ColorBlack,
+
+		PopupBackground: tcell.ColorDarkSlateGray,
+		PopupText:       tcell.ColorWhite,
+		PopupDetail:     tcell.ColorSilver,
+
+		DiffAdded:   tcell.NewHexColor(0x1f3d1f),
+		DiffRemoved: tcell.NewHexColor(0x4b1f1f),
+		DiffFill:    tcell.NewHexColor(0x262626),
+
+		ExecutionLine: tcell.NewHexColor(0x3d3d00),
+
+		Tokens: map[editor.TokenKind]tcell.Color{
+			editor.TokenKeyword:  tcell.ColorYellow,
+			editor.TokenType:     tcell.ColorDarkCyan,
+			editor.TokenFunction: tcell.ColorLightSkyBlue,
+			editor.TokenString:   tcell.ColorGreen,
+			editor.TokenNumber:   tcell.ColorFuchsia,
+			editor.TokenComment:  tcell.ColorGray,
+			editor.TokenOperator: tcell.ColorSilver,
+			editor.TokenVariable: tcell.ColorOrange,
+			editor.TokenHeading:  tcell.ColorYellow,
+			editor.TokenEmphasis: tcell.ColorWhite,
+			editor.TokenLink:     tcell.ColorLightSkyBlue,
+		},
+	},
+	"light": {
+		Name:       "light",
+		Background: tcell.NewHexColor(0xffffff),
+		Text:       tcell.NewHexColor(0x1f1f1f),
+		Muted:      tcell.NewHexColor(0x8c8c8c),
+		Border:     tcell.NewHexColor(0x9e9e9e),
+		Title:      tcell.NewHexColor(0x1f1f1f),
+		Accent:     tcell.NewHexColor(0xa05a00),
+		AccentText: tcell.NewHexColor(0xffffff),
+		Secondary:  tcell.NewHexColor(0x00838f),
+		Directory:  tcell.NewHexColor(0x2e7d32),
+
+		Success: tcell.NewHexColor(0x2e7d32),
+		Warning: tcell.NewHexColor(0xb26a00),
+		Error:   tcell.NewHexColor(0xc62828),
+		Info:    tcell.NewHexColor(0x1565c0),
+		Special: tcell.NewHexColor(0x8e24aa),
+
+		Contrast:     tcell.NewHexColor(0xdde6f0),
+		ContrastText: tcell.NewHexColor(0x5a6b7d),
+
+		MatchBackground: tcell.NewHexColor(0xfff176),
+		MatchText:       tcell.NewHexColor(0x1f1f1f),
+
+		PopupBackground: tcell.NewHexColor(0xeeeeee),
+		PopupText:       tcell.NewHexColor(0x1f1f1f),
+		PopupDetail:     tcell.NewHexColor(0x5f5f5f),
+
+		DiffAdded:   tcell.NewHexColor(0xdcf5dc),
+		DiffRemoved: tcell.NewHexColor(0xf8dcdc),
+		DiffFill:    tcell.NewHexColor(0xf0f0f0),
+
+		ExecutionLine: tcell.NewHexColor(0xfff3c4),
+
+		Tokens: map[editor.TokenKind]tcell.Color{
+			editor.TokenKeyword:  tcell.NewHexColor(0x0033b3),
+			editor.TokenType:     tcell.NewHexColor(0x00838f),
+			editor.TokenFunction: tcell.NewHexColor(0x795e26),
+			editor.TokenString:   tcell.NewHexColor(0x2e7d32),
+			editor.TokenNumber:   tcell.NewHexColor(0x8e24aa),
+			editor.TokenComment:  tcell.NewHexColor(0x8c8c8c),
+			editor.TokenOperator: tcell.NewHexColor(0x444444),
+			editor.TokenVariable: tcell.NewHexColor(0xa05a00),
+			editor.TokenHeading:  tcell.NewHexColor(0x0033b3),
+			editor.TokenEmphasis: tcell.NewHexColor(0x000000),
+			editor.TokenLink:     tcell.NewHexColor(0x1565c0),
+		},
+	},
+	"solarized": {
+		Name:       "solarized",
+		Background: tcell.NewHexColor(0x002b36),
+		Text:       tcell.NewHexColor(0x839496),
+		Muted:      tcell.NewHexColor(0x586e75),
+		Border:     tcell.NewHexColor(0x586e75),
+		Title:      tcell.NewHexColor(0x93a1a1),
+		Accent:     tcell.NewHexColor(0xb58900),
+		AccentText: tcell.NewHexColor(0x002b36),
+		Secondary:  tcell.NewHexColor(0x2aa198),
+		Directory:  tcell.NewHexColor(0x268bd2),
+
+		Success: tcell.NewHexColor(0x859900),
+		Warning: tcell.NewHexColor(0xb58900),
+		Error:   tcell.NewHexColor(0xdc322f),
+		Info:    tcell.NewHexColor(0x268bd2),
+		Special: tcell.NewHexColor(0xd33682),
+
+		Contrast:     tcell.NewHexColor(0x073642),
+		ContrastText: tcell.NewHexColor(0x586e75),
+
+		MatchBackground: tcell.NewHexColor(0xb58900),
+		MatchText:       tcell.NewHexColor(0x002b36),
+
+		PopupBackground: tcell.NewHexColor(0x073642),
+		PopupText:       tcell.NewHexColor(0x93a1a1),
+		PopupDetail:     tcell.NewHexColor(0x657b83),
+
+		DiffAdded:   tcell.NewHexColor(0x173d2a),
+		DiffRemoved: tcell.NewHexColor(0x47202a),
+		DiffFill:    tcell.NewHexColor(0x073642),
+
+		ExecutionLine: tcell.NewHexColor(0x2b3a14),
+
+		Tokens: map[editor.TokenKind]tcell.Color{
+			editor.TokenKeyword:  tcell.NewHexColor(0x859900),
+			editor.TokenType:     tcell.NewHexColor(0xb58900),
+			editor.TokenFunction: tcell.NewHexColor(0x268bd2),
+			editor.TokenString:   tcell.NewHexColor(0x2aa198),
+			editor.TokenNumber:   tcell.NewHexColor(0xd33682),
+			editor.TokenComment:  tcell.NewHexColor(0x586e75),
+			editor.TokenOperator: tcell.NewHexColor(0x839496),
+			editor.TokenVariable: tcell.NewHexColor(0xcb4b16),
+			editor.TokenHeading:  tcell.NewHexColor(0x268bd2),
+			editor.TokenEmphasis: tcell.NewHexColor(0x93a1a1),
+			editor.TokenLink:     tcell.NewHexColor(0x6c71c4),
+		},
+	},
+	"gruvbox": {
+		Name:       "gruvbox",
+		Background: tcell.NewHexColor(0x282828),
+		Text:       tcell.NewHexColor(0xebdbb2),
+		Muted:      tcell.NewHexColor(0x928374),
+		Border:     tcell.NewHexColor(0x7c6f64),
+		Title:      tcell.NewHexColor(0xebdbb2),
+		Accent:     tcell.NewHexColor(0xfabd2f),
+		AccentText: tcell.NewHexColor(0x282828),
+		Secondary:  tcell.NewHexColor(0x8ec07c),
+		Directory:  tcell.NewHexColor(0xb8bb26),
+
+		Success: tcell.NewHexColor(0xb8bb26),
+		Warning: tcell.NewHexColor(0xfabd2f),
+		Error:   tcell.NewHexColor(0xfb4934),
+		Info:    tcell.NewHexColor(0x83a598),
+		Special: tcell.NewHexColor(0xd3869b),
+
+		Contrast:     tcell.NewHexColor(0x3c3836),
+		ContrastText: tcell.NewHexColor(0xa89984),
+
+		MatchBackground: tcell.NewHexColor(0xd79921),
+		MatchText:       tcell.NewHexColor(0x282828),
+
+		PopupBackground: tcell.NewHexColor(0x3c3836),
+		PopupText:       tcell.NewHexColor(0xebdbb2),
+		PopupDetail:     tcell.NewHexColor(0xa89984),
+
+		DiffAdded:   tcell.NewHexColor(0x34381b),
+		DiffRemoved: tcell.NewHexColor(0x402120),
+		DiffFill:    tcell.NewHexColor(0x32302f),
+
+		ExecutionLine: tcell.NewHexColor(0x48401c),
+
+		Tokens: map[editor.TokenKind]tcell.Color{
+			editor.TokenKeyword:  tcell.NewHexColor(0xfb4934),
+			editor.TokenType:     tcell.NewHexColor(0xfabd2f),
+			editor.TokenFunction: tcell.NewHexColor(0x8ec07c),
+			editor.TokenString:   tcell.NewHexColor(0xb8bb26),
+			editor.TokenNumber:   tcell.NewHexColor(0xd3869b),
+			editor.TokenComment:  tcell.NewHexColor(0x928374),
+			editor.TokenOperator: tcell.NewHexColor(0xd5c4a1),
+			editor.TokenVariable: tcell.NewHexColor(0x83a598),
+			editor.TokenHeading:  tcell.NewHexColor(0xfabd2f),
+			editor.TokenEmphasis: tcell.NewHexColor(0xfbf1c7),
+			editor.TokenLink:     tcell.NewHexColor(0x83a598),
+		},
+	},
+}
+
+// theme is the color scheme in use
+var theme = themes["dark"]
+
+// themeNames returns the names of the built-in themes, sorted
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// severityColor returns the color of a diagnostic severity's markers
+func (t *Theme) severityColor(severity int) tcell.Color {
+	switch severity {
+	case SeverityError:
+		return t.Error
+	case SeverityWarning:
+		return t.Warning
+	case SeverityInfo:
+		return t.Info
+	}
+	return t.Muted
+}
+
+// statusColor returns the color a git status letter is shown in
+func (t *Theme) statusColor(status byte) tcell.Color {
+	switch status {
+	case 'M', 'T':
+		return t.Warning
+	case 'A', '?':
+		return t.Success
+	case 'R', 'C':
+		return t.Info
+	case 'D':
+		return t.Error
+	case 'U':
+		return t.Special
+	}
+	return t.Text
+}
+
+// colorNames maps colors back to the names tview's color tags know them by
+var colorNames = func() map[tcell.Color]string {
+	names := make(map[tcell.Color]string, len(tcell.ColorNames))
+	for name, color := range tcell.ColorNames {
+		// Several names share a color; pick the same one every time
+		if other, ok := names[color]; !ok || name < other {
+			names[color] = name
+		}
+	}
+	return names
+}()
+
+// colorName returns the name of a color in a tview color tag
+func colorName(color tcell.Color) string {
+	if color == tcell.ColorDefault {
+		return "-"
+	}
+	if name, ok := colorNames[color]; ok {
+		return name
+	}
+	return fmt.Sprintf("#%06x", color.Hex())
+}
+
+// colorTag returns the tview tag that switches the text color
+func colorTag(color tcell.Color) string {
+	return "[" + colorName(color) + "]"
+}
+
+// highlightTag returns the tview tag that switches the text and background
+// colors
+func highlightTag(text, background tcell.Color) string {
+	return "[" + colorName(text) + ":" + colorName(background) + "]"
+}
+
+// themed is implemented by the custom widgets, which keep their styles in
+// fields rather than in their Box
+type themed interface {
+	applyTheme(t *Theme)
+}
+
+// pageItems are the pages added to each tview.Pages we walk when the theme
+// changes, which tview does not give access to
+var pageItems = map[*tview.Pages][]tview.Primitive{}
+
+// setTheme switches to a built-in theme and restyles the whole UI
+func setTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(themeNames(), ", "))
+	}
+	theme = t
+	applyThemeStyles(t)
+	if ui.root == nil {
+		// The widgets pick the styles up when they are created
+		return nil
+	}
+	restyle(ui.root)
+	// Text with color tags and marks are rendered again in the new colors
+	ui.menuBar.SetText(menuText())
+	buffers.refresh()
+	terminals.refresh()
+	terminals.applyTheme(t)
+	outputs.refresh()
+	problems.decorate()
+	git.render()
+	git.decorate()
+	tests.recolor()
+	return nil
+}
+
+// applyThemeStyles sets the colors tview gives new primitives
+func applyThemeStyles(t *Theme) {
+	tview.Styles = tview.Theme{
+		PrimitiveBackgroundColor:    t.Background,
+		ContrastBackgroundColor:     t.Contrast,
+		MoreContrastBackgroundColor: t.Success,
+		BorderColor:                 t.Border,
+		TitleColor:                  t.Title,
+		GraphicsColor:               t.Border,
+		PrimaryTextColor:            t.Text,
+		SecondaryTextColor:          t.Accent,
+		TertiaryTextColor:           t.Success,
+		InverseTextColor:            t.Contrast,
+		ContrastSecondaryTextColor:  t.ContrastText,
+	}
+}
+
+// editorColors returns the colors of a theme an editor is drawn in
+func editorColors(t *Theme) editor.Colors {
+	return editor.Colors{
+		Background:      t.Background,
+		Text:            t.Text,
+		Muted:           t.Muted,
+		Accent:          t.Accent,
+		AccentText:      t.AccentText,
+		Success:         t.Success,
+		Contrast:        t.Contrast,
+		PopupBackground: t.PopupBackground,
+		PopupText:       t.PopupText,
+		PopupDetail:     t.PopupDetail,
+		Tokens:          t.Tokens,
+	}
+}
+
+// restyle applies the theme's colors to a primitive and everything it
+// contains
+func restyle(p tview.Primitive) {
+	if box, ok := p.(interface {
+		SetBackgroundColor(tcell.Color) *tview.Box
+		SetBorderColor(tcell.Color) *tview.Box
+		SetTitleColor(tcell.Color) *tview.Box
+	}); ok {
+		box.SetBackgroundColor(theme.Background)
+		box.SetBorderColor(theme.Border)
+		box.SetTitleColor(theme.Title)
+	}
+	switch p := p.(type) {
+	case *tview.Flex:
+		for i := 0; i < p.GetItemCount(); i++ {
+			restyle(p.GetItem(i))
+		}
+	case *tview.Pages:
+		for _, item := range pageItems[p] {
+			restyle(item)
+		}
+	case *tview.TextView:
+		p.SetTextColor(theme.Text)
+	case *tview.TreeView:
+		p.SetGraphicsColor(theme.Border)
+	case *explorer.Explorer:
+		p.SetGraphicsColor(theme.Border)
+		p.SetColors(theme.Directory, theme.Text, theme.Success)
+	case *tview.List:
+		p.SetMainTextColor(theme.Text).
+			SetSecondaryTextColor(theme.Success).
+			SetShortcutColor(theme.Accent).
+			SetSelectedTextColor(theme.Background).
+			SetSelectedBackgroundColor(theme.Text)
+	case *tview.InputField:
+		p.SetLabelColor(theme.Accent).
+			SetFieldBackgroundColor(theme.Contrast).
+			SetFieldTextColor(theme.Text).
+			SetPlaceholderTextColor(theme.ContrastText)
+	case *editor.Editor:
+		p.SetColors(editorColors(theme))
+	case themed:
+		p.applyTheme(theme)
+	}
+}
+
+// showThemes opens the list of built-in themes; choosing one switches to it
+// for this session
+func showThemes() {
+	names := themeNames()
+	current := 0
+	for i, name := range names {
+		if name == theme.Name {
+			current = i
+		}
+	}
+	showPicker("Themes", names, current, func(index int) {
+		name := names[index]
+		if err := setTheme(name); err != nil {
+			notify(noticeError, fmt.Sprintf("Error switching theme: %s", err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Switched to the %s theme (set theme = %q in config.toml to keep it)", name, name))
+	})
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long changes are collected before they are applied, so
+// a burst such as a git checkout refreshes the tree once
+const watchDelay = 150 * time.Millisecond
+
+// fileWatcher watches the directories shown in the file explorer and those
+// of open files for changes made outside the editor
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	pending map[string]bool // changed paths
+	timer   *time.Timer
+}
+
+var watcher fileWatcher
+
+// start creates the watcher. Without it the editor works as before, only
+// without noticing outside changes.
+func (w *fileWatcher) start() error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w.watcher = fw
+	w.pending = map[string]bool{}
+	go w.run()
+	return nil
+}
+
+// close stops watching
+func (w *fileWatcher) close() {
+	if w.watcher != nil {
+		w.watcher.Close()
+	}
+}
+
+// watch adds a directory to the watched ones
+func (w *fileWatcher) watch(dir string) {
+	if w.watcher == nil {
+		return
+	}
+	if err := w.watcher.Add(dir); err != nil {
+		applog.log(logError, "Error watching directory", "dir", dir, "err", err)
+	}
+}
+
+// run receives the watcher's events until it is closed
+func (w *fileWatcher) run() {
+	defer recoverPanic()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			w.changed(event.Name)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			applog.log(logError, "Error watching files", "err", err)
+		}
+	}
+}
+
+// changed records a changed path and schedules a flush
+func (w *fileWatcher) changed(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[filepath.Clean(path)] = true
+	if w.timer == nil {
+		w.timer = time.AfterFunc(watchDelay, func() {
+			ui.app.QueueUpdateDraw(w.flush)
+		})
+	}
+}
+
+// flush applies the collected changes: the directories containing changed
+// paths are read again, and open files that changed are checked
+func (w *fileWatcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending, w.timer = map[string]bool{}, nil
+	w.mu.Unlock()
+
+	dirs := map[string]bool{}
+	for path := range pending {
+		dirs[filepath.Dir(path)] = true
+		if filepath.Base(path) == ".gitignore" {
+			if err := reloadIgnoreRules(); err != nil {
+				notify(noticeError, fmt.Sprintf("Error reading directory: %s", err))
+			}
+		}
+	}
+	if pending[filepath.Join(workspaceRoot, "go.mod")] {
+		modules.goModChanged()
+	}
+	for dir := range dirs {
+		if err := ui.fileExplorer.RefreshDir(dir); err != nil {
+			notify(noticeError, fmt.Sprintf("Error reading directory: %s", err))
+		}
+	}
+	for _, buf := range buffers.buffers {
+		if pending[buf.Path()] {
+			checkDiskChange(buf)
+		}
+	}
+	git.refresh()
+	watchMode.changed(pending)
+}
+
+// fileModTime returns the modification time of a file, or the zero time if
+// it does not exist
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// checkDiskChange handles a buffer whose file changed outside the editor.
+// Buffers without unsaved changes are reloaded; otherwise the user is asked
+// whether to drop their changes.
+func checkDiskChange(buf *Buffer) {
+	modTime := fileModTime(buf.Path())
+	if modTime.Equal(buf.modTime) || modTime.Equal(buf.declined) {
+		return
+	}
+	if modTime.IsZero() {
+		ui.output.message(fmt.Sprintf("%s was deleted on disk; saving recreates it", buf.Path()))
+		return
+	}
+	if !buf.changedOnDisk() {
+		return
+	}
+	if !buf.dirty {
+		if err := buffers.reload(buf); err != nil {
+			notify(noticeError, fmt.Sprintf("Error reloading file: %s", err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Reloaded %s, which changed on disk", buf.Path()))
+		return
+	}
+	buf.declined = modTime
+	question := fmt.Sprintf("%s changed on disk. Reload it and discard your changes?", buf.Path())
+	confirmAction(question, "Reload", func() {
+		if err := buffers.reload(buf); err != nil {
+			notify(noticeError, fmt.Sprintf("Error reloading file: %s", err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Reloaded %s", buf.Path()))
+	})
+}
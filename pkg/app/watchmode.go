@@ -0,0 +1,162 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchRerunDelay is how long watch mode waits for files to stop changing
+// before it runs the command again, so saving several files runs it once
+const watchRerunDelay = 300 * time.Millisecond
+
+// commandWatch reruns the command started last, a build or run, the tests,
+// or a task, whenever files of the workspace change
+type commandWatch struct {
+	on      bool
+	changes int             // number of changes seen, to wait for the last one
+	pending bool            // a file was saved while the command was running
+	dirs    map[string]bool // directories added to the file watcher
+}
+
+var watchMode = commandWatch{dirs: map[string]bool{}}
+
+func init() {
+	RegisterStatusSegment(StatusSegment{Name: "watch", Order: 31, Text: watchMode.status})
+}
+
+// toggle turns watch mode on for the command started last, or off
+func (w *commandWatch) toggle() {
+	if w.on {
+		w.on, w.pending = false, false
+		ui.output.message("Watch mode is off")
+		return
+	}
+	if jobs.last == nil {
+		ui.output.message("Nothing to watch; run a build, the tests, or a task first")
+		return
+	}
+	if watcher.watcher == nil {
+		notify(noticeWarning, "Watch mode needs the file watcher, which failed to start")
+		return
+	}
+	w.on = true
+	w.watchTree(workspaceRoot)
+	ui.output.message(fmt.Sprintf("Watch mode is on: %s runs again when files of the workspace change", jobs.last.name))
+}
+
+// status shows the watched command while it is not running
+func (w *commandWatch) status() string {
+	if !w.on || jobs.running(jobs.last) {
+		return ""
+	}
+	return "Watching: " + jobs.last.name
+}
+
+// watchTree adds dir and the directories under it to the file watcher,
+// leaving out hidden and ignored ones
+func (w *commandWatch) watchTree(dir string) {
+	ignore := newGitignore(workspaceRoot)
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if path != dir && !w.relevant(ignore, path, true) {
+			return filepath.SkipDir
+		}
+		if !w.dirs[path] {
+			w.dirs[path] = true
+			watcher.watch(path)
+		}
+		return nil
+	})
+}
+
+// relevant reports whether a change to path can affect the command: it is
+// in the workspace, and neither hidden nor ignored
+func (w *commandWatch) relevant(ignore *gitignore, path string, isDir bool) bool {
+	rel, err := filepath.Rel(workspaceRoot, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, part := range parts {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return !ignore.ignored(filepath.ToSlash(rel), isDir)
+}
+
+// changed sees the paths the file watcher found changed. Files modified
+// while the command ran are left out, as they may be the command's own
+// output, such as the binary go build writes.
+func (w *commandWatch) changed(paths map[string]bool) {
+	if !w.on {
+		return
+	}
+	ignore := newGitignore(workspaceRoot)
+	relevant := false
+	for path := range paths {
+		info, err := os.Stat(path)
+		isDir := err == nil && info.IsDir()
+		if !w.relevant(ignore, path, isDir) {
+			continue
+		}
+		switch {
+		case isDir:
+			if !w.dirs[path] {
+				w.watchTree(path)
+			}
+		case err != nil || !w.ranAt(info.ModTime()):
+			relevant = true
+		}
+	}
+	if relevant && !jobs.running(jobs.last) {
+		w.schedule()
+	}
+}
+
+// ranAt reports whether the command was running at a time
+func (w *commandWatch) ranAt(t time.Time) bool {
+	j := jobs.last
+	return !t.Before(j.started) && (jobs.running(j) || !t.After(j.ended))
+}
+
+// saved sees a file saved in the editor, running the command again once it
+// finishes if it is running
+func (w *commandWatch) saved(buf *Buffer) {
+	if !w.on || !w.relevant(newGitignore(workspaceRoot), buf.Path(), false) {
+		return
+	}
+	if jobs.running(jobs.last) {
+		w.pending = true
+		return
+	}
+	w.schedule()
+}
+
+// finished runs the command again if a file was saved while it ran
+func (w *commandWatch) finished() {
+	if w.on && w.pending {
+		w.pending = false
+		w.schedule()
+	}
+}
+
+// schedule runs the command again once files stopped changing for
+// watchRerunDelay
+func (w *commandWatch) schedule() {
+	w.changes++
+	changes := w.changes
+	time.AfterFunc(watchRerunDelay, func() {
+		ui.app.QueueUpdateDraw(func() {
+			if w.on && w.changes == changes && !jobs.running(jobs.last) {
+				jobs.last.rerun()
+			}
+		})
+	})
+}
@@ -0,0 +1,20 @@
+package app
+
+import "strings"
+
+// cleanWhitespace applies the whitespace settings for saving to text,
+// stripping the spaces and tabs at the end of its lines and making it end
+// with a newline as configured
+func cleanWhitespace(text string) string {
+	if config.Editor.TrimTrailingWhitespace {
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		text = strings.Join(lines, "\n")
+	}
+	if config.Editor.InsertFinalNewline && text != "" && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text
+}
@@ -0,0 +1,245 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rivo/tview"
+)
+
+// workspaceFolders are the folders added to the workspace besides
+// workspaceRoot, as absolute paths. Each one is a top-level node of the file
+// explorer, next to the workspace's own.
+var workspaceFolders []string
+
+// workspaceRoots returns workspaceRoot followed by the added folders
+func workspaceRoots() []string {
+	return append([]string{workspaceRoot}, workspaceFolders...)
+}
+
+// rootOf returns the root of the workspace path is in: the added folder
+// containing it, or else workspaceRoot
+func rootOf(path string) string {
+	abs := absPath(path)
+	for _, folder := range workspaceFolders {
+		if withinPath(abs, folder) {
+			return folder
+		}
+	}
+	return workspaceRoot
+}
+
+// activeRoot returns the root of the file in the editor, which the go tool
+// builds, runs and tests
+func activeRoot() string {
+	if buf := buffers.current(); buf != nil && buf.Path() != "" {
+		return rootOf(buf.Path())
+	}
+	return workspaceRoot
+}
+
+// rootName returns the name of a root's node in the explorer. The
+// workspace's own is "." until folders are added next to it.
+func rootName(root string) string {
+	if root == workspaceRoot && len(workspaceFolders) == 0 {
+		return workspaceRoot
+	}
+	return filepath.Base(absPath(root))
+}
+
+// workspaceFoldersPath returns the location of the file keeping the added
+// folders of every workspace between sessions
+func workspaceFoldersPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "goui", "folders.toml"), nil
+}
+
+// workspaceFoldersFile is the content of the folders file: the added folders
+// by the absolute path of their workspace
+type workspaceFoldersFile struct {
+	Workspaces map[string][]string `toml:"workspaces"`
+}
+
+// readWorkspaceFolders reads the folders file. A missing file has no
+// folders.
+func readWorkspaceFolders() (workspaceFoldersFile, error) {
+	saved := workspaceFoldersFile{Workspaces: map[string][]string{}}
+	path, err := workspaceFoldersPath()
+	if err != nil {
+		return saved, err
+	}
+	if _, err := toml.DecodeFile(path, &saved); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return saved, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if saved.Workspaces == nil {
+		saved.Workspaces = map[string][]string{}
+	}
+	return saved, nil
+}
+
+// loadWorkspaceFolders adds the folders the workspace had in the last
+// session. Folders that are gone are left out.
+func loadWorkspaceFolders() error {
+	saved, err := readWorkspaceFolders()
+	if err != nil {
+		return err
+	}
+	for _, folder := range saved.Workspaces[absPath(workspaceRoot)] {
+		if info, err := os.Stat(folder); err == nil && info.IsDir() && checkWorkspaceFolder(folder) == nil {
+			workspaceFolders = append(workspaceFolders, folder)
+		}
+	}
+	if len(workspaceFolders) > 0 {
+		workspaceRootsChanged()
+	}
+	return nil
+}
+
+// saveWorkspaceFolders remembers the added folders for the next session
+func saveWorkspaceFolders() error {
+	saved, err := readWorkspaceFolders()
+	if err != nil {
+		return err
+	}
+	if key := absPath(workspaceRoot); len(workspaceFolders) > 0 {
+		saved.Workspaces[key] = workspaceFolders
+	} else {
+		delete(saved.Workspaces, key)
+	}
+	path, err := workspaceFoldersPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer file.Close()
+	return toml.NewEncoder(file).Encode(saved)
+}
+
+// checkWorkspaceFolder rejects a folder that overlaps a root of the
+// workspace, as its files would be shown twice
+func checkWorkspaceFolder(folder string) error {
+	// withinPath misses the paths below the file system's root
+	within := func(path, dir string) bool {
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	for _, root := range workspaceRoots() {
+		abs := absPath(root)
+		switch {
+		case within(folder, abs):
+			return fmt.Errorf("%s is already in the workspace", folder)
+		case within(abs, folder):
+			return fmt.Errorf("%s contains the workspace folder %s", folder, abs)
+		}
+	}
+	return nil
+}
+
+// addWorkspaceFolder adds a folder to the workspace and selects its node
+func addWorkspaceFolder(path string) error {
+	folder := absPath(expandHome(path))
+	info, err := os.Stat(folder)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", folder)
+	}
+	if err := checkWorkspaceFolder(folder); err != nil {
+		return err
+	}
+	workspaceFolders = append(workspaceFolders, folder)
+	workspaceRootsChanged()
+	gopls.foldersChanged([]string{folder}, nil)
+	ui.fileExplorer.SelectRoot(folder)
+	return saveWorkspaceFolders()
+}
+
+// removeWorkspaceFolder takes an added folder out of the workspace. Its open
+// files stay open.
+func removeWorkspaceFolder(folder string) error {
+	for i, f := range workspaceFolders {
+		if f != folder {
+			continue
+		}
+		workspaceFolders = append(workspaceFolders[:i:i], workspaceFolders[i+1:]...)
+		workspaceRootsChanged()
+		gopls.foldersChanged(nil, []string{folder})
+		return saveWorkspaceFolders()
+	}
+	return fmt.Errorf("%s is not a folder of the workspace", folder)
+}
+
+// workspaceRootsChanged shows the roots of the workspace in the explorer,
+// keeping the nodes of the roots it already showed, and reads the
+// .gitignore rules of each
+func workspaceRootsChanged() {
+	explorerFilter.ignores = rootIgnoreRules()
+	ui.fileExplorer.SetFilterFunc(explorerFilter.hides).SetRoots(workspaceRoots(), rootName)
+}
+
+// rootIgnoreRules returns the .gitignore matchers of the workspace roots
+func rootIgnoreRules() map[string]*gitignore {
+	ignores := make(map[string]*gitignore)
+	for _, root := range workspaceRoots() {
+		ignores[root] = newGitignore(root)
+	}
+	return ignores
+}
+
+// promptAddWorkspaceFolder asks for a folder to add to the workspace
+func promptAddWorkspaceFolder() {
+	showPrompt("Add folder to workspace: ", "", func(path string) {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return
+		}
+		if err := addWorkspaceFolder(path); err != nil {
+			notify(noticeError, fmt.Sprintf("Error adding folder: %s", err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Added %s to the workspace", absPath(expandHome(path))))
+	})
+}
+
+// pickRemoveWorkspaceFolder removes the added folder the explorer's
+// selection is in, or else asks which one to remove
+func pickRemoveWorkspaceFolder() {
+	if len(workspaceFolders) == 0 {
+		ui.output.message("No folders have been added to the workspace")
+		return
+	}
+	remove := func(folder string) {
+		if err := removeWorkspaceFolder(folder); err != nil {
+			notify(noticeError, fmt.Sprintf("Error removing folder: %s", err))
+			return
+		}
+		ui.output.message(fmt.Sprintf("Removed %s from the workspace", folder))
+	}
+	if node := ui.fileExplorer.GetCurrentNode(); node != nil && ui.fileExplorer.HasFocus() {
+		if root := rootOf(ui.fileExplorer.Path(node)); root != workspaceRoot {
+			remove(root)
+			return
+		}
+	}
+	labels := make([]string, len(workspaceFolders))
+	for i, folder := range workspaceFolders {
+		labels[i] = tview.Escape(abbreviateHome(folder))
+	}
+	showPicker("Remove Folder from Workspace", labels, 0, func(index int) {
+		remove(workspaceFolders[index])
+	})
+}
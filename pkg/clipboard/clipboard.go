@@ -0,0 +1,178 @@
+// Package clipboard reaches the system clipboard through the clipboard tools
+// of the platform, or through the terminal with the OSC 52 escape sequence.
+package clipboard
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Provider is a way of reaching the system clipboard
+type Provider interface {
+	Name() string
+	Copy(text string) error
+	// Paste returns the clipboard content, or ErrWriteOnly when the provider
+	// cannot read it
+	Paste() (string, error)
+}
+
+// ErrWriteOnly is returned by providers that can only copy
+var ErrWriteOnly = errors.New("clipboard cannot be read")
+
+// ErrNoProvider is returned when copying without a way to reach the system
+// clipboard
+var ErrNoProvider = errors.New("no clipboard tool found (install wl-clipboard, xclip, or xsel); the text can only be pasted within goui")
+
+// Command uses clipboard tools such as xclip or pbcopy
+type Command struct {
+	copy  []string
+	paste []string
+}
+
+// NewCommand returns a provider running the copy command with the text on
+// its input, and the paste command for the clipboard content on its output
+func NewCommand(copy, paste []string) Command {
+	return Command{copy: copy, paste: paste}
+}
+
+// Commands returns the tools tried, in order, to reach the system clipboard
+// locally
+func Commands() []Command {
+	return []Command{
+		NewCommand([]string{"wl-copy"}, []string{"wl-paste", "--no-newline"}),
+		NewCommand([]string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-out"}),
+		NewCommand([]string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}),
+		NewCommand([]string{"pbcopy"}, []string{"pbpaste"}),
+		NewCommand([]string{"clip.exe"}, []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}),
+	}
+}
+
+// Name returns the name of the copy tool
+func (c Command) Name() string {
+	return c.copy[0]
+}
+
+// Copy writes text to the clipboard
+func (c Command) Copy(text string) error {
+	cmd := exec.Command(c.copy[0], c.copy[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	// Output is not captured: xclip and xsel keep running in the background
+	// to serve the selection, holding any pipes open
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run %s: %w", c.copy[0], err)
+	}
+	return nil
+}
+
+// Paste returns the clipboard content
+func (c Command) Paste() (string, error) {
+	out, err := exec.Command(c.paste[0], c.paste[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s: %w", c.paste[0], err)
+	}
+	return string(out), nil
+}
+
+// Available reports whether the copy tool is installed
+func (c Command) Available() bool {
+	_, err := exec.LookPath(c.copy[0])
+	return err == nil
+}
+
+// OSC52 sets the clipboard of a terminal with the OSC 52 escape sequence,
+// which also works over SSH
+type OSC52 struct {
+	terminal io.Writer
+}
+
+// NewOSC52 returns a provider writing the escape sequence to terminal
+func NewOSC52(terminal io.Writer) OSC52 {
+	return OSC52{terminal: terminal}
+}
+
+// Name returns the provider name
+func (OSC52) Name() string {
+	return "OSC 52"
+}
+
+// Copy sends text to the terminal's clipboard
+func (o OSC52) Copy(text string) error {
+	sequence := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+	if _, err := io.WriteString(o.terminal, sequence); err != nil {
+		return fmt.Errorf("failed to write to terminal: %w", err)
+	}
+	return nil
+}
+
+// Paste is not supported: most terminals refuse OSC 52 clipboard reads
+func (OSC52) Paste() (string, error) {
+	return "", ErrWriteOnly
+}
+
+// Detect picks a provider: OSC 52 on terminal in SSH sessions, where local
+// tools would reach the remote machine's clipboard, and otherwise the first
+// installed clipboard tool. It returns nil if there is none.
+func Detect(terminal io.Writer) Provider {
+	if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		return NewOSC52(terminal)
+	}
+	for _, command := range Commands() {
+		if command.Available() {
+			return command
+		}
+	}
+	return nil
+}
+
+// Clipboard copies and pastes through a provider detected the first time it
+// is used. The last copied text is kept, so pasting works within the
+// program even when the system clipboard cannot be read.
+type Clipboard struct {
+	detect   func() Provider
+	provider Provider
+	detected bool
+	last     string
+}
+
+// New returns a clipboard using the provider detect returns, which may be
+// nil if there is none
+func New(detect func() Provider) *Clipboard {
+	return &Clipboard{detect: detect}
+}
+
+// Provider returns the provider in use, or nil if there is none
+func (c *Clipboard) Provider() Provider {
+	if !c.detected {
+		c.detected = true
+		c.provider = c.detect()
+	}
+	return c.provider
+}
+
+// Copy writes text to the clipboard
+func (c *Clipboard) Copy(text string) error {
+	c.last = text
+	provider := c.Provider()
+	if provider == nil {
+		return ErrNoProvider
+	}
+	return provider.Copy(text)
+}
+
+// Paste returns the clipboard content
+func (c *Clipboard) Paste() (string, error) {
+	provider := c.Provider()
+	if provider == nil {
+		return c.last, nil
+	}
+	text, err := provider.Paste()
+	if errors.Is(err, ErrWriteOnly) {
+		return c.last, nil
+	}
+	return text, err
+}
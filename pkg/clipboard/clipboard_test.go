@@ -0,0 +1,50 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestOSC52(t *testing.T) {
+	var terminal bytes.Buffer
+	o := NewOSC52(&terminal)
+	if err := o.Copy("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := terminal.String(), "\x1b]52;c;aGk=\x07"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+	if _, err := o.Paste(); !errors.Is(err, ErrWriteOnly) {
+		t.Errorf("Paste() error = %v, want ErrWriteOnly", err)
+	}
+}
+
+func TestClipboardKeepsLastCopy(t *testing.T) {
+	var terminal bytes.Buffer
+	detected := 0
+	c := New(func() Provider {
+		detected++
+		return NewOSC52(&terminal)
+	})
+	if err := c.Copy("text"); err != nil {
+		t.Fatal(err)
+	}
+	text, err := c.Paste()
+	if err != nil || text != "text" {
+		t.Errorf("Paste() = %q, %v, want the copied text", text, err)
+	}
+	if detected != 1 {
+		t.Errorf("detected the provider %d times, want once", detected)
+	}
+}
+
+func TestClipboardWithoutProvider(t *testing.T) {
+	c := New(func() Provider { return nil })
+	if err := c.Copy("text"); !errors.Is(err, ErrNoProvider) {
+		t.Errorf("Copy() error = %v, want ErrNoProvider", err)
+	}
+	if text, err := c.Paste(); err != nil || text != "text" {
+		t.Errorf("Paste() = %q, %v, want the copied text", text, err)
+	}
+}
@@ -0,0 +1,156 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// bracketPairs maps the brackets and quotes closed automatically to their
+// closing rune
+var bracketPairs = map[rune]rune{'(': ')', '[': ']', '{': '}', '"': '"', '\'': '\'', '`': '`'}
+
+// SetAutoIndent sets whether Enter carries the indentation of a line over
+// to the next one, adding a level after an opening bracket
+func (e *Editor) SetAutoIndent(on bool) *Editor {
+	e.autoIndent = on
+	return e
+}
+
+// SetAutoClose sets whether typing an opening bracket or quote inserts the
+// closing one too, and typing a closing one steps over the one that is there
+func (e *Editor) SetAutoClose(on bool) *Editor {
+	e.autoClose = on
+	return e
+}
+
+// LeadingSpace returns the blanks a line starts with
+func LeadingSpace(line []rune) []rune {
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	return line[:n]
+}
+
+// indentUnit returns one level of indentation in the style of a line's
+// indentation: spaces if it is indented with spaces, or if it is not
+// indented and Tab inserts spaces, and a tab otherwise
+func (e *Editor) indentUnit(indent []rune) string {
+	if len(indent) > 0 && indent[0] == ' ' || len(indent) == 0 && e.insertSpaces {
+		return strings.Repeat(" ", e.tabWidth)
+	}
+	return "\t"
+}
+
+// newline breaks the line at the cursor. With auto-indent, the new line
+// gets the indentation of the old one, plus a level after an opening
+// bracket; between a pair of brackets, the closing one moves to a line of
+// its own below the cursor.
+func (e *Editor) newline() {
+	from, to := e.Selection()
+	if !e.autoIndent {
+		e.InsertText("\n")
+		return
+	}
+	line := e.buf.lines[from.Line]
+	indent := LeadingSpace(line[:from.Col])
+	text := "\n" + string(indent)
+	after := ""
+	if before := lastNonBlank(line[:from.Col]); before == '(' || before == '[' || before == '{' {
+		if rest := e.buf.lines[to.Line][to.Col:]; len(rest) > 0 && rest[0] == bracketPairs[before] {
+			after = "\n" + string(indent)
+		}
+		text += e.indentUnit(indent)
+	}
+	end := e.Replace(from, to, text+after)
+	if after != "" {
+		end = Position{Line: end.Line - 1, Col: len([]rune(text)) - 1}
+	}
+	e.moveTo(end, false)
+}
+
+// lastNonBlank returns the last rune of text that is not a blank, or 0
+func lastNonBlank(text []rune) rune {
+	for i := len(text) - 1; i >= 0; i-- {
+		if text[i] != ' ' && text[i] != '\t' {
+			return text[i]
+		}
+	}
+	return 0
+}
+
+// typeRune inserts a typed rune. With auto-close, an opening bracket or
+// quote is inserted with its closing rune, or put around the selection, and
+// a closing rune typed in front of the same one steps over it.
+func (e *Editor) typeRune(r rune) {
+	if !e.autoClose {
+		e.InsertText(string(r))
+		return
+	}
+	from, to := e.Selection()
+	line := e.buf.lines[from.Line]
+	var next, prev rune
+	if from == to && from.Col < len(line) {
+		next = line[from.Col]
+	}
+	if from.Col > 0 {
+		prev = line[from.Col-1]
+	}
+	closing, opens := bracketPairs[r]
+	switch {
+	case next == r && isClosing(r):
+		e.moveTo(Position{Line: from.Line, Col: from.Col + 1}, false)
+	case opens && from != to:
+		selected := e.TextRange(from, to)
+		e.Replace(from, to, string(r)+selected+string(closing))
+		// The selection stays on the text inside
+		end := textEnd(Position{Line: from.Line, Col: from.Col + 1}, selected)
+		e.Select(Position{Line: from.Line, Col: from.Col + 1}, end)
+	case opens && canClose(r, prev, next):
+		end := e.Replace(from, to, string(r)+string(closing))
+		e.moveTo(Position{Line: end.Line, Col: end.Col - 1}, false)
+	default:
+		e.InsertText(string(r))
+	}
+}
+
+// isClosing reports whether r closes a pair
+func isClosing(r rune) bool {
+	for _, closing := range bracketPairs {
+		if r == closing {
+			return true
+		}
+	}
+	return false
+}
+
+// canClose reports whether an opening rune typed between prev and next gets
+// its closing rune: brackets before blanks, closing brackets, or the end
+// of the line, and quotes also outside of words, so apostrophes stay alone
+func canClose(r, prev, next rune) bool {
+	if next != 0 && !unicode.IsSpace(next) && !isClosing(next) {
+		return false
+	}
+	if bracketPairs[r] == r {
+		return next != r && !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && prev != r
+	}
+	return true
+}
+
+// deletePair deletes an empty pair of brackets or quotes around the cursor
+// on Backspace, reporting whether there was one
+func (e *Editor) deletePair() bool {
+	cursor := e.buf.cursor
+	line := e.buf.lines[cursor.Line]
+	if !e.autoClose || e.HasSelection() || cursor.Col == 0 || cursor.Col >= len(line) {
+		return false
+	}
+	closing, ok := bracketPairs[line[cursor.Col-1]]
+	if !ok || line[cursor.Col] != closing {
+		return false
+	}
+	from := Position{Line: cursor.Line, Col: cursor.Col - 1}
+	e.Replace(from, Position{Line: cursor.Line, Col: cursor.Col + 1}, "")
+	e.moveTo(from, false)
+	return true
+}
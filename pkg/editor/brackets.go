@@ -0,0 +1,102 @@
+package editor
+
+import "github.com/gdamore/tcell/v2"
+
+// matchingBrackets maps each bracket to its counterpart
+var matchingBrackets = map[rune]rune{'(': ')', '[': ']', '{': '}', ')': '(', ']': '[', '}': '{'}
+
+// bracketScanLines is the number of lines searched for a matching bracket
+const bracketScanLines = 5000
+
+// inCode reports whether the rune at pos is code rather than part of a
+// string or comment. Only Go files are told apart; in other files
+// everything is code.
+func (e *Editor) inCode(pos Position) bool {
+	if FileLanguage(e.buf.path) != "go" {
+		return true
+	}
+	for _, token := range e.buf.highlight.lineTokens(e.buf.lines, pos.Line) {
+		if token.Start <= pos.Col && pos.Col < token.End {
+			return token.Kind != TokenString && token.Kind != TokenComment
+		}
+	}
+	return true
+}
+
+// matchBracket returns the position of the bracket matching the one at pos,
+// and false if there is no bracket at pos or it has no match
+func (e *Editor) matchBracket(pos Position) (Position, bool) {
+	bracket := e.runeAt(pos)
+	closing, ok := matchingBrackets[bracket]
+	if !ok || !e.inCode(pos) {
+		return Position{}, false
+	}
+	forward := bracket == '(' || bracket == '[' || bracket == '{'
+	depth := 0
+	for line, scanned := pos.Line, 0; line >= 0 && line < len(e.buf.lines) && scanned < bracketScanLines; scanned++ {
+		runes := e.buf.lines[line]
+		col, step := 0, 1
+		if !forward {
+			col, step = len(runes)-1, -1
+		}
+		if line == pos.Line {
+			col = pos.Col
+		}
+		for ; col >= 0 && col < len(runes); col += step {
+			if r := runes[col]; r != bracket && r != closing || !e.inCode(Position{Line: line, Col: col}) {
+				continue
+			}
+			if runes[col] == bracket {
+				depth++
+			} else if depth--; depth == 0 {
+				return Position{Line: line, Col: col}, true
+			}
+		}
+		line += step
+	}
+	return Position{}, false
+}
+
+// bracketAtCursor returns the bracket at the cursor, or else the one before
+// it, with the bracket it matches
+func (e *Editor) bracketAtCursor() (Position, Position, bool) {
+	cursor := e.buf.cursor
+	if match, ok := e.matchBracket(cursor); ok {
+		return cursor, match, true
+	}
+	if cursor.Col > 0 {
+		before := Position{Line: cursor.Line, Col: cursor.Col - 1}
+		if match, ok := e.matchBracket(before); ok {
+			return before, match, true
+		}
+	}
+	return Position{}, Position{}, false
+}
+
+// highlightBrackets marks the bracket at the cursor and its match
+func (e *Editor) highlightBrackets() {
+	bracket, match, ok := e.bracketAtCursor()
+	if !ok || e.HasSelection() {
+		e.ClearDecorations("brackets")
+		return
+	}
+	from, to := orderPositions(bracket, match)
+	ranges := []Range{
+		{From: from, To: Position{Line: from.Line, Col: from.Col + 1}},
+		{From: to, To: Position{Line: to.Line, Col: to.Col + 1}},
+	}
+	e.SetDecorations("brackets", ranges, func(style tcell.Style) tcell.Style {
+		return style.Background(e.colors.Contrast).Bold(true)
+	})
+}
+
+// JumpToBracket moves the cursor to the bracket matching the one at the
+// cursor, reporting whether there was one
+func (e *Editor) JumpToBracket() bool {
+	_, match, ok := e.bracketAtCursor()
+	if ok {
+		e.moveTo(match, false)
+		e.buf.goalX = -1
+	}
+	return ok
+}
@@ -0,0 +1,144 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Buffer holds the text and view state of a document shown in the editor
+type Buffer struct {
+	path     string
+	readOnly bool
+
+	lines     [][]rune
+	cursor    Position
+	anchor    Position        // selection anchor; equal to cursor when nothing is selected
+	extra     []selection     // further cursors, while there are several
+	snippet   *snippetSession // the snippet whose fields Tab moves between
+	goalX     int             // preferred display column for vertical movement, -1 if unset
+	rowOffset int
+	rowSkip   int // rows of the line at rowOffset scrolled past while lines wrap
+	colOffset int
+	highlight highlighter
+	undo      undoStack
+
+	// folds are the folded ranges, sorted by their first line. foldable
+	// caches the ranges that can be folded, for a tab width of
+	// foldableWidth; it is nil after an edit.
+	folds         []lineFold
+	foldable      []lineFold
+	foldableWidth int
+
+	// occurrences caches the occurrences of occurrenceWord, the word at
+	// the cursor, sorted; it is nil after an edit
+	occurrences    []Range
+	occurrenceWord string
+}
+
+// NewBuffer returns a buffer for the file at path holding text. An empty
+// path creates an unnamed scratch buffer.
+func NewBuffer(path, text string) *Buffer {
+	b := &Buffer{path: path}
+	if path != "" {
+		b.highlight.lexer = LexerForPath(path)
+	}
+	b.SetText(text)
+	return b
+}
+
+// SetText replaces the content and resets the view state
+func (b *Buffer) SetText(text string) {
+	b.lines = SplitLines(text)
+	b.cursor, b.anchor = Position{}, Position{}
+	b.extra, b.snippet = nil, nil
+	b.goalX = -1
+	b.rowOffset, b.rowSkip, b.colOffset = 0, 0, 0
+	b.highlight.invalidate(0)
+	b.folds, b.foldable = nil, nil
+	b.occurrences = nil
+	b.undo.reset()
+}
+
+// Text returns the full content of the buffer
+func (b *Buffer) Text() string {
+	var sb strings.Builder
+	for i, line := range b.lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(string(line))
+	}
+	return sb.String()
+}
+
+// Lines returns the lines of the buffer. They must not be modified.
+func (b *Buffer) Lines() [][]rune {
+	return b.lines
+}
+
+// Clamp restricts a position to the bounds of the buffer
+func (b *Buffer) Clamp(pos Position) Position {
+	if pos.Line < 0 {
+		return Position{}
+	}
+	if pos.Line >= len(b.lines) {
+		last := len(b.lines) - 1
+		return Position{Line: last, Col: len(b.lines[last])}
+	}
+	if pos.Col < 0 {
+		pos.Col = 0
+	}
+	if pos.Col > len(b.lines[pos.Line]) {
+		pos.Col = len(b.lines[pos.Line])
+	}
+	return pos
+}
+
+// Cursor returns the position of the main cursor
+func (b *Buffer) Cursor() Position {
+	return b.cursor
+}
+
+// SetCursor moves the cursor of a buffer the editor does not show, clearing
+// the selection and the other cursors
+func (b *Buffer) SetCursor(pos Position) {
+	b.cursor = b.Clamp(pos)
+	b.anchor, b.extra = b.cursor, nil
+}
+
+// Cursors returns the number of cursors in the buffer
+func (b *Buffer) Cursors() int {
+	return len(b.extra) + 1
+}
+
+// Path returns the file the buffer belongs to
+func (b *Buffer) Path() string {
+	return b.path
+}
+
+// SetPath moves the buffer to another file, highlighting it as the new
+// file's language
+func (b *Buffer) SetPath(path string) {
+	b.path = path
+	b.highlight.lexer = LexerForPath(path)
+	b.highlight.invalidate(0)
+	b.foldable = nil
+}
+
+// Name returns the label shown for the buffer in the tab bar
+func (b *Buffer) Name() string {
+	if b.path == "" {
+		return "untitled"
+	}
+	return filepath.Base(b.path)
+}
+
+// ReadOnly reports whether edits of the buffer are refused
+func (b *Buffer) ReadOnly() bool {
+	return b.readOnly
+}
+
+// SetReadOnly sets whether edits of the buffer are refused
+func (b *Buffer) SetReadOnly(readOnly bool) {
+	b.readOnly = readOnly
+}
@@ -0,0 +1,43 @@
+package editor
+
+import "testing"
+
+func TestBufferText(t *testing.T) {
+	for _, text := range []string{"", "one", "one\ntwo", "trailing\n", "\n\n"} {
+		if got := NewBuffer("", text).Text(); got != text {
+			t.Errorf("Text() = %q, want %q", got, text)
+		}
+	}
+}
+
+func TestBufferClamp(t *testing.T) {
+	b := NewBuffer("", "one\nthree")
+	tests := []struct {
+		pos, want Position
+	}{
+		{Position{-1, 2}, Position{0, 0}},
+		{Position{0, -1}, Position{0, 0}},
+		{Position{0, 10}, Position{0, 3}},
+		{Position{5, 0}, Position{1, 5}},
+		{Position{1, 2}, Position{1, 2}},
+	}
+	for _, tt := range tests {
+		if got := b.Clamp(tt.pos); got != tt.want {
+			t.Errorf("Clamp(%v) = %v, want %v", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestBufferName(t *testing.T) {
+	if got := NewBuffer("", "").Name(); got != "untitled" {
+		t.Errorf("Name() = %q, want untitled", got)
+	}
+	b := NewBuffer("/src/main.go", "")
+	if got := b.Name(); got != "main.go" {
+		t.Errorf("Name() = %q, want main.go", got)
+	}
+	b.SetPath("/src/README.md")
+	if got := b.Name(); got != "README.md" {
+		t.Errorf("Name() after SetPath = %q, want README.md", got)
+	}
+}
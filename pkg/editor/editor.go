@@ -0,0 +1,1362 @@
+// Package editor is a text editing widget for tview applications, with syntax
+// highlighting, undo, folding, several cursors, snippets, completion, and vim
+// and emacs keymaps. An Editor shows one Buffer at a time.
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// Position is a location in the editor buffer, with the column counted in runes
+type Position struct {
+	Line int
+	Col  int
+}
+
+// Less reports whether p comes before q
+func (p Position) Less(q Position) bool {
+	return p.Line < q.Line || (p.Line == q.Line && p.Col < q.Col)
+}
+
+// Range is a span of buffer text from From up to, but not including, To
+type Range struct {
+	From Position
+	To   Position
+}
+
+// Contains reports whether pos lies within the range
+func (r Range) Contains(pos Position) bool {
+	return !pos.Less(r.From) && pos.Less(r.To)
+}
+
+// decorationLayer styles a set of ranges, such as search matches
+type decorationLayer struct {
+	name   string
+	ranges []Range // sorted by From
+	style  func(tcell.Style) tcell.Style
+}
+
+// GutterMark is a sign drawn in the editor gutter next to a line
+type GutterMark struct {
+	Rune  rune
+	Color tcell.Color
+}
+
+// LineNote is text shown after the end of a line, such as a message about it
+type LineNote struct {
+	Text  string
+	Color tcell.Color
+}
+
+// markLayer holds the gutter marks contributed by one feature
+type markLayer struct {
+	name  string
+	marks map[int]GutterMark // keyed by line
+}
+
+// signColumnWidth is the width of the gutter column showing marks
+const signColumnWidth = 2
+
+// orderPositions returns the two positions sorted in buffer order
+func orderPositions(a, b Position) (Position, Position) {
+	if b.Less(a) {
+		return b, a
+	}
+	return a, b
+}
+
+// Editor is a multi-line text editing widget with syntax highlighting. The
+// text and view state live in a Buffer, so one editor can switch between
+// several open documents.
+type Editor struct {
+	*tview.Box
+
+	buf *Buffer
+
+	pageHeight  int
+	trackCursor bool
+	dragging    bool
+	// columnDrag is set while the mouse selects columns, from the display
+	// column columnX of the line of columnStart
+	columnDrag  bool
+	columnStart Position
+	columnX     int
+
+	tabWidth    int
+	autoIndent  bool
+	autoClose   bool
+	lineNumbers bool
+	folding     bool
+	// wordHighlight is set to highlight the occurrences of the identifier
+	// at the cursor
+	wordHighlight bool
+	invisibles    bool // draw the spaces and tabs ending lines
+	insertSpaces  bool // Tab inserts spaces
+	wrap          bool // long lines wrap at wrapWidth
+	wrapWidth     int  // the width of the text at the last draw
+	placeholder   string
+	standIn       tview.Primitive // takes the focus given to the editor, if set
+	decorations   []*decorationLayer
+	marks         []*markLayer
+	annotations   []string // text shown left of the gutter, by line
+	annotationW   int      // width of the annotation column, 0 without one
+	completion    *completionPopup
+	info          string
+	keymap        Keymap
+	notes         map[int]LineNote // text shown after the end of lines, by line
+	snippets      func(path string) ([]Snippet, error)
+
+	colors           Colors
+	textStyle        tcell.Style
+	selectedStyle    tcell.Style
+	placeholderStyle tcell.Style
+	lineNumberStyle  tcell.Style
+	modeLineStyle    tcell.Style
+
+	changed     func()
+	moved       func()
+	replaced    func(from, to, end Position)
+	gutterClick func(line int, annotation bool)
+	refused     func()
+}
+
+// Colors are the colors an editor is drawn in
+type Colors struct {
+	Background tcell.Color
+	Text       tcell.Color
+	Muted      tcell.Color // line numbers and the marks of trailing spaces
+	Accent     tcell.Color // the mode line and the selected completion
+	AccentText tcell.Color // text on the accent color
+	Success    tcell.Color // the placeholder
+	Contrast   tcell.Color // background of the matching brackets
+
+	PopupBackground tcell.Color
+	PopupText       tcell.Color
+	PopupDetail     tcell.Color
+
+	Tokens map[TokenKind]tcell.Color
+}
+
+// DefaultColors are the colors of a new editor, light text on black
+var DefaultColors = Colors{
+	Background: tcell.ColorBlack,
+	Text:       tcell.ColorWhite,
+	Muted:      tcell.ColorGray,
+	Accent:     tcell.ColorYellow,
+	AccentText: tcell.ColorBlack,
+	Success:    tcell.ColorGreen,
+	Contrast:   tcell.ColorBlue,
+
+	PopupBackground: tcell.ColorDarkSlateGray,
+	PopupText:       tcell.ColorWhite,
+	PopupDetail:     tcell.ColorSilver,
+
+	Tokens: map[TokenKind]tcell.Color{
+		TokenKeyword:  tcell.ColorYellow,
+		TokenType:     tcell.ColorDarkCyan,
+		TokenFunction: tcell.ColorLightSkyBlue,
+		TokenString:   tcell.ColorGreen,
+		TokenNumber:   tcell.ColorFuchsia,
+		TokenComment:  tcell.ColorGray,
+		TokenOperator: tcell.ColorSilver,
+		TokenVariable: tcell.ColorOrange,
+		TokenHeading:  tcell.ColorYellow,
+		TokenEmphasis: tcell.ColorWhite,
+		TokenLink:     tcell.ColorLightSkyBlue,
+	},
+}
+
+// NewEditor returns a new editor showing an empty buffer
+func NewEditor() *Editor {
+	e := &Editor{
+		Box:         tview.NewBox(),
+		buf:         NewBuffer("", ""),
+		tabWidth:    4,
+		lineNumbers: true,
+	}
+	return e.SetColors(DefaultColors)
+}
+
+// SetColors sets the colors the editor is drawn in
+func (e *Editor) SetColors(c Colors) *Editor {
+	base := tcell.StyleDefault.Background(c.Background)
+	e.colors = c
+	e.SetBackgroundColor(c.Background)
+	e.textStyle = base.Foreground(c.Text)
+	e.selectedStyle = tcell.StyleDefault.Background(c.Text).Foreground(c.Background)
+	e.placeholderStyle = base.Foreground(c.Success)
+	e.lineNumberStyle = base.Foreground(c.Muted)
+	e.modeLineStyle = base.Foreground(c.Accent)
+	return e
+}
+
+// SetBuffer switches the editor to another buffer
+func (e *Editor) SetBuffer(buf *Buffer) *Editor {
+	e.buf = buf
+	e.trackCursor = true
+	e.dragging = false
+	e.completion, e.info = nil, ""
+	e.notifyMoved()
+	return e
+}
+
+// SetDecorations styles the given ranges, replacing any previous ranges of
+// the layer with the same name. Layers are drawn in the order they were
+// first added, so later layers take precedence. The ranges must be sorted.
+func (e *Editor) SetDecorations(name string, ranges []Range, style func(tcell.Style) tcell.Style) *Editor {
+	for _, layer := range e.decorations {
+		if layer.name == name {
+			layer.ranges, layer.style = ranges, style
+			return e
+		}
+	}
+	e.decorations = append(e.decorations, &decorationLayer{name: name, ranges: ranges, style: style})
+	return e
+}
+
+// SetGutterMarks sets the gutter marks of the named layer, replacing its
+// previous marks. Where layers mark the same line, the layer added last wins.
+func (e *Editor) SetGutterMarks(name string, marks map[int]GutterMark) *Editor {
+	for _, layer := range e.marks {
+		if layer.name == name {
+			layer.marks = marks
+			return e
+		}
+	}
+	e.marks = append(e.marks, &markLayer{name: name, marks: marks})
+	return e
+}
+
+// SetLineNotes sets the text shown after the end of lines, replacing the
+// notes set before
+func (e *Editor) SetLineNotes(notes map[int]LineNote) *Editor {
+	e.notes = notes
+	return e
+}
+
+// SetKeymap installs a keymap that sees every key before the default
+// bindings; nil restores the default bindings alone. A keymap gets a mode
+// line below the text.
+func (e *Editor) SetKeymap(keymap Keymap) *Editor {
+	e.keymap = keymap
+	return e
+}
+
+// Keymap returns the installed keymap, or nil if there is none
+func (e *Editor) Keymap() Keymap {
+	return e.keymap
+}
+
+// Inserting reports whether typed characters are currently inserted as text
+func (e *Editor) Inserting() bool {
+	return e.keymap == nil || e.keymap.Inserting()
+}
+
+// SetAnnotations shows a column of text left of the gutter, one entry per
+// line, or removes it when lines is nil
+func (e *Editor) SetAnnotations(lines []string) *Editor {
+	e.annotations, e.annotationW = lines, 0
+	for _, line := range lines {
+		if w := runewidth.StringWidth(line) + 1; w > e.annotationW {
+			e.annotationW = w
+		}
+	}
+	return e
+}
+
+// SetLineNumbers shows or hides the line numbers in the gutter
+func (e *Editor) SetLineNumbers(show bool) *Editor {
+	e.lineNumbers = show
+	return e
+}
+
+// SetTabWidth sets the number of columns between tab stops
+func (e *Editor) SetTabWidth(width int) *Editor {
+	if width > 0 {
+		e.tabWidth = width
+	}
+	return e
+}
+
+// TabWidth returns the number of columns between tab stops
+func (e *Editor) TabWidth() int {
+	return e.tabWidth
+}
+
+// SetGutterClickFunc sets a handler called with the line number when the
+// gutter next to a line is clicked, and whether the click was on the
+// annotations
+func (e *Editor) SetGutterClickFunc(handler func(line int, annotation bool)) *Editor {
+	e.gutterClick = handler
+	return e
+}
+
+// gutterWidth returns the number of columns left of the text: the
+// annotations, the sign column, the line numbers and a separating space,
+// and the fold markers
+func (e *Editor) gutterWidth() int {
+	width := e.annotationW + signColumnWidth
+	if e.lineNumbers {
+		width += e.lineNumberWidth() + 1
+	}
+	if e.folding {
+		width += foldColumnWidth
+	}
+	return width
+}
+
+// lineNumberWidth returns the width of the line numbers, without the space
+// following them
+func (e *Editor) lineNumberWidth() int {
+	return len(strconv.Itoa(len(e.buf.lines)))
+}
+
+// textRect returns the screen area used for text, to the right of the gutter
+func (e *Editor) textRect() (int, int, int, int) {
+	x, y, width, height := e.GetInnerRect()
+	gutter := e.gutterWidth()
+	if gutter > width {
+		gutter = width
+	}
+	if e.keymap != nil && height > 1 {
+		height--
+	}
+	return x + gutter, y, width - gutter, height
+}
+
+// ClearDecorations removes the ranges of a decoration layer
+func (e *Editor) ClearDecorations(name string) *Editor {
+	for _, layer := range e.decorations {
+		if layer.name == name {
+			layer.ranges = nil
+		}
+	}
+	return e
+}
+
+// Transaction runs fn, recording all edits it makes as one undo step and
+// reporting a single change once it returns
+func (e *Editor) Transaction(fn func()) {
+	s := &e.buf.undo
+	s.batching, s.batchOpen = true, false
+	fn()
+	s.batching = false
+	s.breakGroup()
+	if s.batchOpen {
+		e.notifyChanged()
+	}
+}
+
+// Buffer returns the buffer currently shown in the editor
+func (e *Editor) Buffer() *Buffer {
+	return e.buf
+}
+
+// SetPlaceholder sets the text shown while the buffer is empty
+func (e *Editor) SetPlaceholder(text string) *Editor {
+	e.placeholder = text
+	return e
+}
+
+// SetStandIn sets a primitive shown in place of the editor, which is given
+// the focus whenever the editor would get it; nil clears it
+func (e *Editor) SetStandIn(p tview.Primitive) *Editor {
+	e.standIn = p
+	return e
+}
+
+// Focus is called when this primitive receives focus
+func (e *Editor) Focus(delegate func(p tview.Primitive)) {
+	if e.standIn != nil {
+		delegate(e.standIn)
+		return
+	}
+	e.Box.Focus(delegate)
+}
+
+// SetLexer sets the lexer used for syntax highlighting; nil disables it
+func (e *Editor) SetLexer(lexer Lexer) *Editor {
+	e.buf.highlight = highlighter{lexer: lexer}
+	return e
+}
+
+// SetChangedFunc sets a handler called whenever the buffer content changes
+func (e *Editor) SetChangedFunc(handler func()) *Editor {
+	e.changed = handler
+	return e
+}
+
+// SetRefusedFunc sets a handler called when an edit of a read-only buffer
+// is refused
+func (e *Editor) SetRefusedFunc(handler func()) *Editor {
+	e.refused = handler
+	return e
+}
+
+// SetReplacedFunc sets a handler called after the text between from and to
+// was replaced with text ending at end, to move marks along with the lines
+// of the buffer
+func (e *Editor) SetReplacedFunc(handler func(from, to, end Position)) *Editor {
+	e.replaced = handler
+	return e
+}
+
+// SetMovedFunc sets a handler called whenever the cursor or selection moves
+func (e *Editor) SetMovedFunc(handler func()) *Editor {
+	e.moved = handler
+	return e
+}
+
+// SetText replaces the buffer content and moves the cursor to the start
+func (e *Editor) SetText(text string) *Editor {
+	e.buf.SetText(text)
+	return e
+}
+
+// GetText returns the full buffer content
+func (e *Editor) GetText() string {
+	return e.buf.Text()
+}
+
+// LineCount returns the number of lines in the buffer
+func (e *Editor) LineCount() int {
+	return len(e.buf.lines)
+}
+
+// Line returns the text of line n
+func (e *Editor) Line(n int) string {
+	if n < 0 || n >= len(e.buf.lines) {
+		return ""
+	}
+	return string(e.buf.lines[n])
+}
+
+// Cursor returns the current cursor position
+func (e *Editor) Cursor() Position {
+	return e.buf.cursor
+}
+
+// SetCursor moves the cursor, clearing any selection and other cursors
+func (e *Editor) SetCursor(pos Position) *Editor {
+	e.ClearCursors()
+	e.moveTo(e.clamp(pos), false)
+	return e
+}
+
+// Selection returns the selected range in buffer order; both ends are equal
+// when nothing is selected
+func (e *Editor) Selection() (Position, Position) {
+	return orderPositions(e.buf.anchor, e.buf.cursor)
+}
+
+// HasSelection reports whether any text is selected
+func (e *Editor) HasSelection() bool {
+	return e.buf.anchor != e.buf.cursor
+}
+
+// Select selects the text between from and to, leaving the cursor at to
+func (e *Editor) Select(from, to Position) *Editor {
+	e.buf.anchor = e.clamp(from)
+	e.buf.cursor = e.clamp(to)
+	e.buf.goalX = -1
+	e.trackCursor = true
+	e.notifyMoved()
+	return e
+}
+
+// SelectedText returns the currently selected text
+func (e *Editor) SelectedText() string {
+	from, to := e.Selection()
+	return e.TextRange(from, to)
+}
+
+// TextRange returns the text between two positions
+func (e *Editor) TextRange(from, to Position) string {
+	from, to = orderPositions(e.clamp(from), e.clamp(to))
+	if from.Line == to.Line {
+		return string(e.buf.lines[from.Line][from.Col:to.Col])
+	}
+	var b strings.Builder
+	b.WriteString(string(e.buf.lines[from.Line][from.Col:]))
+	for i := from.Line + 1; i < to.Line; i++ {
+		b.WriteByte('\n')
+		b.WriteString(string(e.buf.lines[i]))
+	}
+	b.WriteByte('\n')
+	b.WriteString(string(e.buf.lines[to.Line][:to.Col]))
+	return b.String()
+}
+
+// Replace replaces the text between from and to with text and returns the
+// position just after the inserted text. The cursor is left unchanged
+// relative to the surrounding text. Read-only buffers are left alone and the
+// cursor position is returned.
+func (e *Editor) Replace(from, to Position, text string) Position {
+	if e.buf.readOnly {
+		if e.refused != nil {
+			e.refused()
+		}
+		return e.buf.cursor
+	}
+	from, to = orderPositions(e.clamp(from), e.clamp(to))
+	e.buf.undo.record(edit{from: from, removed: e.TextRange(from, to), inserted: text}, e.buf.cursor, e.buf.anchor)
+	inserted := SplitLines(text)
+
+	prefix := e.buf.lines[from.Line][:from.Col]
+	suffix := e.buf.lines[to.Line][to.Col:]
+	end := Position{Line: from.Line + len(inserted) - 1, Col: len(inserted[len(inserted)-1])}
+	if len(inserted) == 1 {
+		end.Col += from.Col
+	}
+
+	first := append(append([]rune{}, prefix...), inserted[0]...)
+	inserted[0] = first
+	last := len(inserted) - 1
+	inserted[last] = append(inserted[last], suffix...)
+
+	lines := make([][]rune, 0, len(e.buf.lines)-(to.Line-from.Line)+len(inserted)-1)
+	lines = append(lines, e.buf.lines[:from.Line]...)
+	lines = append(lines, inserted...)
+	lines = append(lines, e.buf.lines[to.Line+1:]...)
+	e.buf.lines = lines
+
+	e.buf.cursor = ShiftPosition(e.buf.cursor, from, to, end)
+	e.buf.anchor = ShiftPosition(e.buf.anchor, from, to, end)
+	for i := range e.buf.extra {
+		s := &e.buf.extra[i]
+		s.cursor, s.anchor = ShiftPosition(s.cursor, from, to, end), ShiftPosition(s.anchor, from, to, end)
+	}
+	e.buf.shiftFolds(from, to, end)
+	e.buf.shiftRowOffset(from, to, end)
+	if e.replaced != nil {
+		e.replaced(from, to, end)
+	}
+	if e.buf.snippet != nil {
+		e.buf.snippet.shift(from, to, end)
+	}
+	e.buf.highlight.invalidate(from.Line)
+	e.buf.occurrences = nil
+	e.trackCursor = true
+	if !e.buf.undo.applying && !e.buf.undo.batching {
+		e.notifyChanged()
+	}
+	return end
+}
+
+// ShiftPosition maps a position across a replacement of [from, to) with text
+// ending at end
+func ShiftPosition(pos, from, to, end Position) Position {
+	switch {
+	case pos.Less(from):
+		return pos
+	case pos.Less(to):
+		return end
+	case pos.Line == to.Line:
+		return Position{Line: end.Line, Col: end.Col + pos.Col - to.Col}
+	default:
+		return Position{Line: pos.Line + end.Line - to.Line, Col: pos.Col}
+	}
+}
+
+// InsertText replaces the selection (if any) with text at the cursor
+func (e *Editor) InsertText(text string) {
+	from, to := e.Selection()
+	end := e.Replace(from, to, text)
+	e.moveTo(end, false)
+}
+
+// SplitLines splits text into rune lines; the result always has at least one line
+func SplitLines(text string) [][]rune {
+	parts := strings.Split(text, "\n")
+	lines := make([][]rune, len(parts))
+	for i, part := range parts {
+		lines[i] = []rune(part)
+	}
+	return lines
+}
+
+// clamp restricts a position to the bounds of the buffer
+func (e *Editor) clamp(pos Position) Position {
+	return e.buf.Clamp(pos)
+}
+
+// moveTo moves the cursor, extending the selection if requested
+func (e *Editor) moveTo(pos Position, extend bool) {
+	e.buf.cursor = pos
+	if !extend {
+		e.buf.anchor = pos
+	}
+	e.trackCursor = true
+	e.notifyMoved()
+}
+
+func (e *Editor) notifyChanged() {
+	if e.changed != nil {
+		e.changed()
+	}
+}
+
+func (e *Editor) notifyMoved() {
+	if e.moved != nil {
+		e.moved()
+	}
+}
+
+// runeWidth returns the number of cells r occupies at display column x
+func (e *Editor) runeWidth(r rune, x int) int {
+	if r == '\t' {
+		return e.tabWidth - x%e.tabWidth
+	}
+	return runewidth.RuneWidth(r)
+}
+
+// displayColumn returns the display column of rune column col on a line
+func (e *Editor) displayColumn(line []rune, col int) int {
+	x := 0
+	for i := 0; i < col && i < len(line); i++ {
+		x += e.runeWidth(line[i], x)
+	}
+	return x
+}
+
+// columnAt returns the rune column at display column x on a line
+func (e *Editor) columnAt(line []rune, x int) int {
+	cx := 0
+	for i, r := range line {
+		w := e.runeWidth(r, cx)
+		if cx+w > x {
+			return i
+		}
+		cx += w
+	}
+	return len(line)
+}
+
+// wordLeft returns the start of the word before pos
+func (e *Editor) wordLeft(pos Position) Position {
+	if pos.Col == 0 {
+		if pos.Line == 0 {
+			return pos
+		}
+		return Position{Line: pos.Line - 1, Col: len(e.buf.lines[pos.Line-1])}
+	}
+	line := e.buf.lines[pos.Line]
+	col := pos.Col
+	for col > 0 && unicode.IsSpace(line[col-1]) {
+		col--
+	}
+	if col > 0 && IsIdentPart(line[col-1]) {
+		for col > 0 && IsIdentPart(line[col-1]) {
+			col--
+		}
+	} else if col > 0 {
+		col--
+	}
+	return Position{Line: pos.Line, Col: col}
+}
+
+// wordRight returns the end of the word after pos
+func (e *Editor) wordRight(pos Position) Position {
+	line := e.buf.lines[pos.Line]
+	if pos.Col >= len(line) {
+		if pos.Line == len(e.buf.lines)-1 {
+			return pos
+		}
+		return Position{Line: pos.Line + 1}
+	}
+	col := pos.Col
+	for col < len(line) && unicode.IsSpace(line[col]) {
+		col++
+	}
+	if col < len(line) && IsIdentPart(line[col]) {
+		for col < len(line) && IsIdentPart(line[col]) {
+			col++
+		}
+	} else if col < len(line) {
+		col++
+	}
+	return Position{Line: pos.Line, Col: col}
+}
+
+// WordBounds returns the extent of the identifier at pos; both ends equal pos
+// when it is not on an identifier
+func (e *Editor) WordBounds(pos Position) (Position, Position) {
+	line := e.buf.lines[pos.Line]
+	start, end := pos.Col, pos.Col
+	for start > 0 && IsIdentPart(line[start-1]) {
+		start--
+	}
+	for end < len(line) && IsIdentPart(line[end]) {
+		end++
+	}
+	return Position{Line: pos.Line, Col: start}, Position{Line: pos.Line, Col: end}
+}
+
+// runeAt returns the rune at pos, or a newline at the end of a line
+func (e *Editor) runeAt(pos Position) rune {
+	if line := e.buf.lines[pos.Line]; pos.Col < len(line) {
+		return line[pos.Col]
+	}
+	return '\n'
+}
+
+// LastPosition returns the end of the buffer
+func (e *Editor) LastPosition() Position {
+	return e.clamp(Position{Line: len(e.buf.lines)})
+}
+
+// left returns the position one rune before pos
+func (e *Editor) left(pos Position) Position {
+	if pos.Col > 0 {
+		return Position{Line: pos.Line, Col: pos.Col - 1}
+	}
+	if pos.Line > 0 {
+		return Position{Line: pos.Line - 1, Col: len(e.buf.lines[pos.Line-1])}
+	}
+	return pos
+}
+
+// right returns the position one rune after pos
+func (e *Editor) right(pos Position) Position {
+	if pos.Col < len(e.buf.lines[pos.Line]) {
+		return Position{Line: pos.Line, Col: pos.Col + 1}
+	}
+	if pos.Line < len(e.buf.lines)-1 {
+		return Position{Line: pos.Line + 1}
+	}
+	return pos
+}
+
+// vertical moves the cursor by n rows, keeping the preferred display column.
+// Rows are lines unless they wrap.
+func (e *Editor) vertical(n int, extend bool) {
+	if e.wrapping() {
+		e.verticalRows(n, extend)
+		return
+	}
+	e.verticalLines(n, extend)
+}
+
+// verticalLines moves the cursor by n lines, keeping the preferred display
+// column
+func (e *Editor) verticalLines(n int, extend bool) {
+	if e.buf.goalX < 0 {
+		e.buf.goalX = e.displayColumn(e.buf.lines[e.buf.cursor.Line], e.buf.cursor.Col)
+	}
+	line := e.buf.moveVisible(e.buf.cursor.Line, n)
+	goal := e.buf.goalX
+	e.moveTo(Position{Line: line, Col: e.columnAt(e.buf.lines[line], goal)}, extend)
+	e.buf.goalX = goal
+}
+
+// deleteBackward deletes the selection or the rune (or word) before the cursor
+func (e *Editor) deleteBackward(word bool) {
+	from, to := e.Selection()
+	if from == to {
+		if word {
+			from = e.wordLeft(to)
+		} else {
+			from = e.left(to)
+		}
+	}
+	e.Replace(from, to, "")
+	e.moveTo(from, false)
+}
+
+// deleteForward deletes the selection or the rune (or word) after the cursor
+func (e *Editor) deleteForward(word bool) {
+	from, to := e.Selection()
+	if from == to {
+		if word {
+			to = e.wordRight(from)
+		} else {
+			to = e.right(from)
+		}
+	}
+	e.Replace(from, to, "")
+	e.moveTo(from, false)
+}
+
+// handleKey processes a key event and reports whether it was consumed
+func (e *Editor) handleKey(event *tcell.EventKey) bool {
+	if e.handlePopupKey(event) {
+		return true
+	}
+	if e.keymap != nil && e.keymap.HandleKey(e, event) {
+		e.FilterCompletions()
+		return true
+	}
+	var handled bool
+	if len(e.buf.extra) > 0 {
+		handled = e.handleCursorsKey(event)
+	} else {
+		handled = e.handleEditKey(event)
+	}
+	e.FilterCompletions()
+	return handled
+}
+
+// handleEditKey applies an editing or navigation key to the buffer
+func (e *Editor) handleEditKey(event *tcell.EventKey) bool {
+	extend := event.Modifiers()&tcell.ModShift != 0
+	word := event.Modifiers()&(tcell.ModCtrl|tcell.ModAlt) != 0
+	goal := -1
+
+	switch event.Key() {
+	case tcell.KeyRune:
+		if event.Modifiers()&tcell.ModAlt != 0 {
+			return false
+		}
+		e.typeRune(event.Rune())
+		return true
+	case tcell.KeyEnter:
+		e.newline()
+		return true
+	case tcell.KeyTab:
+		if !e.nextSnippetField(1) && !e.expandSnippet() {
+			e.insertTab()
+		}
+		return true
+	case tcell.KeyBacktab:
+		return e.nextSnippetField(-1)
+	case tcell.KeyEscape:
+		if e.buf.snippet == nil {
+			return false
+		}
+		e.buf.snippet = nil
+		return true
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if !word && e.deletePair() {
+			return true
+		}
+		e.deleteBackward(word)
+		return true
+	case tcell.KeyDelete:
+		e.deleteForward(word)
+		return true
+	case tcell.KeyCtrlZ:
+		e.Undo()
+		return true
+	case tcell.KeyCtrlY:
+		e.Redo()
+		return true
+	case tcell.KeyLeft:
+		if word {
+			e.moveTo(e.wordLeft(e.buf.cursor), extend)
+		} else if !extend && e.HasSelection() {
+			from, _ := e.Selection()
+			e.moveTo(from, false)
+		} else {
+			e.moveTo(e.buf.visiblePosition(e.left(e.buf.cursor), false), extend)
+		}
+	case tcell.KeyRight:
+		if word {
+			e.moveTo(e.wordRight(e.buf.cursor), extend)
+		} else if !extend && e.HasSelection() {
+			_, to := e.Selection()
+			e.moveTo(to, false)
+		} else {
+			e.moveTo(e.buf.visiblePosition(e.right(e.buf.cursor), true), extend)
+		}
+	case tcell.KeyUp:
+		e.vertical(-1, extend)
+		goal = e.buf.goalX
+	case tcell.KeyDown:
+		e.vertical(1, extend)
+		goal = e.buf.goalX
+	case tcell.KeyPgUp:
+		e.vertical(-e.page(), extend)
+		goal = e.buf.goalX
+	case tcell.KeyPgDn:
+		e.vertical(e.page(), extend)
+		goal = e.buf.goalX
+	case tcell.KeyHome:
+		if event.Modifiers()&tcell.ModCtrl != 0 {
+			e.moveTo(Position{}, extend)
+		} else {
+			e.moveTo(Position{Line: e.buf.cursor.Line, Col: e.indentEnd(e.buf.cursor)}, extend)
+		}
+	case tcell.KeyEnd:
+		if event.Modifiers()&tcell.ModCtrl != 0 {
+			e.moveTo(e.clamp(Position{Line: len(e.buf.lines)}), extend)
+		} else {
+			e.moveTo(Position{Line: e.buf.cursor.Line, Col: len(e.buf.lines[e.buf.cursor.Line])}, extend)
+		}
+	default:
+		return false
+	}
+	e.buf.goalX = goal
+	e.buf.undo.breakGroup()
+	return true
+}
+
+// indentEnd returns the column to jump to for Home: the first non-blank rune,
+// or the start of the line if the cursor is already there
+func (e *Editor) indentEnd(pos Position) int {
+	line := e.buf.lines[pos.Line]
+	col := 0
+	for col < len(line) && (line[col] == ' ' || line[col] == '\t') {
+		col++
+	}
+	if col == pos.Col {
+		return 0
+	}
+	return col
+}
+
+// page returns the number of lines moved by PgUp and PgDn
+func (e *Editor) page() int {
+	if e.pageHeight > 1 {
+		return e.pageHeight - 1
+	}
+	return 1
+}
+
+// InputHandler returns the handler for this primitive
+func (e *Editor) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return e.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		e.handleKey(event)
+	})
+}
+
+// PasteHandler returns the handler for this primitive
+func (e *Editor) PasteHandler() func(text string, setFocus func(p tview.Primitive)) {
+	return e.WrapPasteHandler(func(text string, setFocus func(p tview.Primitive)) {
+		e.Paste(strings.ReplaceAll(text, "\r\n", "\n"))
+	})
+}
+
+// positionAt converts screen coordinates into a buffer position
+func (e *Editor) positionAt(x, y int) Position {
+	rectX, rectY, _, _ := e.textRect()
+	line, index := e.moveRows(e.buf.rowOffset, e.buf.rowSkip, y-rectY)
+	row := e.lineRows(line)[index]
+	col := e.columnAt(e.buf.lines[line], e.rowShift(row)+x-rectX)
+	if col >= row.end && row.end < len(e.buf.lines[line]) {
+		col = row.end - 1
+	}
+	return Position{Line: line, Col: col}
+}
+
+// columnOf converts a screen column into a display column of the text
+func (e *Editor) columnOf(x int) int {
+	rectX, _, _, _ := e.textRect()
+	if column := e.buf.colOffset + x - rectX; column > 0 {
+		return column
+	}
+	return 0
+}
+
+// MouseHandler returns the mouse handler for this primitive
+func (e *Editor) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return e.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		x, y := event.Position()
+		inside := e.InRect(x, y)
+		if !inside && !e.dragging {
+			return false, nil
+		}
+
+		switch action {
+		case tview.MouseLeftDown:
+			setFocus(e)
+			e.completion, e.info = nil, ""
+			if textX, _, _, _ := e.textRect(); e.folding && x >= textX-foldColumnWidth && x < textX {
+				if line := e.positionAt(x, y).Line; e.foldMarker(line, e.buf.foldRanges(e.tabWidth)) != 0 {
+					e.ToggleFold(line)
+				}
+				return true, nil
+			}
+			if textX, _, _, _ := e.textRect(); x < textX && e.gutterClick != nil {
+				e.gutterClick(e.positionAt(x, y).Line, x < textX-e.gutterWidth()+e.annotationW)
+				return true, nil
+			}
+			e.buf.undo.breakGroup()
+			e.ClearCursors()
+			e.moveTo(e.positionAt(x, y), event.Modifiers()&tcell.ModShift != 0)
+			e.buf.goalX = -1
+			e.dragging = true
+			if e.columnDrag = event.Modifiers()&tcell.ModAlt != 0; e.columnDrag {
+				e.columnStart, e.columnX = e.buf.cursor, e.columnOf(x)
+			}
+			return true, e
+		case tview.MouseMove:
+			if e.dragging && e.columnDrag {
+				e.selectColumns(e.columnStart, e.columnX, e.positionAt(x, y).Line, e.columnOf(x))
+				return true, e
+			}
+			if e.dragging {
+				e.moveTo(e.positionAt(x, y), true)
+				return true, e
+			}
+		case tview.MouseLeftUp:
+			if e.dragging {
+				e.dragging = false
+				return true, nil
+			}
+		case tview.MouseLeftDoubleClick:
+			e.ClearCursors()
+			from, to := e.WordBounds(e.positionAt(x, y))
+			e.Select(from, to)
+			return true, nil
+		case tview.MouseScrollUp:
+			e.scroll(-3)
+			return true, nil
+		case tview.MouseScrollDown:
+			e.scroll(3)
+			return true, nil
+		}
+		return inside, nil
+	})
+}
+
+// scroll moves the viewport by n rows without moving the cursor
+func (e *Editor) scroll(n int) {
+	e.buf.rowOffset, e.buf.rowSkip = e.moveRows(e.buf.rowOffset, e.buf.rowSkip, n)
+	last := len(e.buf.lines) - 1
+	line, index := e.moveRows(last, len(e.wrapStarts(last))-1, 1-e.pageHeight)
+	if e.buf.rowOffset > line || e.buf.rowOffset == line && e.buf.rowSkip > index {
+		e.buf.rowOffset, e.buf.rowSkip = line, index
+	}
+}
+
+// cursorRow returns the screen row of the cursor, counted from the top of
+// the viewport; it is negative for a cursor above it
+func (e *Editor) cursorRow() int {
+	cursor := e.buf.cursor
+	if cursor.Line < e.buf.rowOffset {
+		return cursor.Line - e.buf.rowOffset
+	}
+	if !e.wrapping() {
+		return e.buf.visibleRows(e.buf.rowOffset, cursor.Line)
+	}
+	rows := e.rowIndex(cursor) - e.buf.rowSkip
+	for line := e.buf.rowOffset; line < cursor.Line; line = e.buf.moveVisible(line, 1) {
+		rows += len(e.wrapStarts(line))
+	}
+	return rows
+}
+
+// shiftRowOffset keeps the first line of the viewport across a replacement
+// of [from, to) with text ending at end. A first line the edit removes
+// gives way to the line the edit starts on.
+func (b *Buffer) shiftRowOffset(from, to, end Position) {
+	switch {
+	case b.rowOffset > to.Line:
+		b.rowOffset += end.Line - to.Line
+	case b.rowOffset > from.Line:
+		b.rowOffset, b.rowSkip = from.Line, 0
+	}
+}
+
+// scrollToCursor adjusts the viewport so the cursor is visible
+func (e *Editor) scrollToCursor(width, height int) {
+	e.buf.rowOffset = e.buf.foldedInto(e.buf.rowOffset)
+	if rows := len(e.wrapStarts(e.buf.rowOffset)); e.buf.rowSkip >= rows {
+		e.buf.rowSkip = rows - 1
+	}
+	cursor := e.buf.cursor
+	index := e.rowIndex(cursor)
+	if cursor.Line < e.buf.rowOffset || cursor.Line == e.buf.rowOffset && index < e.buf.rowSkip {
+		e.buf.rowOffset, e.buf.rowSkip = cursor.Line, index
+	}
+	if e.cursorRow() >= height {
+		e.buf.rowOffset, e.buf.rowSkip = e.moveRows(cursor.Line, index, 1-height)
+	}
+	if e.wrapping() {
+		e.buf.colOffset = 0
+		return
+	}
+	cx := e.displayColumn(e.buf.lines[e.buf.cursor.Line], e.buf.cursor.Col)
+	if cx < e.buf.colOffset {
+		e.buf.colOffset = cx
+	}
+	if cx >= e.buf.colOffset+width {
+		e.buf.colOffset = cx - width + 1
+	}
+}
+
+// Draw draws this primitive onto the screen
+func (e *Editor) Draw(screen tcell.Screen) {
+	e.Box.DrawForSubclass(screen, e)
+	x, y, width, height := e.textRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	e.pageHeight = height
+	if e.wrap && width != e.wrapWidth {
+		e.wrapWidth = width
+		e.trackCursor = true
+	}
+	if e.buf.hiddenBy(e.buf.cursor.Line) >= 0 {
+		// The cursor got into a fold by a jump or an undo
+		e.buf.reveal(e.buf.cursor.Line)
+		e.trackCursor = true
+	}
+	if e.trackCursor {
+		e.scrollToCursor(width, height)
+		e.trackCursor = false
+	}
+	e.highlightBrackets()
+	e.highlightOccurrences()
+	rows := e.displayRows(height)
+	e.drawGutter(screen, x-e.gutterWidth(), y, rows)
+
+	if len(e.buf.lines) == 1 && len(e.buf.lines[0]) == 0 && e.placeholder != "" {
+		fg, _, _ := e.placeholderStyle.Decompose()
+		tview.Print(screen, tview.Escape(e.placeholder), x, y, width, tview.AlignLeft, fg)
+	}
+
+	selected, cursors := e.cursorStyles()
+	for i, row := range rows {
+		e.drawLine(screen, row, x, y+i, width, selected, cursors)
+		if e.buf.foldAt(row.line) >= 0 && row.end == len(e.buf.lines[row.line]) {
+			e.drawFolded(screen, row, x, y+i, width)
+		}
+		if note, ok := e.notes[row.line]; ok && row.end == len(e.buf.lines[row.line]) {
+			e.drawNote(screen, row, note, x, y+i, width)
+		}
+	}
+
+	e.drawPopups(screen)
+
+	if e.keymap != nil {
+		innerX, innerY, innerWidth, innerHeight := e.GetInnerRect()
+		if innerHeight > height {
+			fillRow(screen, innerX, innerY+height, innerWidth, e.modeLineStyle)
+			printText(screen, e.keymap.Status(), innerX, innerY+height, innerWidth, e.modeLineStyle)
+		}
+	}
+
+	if e.HasFocus() {
+		cx := e.cursorColumn()
+		cy := e.cursorRow()
+		if cx >= 0 && cx < width && cy >= 0 && cy < height {
+			screen.ShowCursor(x+cx, y+cy)
+		}
+	}
+}
+
+// displayRows returns the rows of the viewport
+func (e *Editor) displayRows(height int) []displayRow {
+	var rows []displayRow
+	skip := e.buf.rowSkip
+	for n := e.buf.rowOffset; len(rows) < height && n < len(e.buf.lines); n = e.buf.moveVisible(n, 1) {
+		lineRows := e.lineRows(n)
+		if skip >= len(lineRows) {
+			skip = len(lineRows) - 1
+		}
+		for _, row := range lineRows[skip:] {
+			if len(rows) < height {
+				rows = append(rows, row)
+			}
+		}
+		skip = 0
+		if n == len(e.buf.lines)-1 {
+			break
+		}
+	}
+	return rows
+}
+
+// drawGutter draws the gutter marks of the lines shown on the rows
+func (e *Editor) drawGutter(screen tcell.Screen, x, y int, rows []displayRow) {
+	var ranges []lineFold
+	if e.folding {
+		ranges = e.buf.foldRanges(e.tabWidth)
+	}
+	for row, r := range rows {
+		if r.start > 0 {
+			// The gutter is blank next to the rest of a wrapped line
+			continue
+		}
+		n := r.line
+		if n < len(e.annotations) {
+			printText(screen, e.annotations[n], x, y+row, e.annotationW-1, e.lineNumberStyle)
+		}
+		signX := x + e.annotationW
+		for _, layer := range e.marks {
+			if mark, ok := layer.marks[n]; ok {
+				screen.SetContent(signX, y+row, mark.Rune, nil, e.textStyle.Foreground(mark.Color))
+			}
+		}
+		if e.lineNumbers {
+			style := e.lineNumberStyle
+			if n == e.buf.cursor.Line {
+				style = e.textStyle
+			}
+			digits := e.lineNumberWidth()
+			printText(screen, fmt.Sprintf("%*d", digits, n+1), signX+signColumnWidth, y+row, digits, style)
+		}
+		if marker := e.foldMarker(n, ranges); e.folding && marker != 0 {
+			screen.SetContent(x+e.gutterWidth()-foldColumnWidth, y+row, marker, nil, e.lineNumberStyle)
+		}
+	}
+}
+
+// drawFolded marks the end of the first line of a fold, shown on a row at
+// screen row y
+func (e *Editor) drawFolded(screen tcell.Screen, row displayRow, x, y, width int) {
+	line := e.buf.lines[row.line]
+	sx := e.displayColumn(line, len(line)) - e.rowShift(row) + 1
+	if sx >= 0 && sx < width {
+		screen.SetContent(x+sx, y, '⋯', nil, e.modeLineStyle)
+	}
+}
+
+// decorationSpan is the part of a decorated range that falls on one line
+type decorationSpan struct {
+	start, end int
+	style      func(tcell.Style) tcell.Style
+}
+
+// lineDecorations returns the decorated column spans of line n
+func (e *Editor) lineDecorations(n int) []decorationSpan {
+	var spans []decorationSpan
+	for _, layer := range e.decorations {
+		// Skip ranges ending before this line; they are sorted by start.
+		i := sort.Search(len(layer.ranges), func(i int) bool {
+			return layer.ranges[i].From.Line >= n
+		})
+		for i > 0 && layer.ranges[i-1].To.Line >= n {
+			i--
+		}
+		for ; i < len(layer.ranges) && layer.ranges[i].From.Line <= n; i++ {
+			r := layer.ranges[i]
+			if r.To.Line < n {
+				continue
+			}
+			span := decorationSpan{start: 0, end: len(e.buf.lines[n]) + 1, style: layer.style}
+			if r.From.Line == n {
+				span.start = r.From.Col
+			}
+			if r.To.Line == n {
+				span.end = r.To.Col
+			}
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// drawNote shows a note after the end of the last row of a line, past the
+// marker of a fold starting there
+func (e *Editor) drawNote(screen tcell.Screen, row displayRow, note LineNote, x, y, width int) {
+	line := e.buf.lines[row.line]
+	sx := e.displayColumn(line, len(line)) - e.rowShift(row) + 3
+	if sx < 0 || sx >= width {
+		return
+	}
+	printText(screen, note.Text, x+sx, y, width-sx, e.textStyle.Foreground(note.Color).Italic(true))
+}
+
+// drawLine draws buffer line n at screen row y, with the selected ranges
+// and the cursors other than the main one
+func (e *Editor) drawLine(screen tcell.Screen, row displayRow, x, y, width int, selected []Range, cursors map[Position]bool) {
+	n := row.line
+	var spans []Range
+	for _, r := range selected {
+		if r.From.Line <= n && n <= r.To.Line && r.From != r.To {
+			spans = append(spans, r)
+		}
+	}
+	isSelected := func(pos Position) bool {
+		for _, r := range spans {
+			if !pos.Less(r.From) && pos.Less(r.To) {
+				return true
+			}
+		}
+		return false
+	}
+	line := e.buf.lines[n]
+	tokens := e.buf.highlight.lineTokens(e.buf.lines, n)
+	decorations := e.lineDecorations(n)
+	trailing := len(line)
+	if e.invisibles {
+		trailing = trailingSpace(line)
+	}
+	cx, shift := 0, e.rowShift(row)
+	for col, r := range line {
+		w := e.runeWidth(r, cx)
+		if col < row.start {
+			cx += w
+			continue
+		}
+		if col >= row.end {
+			break
+		}
+		style := e.textStyle
+		for len(tokens) > 0 && tokens[0].End <= col {
+			tokens = tokens[1:]
+		}
+		if len(tokens) > 0 && tokens[0].Start <= col {
+			if color, ok := e.colors.Tokens[tokens[0].Kind]; ok {
+				style = style.Foreground(color)
+			}
+		}
+		for _, span := range decorations {
+			if col >= span.start && col < span.end {
+				style = span.style(style)
+			}
+		}
+		pos := Position{Line: n, Col: col}
+		if isSelected(pos) {
+			style = e.selectedStyle
+		}
+		if cursors[pos] {
+			style = style.Reverse(true)
+		}
+		glyph := r
+		if col >= trailing {
+			glyph = invisibleMark(r)
+			style = style.Foreground(e.colors.Muted)
+		}
+
+		sx := cx - shift
+		cx += w
+		if sx+w <= 0 {
+			continue
+		}
+		if sx >= width {
+			break
+		}
+		switch {
+		case w == 0:
+			if sx > 0 {
+				mainc, combc, prevStyle, _ := screen.GetContent(x+sx-1, y)
+				screen.SetContent(x+sx-1, y, mainc, append(combc, r), prevStyle)
+			}
+		case r == '\t' || sx < 0 || sx+w > width:
+			for i := 0; i < w; i++ {
+				if sx+i >= 0 && sx+i < width {
+					fill := ' '
+					if i == 0 && glyph != r {
+						fill = glyph
+					}
+					screen.SetContent(x+sx+i, y, fill, nil, style)
+				}
+			}
+		default:
+			screen.SetContent(x+sx, y, glyph, nil, style)
+		}
+	}
+	if row.end < len(line) {
+		return
+	}
+	end := Position{Line: n, Col: len(line)}
+	if sx := cx - shift; sx >= 0 && sx < width {
+		switch {
+		case cursors[end]:
+			screen.SetContent(x+sx, y, ' ', nil, e.textStyle.Reverse(true))
+		case isSelected(end):
+			screen.SetContent(x+sx, y, ' ', nil, e.selectedStyle)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package editor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		from, to Position
+		insert   string
+		want     string
+		end      Position
+	}{
+		{"insert", "hello world", Position{0, 5}, Position{0, 5}, ",", "hello, world", Position{0, 6}},
+		{"delete", "hello world", Position{0, 5}, Position{0, 11}, "", "hello", Position{0, 5}},
+		{"join lines", "one\ntwo", Position{0, 3}, Position{1, 0}, " ", "one two", Position{0, 4}},
+		{"split line", "onetwo", Position{0, 3}, Position{0, 3}, "\n", "one\ntwo", Position{1, 0}},
+		{"reversed range", "abc", Position{0, 2}, Position{0, 1}, "x", "axc", Position{0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEditor().SetText(tt.text)
+			end := e.Replace(tt.from, tt.to, tt.insert)
+			if got := e.GetText(); got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+			if end != tt.end {
+				t.Errorf("end = %v, want %v", end, tt.end)
+			}
+		})
+	}
+}
+
+func TestReplaceReadOnly(t *testing.T) {
+	e := NewEditor().SetText("text")
+	e.Buffer().SetReadOnly(true)
+	refused := false
+	e.SetRefusedFunc(func() { refused = true })
+	e.Replace(Position{}, Position{Col: 4}, "other")
+	if got := e.GetText(); got != "text" {
+		t.Errorf("text = %q, want it unchanged", got)
+	}
+	if !refused {
+		t.Error("refused handler was not called")
+	}
+}
+
+func TestReplaceShiftsCursor(t *testing.T) {
+	e := NewEditor().SetText("one\ntwo")
+	e.SetCursor(Position{Line: 1, Col: 2})
+	var replaced []Position
+	e.SetReplacedFunc(func(from, to, end Position) { replaced = []Position{from, to, end} })
+	e.Replace(Position{}, Position{}, "zero\n")
+	if got, want := e.Cursor(), (Position{Line: 2, Col: 2}); got != want {
+		t.Errorf("cursor = %v, want %v", got, want)
+	}
+	if want := []Position{{0, 0}, {0, 0}, {1, 0}}; !reflect.DeepEqual(replaced, want) {
+		t.Errorf("replaced = %v, want %v", replaced, want)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	e := NewEditor().SetText("abc")
+	e.Replace(Position{Col: 3}, Position{Col: 3}, "def")
+	if !e.Buffer().Modified() {
+		t.Error("buffer is not modified after an edit")
+	}
+	for e.Undo() {
+	}
+	if got := e.GetText(); got != "abc" {
+		t.Errorf("text after undo = %q, want %q", got, "abc")
+	}
+	if e.Buffer().Modified() {
+		t.Error("buffer is modified after undoing every edit")
+	}
+	for e.Redo() {
+	}
+	if got := e.GetText(); got != "abcdef" {
+		t.Errorf("text after redo = %q, want %q", got, "abcdef")
+	}
+}
+
+func TestShiftPosition(t *testing.T) {
+	// "one two" with "two" replaced by "2\n3"
+	from, to, end := Position{0, 4}, Position{0, 7}, Position{1, 1}
+	tests := []struct {
+		pos, want Position
+	}{
+		{Position{0, 2}, Position{0, 2}},
+		{Position{0, 5}, Position{1, 1}},
+		{Position{0, 7}, Position{1, 1}},
+		{Position{1, 3}, Position{2, 3}},
+	}
+	for _, tt := range tests {
+		if got := ShiftPosition(tt.pos, from, to, end); got != tt.want {
+			t.Errorf("ShiftPosition(%v) = %v, want %v", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	e := NewEditor().SetText("Go go\ngone")
+	want := []Range{
+		{From: Position{0, 0}, To: Position{0, 2}},
+		{From: Position{0, 3}, To: Position{0, 5}},
+		{From: Position{1, 0}, To: Position{1, 2}},
+	}
+	if got := e.FindAll("go"); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAll(go) = %v, want %v", got, want)
+	}
+	if got := e.FindAll("Go"); len(got) != 1 {
+		t.Errorf("FindAll(Go) = %v, want one match", got)
+	}
+	if got := e.FindAll(""); got != nil {
+		t.Errorf("FindAll() = %v, want no matches", got)
+	}
+}
+
+func TestReindent(t *testing.T) {
+	e := NewEditor().SetText("func() {\n\tx\n\t\ty\n\n}")
+	e.SetInsertSpaces(true)
+	if got := e.Reindent(); got != 2 {
+		t.Errorf("Reindent() = %d, want 2", got)
+	}
+	if got, want := e.GetText(), "func() {\n    x\n        y\n\n}"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
+
+func TestReplaceBelowViewport(t *testing.T) {
+	lines := make([]string, 30)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	e := NewEditor().SetText(strings.Join(lines, "\n")).SetWrap(true)
+	e.SetRect(0, 0, 20, 5)
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(20, 5)
+	e.Draw(screen)
+	e.SetCursor(Position{Line: 29})
+	e.Draw(screen)
+
+	// Deleting lines 2 to 30 leaves the first line of the viewport past the
+	// end of the text
+	e.Replace(Position{Line: 0, Col: 4}, Position{Line: 29, Col: 4}, "")
+	e.Draw(screen)
+	if got := e.GetText(); got != "line" {
+		t.Errorf("text = %q, want %q", got, "line")
+	}
+}
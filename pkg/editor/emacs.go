@@ -0,0 +1,300 @@
+package editor
+
+import "github.com/gdamore/tcell/v2"
+
+// emacsKillRingSize bounds the number of remembered kills
+const emacsKillRingSize = 60
+
+// EmacsKeymap implements the common Emacs editing chords: cursor motion,
+// the mark and region, and a kill ring with yank and yank-pop
+type EmacsKeymap struct {
+	prefix  bool // C-x was pressed
+	marking bool // the mark is active and motions extend the region
+	message string
+
+	kills    []string
+	yankFrom Position // extent of the last yank, for M-y
+	yankTo   Position
+	yanked   int // kill ring index of the last yank
+
+	// last is the kind of the previous command, so consecutive kills are
+	// merged and M-y only follows a yank
+	last string
+
+	// run performs application actions bound to chords, such as "save"
+	run func(action string) error
+}
+
+// NewEmacsKeymap returns an Emacs keymap
+func NewEmacsKeymap(run func(action string) error) *EmacsKeymap {
+	return &EmacsKeymap{run: run}
+}
+
+// Inserting reports whether typed characters are inserted as text
+func (m *EmacsKeymap) Inserting() bool {
+	return true
+}
+
+// Status returns the mode line text
+func (m *EmacsKeymap) Status() string {
+	switch {
+	case m.prefix:
+		return "Emacs  C-x-"
+	case m.message != "":
+		return "Emacs  " + m.message
+	}
+	return "Emacs"
+}
+
+// Mode returns "", Emacs editing has no modes
+func (m *EmacsKeymap) Mode() string {
+	return ""
+}
+
+// ctrlRunes maps the control keys used by the keymap to their letters
+var ctrlRunes = map[tcell.Key]rune{
+	tcell.KeyCtrlA: 'a', tcell.KeyCtrlB: 'b', tcell.KeyCtrlC: 'c', tcell.KeyCtrlD: 'd',
+	tcell.KeyCtrlE: 'e', tcell.KeyCtrlF: 'f', tcell.KeyCtrlG: 'g', tcell.KeyCtrlK: 'k',
+	tcell.KeyCtrlN: 'n', tcell.KeyCtrlO: 'o', tcell.KeyCtrlP: 'p', tcell.KeyCtrlR: 'r',
+	tcell.KeyCtrlS: 's', tcell.KeyCtrlV: 'v', tcell.KeyCtrlW: 'w', tcell.KeyCtrlX: 'x',
+	tcell.KeyCtrlY: 'y', tcell.KeyCtrlSpace: ' ', tcell.KeyCtrlUnderscore: '_',
+}
+
+// Reserved reports whether the keymap takes over a key that would otherwise
+// trigger a global binding
+func (m *EmacsKeymap) Reserved(event *tcell.EventKey) bool {
+	if m.prefix {
+		return true
+	}
+	_, ok := ctrlRunes[event.Key()]
+	return ok || event.Key() == tcell.KeyRune && event.Modifiers()&tcell.ModAlt != 0
+}
+
+// HandleKey interprets Emacs chords
+func (m *EmacsKeymap) HandleKey(e *Editor, event *tcell.EventKey) bool {
+	m.message = ""
+	last := m.last
+	m.last = ""
+	if m.prefix {
+		m.prefix = false
+		m.handlePrefix(e, event)
+		return true
+	}
+
+	if event.Key() == tcell.KeyRune && event.Modifiers()&tcell.ModAlt != 0 {
+		return m.handleMeta(e, event.Rune(), last)
+	}
+	if event.Key() == tcell.KeyBackspace || event.Key() == tcell.KeyBackspace2 {
+		if event.Modifiers()&tcell.ModAlt != 0 {
+			m.kill(e, e.wordLeft(e.buf.cursor), e.buf.cursor, last, true)
+			return true
+		}
+		return false
+	}
+	key, ok := ctrlRunes[event.Key()]
+	if !ok {
+		if m.marking && event.Key() == tcell.KeyRune {
+			// Typing deactivates the mark rather than replacing the region
+			e.moveTo(e.buf.cursor, false)
+		}
+		m.marking = false
+		return false
+	}
+
+	cursor := e.buf.cursor
+	line := e.buf.lines[cursor.Line]
+	switch key {
+	case 'a':
+		m.move(e, Position{Line: cursor.Line})
+	case 'e':
+		m.move(e, Position{Line: cursor.Line, Col: len(line)})
+	case 'f':
+		m.move(e, e.right(cursor))
+	case 'b':
+		m.move(e, e.left(cursor))
+	case 'n', 'p':
+		n := 1
+		if key == 'p' {
+			n = -1
+		}
+		e.vertical(n, m.marking)
+		return true
+	case 'v':
+		e.vertical(e.page(), m.marking)
+		return true
+	case 'd':
+		e.Replace(cursor, e.right(cursor), "")
+	case 'k':
+		to := Position{Line: cursor.Line, Col: len(line)}
+		if cursor.Col == len(line) {
+			to = e.right(cursor)
+		}
+		m.kill(e, cursor, to, last, false)
+	case 'w':
+		if from, to := e.Selection(); from != to {
+			m.kill(e, from, to, "", false)
+		}
+	case 'y':
+		m.yank(e)
+	case 'o':
+		e.Replace(cursor, cursor, "\n")
+		e.moveTo(cursor, false)
+	case ' ':
+		m.marking = true
+		e.buf.anchor = cursor
+		m.message = "Mark set"
+	case 'g':
+		m.marking = false
+		e.moveTo(cursor, false)
+		m.message = "Quit"
+	case '_':
+		e.Undo()
+	case 'x':
+		m.prefix = true
+	case 's', 'r':
+		m.action("find")
+	default:
+		return false
+	}
+	e.buf.undo.breakGroup()
+	return true
+}
+
+// handleMeta interprets M- chords
+func (m *EmacsKeymap) handleMeta(e *Editor, r rune, last string) bool {
+	cursor := e.buf.cursor
+	switch r {
+	case 'f':
+		m.move(e, e.wordRight(cursor))
+	case 'b':
+		m.move(e, e.wordLeft(cursor))
+	case '<':
+		m.move(e, Position{})
+	case '>':
+		m.move(e, e.LastPosition())
+	case 'v':
+		e.vertical(-e.page(), m.marking)
+	case 'd':
+		m.kill(e, cursor, e.wordRight(cursor), last, false)
+	case 'w':
+		if from, to := e.Selection(); from != to {
+			m.push(e.TextRange(from, to), "", false)
+			m.marking = false
+			e.moveTo(cursor, false)
+			m.message = "Copied region"
+		}
+	case 'y':
+		m.yankPop(e, last)
+	case 'g':
+		m.action("goto-line")
+	case '/':
+		m.action("complete")
+	default:
+		return false
+	}
+	e.buf.undo.breakGroup()
+	return true
+}
+
+// handlePrefix interprets the key following C-x
+func (m *EmacsKeymap) handlePrefix(e *Editor, event *tcell.EventKey) {
+	switch key, _ := ctrlRunes[event.Key()]; {
+	case key == 's':
+		m.action("save")
+	case key == 'c':
+		m.action("quit")
+	case key == 'f':
+		m.action("find-file")
+	case event.Key() != tcell.KeyRune:
+		m.message = "C-x is undefined for this key"
+	case event.Rune() == 'k':
+		m.action("close")
+	case event.Rune() == 'b':
+		m.action("next-buffer")
+	case event.Rune() == 'u':
+		e.Undo()
+	case event.Rune() == 'h':
+		e.Select(Position{}, e.LastPosition())
+		m.marking = true
+	default:
+		m.message = "C-x " + string(event.Rune()) + " is undefined"
+	}
+}
+
+// action runs an application action, showing any error on the mode line
+func (m *EmacsKeymap) action(name string) {
+	if m.run == nil {
+		return
+	}
+	if err := m.run(name); err != nil {
+		m.message = err.Error()
+	}
+}
+
+// move moves the cursor, extending the region while the mark is active
+func (m *EmacsKeymap) move(e *Editor, pos Position) {
+	e.moveTo(pos, m.marking)
+	e.buf.goalX = -1
+}
+
+// kill deletes the text between from and to into the kill ring. Kills
+// directly following another kill are merged into one entry.
+func (m *EmacsKeymap) kill(e *Editor, from, to Position, last string, backward bool) {
+	from, to = orderPositions(from, to)
+	if from == to {
+		return
+	}
+	m.push(e.TextRange(from, to), last, backward)
+	e.Replace(from, to, "")
+	e.moveTo(from, false)
+	m.marking = false
+	m.last = "kill"
+}
+
+// push adds text to the kill ring, appending to the newest entry when the
+// previous command was a kill
+func (m *EmacsKeymap) push(text, last string, backward bool) {
+	if last == "kill" && len(m.kills) > 0 {
+		n := len(m.kills) - 1
+		if backward {
+			m.kills[n] = text + m.kills[n]
+		} else {
+			m.kills[n] += text
+		}
+		return
+	}
+	m.kills = append(m.kills, text)
+	if len(m.kills) > emacsKillRingSize {
+		m.kills = m.kills[len(m.kills)-emacsKillRingSize:]
+	}
+}
+
+// yank inserts the newest kill at the cursor
+func (m *EmacsKeymap) yank(e *Editor) {
+	if len(m.kills) == 0 {
+		m.message = "Kill ring is empty"
+		return
+	}
+	m.yanked = len(m.kills) - 1
+	m.insertYank(e, e.buf.cursor, e.buf.cursor)
+}
+
+// yankPop replaces the text just yanked with the previous kill
+func (m *EmacsKeymap) yankPop(e *Editor, last string) {
+	if last != "yank" {
+		m.message = "Previous command was not a yank"
+		return
+	}
+	m.yanked = (m.yanked + len(m.kills) - 1) % len(m.kills)
+	m.insertYank(e, m.yankFrom, m.yankTo)
+}
+
+// insertYank replaces from..to with the selected kill
+func (m *EmacsKeymap) insertYank(e *Editor, from, to Position) {
+	text := m.kills[m.yanked]
+	end := e.Replace(from, to, text)
+	e.moveTo(end, false)
+	m.yankFrom, m.yankTo = from, end
+	m.marking = false
+	m.last = "yank"
+}
@@ -0,0 +1,349 @@
+package editor
+
+import (
+	"go/scanner"
+	"go/token"
+	"sort"
+)
+
+// foldColumnWidth is the width of the gutter column showing fold markers
+const foldColumnWidth = 2
+
+// lineFold is a range of lines that can fold into its first line, which
+// stays visible while the lines after it up to end are hidden
+type lineFold struct {
+	start, end int
+}
+
+// SetFolding shows or hides the fold markers in the gutter. Clicking a
+// marker folds or unfolds the lines below it.
+func (e *Editor) SetFolding(on bool) *Editor {
+	e.folding = on
+	return e
+}
+
+// foldRanges returns the ranges of the buffer that can be folded, sorted by
+// their first line: blocks and comments in Go files, and indented runs of
+// lines in other files. They are computed again after an edit.
+func (b *Buffer) foldRanges(tabWidth int) []lineFold {
+	if b.foldable == nil || b.foldableWidth != tabWidth {
+		if FileLanguage(b.path) == "go" {
+			b.foldable = goFoldRanges([]byte(b.Text()))
+		} else {
+			b.foldable = indentFoldRanges(b.lines, tabWidth)
+		}
+		b.foldableWidth = tabWidth
+	}
+	return b.foldable
+}
+
+// goFoldRanges finds the brackets and comments of Go source that span
+// several lines. The closing bracket stays visible when it starts a line.
+func goFoldRanges(src []byte) []lineFold {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	type open struct {
+		tok  token.Token
+		line int
+	}
+	var (
+		stack    []open
+		ranges   []lineFold
+		previous = -1 // line of the previous token
+		comments = lineFold{start: -1}
+	)
+	closing := map[token.Token]token.Token{token.RBRACE: token.LBRACE, token.RPAREN: token.LPAREN, token.RBRACK: token.LBRACK}
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		line := file.Line(pos) - 1
+		first := line > previous
+		switch tok {
+		case token.COMMENT:
+			last := line
+			for _, c := range lit {
+				if c == '\n' {
+					last++
+				}
+			}
+			// Comments alone on consecutive lines fold together
+			if first && comments.start >= 0 && line == comments.end+1 {
+				comments.end = last
+				previous = last
+				continue
+			}
+			if comments.start >= 0 && comments.end > comments.start {
+				ranges = append(ranges, comments)
+			}
+			comments = lineFold{start: -1}
+			if first {
+				comments = lineFold{start: line, end: last}
+			} else if last > line {
+				ranges = append(ranges, lineFold{start: line, end: last})
+			}
+			previous = last
+			continue
+		case token.LBRACE, token.LPAREN, token.LBRACK:
+			stack = append(stack, open{tok: tok, line: line})
+		case token.RBRACE, token.RPAREN, token.RBRACK:
+			if n := len(stack); n > 0 && stack[n-1].tok == closing[tok] {
+				start := stack[n-1].line
+				stack = stack[:n-1]
+				end := line
+				if first {
+					end--
+				}
+				if end > start {
+					ranges = append(ranges, lineFold{start: start, end: end})
+				}
+			}
+		}
+		previous = line
+	}
+	if comments.start >= 0 && comments.end > comments.start {
+		ranges = append(ranges, comments)
+	}
+	return sortFolds(ranges)
+}
+
+// indentFoldRanges finds the lines followed by lines indented deeper than
+// them. Blank lines at the end of such a run are not part of it.
+func indentFoldRanges(lines [][]rune, tabWidth int) []lineFold {
+	type open struct {
+		line, indent int
+	}
+	var (
+		stack    []open
+		ranges   []lineFold
+		lastText = -1 // last line that is not blank
+	)
+	closeTo := func(indent int) {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if lastText > top.line {
+				ranges = append(ranges, lineFold{start: top.line, end: lastText})
+			}
+		}
+	}
+	for n, line := range lines {
+		blanks := LeadingSpace(line)
+		if len(blanks) == len(line) {
+			continue
+		}
+		indent := 0
+		for _, r := range blanks {
+			if r == '\t' {
+				indent += tabWidth - indent%tabWidth
+			} else {
+				indent++
+			}
+		}
+		closeTo(indent)
+		stack = append(stack, open{line: n, indent: indent})
+		lastText = n
+	}
+	closeTo(0)
+	return sortFolds(ranges)
+}
+
+// sortFolds sorts ranges by their first line, keeping the longest of those
+// starting on the same line
+func sortFolds(ranges []lineFold) []lineFold {
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].start != ranges[j].start {
+			return ranges[i].start < ranges[j].start
+		}
+		return ranges[i].end > ranges[j].end
+	})
+	unique := ranges[:0]
+	for _, r := range ranges {
+		if len(unique) == 0 || unique[len(unique)-1].start != r.start {
+			unique = append(unique, r)
+		}
+	}
+	if unique == nil {
+		// An empty cache is not a missing one
+		return []lineFold{}
+	}
+	return unique
+}
+
+// hiddenBy returns the index of a fold hiding line, or -1 if it is visible
+func (b *Buffer) hiddenBy(line int) int {
+	for i, f := range b.folds {
+		if f.start >= line {
+			break
+		}
+		if line <= f.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// foldAt returns the index of the fold starting at line, or -1
+func (b *Buffer) foldAt(line int) int {
+	for i, f := range b.folds {
+		if f.start == line {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveVisible returns the line n visible lines below line, or above it for
+// a negative n, stopping at the first and last line
+func (b *Buffer) moveVisible(line, n int) int {
+	for ; n > 0; n-- {
+		next := line + 1
+		for i := b.hiddenBy(next); i >= 0; i = b.hiddenBy(next) {
+			next = b.folds[i].end + 1
+		}
+		if next >= len(b.lines) {
+			break
+		}
+		line = next
+	}
+	for ; n < 0 && line > 0; n++ {
+		line = b.foldedInto(line - 1)
+	}
+	return line
+}
+
+// visibleRows returns the number of visible lines from line from up to,
+// but not including, line to
+func (b *Buffer) visibleRows(from, to int) int {
+	rows := 0
+	for line := from; line < to; line = b.moveVisible(line, 1) {
+		rows++
+		if line == len(b.lines)-1 {
+			break
+		}
+	}
+	return rows
+}
+
+// visiblePosition moves a position on a hidden line past the folds hiding
+// it, forward to the next visible line or back to the end of the fold's
+// first line
+func (b *Buffer) visiblePosition(pos Position, forward bool) Position {
+	i := b.hiddenBy(pos.Line)
+	if i < 0 {
+		return pos
+	}
+	if forward {
+		if line := b.moveVisible(pos.Line, 1); line != pos.Line {
+			return Position{Line: line}
+		}
+	}
+	line := b.foldedInto(pos.Line)
+	return Position{Line: line, Col: len(b.lines[line])}
+}
+
+// foldedInto returns the visible line a line is folded into, or the line
+// itself if it is visible
+func (b *Buffer) foldedInto(line int) int {
+	for i := b.hiddenBy(line); i >= 0; i = b.hiddenBy(line) {
+		line = b.folds[i].start
+	}
+	return line
+}
+
+// reveal unfolds the folds hiding line
+func (b *Buffer) reveal(line int) {
+	kept := b.folds[:0]
+	for _, f := range b.folds {
+		if f.start >= line || line > f.end {
+			kept = append(kept, f)
+		}
+	}
+	b.folds = kept
+}
+
+// shiftFolds maps the folds across a replacement of [from, to) with text
+// ending at end. Folds whose hidden lines the edit touches are unfolded.
+func (b *Buffer) shiftFolds(from, to, end Position) {
+	b.foldable = nil
+	delta := end.Line - to.Line
+	kept := b.folds[:0]
+	for _, f := range b.folds {
+		switch {
+		case from.Line > f.end:
+		case to.Line < f.start:
+			f.start += delta
+			f.end += delta
+		case from.Line == f.start && to.Line == f.start && end.Line == f.start:
+			// An edit within the first line keeps the fold
+		default:
+			continue
+		}
+		kept = append(kept, f)
+	}
+	b.folds = kept
+}
+
+// setFolds replaces the folds and moves the cursor out of hidden lines, to
+// the first line of the fold hiding it
+func (e *Editor) setFolds(folds []lineFold) {
+	e.buf.folds = folds
+	if line := e.buf.foldedInto(e.buf.cursor.Line); line != e.buf.cursor.Line {
+		e.moveTo(Position{Line: line, Col: len(e.buf.lines[line])}, false)
+	}
+	e.trackCursor = true
+}
+
+// ToggleFold folds the innermost foldable range around line, or unfolds the
+// fold starting at line. It reports whether there was anything to fold.
+func (e *Editor) ToggleFold(line int) bool {
+	if i := e.buf.foldAt(line); i >= 0 {
+		e.buf.folds = append(e.buf.folds[:i], e.buf.folds[i+1:]...)
+		return true
+	}
+	found := lineFold{start: -1}
+	for _, r := range e.buf.foldRanges(e.tabWidth) {
+		if r.start > line {
+			break
+		}
+		if line <= r.end {
+			found = r
+		}
+	}
+	if found.start < 0 {
+		return false
+	}
+	folds := append(append([]lineFold{}, e.buf.folds...), found)
+	e.setFolds(sortFolds(folds))
+	return true
+}
+
+// FoldAll folds every foldable range of the buffer
+func (e *Editor) FoldAll() {
+	e.setFolds(append([]lineFold{}, e.buf.foldRanges(e.tabWidth)...))
+}
+
+// UnfoldAll shows the lines of every fold
+func (e *Editor) UnfoldAll() {
+	e.setFolds(nil)
+}
+
+// foldMarker returns the gutter marker of a line: one for a fold, one for
+// a foldable range, or 0 for neither
+func (e *Editor) foldMarker(line int, ranges []lineFold) rune {
+	if e.buf.foldAt(line) >= 0 {
+		return '▸'
+	}
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].start >= line })
+	if i < len(ranges) && ranges[i].start == line {
+		return '▾'
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package editor
+
+import "strings"
+
+// SetInsertSpaces sets whether Tab and new indentation levels insert
+// spaces up to the next tab stop rather than a tab
+func (e *Editor) SetInsertSpaces(on bool) *Editor {
+	e.insertSpaces = on
+	return e
+}
+
+// InsertSpaces reports whether Tab and new indentation levels insert spaces
+func (e *Editor) InsertSpaces() bool {
+	return e.insertSpaces
+}
+
+// insertTab inserts a tab at the cursor, or with insert spaces set, the
+// spaces up to the next tab stop
+func (e *Editor) insertTab() {
+	if !e.insertSpaces {
+		e.InsertText("\t")
+		return
+	}
+	from, _ := e.Selection()
+	x := e.displayColumn(e.buf.lines[from.Line], from.Col)
+	e.InsertText(strings.Repeat(" ", e.tabWidth-x%e.tabWidth))
+}
+
+// indentation returns the indentation of the given display width in the
+// editor's style: in spaces, or in tabs followed by the spaces left over
+func (e *Editor) indentation(width int) string {
+	if e.insertSpaces {
+		return strings.Repeat(" ", width)
+	}
+	return strings.Repeat("\t", width/e.tabWidth) + strings.Repeat(" ", width%e.tabWidth)
+}
+
+// Reindent rewrites the indentation of every line in the current style,
+// keeping its width, and returns the number of lines changed
+func (e *Editor) Reindent() int {
+	changed := 0
+	e.Transaction(func() {
+		for n, line := range e.buf.lines {
+			indent := LeadingSpace(line)
+			if len(indent) == len(line) {
+				// Blank lines keep their whitespace
+				continue
+			}
+			text := e.indentation(e.displayColumn(line, len(indent)))
+			if text != string(indent) {
+				e.Replace(Position{Line: n}, Position{Line: n, Col: len(indent)}, text)
+				changed++
+			}
+		}
+	})
+	return changed
+}
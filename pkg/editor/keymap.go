@@ -0,0 +1,20 @@
+package editor
+
+import "github.com/gdamore/tcell/v2"
+
+// Keymap interprets key presses for an editor ahead of its default
+// bindings, allowing modal or alternative editing schemes
+type Keymap interface {
+	// HandleKey reports whether the key was consumed
+	HandleKey(e *Editor, event *tcell.EventKey) bool
+	// Status returns the text shown on the editor's mode line
+	Status() string
+	// Mode names the current mode for the status bar, "" if the keymap has
+	// no modes
+	Mode() string
+	// Inserting reports whether typed characters are inserted as text
+	Inserting() bool
+	// Reserved reports whether the keymap takes over a key from the global
+	// bindings while the editor has focus
+	Reserved(event *tcell.EventKey) bool
+}
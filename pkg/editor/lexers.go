@@ -0,0 +1,349 @@
+package editor
+
+import "unicode"
+
+// Lexer states shared by the built-in lexers
+const (
+	stateNormal = iota
+	stateBlockComment
+	stateRawString
+	stateSingleQuote
+	stateDoubleQuote
+	stateCodeFence
+)
+
+var goKeywords = wordSet("break", "case", "chan", "const", "continue", "default", "defer",
+	"else", "fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+	"map", "package", "range", "return", "select", "struct", "switch", "type", "var")
+
+var goPredeclared = wordSet("bool", "byte", "complex64", "complex128", "error", "float32",
+	"float64", "int", "int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8",
+	"uint16", "uint32", "uint64", "uintptr", "any", "comparable",
+	"true", "false", "iota", "nil")
+
+var shellKeywords = wordSet("if", "then", "else", "elif", "fi", "for", "while", "until", "do",
+	"done", "case", "esac", "in", "function", "select", "return", "break", "continue",
+	"export", "local", "readonly", "declare", "unset", "shift", "source", "exit")
+
+// wordSet builds a lookup set from a list of words
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// IsIdentPart reports whether r can be part of an identifier
+func IsIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// scanIdent returns the end of the identifier starting at i
+func scanIdent(line []rune, i int) int {
+	for i < len(line) && IsIdentPart(line[i]) {
+		i++
+	}
+	return i
+}
+
+// scanNumber returns the end of the numeric literal starting at i
+func scanNumber(line []rune, i int) int {
+	for i < len(line) && (IsIdentPart(line[i]) || line[i] == '.') {
+		i++
+	}
+	return i
+}
+
+// scanQuoted returns the end of a quoted string starting at i, honoring
+// backslash escapes, and whether the closing quote was found
+func scanQuoted(line []rune, i int, quote rune) (int, bool) {
+	for i++; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1, true
+		}
+	}
+	return len(line), false
+}
+
+// scanUntil returns the index just past the first occurrence of delim at or
+// after i, or len(line) and false if there is none
+func scanUntil(line []rune, i int, delim string) (int, bool) {
+	d := []rune(delim)
+	for ; i+len(d) <= len(line); i++ {
+		if string(line[i:i+len(d)]) == delim {
+			return i + len(d), true
+		}
+	}
+	return len(line), false
+}
+
+// nextNonSpace returns the first non-space rune at or after i, or 0
+func nextNonSpace(line []rune, i int) rune {
+	for ; i < len(line); i++ {
+		if !unicode.IsSpace(line[i]) {
+			return line[i]
+		}
+	}
+	return 0
+}
+
+// goLexer highlights Go source code
+type goLexer struct{}
+
+// Lex implements Lexer
+func (goLexer) Lex(line []rune, state int) ([]Token, int) {
+	var tokens []Token
+	i := 0
+	switch state {
+	case stateBlockComment:
+		end, closed := scanUntil(line, 0, "*/")
+		tokens = append(tokens, Token{0, end, TokenComment})
+		if !closed {
+			return tokens, stateBlockComment
+		}
+		i = end
+	case stateRawString:
+		end, closed := scanUntil(line, 0, "`")
+		tokens = append(tokens, Token{0, end, TokenString})
+		if !closed {
+			return tokens, stateRawString
+		}
+		i = end
+	}
+	for i < len(line) {
+		r := line[i]
+		switch {
+		case r == '/' && i+1 < len(line) && line[i+1] == '/':
+			return append(tokens, Token{i, len(line), TokenComment}), stateNormal
+		case r == '/' && i+1 < len(line) && line[i+1] == '*':
+			end, closed := scanUntil(line, i+2, "*/")
+			tokens = append(tokens, Token{i, end, TokenComment})
+			if !closed {
+				return tokens, stateBlockComment
+			}
+			i = end
+		case r == '`':
+			end, closed := scanUntil(line, i+1, "`")
+			tokens = append(tokens, Token{i, end, TokenString})
+			if !closed {
+				return tokens, stateRawString
+			}
+			i = end
+		case r == '"' || r == '\'':
+			end, _ := scanQuoted(line, i, r)
+			tokens = append(tokens, Token{i, end, TokenString})
+			i = end
+		case unicode.IsDigit(r):
+			end := scanNumber(line, i)
+			tokens = append(tokens, Token{i, end, TokenNumber})
+			i = end
+		case isIdentStart(r):
+			end := scanIdent(line, i)
+			word := string(line[i:end])
+			switch {
+			case goKeywords[word]:
+				tokens = append(tokens, Token{i, end, TokenKeyword})
+			case goPredeclared[word]:
+				tokens = append(tokens, Token{i, end, TokenType})
+			case nextNonSpace(line, end) == '(':
+				tokens = append(tokens, Token{i, end, TokenFunction})
+			}
+			i = end
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			tokens = append(tokens, Token{i, i + 1, TokenOperator})
+			i++
+		default:
+			i++
+		}
+	}
+	return tokens, stateNormal
+}
+
+// jsonLexer highlights JSON documents
+type jsonLexer struct{}
+
+// Lex implements Lexer
+func (jsonLexer) Lex(line []rune, state int) ([]Token, int) {
+	var tokens []Token
+	for i := 0; i < len(line); {
+		r := line[i]
+		switch {
+		case r == '"':
+			end, _ := scanQuoted(line, i, '"')
+			kind := TokenString
+			if nextNonSpace(line, end) == ':' {
+				kind = TokenKeyword
+			}
+			tokens = append(tokens, Token{i, end, kind})
+			i = end
+		case r == '-' || unicode.IsDigit(r):
+			end := scanNumber(line, i+1)
+			tokens = append(tokens, Token{i, end, TokenNumber})
+			i = end
+		case unicode.IsLetter(r):
+			end := scanIdent(line, i)
+			switch string(line[i:end]) {
+			case "true", "false", "null":
+				tokens = append(tokens, Token{i, end, TokenType})
+			}
+			i = end
+		case r == '{' || r == '}' || r == '[' || r == ']' || r == ':' || r == ',':
+			tokens = append(tokens, Token{i, i + 1, TokenOperator})
+			i++
+		default:
+			i++
+		}
+	}
+	return tokens, stateNormal
+}
+
+// markdownLexer highlights Markdown documents
+type markdownLexer struct{}
+
+// Lex implements Lexer
+func (markdownLexer) Lex(line []rune, state int) ([]Token, int) {
+	trimmed := 0
+	for trimmed < len(line) && line[trimmed] == ' ' {
+		trimmed++
+	}
+	fence := len(line)-trimmed >= 3 && string(line[trimmed:trimmed+3]) == "```"
+	if state == stateCodeFence {
+		if fence {
+			return []Token{{0, len(line), TokenComment}}, stateNormal
+		}
+		return []Token{{0, len(line), TokenString}}, stateCodeFence
+	}
+	if fence {
+		return []Token{{0, len(line), TokenComment}}, stateCodeFence
+	}
+	if trimmed < len(line) {
+		switch line[trimmed] {
+		case '#':
+			return []Token{{0, len(line), TokenHeading}}, stateNormal
+		case '>':
+			return []Token{{0, len(line), TokenComment}}, stateNormal
+		}
+	}
+
+	var tokens []Token
+	if trimmed+1 < len(line) && (line[trimmed] == '-' || line[trimmed] == '*' || line[trimmed] == '+') && line[trimmed+1] == ' ' {
+		tokens = append(tokens, Token{trimmed, trimmed + 1, TokenKeyword})
+	}
+	for i := trimmed; i < len(line); {
+		r := line[i]
+		switch {
+		case r == '`':
+			end, _ := scanUntil(line, i+1, "`")
+			tokens = append(tokens, Token{i, end, TokenString})
+			i = end
+		case (r == '*' || r == '_') && i+1 < len(line) && line[i+1] != ' ':
+			delim := string(r)
+			if line[i+1] == r {
+				delim += string(r)
+			}
+			end, closed := scanUntil(line, i+len([]rune(delim)), delim)
+			if !closed {
+				i++
+				continue
+			}
+			tokens = append(tokens, Token{i, end, TokenEmphasis})
+			i = end
+		case r == '[':
+			end, closed := scanUntil(line, i+1, "](")
+			if !closed {
+				i++
+				continue
+			}
+			end, _ = scanUntil(line, end, ")")
+			tokens = append(tokens, Token{i, end, TokenLink})
+			i = end
+		default:
+			i++
+		}
+	}
+	return tokens, stateNormal
+}
+
+// shellLexer highlights POSIX shell scripts
+type shellLexer struct{}
+
+// Lex implements Lexer
+func (shellLexer) Lex(line []rune, state int) ([]Token, int) {
+	var tokens []Token
+	i := 0
+	switch state {
+	case stateSingleQuote:
+		end, closed := scanUntil(line, 0, "'")
+		tokens = append(tokens, Token{0, end, TokenString})
+		if !closed {
+			return tokens, stateSingleQuote
+		}
+		i = end
+	case stateDoubleQuote:
+		end, closed := scanQuoted(line, -1, '"')
+		tokens = append(tokens, Token{0, end, TokenString})
+		if !closed {
+			return tokens, stateDoubleQuote
+		}
+		i = end
+	}
+	for i < len(line) {
+		r := line[i]
+		switch {
+		case r == '#' && (i == 0 || unicode.IsSpace(line[i-1])):
+			return append(tokens, Token{i, len(line), TokenComment}), stateNormal
+		case r == '\'':
+			end, closed := scanUntil(line, i+1, "'")
+			tokens = append(tokens, Token{i, end, TokenString})
+			if !closed {
+				return tokens, stateSingleQuote
+			}
+			i = end
+		case r == '"':
+			end, closed := scanQuoted(line, i, '"')
+			tokens = append(tokens, Token{i, end, TokenString})
+			if !closed {
+				return tokens, stateDoubleQuote
+			}
+			i = end
+		case r == '$':
+			end := i + 1
+			if end < len(line) && line[end] == '{' {
+				end, _ = scanUntil(line, end, "}")
+			} else if end < len(line) && !IsIdentPart(line[end]) {
+				end++
+			} else {
+				end = scanIdent(line, end)
+			}
+			tokens = append(tokens, Token{i, end, TokenVariable})
+			i = end
+		case unicode.IsDigit(r) && (i == 0 || !IsIdentPart(line[i-1])):
+			end := scanNumber(line, i)
+			tokens = append(tokens, Token{i, end, TokenNumber})
+			i = end
+		case isIdentStart(r):
+			end := i
+			for end < len(line) && (IsIdentPart(line[end]) || line[end] == '-') {
+				end++
+			}
+			if shellKeywords[string(line[i:end])] {
+				tokens = append(tokens, Token{i, end, TokenKeyword})
+			}
+			i = end
+		case r == '|' || r == '&' || r == ';' || r == '<' || r == '>':
+			tokens = append(tokens, Token{i, i + 1, TokenOperator})
+			i++
+		default:
+			i++
+		}
+	}
+	return tokens, stateNormal
+}
@@ -0,0 +1,235 @@
+package editor
+
+import (
+	"sort"
+	"strings"
+
+	"gotui/pkg/textsearch"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// selection is a cursor with its selection anchor, for the cursors of a
+// buffer besides its main one
+type selection struct {
+	anchor Position
+	cursor Position
+	goalX  int
+}
+
+// Range returns the selected text range in buffer order
+func (s selection) Range() Range {
+	from, to := orderPositions(s.anchor, s.cursor)
+	return Range{From: from, To: to}
+}
+
+// Cursors returns the number of cursors in the editor
+func (e *Editor) Cursors() int {
+	return len(e.buf.extra) + 1
+}
+
+// ClearCursors removes all cursors but the main one
+func (e *Editor) ClearCursors() *Editor {
+	e.buf.extra = nil
+	return e
+}
+
+// selections returns the selections of all cursors in buffer order
+func (e *Editor) selections() []selection {
+	all := append([]selection{{anchor: e.buf.anchor, cursor: e.buf.cursor, goalX: e.buf.goalX}}, e.buf.extra...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].cursor.Less(all[j].cursor) })
+	return all
+}
+
+// eachCursor runs fn at every cursor as if it were the only one, from the
+// last cursor in the buffer to the first, passing the index of the cursor in
+// buffer order. The edits it makes are undone in one step.
+func (e *Editor) eachCursor(fn func(i int)) {
+	if len(e.buf.extra) == 0 {
+		fn(0)
+		return
+	}
+	// The main cursor goes first; all of them are kept in extra while fn
+	// runs, so that edits move the ones fn is not running at
+	all := append([]selection{{anchor: e.buf.anchor, cursor: e.buf.cursor, goalX: e.buf.goalX}}, e.buf.extra...)
+	order := make([]int, len(all))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return all[order[i]].cursor.Less(all[order[j]].cursor) })
+	e.buf.extra = all
+	e.Transaction(func() {
+		for n := len(order) - 1; n >= 0; n-- {
+			i := order[n]
+			e.buf.anchor, e.buf.cursor, e.buf.goalX = all[i].anchor, all[i].cursor, all[i].goalX
+			fn(n)
+			all[i] = selection{anchor: e.buf.anchor, cursor: e.buf.cursor, goalX: e.buf.goalX}
+		}
+	})
+	e.buf.anchor, e.buf.cursor, e.buf.goalX = all[0].anchor, all[0].cursor, all[0].goalX
+	e.buf.extra = mergeSelections(all[0], all[1:])
+	e.trackCursor = true
+}
+
+// mergeSelections drops the cursors that ended up at the same spot as
+// another one, or whose selections overlap an earlier one's
+func mergeSelections(main selection, extra []selection) []selection {
+	kept := []selection{}
+	taken := []Range{main.Range()}
+	for _, s := range extra {
+		r := s.Range()
+		overlaps := false
+		for _, t := range taken {
+			if r.From == t.From || r.From.Less(t.To) && t.From.Less(r.To) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, s)
+			taken = append(taken, r)
+		}
+	}
+	return kept
+}
+
+// handleCursorsKey applies a key at every cursor while there are several.
+// Keys that only make sense for one cursor go back to a single one first.
+func (e *Editor) handleCursorsKey(event *tcell.EventKey) bool {
+	switch key := event.Key(); {
+	case key == tcell.KeyEscape:
+		e.ClearCursors()
+		e.buf.snippet = nil
+		return true
+	case (key == tcell.KeyTab || key == tcell.KeyBacktab) && e.buf.snippet != nil:
+		// The cursors are those of a repeated snippet field
+		return e.handleEditKey(event)
+	}
+	switch event.Key() {
+	case tcell.KeyRune, tcell.KeyEnter, tcell.KeyTab, tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyDelete,
+		tcell.KeyLeft, tcell.KeyRight, tcell.KeyUp, tcell.KeyDown:
+	case tcell.KeyHome, tcell.KeyEnd:
+		if event.Modifiers()&tcell.ModCtrl == 0 {
+			break
+		}
+		fallthrough
+	default:
+		e.ClearCursors()
+		return e.handleEditKey(event)
+	}
+	handled := false
+	e.eachCursor(func(int) {
+		handled = e.handleEditKey(event)
+	})
+	return handled
+}
+
+// Paste inserts text at every cursor. When the text has one line for each
+// cursor, each cursor gets its own line.
+func (e *Editor) Paste(text string) {
+	lines := strings.Split(text, "\n")
+	cursors := e.Cursors()
+	e.eachCursor(func(i int) {
+		if cursors > 1 && len(lines) == cursors {
+			e.InsertText(lines[i])
+		} else {
+			e.InsertText(text)
+		}
+	})
+}
+
+// SelectedTexts returns the text selected by each cursor in buffer order
+func (e *Editor) SelectedTexts() []string {
+	var texts []string
+	for _, s := range e.selections() {
+		r := s.Range()
+		texts = append(texts, e.TextRange(r.From, r.To))
+	}
+	return texts
+}
+
+// DeleteSelections deletes the text selected by every cursor
+func (e *Editor) DeleteSelections() {
+	e.eachCursor(func(int) {
+		from, to := e.Selection()
+		e.Replace(from, to, "")
+		e.moveTo(from, false)
+	})
+}
+
+// AddNextMatch selects the word at the cursor if nothing is selected, or
+// else adds a cursor selecting the next occurrence of the selected text,
+// which becomes the main cursor. It reports whether it selected anything.
+func (e *Editor) AddNextMatch() bool {
+	from, to := e.Selection()
+	if from == to {
+		from, to = e.WordBounds(from)
+		if from == to {
+			return false
+		}
+		e.Select(from, to)
+		return true
+	}
+	if from.Line != to.Line {
+		return false
+	}
+	needle := e.buf.lines[from.Line][from.Col:to.Col]
+	taken := map[Position]bool{}
+	for _, s := range e.selections() {
+		taken[s.Range().From] = true
+	}
+	lines := len(e.buf.lines)
+	for i := 0; i <= lines; i++ {
+		n := (to.Line + i) % lines
+		for _, col := range textsearch.Find(e.buf.lines[n], needle, false) {
+			match := Position{Line: n, Col: col}
+			if i == 0 && col < to.Col || i == lines && col >= from.Col || taken[match] {
+				continue
+			}
+			e.buf.extra = append(e.buf.extra, selection{anchor: e.buf.anchor, cursor: e.buf.cursor, goalX: e.buf.goalX})
+			e.Select(match, Position{Line: n, Col: col + len(needle)})
+			return true
+		}
+	}
+	return false
+}
+
+// selectColumns puts a cursor on each line from the anchor's line to line,
+// selecting the text between the display columns anchorX and x
+func (e *Editor) selectColumns(anchor Position, anchorX int, line, x int) {
+	first, last, step := anchor.Line, line, 1
+	if last < first {
+		step = -1
+	}
+	var all []selection
+	for n := first; ; n += step {
+		runes := e.buf.lines[n]
+		all = append(all, selection{
+			anchor: Position{Line: n, Col: e.columnAt(runes, anchorX)},
+			cursor: Position{Line: n, Col: e.columnAt(runes, x)},
+			goalX:  -1,
+		})
+		if n == last {
+			break
+		}
+	}
+	// The cursor the drag is at is the main one
+	main := all[len(all)-1]
+	e.buf.anchor, e.buf.cursor, e.buf.goalX = main.anchor, main.cursor, -1
+	e.buf.extra = all[:len(all)-1]
+	e.trackCursor = true
+	e.notifyMoved()
+}
+
+// cursorStyles returns the ranges selected by the cursors, and the positions
+// of the cursors besides the main one, which the terminal cannot show
+func (e *Editor) cursorStyles() ([]Range, map[Position]bool) {
+	from, to := e.Selection()
+	ranges := []Range{{From: from, To: to}}
+	cursors := map[Position]bool{}
+	for _, s := range e.buf.extra {
+		ranges = append(ranges, s.Range())
+		cursors[s.cursor] = true
+	}
+	return ranges, cursors
+}
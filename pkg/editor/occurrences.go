@@ -0,0 +1,95 @@
+package editor
+
+import (
+	"unicode"
+
+	"gotui/pkg/textsearch"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// SetWordHighlight sets whether the other occurrences of the identifier at
+// the cursor are highlighted
+func (e *Editor) SetWordHighlight(on bool) *Editor {
+	e.wordHighlight = on
+	return e
+}
+
+// WordHighlight reports whether the other occurrences of the identifier at
+// the cursor are highlighted
+func (e *Editor) WordHighlight() bool {
+	return e.wordHighlight
+}
+
+// identifierAtCursor returns the bounds of the identifier at or before the
+// cursor, and false if there is none. Keywords, numbers, and the words of
+// strings and comments are not identifiers.
+func (e *Editor) identifierAtCursor() (Position, Position, bool) {
+	from, to := e.WordBounds(e.buf.cursor)
+	if from == to || unicode.IsDigit(e.buf.lines[from.Line][from.Col]) {
+		return from, to, false
+	}
+	for _, token := range e.buf.highlight.lineTokens(e.buf.lines, from.Line) {
+		if token.Start <= from.Col && from.Col < token.End {
+			switch token.Kind {
+			case TokenKeyword, TokenNumber, TokenString, TokenComment:
+				return from, to, false
+			}
+			break
+		}
+	}
+	return from, to, true
+}
+
+// occurrences returns the occurrences of the identifier at the cursor as a
+// whole word, and where the one at the cursor starts, while highlighting
+// them is on and there is a single cursor and no selection. They are found
+// again after an edit.
+func (e *Editor) occurrences() ([]Range, Position) {
+	from, to, ok := e.identifierAtCursor()
+	if !e.wordHighlight || !ok || e.HasSelection() || len(e.buf.extra) > 0 {
+		return nil, from
+	}
+	b := e.buf
+	word := b.lines[from.Line][from.Col:to.Col]
+	if b.occurrences == nil || b.occurrenceWord != string(word) {
+		b.occurrenceWord = string(word)
+		b.occurrences = []Range{}
+		for n, line := range b.lines {
+			for _, col := range textsearch.Find(line, word, false) {
+				end := col + len(word)
+				if col > 0 && IsIdentPart(line[col-1]) || end < len(line) && IsIdentPart(line[end]) {
+					continue
+				}
+				b.occurrences = append(b.occurrences, Range{From: Position{Line: n, Col: col}, To: Position{Line: n, Col: end}})
+			}
+		}
+	}
+	return b.occurrences, from
+}
+
+// Occurrences returns the occurrences of the identifier at the cursor
+// while they are highlighted
+func (e *Editor) Occurrences() []Range {
+	occurrences, _ := e.occurrences()
+	return occurrences
+}
+
+// highlightOccurrences marks the other occurrences of the identifier at the
+// cursor
+func (e *Editor) highlightOccurrences() {
+	occurrences, from := e.occurrences()
+	if len(occurrences) < 2 {
+		e.ClearDecorations("occurrences")
+		return
+	}
+	others := make([]Range, 0, len(occurrences))
+	for _, r := range occurrences {
+		if r.From != from {
+			others = append(others, r)
+		}
+	}
+	e.SetDecorations("occurrences", others, func(style tcell.Style) tcell.Style {
+		return style.Underline(true)
+	})
+}
@@ -0,0 +1,285 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	popupMaxHeight = 10
+	popupMaxWidth  = 60
+)
+
+// CompletionItem is an entry offered by the editor's completion popup
+type CompletionItem struct {
+	Label  string
+	Detail string
+	Filter string // text matched against what was typed, Label if empty
+	Insert string // text replacing the typed prefix, Label if empty
+
+	Snippet *Snippet // inserted instead of Insert, with its fields
+}
+
+// completionPopup is the list of completions shown below the cursor
+type completionPopup struct {
+	from     Position // start of the text the completion replaces
+	items    []CompletionItem
+	filtered []CompletionItem
+	selected int
+	offset   int
+}
+
+// popupStyles returns the styles of popup text, of the selected item, and
+// of item details
+func (e *Editor) popupStyles() (normal, selected, detail tcell.Style) {
+	background := tcell.StyleDefault.Background(e.colors.PopupBackground)
+	return background.Foreground(e.colors.PopupText),
+		tcell.StyleDefault.Background(e.colors.Accent).Foreground(e.colors.AccentText),
+		background.Foreground(e.colors.PopupDetail)
+}
+
+// ShowCompletions opens the completion popup for the text between from and
+// the cursor. The list is narrowed as the user keeps typing.
+func (e *Editor) ShowCompletions(from Position, items []CompletionItem) {
+	if len(items) == 0 {
+		e.completion = nil
+		return
+	}
+	e.info = ""
+	e.completion = &completionPopup{from: from, items: items}
+	e.FilterCompletions()
+}
+
+// ShowInfo displays a read-only text box next to the cursor until the next
+// key press
+func (e *Editor) ShowInfo(text string) {
+	e.completion = nil
+	e.info = strings.TrimRight(text, "\n")
+}
+
+// Completing reports whether the completion popup is open
+func (e *Editor) Completing() bool {
+	return e.completion != nil
+}
+
+// FilterCompletions narrows the popup to items matching the typed prefix,
+// closing it when the cursor has left the completed word. Keys handled by
+// the editor do it on their own.
+func (e *Editor) FilterCompletions() {
+	c := e.completion
+	if c == nil {
+		return
+	}
+	cursor := e.buf.cursor
+	if cursor.Line != c.from.Line || cursor.Less(c.from) {
+		e.completion = nil
+		return
+	}
+	prefix := strings.ToLower(e.TextRange(c.from, cursor))
+	for _, r := range prefix {
+		if !IsIdentPart(r) {
+			e.completion = nil
+			return
+		}
+	}
+
+	c.filtered = c.filtered[:0]
+	for _, item := range c.items {
+		filter := item.Filter
+		if filter == "" {
+			filter = item.Label
+		}
+		if strings.HasPrefix(strings.ToLower(filter), prefix) {
+			c.filtered = append(c.filtered, item)
+		}
+	}
+	if len(c.filtered) == 0 {
+		e.completion = nil
+		return
+	}
+	c.selected, c.offset = 0, 0
+}
+
+// acceptCompletion inserts the selected completion
+func (e *Editor) acceptCompletion() {
+	c := e.completion
+	e.completion = nil
+	item := c.filtered[c.selected]
+	if item.Snippet != nil {
+		e.insertSnippet(c.from, *item.Snippet)
+		return
+	}
+	text := item.Insert
+	if text == "" {
+		text = item.Label
+	}
+	end := e.Replace(c.from, e.buf.cursor, text)
+	e.moveTo(end, false)
+}
+
+// handlePopupKey handles keys while a popup is open and reports whether the
+// key was consumed by it
+func (e *Editor) handlePopupKey(event *tcell.EventKey) bool {
+	if e.info != "" {
+		e.info = ""
+		return event.Key() == tcell.KeyEscape
+	}
+	c := e.completion
+	if c == nil {
+		return false
+	}
+	switch event.Key() {
+	case tcell.KeyUp:
+		c.move(-1)
+	case tcell.KeyDown:
+		c.move(1)
+	case tcell.KeyPgUp:
+		c.move(-popupMaxHeight)
+	case tcell.KeyPgDn:
+		c.move(popupMaxHeight)
+	case tcell.KeyEnter, tcell.KeyTab:
+		e.acceptCompletion()
+	case tcell.KeyEscape:
+		e.completion = nil
+	default:
+		return false
+	}
+	return true
+}
+
+// move changes the selected completion by delta, clamped to the list
+func (c *completionPopup) move(delta int) {
+	c.selected += delta
+	if c.selected < 0 {
+		c.selected = 0
+	}
+	if c.selected >= len(c.filtered) {
+		c.selected = len(c.filtered) - 1
+	}
+	if c.selected < c.offset {
+		c.offset = c.selected
+	}
+	if c.selected >= c.offset+popupMaxHeight {
+		c.offset = c.selected - popupMaxHeight + 1
+	}
+}
+
+// popupRect places a box of the given size next to the cursor, below it when
+// there is room and above otherwise, within the editor's inner rectangle
+func (e *Editor) popupRect(width, height int) (int, int, int, int) {
+	x, y, w, h := e.textRect()
+	cx := x + e.cursorColumn()
+	cy := y + e.cursorRow()
+	if width > w {
+		width = w
+	}
+	if height > h-1 {
+		height = h - 1
+	}
+	px, py := cx, cy+1
+	if py+height > y+h {
+		py = cy - height
+	}
+	if py < y {
+		py = y
+	}
+	if px+width > x+w {
+		px = x + w - width
+	}
+	if px < x {
+		px = x
+	}
+	return px, py, width, height
+}
+
+// drawPopups draws the completion list or info box on top of the text
+func (e *Editor) drawPopups(screen tcell.Screen) {
+	switch {
+	case e.completion != nil:
+		e.drawCompletions(screen)
+	case e.info != "":
+		e.drawInfo(screen)
+	}
+}
+
+func (e *Editor) drawCompletions(screen tcell.Screen) {
+	c := e.completion
+	width := 0
+	for _, item := range c.filtered {
+		w := runewidth.StringWidth(item.Label) + 2
+		if item.Detail != "" {
+			w += runewidth.StringWidth(item.Detail) + 2
+		}
+		if w > width {
+			width = w
+		}
+	}
+	if width > popupMaxWidth {
+		width = popupMaxWidth
+	}
+	height := len(c.filtered)
+	if height > popupMaxHeight {
+		height = popupMaxHeight
+	}
+	px, py, width, height := e.popupRect(width, height)
+	popupStyle, popupSelectedStyle, popupDetailStyle := e.popupStyles()
+	for row := 0; row < height && c.offset+row < len(c.filtered); row++ {
+		item := c.filtered[c.offset+row]
+		style, detailStyle := popupStyle, popupDetailStyle
+		if c.offset+row == c.selected {
+			style, detailStyle = popupSelectedStyle, popupSelectedStyle
+		}
+		fillRow(screen, px, py+row, width, style)
+		end := printText(screen, " "+item.Label, px, py+row, width, style)
+		if item.Detail != "" {
+			printText(screen, "  "+item.Detail, end, py+row, px+width-end, detailStyle)
+		}
+	}
+}
+
+func (e *Editor) drawInfo(screen tcell.Screen) {
+	lines := strings.Split(e.info, "\n")
+	width := 0
+	for _, line := range lines {
+		if w := runewidth.StringWidth(line) + 2; w > width {
+			width = w
+		}
+	}
+	if width > popupMaxWidth+20 {
+		width = popupMaxWidth + 20
+	}
+	px, py, width, height := e.popupRect(width, len(lines))
+	popupStyle, _, _ := e.popupStyles()
+	for row := 0; row < height; row++ {
+		fillRow(screen, px, py+row, width, popupStyle)
+		printText(screen, " "+lines[row], px, py+row, width, popupStyle)
+	}
+}
+
+// fillRow paints width cells starting at x with the style's background
+func fillRow(screen tcell.Screen, x, y, width int, style tcell.Style) {
+	for i := 0; i < width; i++ {
+		screen.SetContent(x+i, y, ' ', nil, style)
+	}
+}
+
+// printText prints plain text clipped to width and returns the column
+// following the last printed cell
+func printText(screen tcell.Screen, text string, x, y, width int, style tcell.Style) int {
+	end := x + width
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			r = ' '
+		}
+		w := runewidth.RuneWidth(r)
+		if x+w > end {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	return x
+}
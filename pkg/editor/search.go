@@ -0,0 +1,19 @@
+package editor
+
+import "gotui/pkg/textsearch"
+
+// FindAll returns every occurrence of query in the buffer. The search
+// ignores case unless the query contains an upper case letter.
+func (e *Editor) FindAll(query string) []Range {
+	needle, fold := textsearch.Needle([]rune(query))
+	var matches []Range
+	for n, line := range e.buf.lines {
+		for _, col := range textsearch.Find(line, needle, fold) {
+			matches = append(matches, Range{
+				From: Position{Line: n, Col: col},
+				To:   Position{Line: n, Col: col + len(needle)},
+			})
+		}
+	}
+	return matches
+}
@@ -0,0 +1,230 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Snippet is a piece of text inserted for a prefix typed before Tab. Its
+// body marks the fields the cursor visits with $1, $2, ... or with
+// ${1:default text}; $0 is where the cursor ends up.
+type Snippet struct {
+	Prefix      string
+	Description string
+	Body        string
+}
+
+// SetSnippetsFunc sets the function returning the snippets available in a
+// file, which Tab expands after their prefix
+func (e *Editor) SetSnippetsFunc(snippets func(path string) ([]Snippet, error)) *Editor {
+	e.snippets = snippets
+	return e
+}
+
+// snippetField is a field of a parsed snippet body, in rune offsets of its
+// text
+type snippetField struct {
+	index      int
+	start, end int
+}
+
+// parseSnippet turns a snippet body into its text, without the field
+// markers, and its fields. A field repeated as $1 after ${1:default text}
+// gets the same text. A backslash escapes $, }, and itself.
+func parseSnippet(body string) (string, []snippetField) {
+	var (
+		text     []rune
+		fields   []snippetField
+		defaults = map[int][]rune{}
+	)
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(`$}\`, runes[i+1]):
+			i++
+			text = append(text, runes[i])
+		case r == '$' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			index, next := snippetIndex(runes, i+1)
+			start := len(text)
+			text = append(text, defaults[index]...)
+			fields = append(fields, snippetField{index: index, start: start, end: len(text)})
+			i = next - 1
+		case r == '$' && i+2 < len(runes) && runes[i+1] == '{' && unicode.IsDigit(runes[i+2]):
+			index, next := snippetIndex(runes, i+2)
+			field := snippetField{index: index, start: len(text)}
+			if next < len(runes) && runes[next] == ':' {
+				for next++; next < len(runes) && runes[next] != '}'; next++ {
+					if runes[next] == '\\' && next+1 < len(runes) {
+						next++
+					}
+					text = append(text, runes[next])
+				}
+			}
+			field.end = len(text)
+			defaults[index] = append([]rune{}, text[field.start:]...)
+			fields = append(fields, field)
+			i = next
+		default:
+			text = append(text, r)
+		}
+	}
+	return string(text), fields
+}
+
+// snippetIndex reads the field number starting at i, returning it and the
+// offset after it
+func snippetIndex(runes []rune, i int) (int, int) {
+	index := 0
+	for ; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+		index = index*10 + int(runes[i]-'0')
+	}
+	return index, i
+}
+
+// snippetSession tracks the fields of an inserted snippet while Tab moves
+// between them. Each stop holds the ranges of a field, more than one when
+// the field is repeated; the last stop is where the snippet ends.
+type snippetSession struct {
+	stops   [][]Range
+	current int
+}
+
+// insertSnippet replaces the text from from to the cursor with a snippet,
+// indenting its lines like the cursor's line, and selects its first field
+func (e *Editor) insertSnippet(from Position, s Snippet) {
+	indent := LeadingSpace(e.buf.lines[from.Line])
+	unit := e.indentUnit(indent)
+	lines := strings.Split(s.Body, "\n")
+	for i := range lines {
+		if unit != "\t" {
+			tabs := len(lines[i]) - len(strings.TrimLeft(lines[i], "\t"))
+			lines[i] = strings.Repeat(unit, tabs) + lines[i][tabs:]
+		}
+		if i > 0 && lines[i] != "" {
+			lines[i] = string(indent) + lines[i]
+		}
+	}
+	text, fields := parseSnippet(strings.Join(lines, "\n"))
+	e.buf.extra = nil
+	e.Replace(from, e.buf.cursor, text)
+
+	runes := []rune(text)
+	position := func(offset int) Position {
+		return textEnd(from, string(runes[:offset]))
+	}
+	byIndex := map[int][]Range{}
+	var order []int
+	for _, f := range fields {
+		if _, ok := byIndex[f.index]; !ok {
+			order = append(order, f.index)
+		}
+		byIndex[f.index] = append(byIndex[f.index], Range{From: position(f.start), To: position(f.end)})
+	}
+	sort.Ints(order)
+	session := &snippetSession{}
+	for _, index := range order {
+		if index > 0 {
+			session.stops = append(session.stops, byIndex[index])
+		}
+	}
+	end := byIndex[0]
+	if end == nil {
+		end = []Range{{From: position(len(runes)), To: position(len(runes))}}
+	}
+	session.stops = append(session.stops, end)
+	e.buf.snippet = session
+	e.selectSnippetStop(0)
+}
+
+// selectSnippetStop selects the ranges of a stop of the snippet, with a
+// cursor for each. The snippet is done once its last stop is reached.
+func (e *Editor) selectSnippetStop(i int) {
+	s := e.buf.snippet
+	s.current = i
+	ranges := s.stops[i]
+	e.Select(ranges[0].From, ranges[0].To)
+	e.buf.extra = nil
+	for _, r := range ranges[1:] {
+		e.buf.extra = append(e.buf.extra, selection{anchor: r.From, cursor: r.To, goalX: -1})
+	}
+	if i == len(s.stops)-1 {
+		e.buf.snippet = nil
+	}
+}
+
+// nextSnippetField moves to the next field of the snippet being filled in,
+// or the previous one for a negative delta, reporting whether there was a
+// snippet around the cursor
+func (e *Editor) nextSnippetField(delta int) bool {
+	s := e.buf.snippet
+	if s == nil {
+		return false
+	}
+	first, last := s.stops[0][0].From, s.stops[0][0].To
+	for _, stop := range s.stops {
+		for _, r := range stop {
+			if r.From.Less(first) {
+				first = r.From
+			}
+			if last.Less(r.To) {
+				last = r.To
+			}
+		}
+	}
+	if cursor := e.buf.cursor; cursor.Less(first) || last.Less(cursor) {
+		// The cursor left the snippet
+		e.buf.snippet = nil
+		return false
+	}
+	i := s.current + delta
+	if i < 0 {
+		i = 0
+	}
+	e.selectSnippetStop(i)
+	return true
+}
+
+// expandSnippet replaces the snippet prefix before the cursor with the
+// snippet, reporting whether there was one
+func (e *Editor) expandSnippet() bool {
+	if e.HasSelection() || len(e.buf.extra) > 0 {
+		return false
+	}
+	from, to := e.WordBounds(e.buf.cursor)
+	if from == to || to != e.buf.cursor {
+		return false
+	}
+	prefix := e.TextRange(from, to)
+	if e.snippets == nil {
+		return false
+	}
+	snippets, err := e.snippets(e.buf.path)
+	if err != nil {
+		e.info = fmt.Sprintf("Error loading snippets: %s", err)
+	}
+	for _, s := range snippets {
+		if s.Prefix == prefix {
+			e.insertSnippet(from, s)
+			return true
+		}
+	}
+	return false
+}
+
+// shift maps the fields of the snippet across a replacement of
+// [from, to) with text ending at end. Text typed at the start of a field
+// being filled in becomes part of it.
+func (s *snippetSession) shift(from, to, end Position) {
+	for i, stop := range s.stops {
+		for j := range stop {
+			r := &stop[j]
+			if i != s.current || r.From != from {
+				r.From = ShiftPosition(r.From, from, to, end)
+			}
+			r.To = ShiftPosition(r.To, from, to, end)
+		}
+	}
+}
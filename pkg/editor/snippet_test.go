@@ -0,0 +1,46 @@
+package editor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSnippet(t *testing.T) {
+	tests := []struct {
+		body   string
+		text   string
+		fields []snippetField
+	}{
+		{"plain", "plain", nil},
+		{"if $1 {\n\t$0\n}", "if  {\n\t\n}", []snippetField{{1, 3, 3}, {0, 7, 7}}},
+		{"${1:name} := $1", "name := name", []snippetField{{1, 0, 4}, {1, 8, 12}}},
+		{`\$1 \} \\`, `$1 } \`, nil},
+		{"${2:a\\}b}$10", "a}b", []snippetField{{2, 0, 3}, {10, 3, 3}}},
+	}
+	for _, tt := range tests {
+		text, fields := parseSnippet(tt.body)
+		if text != tt.text {
+			t.Errorf("parseSnippet(%q) text = %q, want %q", tt.body, text, tt.text)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("parseSnippet(%q) fields = %v, want %v", tt.body, fields, tt.fields)
+		}
+	}
+}
+
+func TestExpandSnippet(t *testing.T) {
+	e := NewEditor().SetText("x iferr")
+	e.SetSnippetsFunc(func(path string) ([]Snippet, error) {
+		return []Snippet{{Prefix: "iferr", Body: "if err != nil {\n\treturn ${1:err}\n}"}}, nil
+	})
+	e.SetCursor(Position{Col: 7})
+	if !e.expandSnippet() {
+		t.Fatal("expandSnippet() = false, want true")
+	}
+	if got, want := e.GetText(), "x if err != nil {\n\treturn err\n}"; got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+	if got := e.SelectedText(); got != "err" {
+		t.Errorf("selection = %q, want the first field", got)
+	}
+}
@@ -0,0 +1,144 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// TokenKind classifies a highlighted span of text
+type TokenKind int
+
+// Token kinds understood by the highlighter
+const (
+	TokenText TokenKind = iota
+	TokenKeyword
+	TokenType
+	TokenFunction
+	TokenString
+	TokenNumber
+	TokenComment
+	TokenOperator
+	TokenVariable
+	TokenHeading
+	TokenEmphasis
+	TokenLink
+)
+
+// Token is a highlighted span of a single line, in rune offsets
+type Token struct {
+	Start int
+	End   int
+	Kind  TokenKind
+}
+
+// Lexer tokenizes source text one line at a time. The state value lets
+// constructs such as block comments or raw strings span multiple lines:
+// Lex receives the state left behind by the previous line (0 for the first
+// line) and returns the state to carry into the next one.
+type Lexer interface {
+	Lex(line []rune, state int) ([]Token, int)
+}
+
+var (
+	lexers         = map[string]Lexer{}
+	lexerPatterns  = map[string]string{}
+	lexerFilenames = map[string]string{}
+)
+
+func init() {
+	RegisterLexer("go", goLexer{}, ".go")
+	RegisterLexer("json", jsonLexer{}, ".json")
+	RegisterLexer("markdown", markdownLexer{}, ".md", ".markdown")
+	RegisterLexer("shell", shellLexer{}, ".sh", ".bash", ".zsh", ".bashrc", ".profile")
+}
+
+// RegisterLexer makes a lexer available under the given name. Patterns are
+// file extensions (".go") or exact base names ("Makefile") the lexer applies to.
+func RegisterLexer(name string, lexer Lexer, patterns ...string) {
+	lexers[name] = lexer
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, ".") {
+			lexerPatterns[pattern] = name
+		} else {
+			lexerFilenames[pattern] = name
+		}
+	}
+}
+
+// LexerForPath returns the registered lexer for a file, or nil if none applies
+func LexerForPath(path string) Lexer {
+	base := filepath.Base(path)
+	if name, ok := lexerFilenames[base]; ok {
+		return lexers[name]
+	}
+	if name, ok := lexerPatterns[base]; ok {
+		return lexers[name]
+	}
+	if name, ok := lexerPatterns[strings.ToLower(filepath.Ext(base))]; ok {
+		return lexers[name]
+	}
+	return nil
+}
+
+// FileLanguage names the language of a file for its snippets and settings:
+// the name of its lexer, or else its extension without the dot, or its base
+// name
+func FileLanguage(path string) string {
+	base := filepath.Base(path)
+	if name, ok := lexerFilenames[base]; ok {
+		return name
+	}
+	ext := strings.ToLower(filepath.Ext(base))
+	if name, ok := lexerPatterns[ext]; ok {
+		return name
+	}
+	if ext != "" {
+		return ext[1:]
+	}
+	return base
+}
+
+// highlighter caches per-line tokens and lexer states for a buffer
+type highlighter struct {
+	lexer  Lexer
+	states []int     // states[i] is the lexer state at the end of line i
+	tokens [][]Token // tokens[i] holds the tokens of line i
+}
+
+// invalidate discards cached results from the given line onwards
+func (h *highlighter) invalidate(line int) {
+	if line < 0 {
+		line = 0
+	}
+	if line < len(h.tokens) {
+		h.tokens = h.tokens[:line]
+		h.states = h.states[:line]
+	}
+}
+
+// lineTokens returns the tokens for line n, lexing any lines before it that
+// are not cached yet
+func (h *highlighter) lineTokens(lines [][]rune, n int) []Token {
+	if h.lexer == nil || n >= len(lines) {
+		return nil
+	}
+	for i := len(h.tokens); i <= n; i++ {
+		state := 0
+		if i > 0 {
+			state = h.states[i-1]
+		}
+		tokens, next := h.lexer.Lex(lines[i], state)
+		h.tokens = append(h.tokens, tokens)
+		h.states = append(h.states, next)
+	}
+	return h.tokens[n]
+}
+
+// lineState returns the lexer state at the end of line n
+func (h *highlighter) lineState(lines [][]rune, n int) int {
+	if h.lexer == nil || n < 0 || n >= len(lines) {
+		return 0
+	}
+	h.lineTokens(lines, n)
+	return h.states[n]
+}
@@ -0,0 +1,195 @@
+package editor
+
+import "strings"
+
+// edit records one replacement made to a buffer so it can be reverted
+type edit struct {
+	from     Position
+	removed  string
+	inserted string
+}
+
+// end returns the position just after the inserted text
+func (ed edit) end() Position {
+	return textEnd(ed.from, ed.inserted)
+}
+
+// textEnd returns the position reached after writing text starting at from
+func textEnd(from Position, text string) Position {
+	lines := strings.Count(text, "\n")
+	if lines == 0 {
+		return Position{Line: from.Line, Col: from.Col + len([]rune(text))}
+	}
+	return Position{Line: from.Line + lines, Col: len([]rune(text[strings.LastIndexByte(text, '\n')+1:]))}
+}
+
+// undoGroup is a sequence of edits undone and redone as a single step
+type undoGroup struct {
+	edits  []edit
+	cursor Position // cursor before the first edit
+	anchor Position
+}
+
+// Kinds of edits that may be merged into the previous undo group
+const (
+	groupNone = iota
+	groupInsert
+	groupBackspace
+	groupDelete
+)
+
+// undoStack is the undo/redo history of a buffer. Consecutive keystrokes
+// that insert or delete single characters at the same spot are merged into
+// one group, so a run of typing is undone in one step.
+type undoStack struct {
+	undo []undoGroup
+	redo []undoGroup
+
+	kind      int      // merge kind of the last recorded edit
+	next      Position // where the next edit must happen to be merged
+	savePoint int      // len(undo) when the buffer was last saved, -1 if unreachable
+	applying  bool
+	batching  bool // edits are being collected into one group by a transaction
+	batchOpen bool // the transaction has started its group
+}
+
+// record adds an edit to the history, merging it with the previous group
+// when it continues the same run of typing or deleting
+func (s *undoStack) record(ed edit, cursor, anchor Position) {
+	if s.applying {
+		return
+	}
+	if s.savePoint > len(s.undo) {
+		s.savePoint = -1
+	}
+	s.redo = nil
+
+	kind, next := groupNone, Position{}
+	removed, inserted := len([]rune(ed.removed)), len([]rune(ed.inserted))
+	switch {
+	case removed == 0 && inserted == 1 && ed.inserted != "\n":
+		kind, next = groupInsert, ed.end()
+	case inserted == 0 && removed == 1 && ed.removed != "\n" && cursor != ed.from:
+		kind, next = groupBackspace, ed.from
+	case inserted == 0 && removed == 1 && ed.removed != "\n":
+		kind, next = groupDelete, ed.from
+	}
+
+	if s.batching {
+		if s.batchOpen {
+			last := &s.undo[len(s.undo)-1]
+			last.edits = append(last.edits, ed)
+		} else {
+			s.undo = append(s.undo, undoGroup{edits: []edit{ed}, cursor: cursor, anchor: anchor})
+			s.batchOpen = true
+		}
+		s.kind = groupNone
+		return
+	}
+
+	merge := kind != groupNone && kind == s.kind && len(s.undo) > 0 && len(s.undo) != s.savePoint
+	switch kind {
+	case groupInsert, groupDelete:
+		merge = merge && ed.from == s.next
+	case groupBackspace:
+		merge = merge && textEnd(ed.from, ed.removed) == s.next
+	}
+
+	if merge {
+		last := &s.undo[len(s.undo)-1]
+		last.edits = append(last.edits, ed)
+	} else {
+		s.undo = append(s.undo, undoGroup{edits: []edit{ed}, cursor: cursor, anchor: anchor})
+	}
+	s.kind, s.next = kind, next
+}
+
+// breakGroup stops the next edit from merging into the current group
+func (s *undoStack) breakGroup() {
+	s.kind = groupNone
+}
+
+// markSaved remembers the current history position as the saved state
+func (s *undoStack) markSaved() {
+	s.savePoint = len(s.undo)
+	s.breakGroup()
+}
+
+// forgetSavePoint makes the saved state unreachable, for buffers that
+// differ from their file by something other than their text
+func (s *undoStack) forgetSavePoint() {
+	s.savePoint = -1
+}
+
+// atSavePoint reports whether the buffer content matches the saved state
+func (s *undoStack) atSavePoint() bool {
+	return s.savePoint == len(s.undo)
+}
+
+// reset clears the history, treating the current content as saved
+func (s *undoStack) reset() {
+	*s = undoStack{}
+}
+
+// MarkSaved remembers the current content as the one saved to the file
+func (b *Buffer) MarkSaved() {
+	b.undo.markSaved()
+}
+
+// ForgetSavePoint makes the saved content unreachable by undoing and
+// redoing, for buffers that differ from their file by something other than
+// their text
+func (b *Buffer) ForgetSavePoint() {
+	b.undo.forgetSavePoint()
+}
+
+// Modified reports whether the content differs from the one saved
+func (b *Buffer) Modified() bool {
+	return !b.undo.atSavePoint()
+}
+
+// Undo reverts the last group of edits
+func (e *Editor) Undo() bool {
+	s := &e.buf.undo
+	if len(s.undo) == 0 {
+		return false
+	}
+	e.ClearCursors()
+	e.buf.snippet = nil
+	group := s.undo[len(s.undo)-1]
+	s.undo = s.undo[:len(s.undo)-1]
+	s.applying = true
+	for i := len(group.edits) - 1; i >= 0; i-- {
+		ed := group.edits[i]
+		e.Replace(ed.from, ed.end(), ed.removed)
+	}
+	s.applying = false
+	s.redo = append(s.redo, group)
+	s.breakGroup()
+	e.Select(group.anchor, group.cursor)
+	e.notifyChanged()
+	return true
+}
+
+// Redo reapplies the last undone group of edits
+func (e *Editor) Redo() bool {
+	s := &e.buf.undo
+	if len(s.redo) == 0 {
+		return false
+	}
+	e.ClearCursors()
+	e.buf.snippet = nil
+	group := s.redo[len(s.redo)-1]
+	s.redo = s.redo[:len(s.redo)-1]
+	s.applying = true
+	var end Position
+	for _, ed := range group.edits {
+		end = e.Replace(ed.from, textEnd(ed.from, ed.removed), ed.inserted)
+	}
+	s.applying = false
+	s.undo = append(s.undo, group)
+	s.breakGroup()
+	e.moveTo(end, false)
+	e.notifyChanged()
+	return true
+}
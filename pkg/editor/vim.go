@@ -0,0 +1,835 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Vim modes
+const (
+	vimNormal = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+)
+
+// Kinds of motion, which decide how much text an operator acts on
+const (
+	motionExclusive = iota // up to but not including the target
+	motionInclusive        // including the character at the target
+	motionLinewise         // whole lines from the cursor line to the target line
+)
+
+// VimKeymap emulates Vim's modal editing: normal, insert, and visual modes
+// with counts, motions, operators, registers, and search
+type VimKeymap struct {
+	mode    int
+	count   int    // pending count, 0 if none was typed
+	pending []rune // keys of an unfinished command, such as "d" or "g"
+
+	register string // unnamed register
+	linewise bool   // the register holds whole lines
+
+	command []rune // command line typed after prompt; nil when closed
+	prompt  rune   // ':', '/' or '?'
+	search  string
+	reverse bool // the last search went backwards
+	message string
+
+	visualStart Position
+
+	// ex runs ':' commands other than line numbers
+	ex func(command string) error
+	// run performs application actions bound to keys, such as "jump-back"
+	run func(action string) error
+}
+
+// NewVimKeymap returns a Vim keymap in normal mode
+func NewVimKeymap(ex func(command string) error, run func(action string) error) *VimKeymap {
+	return &VimKeymap{ex: ex, run: run}
+}
+
+// Inserting reports whether typed characters are inserted as text
+func (v *VimKeymap) Inserting() bool {
+	return v.mode == vimInsert
+}
+
+// Reserved reports whether the keymap takes over a global binding: the
+// jump list keys in normal mode. Ctrl+I arrives as Tab.
+func (v *VimKeymap) Reserved(event *tcell.EventKey) bool {
+	if v.command != nil || v.mode != vimNormal {
+		return false
+	}
+	return event.Key() == tcell.KeyCtrlO || event.Key() == tcell.KeyTab
+}
+
+// Status returns the mode line text
+func (v *VimKeymap) Status() string {
+	if v.command != nil {
+		return string(v.prompt) + string(v.command)
+	}
+	if v.message != "" {
+		return v.message
+	}
+	pending := string(v.pending)
+	if v.count > 0 {
+		pending = strconv.Itoa(v.count) + pending
+	}
+	switch v.mode {
+	case vimInsert:
+		return "-- INSERT --"
+	case vimVisual:
+		return strings.TrimSpace("-- VISUAL -- " + pending)
+	case vimVisualLine:
+		return strings.TrimSpace("-- VISUAL LINE -- " + pending)
+	}
+	return strings.TrimSpace("-- NORMAL -- " + pending)
+}
+
+// Mode names the current mode
+func (v *VimKeymap) Mode() string {
+	switch v.mode {
+	case vimInsert:
+		return "INSERT"
+	case vimVisual:
+		return "VISUAL"
+	case vimVisualLine:
+		return "VISUAL LINE"
+	}
+	return "NORMAL"
+}
+
+// HandleKey interprets a key according to the current mode
+func (v *VimKeymap) HandleKey(e *Editor, event *tcell.EventKey) bool {
+	v.message = ""
+	if v.command != nil {
+		v.handleCommandLine(e, event)
+		return true
+	}
+	if v.mode == vimInsert {
+		if event.Key() != tcell.KeyEscape {
+			return false
+		}
+		v.mode = vimNormal
+		e.buf.undo.breakGroup()
+		if cursor := e.buf.cursor; cursor.Col > 0 {
+			e.moveTo(Position{Line: cursor.Line, Col: cursor.Col - 1}, false)
+		}
+		v.clampCursor(e)
+		return true
+	}
+
+	switch event.Key() {
+	case tcell.KeyRune:
+		if event.Modifiers()&tcell.ModAlt != 0 {
+			return false
+		}
+		v.handleRune(e, event.Rune())
+	case tcell.KeyEscape:
+		v.pending, v.count = v.pending[:0], 0
+		if v.mode != vimNormal {
+			v.setMode(e, vimNormal)
+		}
+	case tcell.KeyCtrlR:
+		for i := 0; i < v.countOr(1); i++ {
+			e.Redo()
+		}
+		v.reset(e)
+	case tcell.KeyCtrlO, tcell.KeyTab:
+		if v.mode == vimNormal {
+			action := "jump-back"
+			if event.Key() == tcell.KeyTab {
+				action = "jump-forward"
+			}
+			for i := 0; i < v.countOr(1); i++ {
+				v.action(action)
+			}
+		}
+		v.reset(e)
+	case tcell.KeyCtrlD, tcell.KeyCtrlU:
+		n := e.page() / 2
+		if event.Key() == tcell.KeyCtrlU {
+			n = -n
+		}
+		e.vertical(n, false)
+		v.reset(e)
+	case tcell.KeyPgDn:
+		e.vertical(e.page(), false)
+		v.reset(e)
+	case tcell.KeyPgUp:
+		e.vertical(-e.page(), false)
+		v.reset(e)
+	case tcell.KeyLeft:
+		v.handleRune(e, 'h')
+	case tcell.KeyRight:
+		v.handleRune(e, 'l')
+	case tcell.KeyUp:
+		v.handleRune(e, 'k')
+	case tcell.KeyDown, tcell.KeyEnter:
+		v.handleRune(e, 'j')
+	case tcell.KeyHome:
+		v.handleRune(e, '0')
+	case tcell.KeyEnd:
+		v.handleRune(e, '$')
+	}
+	// Every other key is swallowed so it cannot edit the text outside
+	// insert mode
+	return true
+}
+
+// countOr returns the typed count, or n when none was typed
+func (v *VimKeymap) countOr(n int) int {
+	if v.count > 0 {
+		return v.count
+	}
+	return n
+}
+
+// handleRune adds a key to the pending command and runs it once complete
+func (v *VimKeymap) handleRune(e *Editor, r rune) {
+	literal := false
+	if n := len(v.pending); n > 0 && strings.ContainsRune("fFtTr", v.pending[n-1]) {
+		literal = true
+	}
+	if !literal && (r >= '1' && r <= '9' || r == '0' && v.count > 0) {
+		v.count = v.count*10 + int(r-'0')
+		return
+	}
+	v.pending = append(v.pending, r)
+	var done bool
+	if v.mode == vimNormal {
+		done = v.executeNormal(e, v.pending)
+	} else {
+		done = v.executeVisual(e, v.pending)
+	}
+	if done {
+		v.pending, v.count = v.pending[:0], 0
+	}
+}
+
+// reset clears the pending command and restores normal-mode invariants
+func (v *VimKeymap) reset(e *Editor) {
+	v.pending, v.count = v.pending[:0], 0
+	v.clampCursor(e)
+	v.updateVisual(e)
+}
+
+// executeNormal runs a normal-mode command and reports whether it is
+// complete; false means more keys are needed
+func (v *VimKeymap) executeNormal(e *Editor, keys []rune) bool {
+	cursor := e.buf.cursor
+	line := e.buf.lines[cursor.Line]
+	count := v.countOr(1)
+
+	switch keys[0] {
+	case 'd', 'c', 'y':
+		if len(keys) == 1 {
+			return false
+		}
+		op := keys[0]
+		if keys[1] == op {
+			last := cursor.Line + count - 1
+			v.operate(e, op, cursor, Position{Line: last}, motionLinewise)
+			return true
+		}
+		if op == 'c' && keys[1] == 'w' && !unicode.IsSpace(e.runeAt(cursor)) {
+			// cw changes to the end of the word, like ce
+			to := v.currentWordEnd(e, cursor)
+			for i := 1; i < count; i++ {
+				to = v.wordEnd(e, to)
+			}
+			v.operate(e, op, cursor, to, motionInclusive)
+			return true
+		}
+		from, to, kind, complete, ok := v.motion(e, keys[1:])
+		if !complete {
+			return false
+		}
+		if ok {
+			if keys[1] == 'w' && to.Line > from.Line {
+				// dw stops at the end of the line instead of joining it
+				// with the line of the next word
+				to = Position{Line: to.Line - 1, Col: len(e.buf.lines[to.Line-1])}
+			}
+			v.operate(e, op, from, to, kind)
+		}
+	case 'x':
+		to := Position{Line: cursor.Line, Col: cursor.Col + count}
+		if len(line) > 0 {
+			v.operate(e, 'd', cursor, e.clamp(to), motionExclusive)
+		}
+	case 'X':
+		from := Position{Line: cursor.Line, Col: cursor.Col - count}
+		v.operate(e, 'd', e.clamp(from), cursor, motionExclusive)
+	case 'D':
+		v.operate(e, 'd', cursor, Position{Line: cursor.Line, Col: len(line)}, motionExclusive)
+	case 'C':
+		v.operate(e, 'c', cursor, Position{Line: cursor.Line, Col: len(line)}, motionExclusive)
+	case 'Y':
+		v.operate(e, 'y', cursor, Position{Line: cursor.Line + count - 1}, motionLinewise)
+	case 'p', 'P':
+		v.put(e, keys[0] == 'P', count)
+	case 'r':
+		if len(keys) == 1 {
+			return false
+		}
+		if cursor.Col+count <= len(line) {
+			end := e.Replace(cursor, Position{Line: cursor.Line, Col: cursor.Col + count}, strings.Repeat(string(keys[1]), count))
+			e.moveTo(e.left(end), false)
+		}
+	case 'J':
+		v.join(e, count)
+	case 'u':
+		for i := 0; i < count; i++ {
+			e.Undo()
+		}
+	case 'i':
+		v.setMode(e, vimInsert)
+	case 'a':
+		if len(line) > 0 {
+			e.moveTo(Position{Line: cursor.Line, Col: cursor.Col + 1}, false)
+		}
+		v.setMode(e, vimInsert)
+	case 'I':
+		e.moveTo(Position{Line: cursor.Line, Col: e.indentEnd(cursor)}, false)
+		v.setMode(e, vimInsert)
+	case 'A':
+		e.moveTo(Position{Line: cursor.Line, Col: len(line)}, false)
+		v.setMode(e, vimInsert)
+	case 'o':
+		end := e.Replace(Position{Line: cursor.Line, Col: len(line)}, Position{Line: cursor.Line, Col: len(line)}, "\n")
+		e.moveTo(end, false)
+		v.setMode(e, vimInsert)
+	case 'O':
+		e.Replace(Position{Line: cursor.Line}, Position{Line: cursor.Line}, "\n")
+		e.moveTo(Position{Line: cursor.Line}, false)
+		v.setMode(e, vimInsert)
+	case 'v':
+		v.setMode(e, vimVisual)
+	case 'V':
+		v.setMode(e, vimVisualLine)
+	case ':', '/', '?':
+		v.command, v.prompt = []rune{}, keys[0]
+	case 'n', 'N':
+		v.searchNext(e, keys[0] == 'N', count)
+	default:
+		_, to, _, complete, ok := v.motion(e, keys)
+		if !complete {
+			return false
+		}
+		if ok {
+			v.moveCursor(e, keys[0], to)
+		}
+	}
+	v.clampCursor(e)
+	return true
+}
+
+// executeVisual runs a visual-mode command and reports whether it is complete
+func (v *VimKeymap) executeVisual(e *Editor, keys []rune) bool {
+	from, to := orderPositions(v.visualStart, e.buf.cursor)
+	kind := motionInclusive
+	if v.mode == vimVisualLine {
+		kind = motionLinewise
+	}
+	switch keys[0] {
+	case 'd', 'x', 'y', 'c':
+		op := keys[0]
+		if op == 'x' {
+			op = 'd'
+		}
+		v.setMode(e, vimNormal)
+		v.operate(e, op, from, to, kind)
+	case 'o':
+		v.visualStart, e.buf.cursor = e.buf.cursor, v.visualStart
+		e.moveTo(e.buf.cursor, false)
+	case 'v', 'V':
+		mode := vimVisual
+		if keys[0] == 'V' {
+			mode = vimVisualLine
+		}
+		if v.mode == mode {
+			mode = vimNormal
+		}
+		v.setMode(e, mode)
+	case ':', '/', '?':
+		v.command, v.prompt = []rune{}, keys[0]
+	case 'n', 'N':
+		v.searchNext(e, keys[0] == 'N', v.countOr(1))
+	default:
+		_, target, _, complete, ok := v.motion(e, keys)
+		if !complete {
+			return false
+		}
+		if ok {
+			v.moveCursor(e, keys[0], target)
+		}
+	}
+	v.clampCursor(e)
+	v.updateVisual(e)
+	return true
+}
+
+// moveCursor moves to the target of a motion, keeping the preferred column
+// for vertical motions
+func (v *VimKeymap) moveCursor(e *Editor, key rune, to Position) {
+	switch key {
+	case 'j', 'k':
+		e.verticalLines(to.Line-e.buf.cursor.Line, false)
+	default:
+		e.moveTo(to, false)
+		e.buf.goalX = -1
+	}
+}
+
+// motion resolves the motion named by keys into the range it covers.
+// complete is false while more keys are needed and ok is false for an
+// unknown or impossible motion.
+func (v *VimKeymap) motion(e *Editor, keys []rune) (from, to Position, kind int, complete, ok bool) {
+	cursor := e.buf.cursor
+	line := e.buf.lines[cursor.Line]
+	count := v.countOr(1)
+	from, to, kind = cursor, cursor, motionExclusive
+
+	switch keys[0] {
+	case 'h':
+		to.Col -= count
+	case 'l', ' ':
+		to.Col += count
+	case 'j', 'k':
+		if keys[0] == 'k' {
+			count = -count
+		}
+		to.Line += count
+		if to.Line < 0 || to.Line >= len(e.buf.lines) {
+			return from, to, kind, true, false
+		}
+		kind = motionLinewise
+	case 'w':
+		for i := 0; i < count; i++ {
+			to = v.wordForward(e, to)
+		}
+	case 'b':
+		for i := 0; i < count; i++ {
+			to = v.wordBackward(e, to)
+		}
+	case 'e':
+		for i := 0; i < count; i++ {
+			to = v.wordEnd(e, to)
+		}
+		kind = motionInclusive
+	case '0':
+		to.Col = 0
+	case '^':
+		to.Col = e.indentEnd(cursor)
+	case '$':
+		to.Line = e.clamp(Position{Line: cursor.Line + count - 1}).Line
+		to.Col = len(e.buf.lines[to.Line])
+		kind = motionInclusive
+	case 'G':
+		to.Line = len(e.buf.lines) - 1
+		if v.count > 0 {
+			to.Line = v.count - 1
+		}
+		to = e.clamp(to)
+		to.Col = e.indentEnd(to)
+		kind = motionLinewise
+	case 'g':
+		if len(keys) == 1 {
+			return from, to, kind, false, false
+		}
+		if keys[1] != 'g' {
+			return from, to, kind, true, false
+		}
+		to = e.clamp(Position{Line: v.countOr(1) - 1})
+		to.Col = e.indentEnd(to)
+		kind = motionLinewise
+	case 'f', 'F', 't', 'T':
+		if len(keys) == 1 {
+			return from, to, kind, false, false
+		}
+		col, found := findInLine(line, cursor.Col, keys[1], keys[0], count)
+		if !found {
+			return from, to, kind, true, false
+		}
+		to.Col = col
+		if keys[0] == 'f' || keys[0] == 't' {
+			kind = motionInclusive
+		}
+	case 'i', 'a':
+		// Text objects only make sense after an operator
+		if len(keys) == 1 {
+			return from, to, kind, false, false
+		}
+		if keys[1] != 'w' {
+			return from, to, kind, true, false
+		}
+		from, to = e.WordBounds(cursor)
+		if keys[0] == 'a' {
+			for to.Col < len(line) && unicode.IsSpace(line[to.Col]) {
+				to.Col++
+			}
+		}
+		if from == to {
+			return from, to, kind, true, false
+		}
+	default:
+		return from, to, kind, true, false
+	}
+	return from, e.clamp(to), kind, true, true
+}
+
+// findInLine finds the count-th occurrence of r on line from col for the f,
+// F, t, and T motions
+func findInLine(line []rune, col int, r, motion rune, count int) (int, bool) {
+	step := 1
+	if motion == 'F' || motion == 'T' {
+		step = -1
+	}
+	i := col
+	for n := 0; n < count; n++ {
+		i += step
+		for i >= 0 && i < len(line) && line[i] != r {
+			i += step
+		}
+		if i < 0 || i >= len(line) {
+			return col, false
+		}
+	}
+	switch motion {
+	case 't':
+		i--
+	case 'T':
+		i++
+	}
+	return i, true
+}
+
+// vimClass classifies a rune for word motions: blanks, word characters, and
+// punctuation each form separate words
+func vimClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case IsIdentPart(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// wordForward returns the start of the next word; empty lines count as words
+func (v *VimKeymap) wordForward(e *Editor, pos Position) Position {
+	last := e.LastPosition()
+	if class := vimClass(e.runeAt(pos)); class != 0 {
+		for pos != last && vimClass(e.runeAt(pos)) == class {
+			pos = e.right(pos)
+		}
+	}
+	for pos != last && vimClass(e.runeAt(pos)) == 0 {
+		pos = e.right(pos)
+		if pos.Col == 0 && len(e.buf.lines[pos.Line]) == 0 {
+			break
+		}
+	}
+	return pos
+}
+
+// wordBackward returns the start of the word before pos
+func (v *VimKeymap) wordBackward(e *Editor, pos Position) Position {
+	if pos == (Position{}) {
+		return pos
+	}
+	pos = e.left(pos)
+	for pos != (Position{}) && vimClass(e.runeAt(pos)) == 0 {
+		if pos.Col == 0 && len(e.buf.lines[pos.Line]) == 0 {
+			return pos
+		}
+		pos = e.left(pos)
+	}
+	line := e.buf.lines[pos.Line]
+	class := vimClass(e.runeAt(pos))
+	for pos.Col > 0 && vimClass(line[pos.Col-1]) == class {
+		pos.Col--
+	}
+	return pos
+}
+
+// wordEnd returns the last character of the word after pos
+func (v *VimKeymap) wordEnd(e *Editor, pos Position) Position {
+	last := e.LastPosition()
+	pos = e.right(pos)
+	for pos != last && vimClass(e.runeAt(pos)) == 0 {
+		pos = e.right(pos)
+	}
+	return v.currentWordEnd(e, pos)
+}
+
+// currentWordEnd returns the last character of the word at pos
+func (v *VimKeymap) currentWordEnd(e *Editor, pos Position) Position {
+	line := e.buf.lines[pos.Line]
+	class := vimClass(e.runeAt(pos))
+	for pos.Col+1 < len(line) && vimClass(line[pos.Col+1]) == class {
+		pos.Col++
+	}
+	return pos
+}
+
+// operate applies an operator (d, c, or y) to the text between from and to
+func (v *VimKeymap) operate(e *Editor, op rune, from, to Position, kind int) {
+	from, to = orderPositions(from, to)
+	switch kind {
+	case motionInclusive:
+		if to.Col < len(e.buf.lines[to.Line]) {
+			to.Col++
+		}
+	case motionLinewise:
+		to = e.clamp(Position{Line: to.Line, Col: len(e.buf.lines[e.clamp(to).Line])})
+		from = Position{Line: from.Line}
+	}
+	v.register = e.TextRange(from, to)
+	v.linewise = kind == motionLinewise
+	if v.linewise {
+		v.register += "\n"
+	}
+
+	switch op {
+	case 'y':
+		if !v.linewise {
+			e.moveTo(from, false)
+		}
+	case 'd':
+		if v.linewise {
+			// Take the line break with the deleted lines
+			if to.Line < len(e.buf.lines)-1 {
+				to = Position{Line: to.Line + 1}
+			} else if from.Line > 0 {
+				from = Position{Line: from.Line - 1, Col: len(e.buf.lines[from.Line-1])}
+			}
+		}
+		e.Replace(from, to, "")
+		e.moveTo(from, false)
+		if v.linewise {
+			e.moveTo(Position{Line: from.Line, Col: e.indentEnd(from)}, false)
+		}
+	case 'c':
+		e.buf.undo.breakGroup()
+		e.Replace(from, to, "")
+		e.moveTo(from, false)
+		v.setMode(e, vimInsert)
+	}
+}
+
+// put pastes the register after (or before) the cursor count times
+func (v *VimKeymap) put(e *Editor, before bool, count int) {
+	if v.register == "" {
+		return
+	}
+	text := strings.Repeat(v.register, count)
+	cursor := e.buf.cursor
+	if v.linewise {
+		var line int
+		if before {
+			e.Replace(Position{Line: cursor.Line}, Position{Line: cursor.Line}, text)
+			line = cursor.Line
+		} else {
+			end := Position{Line: cursor.Line, Col: len(e.buf.lines[cursor.Line])}
+			e.Replace(end, end, "\n"+strings.TrimSuffix(text, "\n"))
+			line = cursor.Line + 1
+		}
+		e.moveTo(Position{Line: line, Col: e.indentEnd(Position{Line: line})}, false)
+		return
+	}
+	pos := cursor
+	if !before && len(e.buf.lines[cursor.Line]) > 0 {
+		pos.Col++
+	}
+	end := e.Replace(pos, pos, text)
+	if strings.Contains(text, "\n") {
+		e.moveTo(pos, false)
+	} else {
+		e.moveTo(e.left(end), false)
+	}
+}
+
+// join joins count lines (at least two) into one, separated by spaces
+func (v *VimKeymap) join(e *Editor, count int) {
+	if count < 2 {
+		count = 2
+	}
+	e.Transaction(func() {
+		line := e.buf.cursor.Line
+		for i := 1; i < count && line < len(e.buf.lines)-1; i++ {
+			end := Position{Line: line, Col: len(e.buf.lines[line])}
+			next := Position{Line: line + 1}
+			next.Col = e.indentEnd(next)
+			separator := " "
+			current := strings.TrimRight(string(e.buf.lines[line]), " \t")
+			if next.Col == len(e.buf.lines[line+1]) || current == "" || e.runeAt(next) == ')' {
+				separator = ""
+			}
+			end.Col = len([]rune(current))
+			e.Replace(end, next, separator)
+			e.moveTo(end, false)
+		}
+	})
+}
+
+// searchNext moves to the next match of the last search, wrapping around
+// the buffer; reverse searches against the last direction
+func (v *VimKeymap) searchNext(e *Editor, reverse bool, count int) {
+	if v.search == "" {
+		v.message = "No previous search"
+		return
+	}
+	matches := e.FindAll(v.search)
+	if len(matches) == 0 {
+		v.message = "Pattern not found: " + v.search
+		return
+	}
+	backward := v.reverse != reverse
+	pos := e.buf.cursor
+	for n := 0; n < count; n++ {
+		index := -1
+		if backward {
+			for i := len(matches) - 1; i >= 0; i-- {
+				if matches[i].From.Less(pos) {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				index = len(matches) - 1
+				v.message = "search hit TOP, continuing at BOTTOM"
+			}
+		} else {
+			for i, match := range matches {
+				if pos.Less(match.From) {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				index = 0
+				v.message = "search hit BOTTOM, continuing at TOP"
+			}
+		}
+		pos = matches[index].From
+	}
+	e.moveTo(pos, false)
+	e.buf.goalX = -1
+}
+
+// handleCommandLine edits and runs the ':', '/' and '?' command line
+func (v *VimKeymap) handleCommandLine(e *Editor, event *tcell.EventKey) {
+	switch event.Key() {
+	case tcell.KeyRune:
+		v.command = append(v.command, event.Rune())
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(v.command) == 0 {
+			v.command = nil
+			return
+		}
+		v.command = v.command[:len(v.command)-1]
+	case tcell.KeyEscape:
+		v.command = nil
+	case tcell.KeyEnter:
+		command := strings.TrimSpace(string(v.command))
+		v.command = nil
+		count := v.countOr(1)
+		v.pending, v.count = v.pending[:0], 0
+		if v.prompt == ':' {
+			v.runEx(e, command)
+			return
+		}
+		if command != "" {
+			v.search = command
+		}
+		v.reverse = v.prompt == '?'
+		v.searchNext(e, false, count)
+		v.clampCursor(e)
+		v.updateVisual(e)
+	}
+}
+
+// runEx runs a ':' command. Line numbers move the cursor; anything else is
+// passed to the ex handler.
+func (v *VimKeymap) runEx(e *Editor, command string) {
+	if command == "" {
+		return
+	}
+	if line, err := strconv.Atoi(command); err == nil {
+		e.moveTo(e.clamp(Position{Line: line - 1}), false)
+		e.moveTo(Position{Line: e.buf.cursor.Line, Col: e.indentEnd(e.buf.cursor)}, false)
+		return
+	}
+	if v.ex == nil {
+		v.message = "Not an editor command: " + command
+		return
+	}
+	if err := v.ex(command); err != nil {
+		v.message = fmt.Sprintf("E: %s", err)
+	}
+}
+
+// action runs an application action, showing any error on the mode line
+func (v *VimKeymap) action(name string) {
+	if v.run == nil {
+		return
+	}
+	if err := v.run(name); err != nil {
+		v.message = fmt.Sprintf("E: %s", err)
+	}
+}
+
+// setMode switches modes, starting or ending a visual selection
+func (v *VimKeymap) setMode(e *Editor, mode int) {
+	if (mode == vimVisual || mode == vimVisualLine) && v.mode != vimVisual && v.mode != vimVisualLine {
+		v.visualStart = e.buf.cursor
+	}
+	if mode == vimInsert {
+		e.buf.undo.breakGroup()
+	}
+	v.mode = mode
+	v.updateVisual(e)
+}
+
+// clampCursor keeps the cursor on a character outside insert mode and drops
+// any editor selection, since visual mode draws its own
+func (v *VimKeymap) clampCursor(e *Editor) {
+	if v.mode == vimInsert {
+		return
+	}
+	cursor := e.buf.cursor
+	if n := len(e.buf.lines[cursor.Line]); cursor.Col >= n && n > 0 {
+		cursor.Col = n - 1
+	}
+	if cursor != e.buf.cursor || e.buf.anchor != cursor {
+		goal := e.buf.goalX
+		e.moveTo(cursor, false)
+		e.buf.goalX = goal
+	}
+}
+
+// updateVisual highlights the visual selection, or clears it outside visual
+// mode
+func (v *VimKeymap) updateVisual(e *Editor) {
+	if v.mode != vimVisual && v.mode != vimVisualLine {
+		e.ClearDecorations("vim.visual")
+		return
+	}
+	from, to := orderPositions(v.visualStart, e.buf.cursor)
+	if v.mode == vimVisualLine {
+		from.Col = 0
+		to.Col = len(e.buf.lines[to.Line])
+	} else if to.Col < len(e.buf.lines[to.Line]) {
+		to.Col++
+	}
+	e.SetDecorations("vim.visual", []Range{{From: from, To: to}}, func(style tcell.Style) tcell.Style {
+		return e.selectedStyle
+	})
+}
@@ -0,0 +1,33 @@
+package editor
+
+// SetInvisibles sets whether the spaces and tabs at the end of lines are
+// drawn as visible marks
+func (e *Editor) SetInvisibles(on bool) *Editor {
+	e.invisibles = on
+	return e
+}
+
+// Invisibles reports whether the spaces and tabs at the end of lines are
+// drawn as visible marks
+func (e *Editor) Invisibles() bool {
+	return e.invisibles
+}
+
+// trailingSpace returns the column where the spaces and tabs ending a line
+// start, or its length if it does not end in any
+func trailingSpace(line []rune) int {
+	end := len(line)
+	for end > 0 && (line[end-1] == ' ' || line[end-1] == '\t') {
+		end--
+	}
+	return end
+}
+
+// invisibleMark returns the mark drawn for a space or tab at the end of a
+// line
+func invisibleMark(r rune) rune {
+	if r == '\t' {
+		return '→'
+	}
+	return '·'
+}
@@ -0,0 +1,149 @@
+package editor
+
+// displayRow is a row of the editor showing the runes of a line from start
+// up to end: all of them, unless the line wraps
+type displayRow struct {
+	line       int
+	start, end int
+}
+
+// SetWrap sets whether lines longer than the editor is wide wrap onto the
+// rows below rather than scroll sideways
+func (e *Editor) SetWrap(on bool) *Editor {
+	if on == e.wrap {
+		return e
+	}
+	e.wrap = on
+	e.buf.rowSkip, e.buf.colOffset = 0, 0
+	e.buf.goalX = -1
+	e.trackCursor = true
+	return e
+}
+
+// Wrap reports whether long lines wrap
+func (e *Editor) Wrap() bool {
+	return e.wrap
+}
+
+// wrapping reports whether lines wrap, which needs the width of a draw
+func (e *Editor) wrapping() bool {
+	return e.wrap && e.wrapWidth > 0
+}
+
+// wrapStarts returns the columns where the rows of line n start. Lines
+// break after the last space or tab that fits on a row, or else at the
+// last rune that fits.
+func (e *Editor) wrapStarts(n int) []int {
+	starts := []int{0}
+	if !e.wrapping() {
+		return starts
+	}
+	line := e.buf.lines[n]
+	start, startX, cx := 0, 0, 0
+	space, spaceX := -1, 0 // the column after the last blank of the row
+	for col, r := range line {
+		w := e.runeWidth(r, cx)
+		if cx+w-startX > e.wrapWidth && col > start {
+			if space > start {
+				start, startX = space, spaceX
+			} else {
+				start, startX = col, cx
+			}
+			starts = append(starts, start)
+		}
+		cx += w
+		if r == ' ' || r == '\t' {
+			space, spaceX = col+1, cx
+		}
+	}
+	return starts
+}
+
+// lineRows returns the rows that line n is shown on
+func (e *Editor) lineRows(n int) []displayRow {
+	starts := e.wrapStarts(n)
+	rows := make([]displayRow, len(starts))
+	for i, start := range starts {
+		end := len(e.buf.lines[n])
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		rows[i] = displayRow{line: n, start: start, end: end}
+	}
+	return rows
+}
+
+// rowIndex returns the index of the row of its line that pos is shown on
+func (e *Editor) rowIndex(pos Position) int {
+	starts := e.wrapStarts(pos.Line)
+	i := len(starts) - 1
+	for i > 0 && pos.Col < starts[i] {
+		i--
+	}
+	return i
+}
+
+// moveRows returns the row n rows below a row, given as a line and the
+// index of the row in it, or above it for a negative n, stopping at the
+// first and the last row. Folded lines are skipped.
+func (e *Editor) moveRows(line, index, n int) (int, int) {
+	for ; n > 0; n-- {
+		if index+1 < len(e.wrapStarts(line)) {
+			index++
+			continue
+		}
+		next := e.buf.moveVisible(line, 1)
+		if next == line {
+			break
+		}
+		line, index = next, 0
+	}
+	for ; n < 0; n++ {
+		if index > 0 {
+			index--
+			continue
+		}
+		previous := e.buf.moveVisible(line, -1)
+		if previous == line {
+			break
+		}
+		line, index = previous, len(e.wrapStarts(previous))-1
+	}
+	return line, index
+}
+
+// rowShift returns the display column shown at the left edge of the
+// editor on a row
+func (e *Editor) rowShift(row displayRow) int {
+	if e.wrapping() {
+		return e.displayColumn(e.buf.lines[row.line], row.start)
+	}
+	return e.buf.colOffset
+}
+
+// cursorColumn returns the screen column of the cursor, counted from the
+// left edge of the text
+func (e *Editor) cursorColumn() int {
+	cursor := e.buf.cursor
+	row := e.lineRows(cursor.Line)[e.rowIndex(cursor)]
+	return e.displayColumn(e.buf.lines[cursor.Line], cursor.Col) - e.rowShift(row)
+}
+
+// verticalRows moves the cursor by n rows of wrapped lines, keeping the
+// preferred display column within the row
+func (e *Editor) verticalRows(n int, extend bool) {
+	cursor := e.buf.cursor
+	if e.buf.goalX < 0 {
+		e.buf.goalX = e.cursorColumn()
+	}
+	goal := e.buf.goalX
+	line, index := e.moveRows(cursor.Line, e.rowIndex(cursor), n)
+	row := e.lineRows(line)[index]
+	col := e.columnAt(e.buf.lines[line], e.rowShift(row)+goal)
+	if col >= row.end && row.end < len(e.buf.lines[line]) {
+		// The end of a row that wraps is the start of the next one
+		col = row.end - 1
+	}
+	e.moveTo(Position{Line: line, Col: col}, extend)
+	e.buf.goalX = goal
+}
@@ -0,0 +1,508 @@
+// Package explorer provides a tview widget showing the files below one or
+// more root directories as a tree. Directories are read in the background
+// the first time they are expanded.
+package explorer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// dirRef is the reference of a directory node. Its children are read the
+// first time it is expanded and kept afterwards, so collapsing and expanding
+// it again does not touch the disk.
+type dirRef struct {
+	path    string
+	loaded  bool
+	loading bool
+}
+
+// spinnerFrames animate the placeholder of a directory being read
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// Explorer is a tree of the files below its roots, which are the top level
+// of the tree, below a root node that is not shown
+type Explorer struct {
+	*tview.TreeView
+
+	update   func(func())
+	hides    func(path string, isDir bool) bool
+	opened   func(path string)
+	loaded   func(dir string)
+	failed   func(err error)
+	panicked func(p interface{})
+
+	dirColor, fileColor, loadingColor tcell.Color
+}
+
+// New returns an explorer without roots. update runs changes made while
+// directories are read in the background, and must run them on the
+// goroutine drawing the tree and draw it afterwards, as
+// tview.Application.QueueUpdateDraw does.
+func New(update func(func())) *Explorer {
+	e := &Explorer{
+		TreeView:     tview.NewTreeView(),
+		update:       update,
+		dirColor:     tview.Styles.SecondaryTextColor,
+		fileColor:    tview.Styles.PrimaryTextColor,
+		loadingColor: tview.Styles.TertiaryTextColor,
+	}
+	e.SetRoot(tview.NewTreeNode("")).SetTopLevel(1)
+	e.SetSelectedFunc(func(node *tview.TreeNode) {
+		switch reference := node.GetReference().(type) {
+		case *dirRef:
+			if node.IsExpanded() {
+				node.Collapse()
+			} else {
+				e.expand(node, reference)
+			}
+		case string:
+			if e.opened != nil {
+				e.opened(reference)
+			}
+		}
+	})
+	return e
+}
+
+// SetOpenFunc sets the handler called with the path of a file when its node
+// is selected
+func (e *Explorer) SetOpenFunc(handler func(path string)) *Explorer {
+	e.opened = handler
+	return e
+}
+
+// SetLoadedFunc sets the handler called with the path of a directory after
+// its children were read for the first time, to watch it for changes
+func (e *Explorer) SetLoadedFunc(handler func(dir string)) *Explorer {
+	e.loaded = handler
+	return e
+}
+
+// SetErrorFunc sets the handler called when a directory expanded in the
+// background cannot be read
+func (e *Explorer) SetErrorFunc(handler func(err error)) *Explorer {
+	e.failed = handler
+	return e
+}
+
+// SetPanicFunc sets the handler called instead of crashing when reading a
+// directory in the background panics
+func (e *Explorer) SetPanicFunc(handler func(p interface{})) *Explorer {
+	e.panicked = handler
+	return e
+}
+
+// SetFilterFunc sets the function deciding which entries are left out of
+// the tree. It is called in the background, so it must not change while it
+// is in use: set a new function, then Refresh, to apply other rules.
+func (e *Explorer) SetFilterFunc(hides func(path string, isDir bool) bool) *Explorer {
+	e.hides = hides
+	return e
+}
+
+// SetColors sets the colors of directories, files, and the placeholder of a
+// directory being read, and colors the nodes again
+func (e *Explorer) SetColors(dir, file, loading tcell.Color) *Explorer {
+	e.dirColor, e.fileColor, e.loadingColor = dir, file, loading
+	e.GetRoot().Walk(func(node, parent *tview.TreeNode) bool {
+		switch node.GetReference().(type) {
+		case *dirRef:
+			node.SetColor(dir)
+		case string:
+			node.SetColor(file)
+		default:
+			if parent != nil {
+				node.SetColor(loading)
+			}
+		}
+		return true
+	})
+	return e
+}
+
+// SetRoots shows the directories roots at the top level, keeping the nodes
+// of the roots already shown. name returns the text of a root's node.
+func (e *Explorer) SetRoots(roots []string, name func(root string) string) *Explorer {
+	existing := make(map[string]*tview.TreeNode)
+	for _, node := range e.GetRoot().GetChildren() {
+		existing[nodePath(node)] = node
+	}
+	nodes := make([]*tview.TreeNode, 0, len(roots))
+	for _, root := range roots {
+		node, ok := existing[root]
+		if !ok {
+			node = e.newDirNode("", root)
+			e.expand(node, node.GetReference().(*dirRef))
+		}
+		nodes = append(nodes, node.SetText(name(root)))
+	}
+	e.GetRoot().SetChildren(nodes)
+	if current := e.GetCurrentNode(); len(nodes) > 0 && (current == nil || len(e.GetPath(current)) == 0) {
+		// Nothing was selected yet, or the selected node was in a removed root
+		e.SetCurrentNode(nodes[0])
+	}
+	return e
+}
+
+// newDirNode returns a collapsed node for the directory at path
+func (e *Explorer) newDirNode(name, path string) *tview.TreeNode {
+	return tview.NewTreeNode(name).
+		SetColor(e.dirColor).
+		SetReference(&dirRef{path: path}).
+		SetExpanded(false)
+}
+
+// recoverPanic hands a panic of a background goroutine to the panic handler
+func (e *Explorer) recoverPanic() {
+	if e.panicked == nil {
+		return
+	}
+	if p := recover(); p != nil {
+		e.panicked(p)
+	}
+}
+
+// expand expands a directory node, reading its children in the background
+// the first time. A spinner is shown in place of the children until they are
+// read.
+func (e *Explorer) expand(node *tview.TreeNode, dir *dirRef) {
+	node.Expand()
+	if dir.loaded || dir.loading {
+		return
+	}
+	dir.loading = true
+	placeholder := tview.NewTreeNode(string(spinnerFrames[0]) + " Loading…").
+		SetColor(e.loadingColor).
+		SetSelectable(false)
+	node.SetChildren([]*tview.TreeNode{placeholder})
+
+	done := make(chan struct{})
+	go func() {
+		defer e.recoverPanic()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for frame := 1; ; frame++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				spinner := spinnerFrames[frame%len(spinnerFrames)]
+				e.update(func() {
+					placeholder.SetText(string(spinner) + " Loading…")
+				})
+			}
+		}
+	}()
+	hides := e.hides
+	go func() {
+		defer e.recoverPanic()
+		children, err := e.readDir(dir.path, hides)
+		close(done)
+		e.update(func() {
+			dir.loading = false
+			if err != nil {
+				// Leave the directory unloaded so expanding it again retries
+				node.ClearChildren().Collapse()
+				if e.failed != nil {
+					e.failed(err)
+				}
+				return
+			}
+			dir.loaded = true
+			node.SetChildren(children)
+			if e.loaded != nil {
+				e.loaded(dir.path)
+			}
+		})
+	}()
+}
+
+// readDir returns the nodes of the entries of a directory that hides lets
+// through, with subdirectories collapsed and unread
+func (e *Explorer) readDir(path string, hides func(path string, isDir bool) bool) ([]*tview.TreeNode, error) {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	nodes := make([]*tview.TreeNode, 0, len(files))
+	for _, file := range files {
+		childPath := filepath.Join(path, file.Name())
+		if hides != nil && hides(childPath, file.IsDir()) {
+			continue
+		}
+		if file.IsDir() {
+			nodes = append(nodes, e.newDirNode(file.Name(), childPath))
+			continue
+		}
+		nodes = append(nodes, tview.NewTreeNode(file.Name()).
+			SetColor(e.fileColor).
+			SetSelectable(true).
+			SetReference(childPath))
+	}
+	return nodes, nil
+}
+
+// nodePath returns the path of the file or directory of a node
+func nodePath(node *tview.TreeNode) string {
+	switch reference := node.GetReference().(type) {
+	case *dirRef:
+		return reference.path
+	case string:
+		return reference
+	}
+	return ""
+}
+
+// Path returns the path of the file or directory of a node, or "" for the
+// loading placeholder
+func (e *Explorer) Path(node *tview.TreeNode) string {
+	return nodePath(node)
+}
+
+// IsDir reports whether node is the node of a directory
+func (e *Explorer) IsDir(node *tview.TreeNode) bool {
+	_, ok := node.GetReference().(*dirRef)
+	return ok
+}
+
+// IsRoot reports whether node is the node of a root
+func (e *Explorer) IsRoot(node *tview.TreeNode) bool {
+	for _, top := range e.GetRoot().GetChildren() {
+		if top == node {
+			return true
+		}
+	}
+	return false
+}
+
+// rootNode returns the top-level node of a root, or nil
+func (e *Explorer) rootNode(root string) *tview.TreeNode {
+	for _, node := range e.GetRoot().GetChildren() {
+		if nodePath(node) == root {
+			return node
+		}
+	}
+	return nil
+}
+
+// rootOf returns the root path is in: the last root containing it, as
+// later roots may lie inside earlier ones, or else the first root
+func (e *Explorer) rootOf(path string) string {
+	roots := e.GetRoot().GetChildren()
+	if len(roots) == 0 {
+		return ""
+	}
+	abs := absPath(path)
+	for i := len(roots) - 1; i > 0; i-- {
+		if root := nodePath(roots[i]); withinPath(abs, absPath(root)) {
+			return root
+		}
+	}
+	return nodePath(roots[0])
+}
+
+// SelectRoot selects the node of a root
+func (e *Explorer) SelectRoot(root string) {
+	if node := e.rootNode(root); node != nil {
+		e.SetCurrentNode(node)
+	}
+}
+
+// SelectedDir returns the selected directory, the directory containing the
+// selected file, or the first root if nothing is selected
+func (e *Explorer) SelectedDir() string {
+	first := ""
+	if roots := e.GetRoot().GetChildren(); len(roots) > 0 {
+		first = nodePath(roots[0])
+	}
+	node := e.GetCurrentNode()
+	if node == nil {
+		return first
+	}
+	if e.IsDir(node) {
+		return nodePath(node)
+	}
+	if path := e.GetPath(node); len(path) > 2 {
+		return nodePath(path[len(path)-2])
+	}
+	return first
+}
+
+// NodeAt returns the node shown on row y of the screen, or nil
+func (e *Explorer) NodeAt(y int) *tview.TreeNode {
+	_, top, _, _ := e.GetInnerRect()
+	row := y - top + e.GetScrollOffset()
+	var found *tview.TreeNode
+	for _, root := range e.GetRoot().GetChildren() {
+		root.Walk(func(node, parent *tview.TreeNode) bool {
+			if found != nil {
+				return false
+			}
+			if row == 0 {
+				found = node
+			}
+			row--
+			return node.IsExpanded()
+		})
+	}
+	return found
+}
+
+// findDirNode returns the loaded node of the directory at path, or nil
+func (e *Explorer) findDirNode(path string) *tview.TreeNode {
+	var found *tview.TreeNode
+	root := e.rootOf(path)
+	e.GetRoot().Walk(func(node, parent *tview.TreeNode) bool {
+		if parent == nil {
+			return true
+		}
+		dir, ok := node.GetReference().(*dirRef)
+		if !ok || found != nil {
+			return false
+		}
+		if isRoot := parent == e.GetRoot(); isRoot && dir.path != root || !isRoot && !withinPath(path, dir.path) {
+			return false
+		}
+		if dir.path == path {
+			found = node
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// refreshDir reads a directory node's children again. Children that still
+// exist keep their nodes, so expanded subdirectories stay as they were.
+func (e *Explorer) refreshDir(node *tview.TreeNode) error {
+	dir := node.GetReference().(*dirRef)
+	children, err := e.readDir(dir.path, e.hides)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*tview.TreeNode)
+	for _, child := range node.GetChildren() {
+		existing[nodePath(child)] = child
+	}
+	for i, child := range children {
+		old, ok := existing[nodePath(child)]
+		if !ok {
+			continue
+		}
+		_, wasDir := old.GetReference().(*dirRef)
+		_, isDir := child.GetReference().(*dirRef)
+		if wasDir == isDir {
+			children[i] = old
+		}
+	}
+	if !dir.loaded && e.loaded != nil {
+		e.loaded(dir.path)
+	}
+	dir.loaded, dir.loading = true, false
+	node.SetChildren(children)
+	return nil
+}
+
+// Refresh reads every loaded directory again
+func (e *Explorer) Refresh() error {
+	var loaded []*tview.TreeNode
+	e.GetRoot().Walk(func(node, parent *tview.TreeNode) bool {
+		dir, ok := node.GetReference().(*dirRef)
+		if ok && dir.loaded {
+			loaded = append(loaded, node)
+		}
+		return ok || parent == nil
+	})
+	for _, node := range loaded {
+		if err := e.refreshDir(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshDir reads the directory at path again if it has been loaded
+func (e *Explorer) RefreshDir(path string) error {
+	node := e.findDirNode(path)
+	if node == nil || !node.GetReference().(*dirRef).loaded {
+		return nil
+	}
+	return e.refreshDir(node)
+}
+
+// Reveal expands the directories leading to path and selects its node.
+// Nothing is selected if the filter hides path.
+func (e *Explorer) Reveal(path string) error {
+	root := e.rootOf(path)
+	node := e.rootNode(root)
+	rel, err := filepath.Rel(absPath(root), absPath(path))
+	if node == nil || err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside the workspace", path)
+	}
+	if rel != "." {
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			dir, ok := node.GetReference().(*dirRef)
+			if !ok {
+				return fmt.Errorf("%s is not a directory", nodePath(node))
+			}
+			if !dir.loaded {
+				if err := e.refreshDir(node); err != nil {
+					return err
+				}
+			}
+			node.Expand()
+			var next *tview.TreeNode
+			for _, child := range node.GetChildren() {
+				if child.GetText() == name {
+					next = child
+					break
+				}
+			}
+			if next == nil {
+				if e.hides != nil && (e.hides(filepath.Join(nodePath(node), name), true) || e.hides(path, false)) {
+					// Hidden entries exist without being shown
+					return nil
+				}
+				return fmt.Errorf("%s not found", path)
+			}
+			node = next
+		}
+	}
+	e.SetCurrentNode(node)
+	return nil
+}
+
+// MouseHandler returns the tree's mouse handler, focusing the explorer
+// rather than the tree inside it on clicks
+func (e *Explorer) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	handler := e.TreeView.MouseHandler()
+	return func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (bool, tview.Primitive) {
+		return handler(action, event, func(p tview.Primitive) {
+			if p == e.TreeView {
+				p = e
+			}
+			setFocus(p)
+		})
+	}
+}
+
+// absPath returns path as an absolute path, or as is if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// withinPath reports whether path is target or lies inside the directory
+// target
+func withinPath(path, target string) bool {
+	return path == target || strings.HasPrefix(path, target+string(filepath.Separator))
+}
@@ -0,0 +1,136 @@
+package explorer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// newTestExplorer returns an explorer of a directory holding the given
+// files, once the root has been read
+func newTestExplorer(t *testing.T, files ...string) (*Explorer, string) {
+	t.Helper()
+	root := t.TempDir()
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if strings.HasSuffix(file, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updates := make(chan func(), 100)
+	e := New(func(update func()) { updates <- update })
+	loaded := map[string]bool{}
+	e.SetLoadedFunc(func(dir string) { loaded[dir] = true })
+	e.SetRoots([]string{root}, filepath.Base)
+	timeout := time.After(5 * time.Second)
+	for !loaded[root] {
+		select {
+		case update := <-updates:
+			update()
+		case <-timeout:
+			t.Fatal("the root was not read")
+		}
+	}
+	return e, root
+}
+
+// names returns the text of the children of node
+func names(node *tview.TreeNode) []string {
+	var names []string
+	for _, child := range node.GetChildren() {
+		names = append(names, child.GetText())
+	}
+	return names
+}
+
+func TestRoots(t *testing.T) {
+	e, root := newTestExplorer(t, "b/", "a.go", ".hidden")
+	top := e.GetRoot().GetChildren()
+	if len(top) != 1 || e.Path(top[0]) != root || !e.IsRoot(top[0]) {
+		t.Fatalf("roots = %v, want %s", top, root)
+	}
+	if got, want := strings.Join(names(top[0]), " "), ".hidden a.go b"; got != want {
+		t.Errorf("children = %q, want %q", got, want)
+	}
+	if got := e.SelectedDir(); got != root {
+		t.Errorf("SelectedDir() = %q, want %q", got, root)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	e, root := newTestExplorer(t, "a.go", ".hidden")
+	hides := func(path string, isDir bool) bool {
+		return strings.HasPrefix(filepath.Base(path), ".")
+	}
+	if err := e.SetFilterFunc(hides).Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(e.rootNode(root)); len(got) != 1 || got[0] != "a.go" {
+		t.Errorf("children = %v, want only a.go", got)
+	}
+	if err := e.Reveal(filepath.Join(root, ".hidden")); err != nil {
+		t.Errorf("Reveal of a hidden file = %v, want no error", err)
+	}
+}
+
+func TestReveal(t *testing.T) {
+	e, root := newTestExplorer(t, "cmd/tool/main.go", "go.mod")
+	path := filepath.Join(root, "cmd", "tool", "main.go")
+	if err := e.Reveal(path); err != nil {
+		t.Fatal(err)
+	}
+	node := e.GetCurrentNode()
+	if node == nil || e.Path(node) != path || e.IsDir(node) {
+		t.Fatalf("selected %v, want the node of %s", node, path)
+	}
+	if got, want := e.SelectedDir(), filepath.Join(root, "cmd", "tool"); got != want {
+		t.Errorf("SelectedDir() = %q, want %q", got, want)
+	}
+	if err := e.Reveal(filepath.Join(root, "missing.go")); err == nil {
+		t.Error("Reveal of a missing file succeeded")
+	}
+	if err := e.Reveal(filepath.Dir(root)); err == nil {
+		t.Error("Reveal outside the root succeeded")
+	}
+}
+
+func TestRefreshDir(t *testing.T) {
+	e, root := newTestExplorer(t, "a.go")
+	if err := os.WriteFile(filepath.Join(root, "b.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RefreshDir(root); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(names(e.rootNode(root)), " "), "a.go b.go"; got != want {
+		t.Errorf("children = %q, want %q", got, want)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	e, root := newTestExplorer(t, "a.go")
+	var opened string
+	e.SetOpenFunc(func(path string) { opened = path })
+	path := filepath.Join(root, "a.go")
+	if err := e.Reveal(path); err != nil {
+		t.Fatal(err)
+	}
+	e.InputHandler()(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), func(tview.Primitive) {})
+	if opened != path {
+		t.Errorf("opened %q, want %q", opened, path)
+	}
+}
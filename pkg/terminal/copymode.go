@@ -0,0 +1,328 @@
+package terminal
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// position is a cell of the terminal in copy mode
+type position struct {
+	Line, Col int
+}
+
+// less reports whether p comes before q
+func (p position) less(q position) bool {
+	return p.Line < q.Line || (p.Line == q.Line && p.Col < q.Col)
+}
+
+// orderPositions returns a and b with the earlier one first
+func orderPositions(a, b position) (position, position) {
+	if b.less(a) {
+		return b, a
+	}
+	return a, b
+}
+
+// copyMode is the state of a terminal view while text is selected with the
+// keyboard instead of being typed into the program. Lines are counted from
+// the start of the scrollback and columns in cells.
+type copyMode struct {
+	cursor    position
+	anchor    position
+	selecting bool
+	lines     bool // the selection covers whole lines
+	dragging  bool
+
+	prompting bool // the search query is being typed
+	input     []rune
+	backward  bool // the search being typed goes up
+	highlight bool // the matches of the last search are shown
+	notFound  bool
+}
+
+// SetCopyFunc sets the handler receiving text yanked in copy mode
+func (t *View) SetCopyFunc(handler func(text string)) *View {
+	t.copied = handler
+	return t
+}
+
+// CopyMode reports whether the view is in copy mode
+func (t *View) CopyMode() bool {
+	return t.copy != nil
+}
+
+// EnterCopyMode starts copy mode with the cursor on the terminal cursor, or
+// on the last visible line when the view is scrolled back
+func (t *View) EnterCopyMode() {
+	if t.copy != nil {
+		return
+	}
+	cx, cy, _ := t.screen.Cursor()
+	pos := position{Line: t.screen.ScrollbackLen() + cy, Col: cx}
+	if t.offset > 0 {
+		pos = position{Line: t.screen.ScrollbackLen() - t.offset + t.screen.rows - 1}
+	}
+	t.copy = &copyMode{cursor: pos, anchor: pos}
+}
+
+// ExitCopyMode returns to typing into the program
+func (t *View) ExitCopyMode() {
+	t.copy = nil
+	t.offset = 0
+}
+
+// lineCount returns the number of lines in the scrollback and on the screen
+func (t *View) lineCount() int {
+	return t.screen.ScrollbackLen() + t.screen.rows
+}
+
+// moveCopyCursor moves the copy mode cursor, scrolling it into view
+func (t *View) moveCopyCursor(pos position) {
+	pos.Line = clampInt(pos.Line, 0, t.lineCount()-1)
+	pos.Col = clampInt(pos.Col, 0, t.screen.cols-1)
+	t.copy.cursor = pos
+	if !t.copy.selecting {
+		t.copy.anchor = pos
+	}
+	first := t.screen.ScrollbackLen() - t.offset
+	if pos.Line < first {
+		t.offset = t.screen.ScrollbackLen() - pos.Line
+	} else if pos.Line >= first+t.screen.rows {
+		t.offset = t.screen.ScrollbackLen() - (pos.Line - t.screen.rows + 1)
+	}
+	t.offset = clampInt(t.offset, 0, t.screen.ScrollbackLen())
+}
+
+// cellsText returns the text of cells from..to (exclusive) of a line, without
+// trailing blanks
+func cellsText(line []vtCell, from, to int) string {
+	var b strings.Builder
+	for x := from; x < to && x < len(line); x++ {
+		if line[x].r != 0 {
+			b.WriteRune(line[x].r)
+			for _, r := range line[x].comb {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// selection returns the ordered bounds of the copy mode selection, with the
+// end column inclusive
+func (t *View) selection() (position, position) {
+	from, to := orderPositions(t.copy.anchor, t.copy.cursor)
+	if t.copy.lines {
+		from.Col, to.Col = 0, t.screen.cols-1
+	}
+	return from, to
+}
+
+// selected reports whether the cell at col of line is part of the selection
+func (t *View) selected(line, col int) bool {
+	if t.copy == nil || !t.copy.selecting {
+		return false
+	}
+	from, to := t.selection()
+	pos := position{Line: line, Col: col}
+	return !pos.less(from) && !to.less(pos)
+}
+
+// SelectedText returns the text of the copy mode selection
+func (t *View) SelectedText() string {
+	if t.copy == nil || !t.copy.selecting {
+		return ""
+	}
+	from, to := t.selection()
+	var lines []string
+	for n := from.Line; n <= to.Line; n++ {
+		line := t.screen.Line(n)
+		start, end := 0, len(line)
+		if n == from.Line {
+			start = from.Col
+		}
+		if n == to.Line {
+			end = to.Col + 1
+		}
+		lines = append(lines, cellsText(line, start, end))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// yank passes the selection to the copy handler and leaves copy mode
+func (t *View) yank() {
+	if text := t.SelectedText(); text != "" && t.copied != nil {
+		t.copied(text)
+	}
+	t.ExitCopyMode()
+}
+
+// firstNonBlank returns the column of the first non-blank cell of a line
+func firstNonBlank(line []vtCell) int {
+	for x, cell := range line {
+		if cell.r != ' ' && cell.r != 0 {
+			return x
+		}
+	}
+	return 0
+}
+
+// lineEnd returns the column of the last non-blank cell of a line
+func lineEnd(line []vtCell) int {
+	for x := len(line) - 1; x > 0; x-- {
+		if line[x].r != ' ' && line[x].r != 0 {
+			return x
+		}
+	}
+	return 0
+}
+
+// HandleCopyKey interprets a key in copy mode. The keys follow tmux's vi
+// copy mode.
+func (t *View) HandleCopyKey(event *tcell.EventKey) {
+	c := t.copy
+	if c.prompting {
+		t.handlePromptKey(event)
+		return
+	}
+	pos := c.cursor
+	half := t.screen.rows / 2
+	switch event.Key() {
+	case tcell.KeyLeft:
+		pos.Col--
+	case tcell.KeyRight:
+		pos.Col++
+	case tcell.KeyUp:
+		pos.Line--
+	case tcell.KeyDown:
+		pos.Line++
+	case tcell.KeyHome:
+		pos.Col = 0
+	case tcell.KeyEnd:
+		pos.Col = lineEnd(t.screen.Line(pos.Line))
+	case tcell.KeyPgUp, tcell.KeyCtrlB:
+		pos.Line -= t.screen.rows - 1
+	case tcell.KeyPgDn, tcell.KeyCtrlF:
+		pos.Line += t.screen.rows - 1
+	case tcell.KeyCtrlU:
+		pos.Line -= half
+	case tcell.KeyCtrlD:
+		pos.Line += half
+	case tcell.KeyEnter:
+		t.yank()
+		return
+	case tcell.KeyEscape:
+		if c.highlight {
+			c.highlight, c.notFound = false, false
+			return
+		}
+		if c.selecting {
+			c.selecting, c.lines = false, false
+			c.anchor = c.cursor
+			return
+		}
+		t.ExitCopyMode()
+		return
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'h':
+			pos.Col--
+		case 'l':
+			pos.Col++
+		case 'k':
+			pos.Line--
+		case 'j':
+			pos.Line++
+		case '0':
+			pos.Col = 0
+		case '^':
+			pos.Col = firstNonBlank(t.screen.Line(pos.Line))
+		case '$':
+			pos.Col = lineEnd(t.screen.Line(pos.Line))
+		case 'g':
+			pos = position{}
+		case 'G':
+			pos = position{Line: t.lineCount() - 1}
+		case 'H':
+			pos.Line = t.screen.ScrollbackLen() - t.offset
+		case 'L':
+			pos.Line = t.screen.ScrollbackLen() - t.offset + t.screen.rows - 1
+		case 'v', ' ':
+			c.selecting = !c.selecting || c.lines
+			c.lines = false
+			c.anchor = c.cursor
+			return
+		case 'V':
+			c.selecting = !c.selecting || !c.lines
+			c.lines = c.selecting
+			c.anchor = c.cursor
+			return
+		case 'y':
+			t.yank()
+			return
+		case 'q':
+			t.ExitCopyMode()
+			return
+		case '/', '?':
+			c.prompting, c.backward = true, event.Rune() == '?'
+			c.input = nil
+			return
+		case 'n', 'N':
+			t.searchNext(event.Rune() == 'N')
+			return
+		}
+	}
+	t.moveCopyCursor(pos)
+}
+
+// cellPosition converts screen coordinates into a copy mode position
+func (t *View) cellPosition(x, y int) position {
+	rectX, rectY, _, _ := t.GetInnerRect()
+	return position{
+		Line: t.screen.ScrollbackLen() - t.offset + clampInt(y-rectY, 0, t.screen.rows-1),
+		Col:  clampInt(x-rectX, 0, t.screen.cols-1),
+	}
+}
+
+// handleCopyMouse selects text by dragging. Releasing the button yanks the
+// selection, as in tmux.
+func (t *View) handleCopyMouse(action tview.MouseAction, event *tcell.EventMouse) bool {
+	x, y := event.Position()
+	switch action {
+	case tview.MouseLeftDown:
+		t.dragStart = t.cellPosition(x, y)
+		t.dragStarted = true
+		if t.copy != nil {
+			t.copy.selecting, t.copy.lines = false, false
+			t.moveCopyCursor(t.dragStart)
+		}
+		return true
+	case tview.MouseMove:
+		if !t.dragStarted {
+			return false
+		}
+		pos := t.cellPosition(x, y)
+		if t.copy == nil {
+			if pos == t.dragStart {
+				return true
+			}
+			t.EnterCopyMode()
+		}
+		if !t.copy.dragging {
+			t.copy.dragging, t.copy.selecting, t.copy.lines = true, true, false
+			t.copy.anchor = t.dragStart
+		}
+		t.moveCopyCursor(pos)
+		return true
+	case tview.MouseLeftUp:
+		dragged := t.copy != nil && t.copy.dragging
+		t.dragStarted = false
+		if dragged {
+			t.yank()
+		}
+		return dragged
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package terminal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// urlPattern matches the URLs the view links besides the hyperlinks
+// programs mark with OSC 8
+var urlPattern = regexp.MustCompile(`\b(?:https?|ftp|file)://[^\s<>"'` + "`" + `]+`)
+
+// urlClosers are the brackets a URL ends with only if it opens them too
+var urlClosers = map[byte]byte{')': '(', ']': '[', '}': '{'}
+
+// FindURLs returns the byte ranges of the URLs in text. Punctuation ending
+// a sentence after a URL, or a bracket closing around it, is left out.
+func FindURLs(text string) [][]int {
+	spans := urlPattern.FindAllStringIndex(text, -1)
+	for _, span := range spans {
+		span[1] = span[0] + len(trimURL(text[span[0]:span[1]]))
+	}
+	return spans
+}
+
+// trimURL removes the punctuation that follows a URL in prose
+func trimURL(url string) string {
+	for len(url) > 0 {
+		last := url[len(url)-1]
+		if open, ok := urlClosers[last]; ok {
+			if strings.Count(url, string(open)) >= strings.Count(url, string(last)) {
+				return url
+			}
+		} else if !strings.ContainsRune(".,;:!?", rune(last)) {
+			return url
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+// linkSpan is a run of cells of a line linking to uri, with to inclusive
+type linkSpan struct {
+	from, to int
+	uri      string
+}
+
+// SetLinkFunc sets the handler receiving the URI of a link clicked outside
+// copy mode
+func (t *View) SetLinkFunc(handler func(uri string)) *View {
+	t.linked = handler
+	return t
+}
+
+// lineLinks returns the links of a line: the hyperlinks marked with OSC 8,
+// and the URLs in the rest of its text
+func (t *View) lineLinks(line []vtCell) []linkSpan {
+	var spans []linkSpan
+	for x := 0; x < len(line); x++ {
+		if id := line[x].link; id > 0 {
+			span := linkSpan{from: x, to: x, uri: t.screen.links[id-1]}
+			for x+1 < len(line) && line[x+1].link == id {
+				x++
+			}
+			span.to = x
+			spans = append(spans, span)
+		}
+	}
+
+	// The cell each byte of the text is in
+	var b strings.Builder
+	var cols []int
+	write := func(r rune, x int) {
+		n, _ := b.WriteRune(r)
+		for i := 0; i < n; i++ {
+			cols = append(cols, x)
+		}
+	}
+	for x, cell := range line {
+		if cell.r == 0 {
+			continue
+		}
+		write(cell.r, x)
+		for _, r := range cell.comb {
+			write(r, x)
+		}
+	}
+	text := b.String()
+	for _, url := range FindURLs(text) {
+		span := linkSpan{from: cols[url[0]], to: cols[url[1]-1], uri: text[url[0]:url[1]]}
+		if line[span.from].link > 0 {
+			continue
+		}
+		if span.to+1 < len(line) && line[span.to+1].r == 0 {
+			span.to++
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// linkAt returns the URI of the link at a position, or "" if there is none
+func (t *View) linkAt(pos position) string {
+	for _, span := range t.lineLinks(t.screen.Line(pos.Line)) {
+		if pos.Col >= span.from && pos.Col <= span.to {
+			return span.uri
+		}
+	}
+	return ""
+}
+
+// Links returns the URIs linked on the screen and in the scrollback, the
+// most recent first, with each one once
+func (t *View) Links() []string {
+	var uris []string
+	seen := map[string]bool{}
+	for n := t.lineCount() - 1; n >= 0; n-- {
+		for _, span := range t.lineLinks(t.screen.Line(n)) {
+			if !seen[span.uri] {
+				seen[span.uri] = true
+				uris = append(uris, span.uri)
+			}
+		}
+	}
+	return uris
+}
@@ -0,0 +1,167 @@
+package terminal
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// Mouse tracking modes, named after the DEC private modes programs set to
+// choose which mouse events are reported to them
+const (
+	mouseOff          = 0
+	mouseX10          = 9    // button presses
+	mouseNormal       = 1000 // presses and releases
+	mouseButtonMotion = 1002 // and motion while a button is held
+	mouseAnyMotion    = 1003 // and all motion
+)
+
+// Button numbers of mouse reports
+const (
+	mouseLeft       = 0
+	mouseMiddle     = 1
+	mouseRight      = 2
+	mouseNoButton   = 3 // released, in the legacy encoding, or moved
+	mouseWheelUp    = 64
+	mouseWheelDown  = 65
+	mouseWheelLeft  = 66
+	mouseWheelRight = 67
+)
+
+// mouseActions are the buttons of the tview mouse actions reported to
+// programs, and whether the action releases the button
+var mouseActions = map[tview.MouseAction]struct {
+	button  int
+	release bool
+}{
+	tview.MouseLeftDown:    {mouseLeft, false},
+	tview.MouseMiddleDown:  {mouseMiddle, false},
+	tview.MouseRightDown:   {mouseRight, false},
+	tview.MouseLeftUp:      {mouseLeft, true},
+	tview.MouseMiddleUp:    {mouseMiddle, true},
+	tview.MouseRightUp:     {mouseRight, true},
+	tview.MouseScrollUp:    {mouseWheelUp, false},
+	tview.MouseScrollDown:  {mouseWheelDown, false},
+	tview.MouseScrollLeft:  {mouseWheelLeft, false},
+	tview.MouseScrollRight: {mouseWheelRight, false},
+}
+
+// SetMouseReportFunc sets the handler receiving the reports of mouse events
+// for programs that track the mouse, and the cursor keys the wheel sends on
+// the alternate screen, which should be sent to the program
+func (t *View) SetMouseReportFunc(handler func(report []byte)) *View {
+	t.reported = handler
+	return t
+}
+
+// MouseTracking reports whether the program asked for mouse events
+func (t *View) MouseTracking() bool {
+	return t.screen.mouseTracking != mouseOff
+}
+
+// reportMouse reports a mouse event to the program if it tracks the mouse,
+// or sends the keys the wheel stands for, and returns whether it did, so
+// the view does not handle the event too.
+// Events with Shift held are left to the view, so text can be selected
+// as in xterm, and so are events while the view is scrolled back or in
+// copy mode.
+func (t *View) reportMouse(action tview.MouseAction, event *tcell.EventMouse) bool {
+	if t.reported == nil || t.copy != nil || t.offset > 0 || event.Modifiers()&tcell.ModShift != 0 {
+		return false
+	}
+	mode := t.screen.mouseTracking
+	if mode == mouseOff {
+		return t.scrollAlternate(action)
+	}
+	button, motion, release := mouseNoButton, false, false
+	switch action {
+	case tview.MouseLeftClick, tview.MouseLeftDoubleClick, tview.MouseMiddleClick, tview.MouseRightClick:
+		// Already reported as a press and a release
+		return true
+	case tview.MouseMove:
+		// tview reports moving to where a button is pressed before the
+		// press, which the program is not told about as a drag
+		motion = true
+		if t.mouseHeld {
+			button = t.mouseButton
+		}
+	default:
+		reported, ok := mouseActions[action]
+		if !ok {
+			return false
+		}
+		button, release = reported.button, reported.release
+	}
+
+	switch {
+	case mode == mouseX10 && (release || motion):
+		return true
+	case mode == mouseNormal && motion:
+		return true
+	case mode == mouseButtonMotion && motion && button == mouseNoButton:
+		return true
+	}
+	if !motion {
+		t.mouseHeld = !release && button < mouseWheelUp
+		t.mouseButton = button
+	}
+
+	code := button
+	if motion {
+		code += 32
+	}
+	if mode != mouseX10 {
+		mod := event.Modifiers()
+		if mod&(tcell.ModAlt|tcell.ModMeta) != 0 {
+			code += 8
+		}
+		if mod&tcell.ModCtrl != 0 {
+			code += 16
+		}
+	}
+	rectX, rectY, width, height := t.GetInnerRect()
+	x, y := event.Position()
+	col, row := clampInt(x-rectX, 0, width-1)+1, clampInt(y-rectY, 0, height-1)+1
+
+	if t.screen.mouseSGR {
+		final := 'M'
+		if release {
+			final = 'm'
+		}
+		t.reported([]byte(fmt.Sprintf("\x1b[<%d;%d;%d%c", code, col, row, final)))
+		return true
+	}
+	if release {
+		// The legacy encoding does not tell which button was released
+		code = code&^3 | mouseNoButton
+	}
+	if col > 223 || row > 223 {
+		// Beyond the coordinates a byte can carry
+		return true
+	}
+	t.reported([]byte{0x1b, '[', 'M', byte(32 + code), byte(32 + col), byte(32 + row)})
+	return true
+}
+
+// scrollAlternate turns the wheel into cursor keys on the alternate screen,
+// which has no scrollback, so programs such as less and man scroll with it
+// even if they do not track the mouse
+func (t *View) scrollAlternate(action tview.MouseAction) bool {
+	if !t.screen.Alternate() || !t.screen.altScroll {
+		return false
+	}
+	key := tcell.KeyUp
+	switch action {
+	case tview.MouseScrollUp:
+	case tview.MouseScrollDown:
+		key = tcell.KeyDown
+	default:
+		return false
+	}
+	seq := encodeKey(tcell.NewEventKey(key, 0, tcell.ModNone), t.screen.appCursor)
+	for i := 0; i < 3; i++ {
+		t.reported(seq)
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package terminal
+
+import (
+	"gotui/pkg/textsearch"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// match is an occurrence of the search query, from the first to the last
+// of its cells
+type match struct {
+	from, to int
+}
+
+// StartSearch enters copy mode and opens the prompt for a search towards
+// the bottom, or, with backward set, towards the top of the scrollback
+func (t *View) StartSearch(backward bool) {
+	t.EnterCopyMode()
+	t.copy.prompting, t.copy.backward = true, backward
+	t.copy.input = nil
+}
+
+// handlePromptKey edits the query typed at the search prompt. Enter
+// searches, with the previous query if none was typed, and Esc closes the
+// prompt.
+func (t *View) handlePromptKey(event *tcell.EventKey) {
+	c := t.copy
+	switch event.Key() {
+	case tcell.KeyEnter:
+		c.prompting = false
+		if len(c.input) > 0 {
+			t.query = c.input
+		}
+		t.searchUp = c.backward
+		t.searchNext(false)
+	case tcell.KeyEscape:
+		c.prompting = false
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(c.input) > 0 {
+			c.input = c.input[:len(c.input)-1]
+		}
+	case tcell.KeyCtrlU:
+		c.input = nil
+	case tcell.KeyRune:
+		c.input = append(c.input, event.Rune())
+	}
+}
+
+// searchNext moves the copy mode cursor to the next match of the query in
+// the direction of the last search, or the other way with reverse set. The
+// search wraps around at the top and the bottom, as in tmux.
+func (t *View) searchNext(reverse bool) {
+	c := t.copy
+	c.highlight, c.notFound = len(t.query) > 0, false
+	if !c.highlight {
+		return
+	}
+	needle, fold := textsearch.Needle(t.query)
+	backward := t.searchUp != reverse
+	count := t.lineCount()
+	from := c.cursor
+	for i := 0; i <= count; i++ {
+		n := from.Line + i
+		if backward {
+			n = from.Line - i
+		}
+		n = (n%count + count) % count
+		matches := lineMatches(t.screen.Line(n), needle, fold)
+		if backward {
+			for j := len(matches) - 1; j >= 0; j-- {
+				col := matches[j].from
+				if i == 0 && col >= from.Col || i == count && col < from.Col {
+					continue
+				}
+				t.moveCopyCursor(position{Line: n, Col: col})
+				return
+			}
+			continue
+		}
+		for _, m := range matches {
+			if i == 0 && m.from <= from.Col || i == count && m.from > from.Col {
+				continue
+			}
+			t.moveCopyCursor(position{Line: n, Col: m.from})
+			return
+		}
+	}
+	c.notFound = true
+}
+
+// matchAt returns the match of a line the cell at col is part of, given
+// the matches of the line
+func matchAt(matches []match, col int) (match, bool) {
+	for _, m := range matches {
+		if col >= m.from && col <= m.to {
+			return m, true
+		}
+	}
+	return match{}, false
+}
+
+// visibleMatches returns the matches of the last search on line, if they
+// are highlighted
+func (t *View) visibleMatches(line int) []match {
+	if t.copy == nil || !t.copy.highlight {
+		return nil
+	}
+	needle, fold := textsearch.Needle(t.query)
+	return lineMatches(t.screen.Line(line), needle, fold)
+}
+
+// lineMatches returns the non-overlapping occurrences of needle in line.
+// Combining characters are matched as the runes they are, but belong to the
+// cell of the character they follow.
+func lineMatches(line []vtCell, needle []rune, fold bool) []match {
+	var text []rune
+	var cols []int
+	for x, cell := range line {
+		// The second cell of a wide character holds no rune
+		if cell.r == 0 {
+			continue
+		}
+		text = append(text, cell.r)
+		cols = append(cols, x)
+		for _, r := range cell.comb {
+			text = append(text, r)
+			cols = append(cols, x)
+		}
+	}
+	var matches []match
+	for _, i := range textsearch.Find(text, needle, fold) {
+		m := match{from: cols[i], to: cols[i+len(needle)-1]}
+		if m.to+1 < len(line) && line[m.to+1].r == 0 {
+			m.to++
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
@@ -0,0 +1,444 @@
+// Package terminal provides a tview widget emulating an xterm compatible
+// terminal. The widget only interprets output and encodes input; running the
+// program, such as a shell in a pty, is up to its user.
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// View is a widget showing the screen of a terminal emulator. Output
+// written to it is interpreted as xterm escape sequences.
+type View struct {
+	*tview.Box
+
+	screen            *vtScreen
+	textColor         tcell.Color
+	indicatorStyle    tcell.Style
+	matchStyle        tcell.Style
+	currentMatchStyle tcell.Style
+
+	// offset is the number of lines the view is scrolled back from the
+	// bottom. With follow set, new output scrolls the view to the bottom.
+	offset int
+	follow bool
+
+	copy        *copyMode // nil unless in copy mode
+	query       []rune    // of the last search, kept for the next copy mode
+	searchUp    bool
+	dragStart   position
+	dragStarted bool
+	mouseHeld   bool // a press of mouseButton was reported to the program
+	mouseButton int
+
+	resized  func(cols, rows int)
+	copied   func(text string)
+	pasted   func(text string)
+	clicked  func(line string, index int)
+	linked   func(uri string)
+	reported func(report []byte)
+}
+
+// NewView returns a terminal widget with an 80x24 screen, which is resized
+// to the widget when it is drawn
+func NewView() *View {
+	return &View{
+		Box:               tview.NewBox(),
+		screen:            newVTScreen(80, 24),
+		textColor:         tview.Styles.PrimaryTextColor,
+		indicatorStyle:    tcell.StyleDefault.Background(tview.Styles.ContrastBackgroundColor).Foreground(tview.Styles.PrimaryTextColor),
+		matchStyle:        tcell.StyleDefault.Background(tcell.ColorOlive).Foreground(tcell.ColorBlack),
+		currentMatchStyle: tcell.StyleDefault.Background(tview.Styles.ContrastBackgroundColor).Foreground(tview.Styles.PrimaryTextColor),
+		follow:            true,
+	}
+}
+
+// SetTextColor sets the color used for text without an explicit color
+func (t *View) SetTextColor(color tcell.Color) *View {
+	t.textColor = color
+	return t
+}
+
+// SetIndicatorStyle sets the style of the scroll position shown in the top
+// right corner while the view is scrolled back
+func (t *View) SetIndicatorStyle(style tcell.Style) *View {
+	t.indicatorStyle = style
+	return t
+}
+
+// SetMatchStyle sets the styles of the matches of a search in copy mode,
+// and of the match the cursor is on
+func (t *View) SetMatchStyle(match, current tcell.Style) *View {
+	t.matchStyle, t.currentMatchStyle = match, current
+	return t
+}
+
+// WorkingDir returns the working directory the program last reported with
+// OSC 7, as shells configured for it do at each prompt, or "" if it did not
+func (t *View) WorkingDir() string {
+	return t.screen.dir
+}
+
+// SetReplyFunc sets the handler receiving the terminal's responses to status
+// requests, which should be sent back to the program
+func (t *View) SetReplyFunc(handler func([]byte)) *View {
+	t.screen.reply = handler
+	return t
+}
+
+// SetResizedFunc sets the handler called when the screen size changes, so
+// the size can be passed on to the program
+func (t *View) SetResizedFunc(handler func(cols, rows int)) *View {
+	t.resized = handler
+	return t
+}
+
+// Size returns the number of columns and rows of the screen
+func (t *View) Size() (int, int) {
+	return t.screen.cols, t.screen.rows
+}
+
+// Write interprets program output
+func (t *View) Write(p []byte) (int, error) {
+	scrolled, trimmed := t.screen.scrolled, t.screen.trimmed
+	alternate := t.screen.Alternate()
+	n, err := t.screen.Write(p)
+	if t.screen.Alternate() != alternate {
+		// The lines of the other screen are numbered differently, and a
+		// full-screen program starting or exiting should be seen
+		t.ExitCopyMode()
+		t.dragStarted = false
+	} else if t.copy != nil {
+		// Lines keep their numbers unless old ones left the scrollback
+		dropped := t.screen.trimmed - trimmed
+		for _, pos := range []*position{&t.copy.cursor, &t.copy.anchor, &t.dragStart} {
+			pos.Line = clampInt(pos.Line-dropped, 0, t.lineCount()-1)
+		}
+		t.Scroll(t.screen.scrolled - scrolled)
+	} else if t.follow {
+		t.offset = 0
+	} else if t.offset > 0 {
+		// Keep showing the same lines while output arrives
+		t.Scroll(t.screen.scrolled - scrolled)
+	}
+	return n, err
+}
+
+// Scroll scrolls the view back by n lines, or forward for negative n
+func (t *View) Scroll(n int) {
+	t.offset = clampInt(t.offset+n, 0, t.screen.ScrollbackLen())
+}
+
+// ScrollPage scrolls the view back by n pages, or forward for negative n
+func (t *View) ScrollPage(n int) {
+	page := t.screen.rows - 1
+	if page < 1 {
+		page = 1
+	}
+	t.Scroll(n * page)
+}
+
+// ScrollToBottom shows the live screen again
+func (t *View) ScrollToBottom() {
+	t.offset = 0
+}
+
+// SetFollow sets whether new output scrolls the view to the bottom
+func (t *View) SetFollow(follow bool) *View {
+	t.follow = follow
+	return t
+}
+
+// Follow reports whether new output scrolls the view to the bottom
+func (t *View) Follow() bool {
+	return t.follow
+}
+
+// SetPasteFunc sets the handler receiving text pasted into the terminal
+func (t *View) SetPasteFunc(handler func(text string)) *View {
+	t.pasted = handler
+	return t
+}
+
+// SetClickFunc sets the handler called when a line is clicked without
+// dragging, outside copy mode. It gets the text of the line and the index of
+// the clicked rune in it, for opening what the text there names.
+func (t *View) SetClickFunc(handler func(line string, index int)) *View {
+	t.clicked = handler
+	return t
+}
+
+// PasteHandler returns the handler for this primitive
+func (t *View) PasteHandler() func(text string, setFocus func(p tview.Primitive)) {
+	return t.WrapPasteHandler(func(text string, setFocus func(p tview.Primitive)) {
+		if t.pasted != nil {
+			t.pasted(text)
+		}
+	})
+}
+
+// EncodePaste returns the bytes to send to the program for pasted text
+func (t *View) EncodePaste(text string) []byte {
+	return encodePaste(text, t.screen.bracketPaste)
+}
+
+// encodePaste prepares pasted text for the program. Line breaks are sent as
+// carriage returns, like typed Enter keys. In bracketed paste mode the text
+// is wrapped in markers so the program can tell it was not typed.
+func encodePaste(text string, bracketed bool) []byte {
+	text = strings.NewReplacer("\r\n", "\r", "\n", "\r").Replace(text)
+	if !bracketed {
+		return []byte(text)
+	}
+	// A pasted end marker would let the rest of the text run as typed input
+	text = strings.ReplaceAll(text, "\x1b[201~", "")
+	return []byte("\x1b[200~" + text + "\x1b[201~")
+}
+
+// MouseHandler returns the mouse handler for this primitive
+func (t *View) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return t.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !t.InRect(event.Position()) && !t.dragStarted && !t.mouseHeld {
+			return false, nil
+		}
+		if action == tview.MouseLeftDown {
+			setFocus(t)
+		}
+		if t.reportMouse(action, event) {
+			if t.mouseHeld {
+				// Keep getting the events until the button is released
+				return true, t
+			}
+			return true, nil
+		}
+		t.mouseHeld = false
+		if t.handleCopyMouse(action, event) {
+			if t.dragStarted {
+				return true, t
+			}
+			return true, nil
+		}
+		switch action {
+		case tview.MouseLeftClick:
+			if t.copy != nil {
+				return true, nil
+			}
+			pos := t.cellPosition(event.Position())
+			if uri := t.linkAt(pos); uri != "" && t.linked != nil {
+				t.linked(uri)
+			} else if t.clicked != nil {
+				t.click(pos)
+			}
+			return true, nil
+		case tview.MouseScrollUp:
+			t.Scroll(3)
+			return true, nil
+		case tview.MouseScrollDown:
+			t.Scroll(-3)
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+// click passes the line clicked at pos to the click handler
+func (t *View) click(pos position) {
+	line := t.screen.Line(pos.Line)
+	index := 0
+	for x := 0; x < pos.Col && x < len(line); x++ {
+		// The second cell of a wide character holds no rune
+		if line[x].r != 0 {
+			index += 1 + len(line[x].comb)
+		}
+	}
+	t.clicked(cellsText(line, 0, len(line)), index)
+}
+
+// Draw draws this primitive onto the screen
+func (t *View) Draw(screen tcell.Screen) {
+	t.Box.DrawForSubclass(screen, t)
+	x, y, width, height := t.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	if width != t.screen.cols || height != t.screen.rows {
+		t.screen.Resize(width, height)
+		if t.resized != nil {
+			t.resized(t.screen.cols, t.screen.rows)
+		}
+	}
+
+	t.offset = clampInt(t.offset, 0, t.screen.ScrollbackLen())
+	first := t.screen.ScrollbackLen() - t.offset
+	bg := t.GetBackgroundColor()
+	for row := 0; row < t.screen.rows; row++ {
+		n := first + row
+		line := t.screen.Line(n)
+		matches := t.visibleMatches(n)
+		links := t.lineLinks(line)
+		for col := 0; col < len(line) && col < width; col++ {
+			cell := line[col]
+			if cell.r == 0 {
+				continue
+			}
+			style := cell.style
+			fg, cellBg, _ := style.Decompose()
+			if fg == tcell.ColorDefault {
+				style = style.Foreground(t.textColor)
+			}
+			if cellBg == tcell.ColorDefault {
+				style = style.Background(bg)
+			}
+			for _, span := range links {
+				if col >= span.from && col <= span.to {
+					style = style.Underline(true)
+				}
+			}
+			if m, ok := matchAt(matches, col); ok {
+				style = t.matchStyle
+				if n == t.copy.cursor.Line && m.from == t.copy.cursor.Col {
+					style = t.currentMatchStyle
+				}
+			}
+			if t.selected(n, col) {
+				_, _, attrs := style.Decompose()
+				style = style.Reverse(attrs&tcell.AttrReverse == 0)
+			}
+			screen.SetContent(x+col, y+row, cell.r, cell.comb, style)
+		}
+	}
+
+	if t.copy != nil {
+		indicator := fmt.Sprintf("[copy %d/%d]", t.offset, t.screen.ScrollbackLen())
+		if t.copy.notFound {
+			indicator = fmt.Sprintf("[not found: %s] ", string(t.query)) + indicator
+		}
+		indicatorWidth := runewidth.StringWidth(indicator)
+		printText(screen, indicator, x+width-indicatorWidth, y, indicatorWidth, t.indicatorStyle)
+		if t.copy.prompting {
+			// The prompt takes the bottom line, like tmux's in the status line
+			prompt := "Search down: "
+			if t.copy.backward {
+				prompt = "Search up: "
+			}
+			for col := 0; col < width; col++ {
+				screen.SetContent(x+col, y+height-1, ' ', nil, t.indicatorStyle)
+			}
+			end := printText(screen, prompt+string(t.copy.input), x, y+height-1, width, t.indicatorStyle)
+			if t.HasFocus() {
+				screen.ShowCursor(end, y+height-1)
+			}
+		} else if row := t.copy.cursor.Line - first; t.HasFocus() && row >= 0 && row < height {
+			screen.ShowCursor(x+t.copy.cursor.Col, y+row)
+		}
+	} else if t.offset > 0 {
+		// Show how far back the view is, like tmux does in copy mode
+		indicator := fmt.Sprintf("[%d/%d]", t.offset, t.screen.ScrollbackLen())
+		printText(screen, indicator, x+width-len(indicator), y, len(indicator), t.indicatorStyle)
+	} else if cx, cy, visible := t.screen.Cursor(); t.HasFocus() && visible {
+		screen.ShowCursor(x+cx, y+cy)
+	}
+}
+
+// printText prints text without interpreting style tags and returns the
+// column after it
+func printText(screen tcell.Screen, text string, x, y, width int, style tcell.Style) int {
+	end := x + width
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			r = ' '
+		}
+		w := runewidth.RuneWidth(r)
+		if x+w > end {
+			break
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+	return x
+}
+
+// EncodeKey returns the bytes to send to the program for a key press, or
+// nil if the key has no encoding
+func (t *View) EncodeKey(event *tcell.EventKey) []byte {
+	return encodeKey(event, t.screen.appCursor)
+}
+
+// cursorKeys are the final bytes of the cursor and editing keys that are
+// sent as CSI or SS3 letter sequences
+var cursorKeys = map[tcell.Key]byte{
+	tcell.KeyUp: 'A', tcell.KeyDown: 'B', tcell.KeyRight: 'C', tcell.KeyLeft: 'D',
+	tcell.KeyHome: 'H', tcell.KeyEnd: 'F',
+	tcell.KeyF1: 'P', tcell.KeyF2: 'Q', tcell.KeyF3: 'R', tcell.KeyF4: 'S',
+}
+
+// tildeKeys are the numbers of the keys sent as "CSI number ~" sequences
+var tildeKeys = map[tcell.Key]int{
+	tcell.KeyInsert: 2, tcell.KeyDelete: 3, tcell.KeyPgUp: 5, tcell.KeyPgDn: 6,
+	tcell.KeyF5: 15, tcell.KeyF6: 17, tcell.KeyF7: 18, tcell.KeyF8: 19,
+	tcell.KeyF9: 20, tcell.KeyF10: 21, tcell.KeyF11: 23, tcell.KeyF12: 24,
+}
+
+// encodeKey translates a key press into the bytes an xterm sends for it.
+// With appCursor the cursor keys use the application mode sequences.
+func encodeKey(event *tcell.EventKey, appCursor bool) []byte {
+	key, mod := event.Key(), event.Modifiers()
+	if key >= tcell.KeyF13 && key <= tcell.KeyF24 {
+		// Shift+F1-F12 in terminals without modifier reporting
+		key, mod = key-12, mod|tcell.ModShift
+	}
+	// xterm encodes modifiers as 1 + Shift + 2*Alt + 4*Ctrl
+	param := 1
+	if mod&tcell.ModShift != 0 {
+		param++
+	}
+	if mod&(tcell.ModAlt|tcell.ModMeta) != 0 {
+		param += 2
+	}
+	if mod&tcell.ModCtrl != 0 {
+		param += 4
+	}
+
+	if final, ok := cursorKeys[key]; ok {
+		isFunction := key >= tcell.KeyF1 && key <= tcell.KeyF4
+		switch {
+		case param > 1:
+			return []byte(fmt.Sprintf("\x1b[1;%d%c", param, final))
+		case appCursor || isFunction:
+			return []byte{0x1b, 'O', final}
+		}
+		return []byte{0x1b, '[', final}
+	}
+	if number, ok := tildeKeys[key]; ok {
+		if param > 1 {
+			return []byte(fmt.Sprintf("\x1b[%d;%d~", number, param))
+		}
+		return []byte(fmt.Sprintf("\x1b[%d~", number))
+	}
+
+	var seq []byte
+	switch {
+	case key == tcell.KeyRune:
+		seq = []byte(string(event.Rune()))
+	case key == tcell.KeyBacktab:
+		return []byte("\x1b[Z")
+	case key == tcell.KeyEnter:
+		seq = []byte{'\r'}
+	case key <= tcell.KeyCtrlUnderscore || key == tcell.KeyDEL:
+		// Control keys, including Tab, Backspace, and Escape, are sent as is
+		seq = []byte{byte(key)}
+	default:
+		return nil
+	}
+	if mod&(tcell.ModAlt|tcell.ModMeta) != 0 {
+		// Alt prefixes the key with ESC
+		seq = append([]byte{0x1b}, seq...)
+	}
+	return seq
+}
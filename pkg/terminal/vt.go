@@ -0,0 +1,1113 @@
+package terminal
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// vtCell is one character cell of the terminal screen. The right half of a
+// wide rune is a cell with r == 0.
+type vtCell struct {
+	r     rune
+	comb  []rune // combining marks and other zero-width runes following r
+	style tcell.Style
+	link  int // hyperlink, counted from 1 in vtScreen.links, 0 if none
+}
+
+// vtCursor is the cursor position together with the state saved by DECSC
+type vtCursor struct {
+	x, y     int
+	style    tcell.Style
+	wrapNext bool // the last column was written and the next rune wraps
+	charsets [2]bool
+	charset  int
+	origin   bool
+}
+
+// vtBuffer is one of the two screens of the terminal
+type vtBuffer struct {
+	lines [][]vtCell
+	saved vtCursor
+}
+
+// vtScrollbackLines is the number of lines kept in the scrollback
+const vtScrollbackLines = 10000
+
+// Parser states of the escape sequence state machine
+const (
+	vtGround = iota
+	vtEscape
+	vtCharset
+	vtCSI
+	vtOSC
+	vtString // DCS, SOS, PM, and APC strings, which are ignored
+	vtStringEscape
+	vtSkip // ignores the final byte of an unsupported escape sequence
+)
+
+// vtScreen models an xterm compatible terminal screen: it interprets the
+// output of programs running in the terminal and keeps the resulting grid
+// of cells
+type vtScreen struct {
+	cols, rows int
+
+	main, alternate vtBuffer
+	buf             *vtBuffer
+	cursor          vtCursor
+
+	top, bottom int // scroll region, bottom is exclusive
+	tabs        []bool
+
+	autowrap      bool
+	insert        bool
+	cursorVisible bool
+	appCursor     bool // DECCKM: cursor keys send SS3 sequences
+	bracketPaste  bool // pasted text is wrapped in CSI 200~ and CSI 201~
+	mouseTracking int  // which mouse events are reported, see mouseX10
+	mouseSGR      bool // mouse events are reported in the SGR encoding
+	altScroll     bool // the wheel sends cursor keys on the alternate screen
+
+	state     int
+	params    []byte // CSI parameter and intermediate bytes
+	osc       []byte
+	charsetOf int    // G0 or G1, while a charset is being designated
+	pending   []byte // incomplete UTF-8 sequence
+
+	scrollback [][]vtCell // lines scrolled off the main screen, oldest first
+	scrolled   int        // number of lines ever added to the scrollback
+	trimmed    int        // number of lines ever dropped from its start
+
+	title string
+	dir   string // working directory, as reported with OSC 7
+
+	// Hyperlinks set with OSC 8. Cells refer to them by number, and link is
+	// the one printed text gets, 0 for none.
+	links   []string
+	linkIDs map[string]int
+	link    int
+
+	// reply sends responses to status requests back to the program
+	reply func([]byte)
+}
+
+// newVTScreen returns a screen with the given size
+func newVTScreen(cols, rows int) *vtScreen {
+	s := &vtScreen{}
+	s.buf = &s.main
+	s.reset(cols, rows)
+	return s
+}
+
+// reset returns the screen to its initial state
+func (s *vtScreen) reset(cols, rows int) {
+	s.cols, s.rows = cols, rows
+	s.main = vtBuffer{lines: s.blankLines(rows)}
+	s.alternate = vtBuffer{lines: s.blankLines(rows)}
+	s.buf = &s.main
+	s.cursor = vtCursor{style: tcell.StyleDefault}
+	s.top, s.bottom = 0, rows
+	s.autowrap, s.insert, s.cursorVisible, s.appCursor = true, false, true, false
+	s.bracketPaste = false
+	s.mouseTracking, s.mouseSGR, s.altScroll = mouseOff, false, true
+	s.link = 0
+	s.state = vtGround
+	s.resetTabs()
+}
+
+// resetTabs sets a tab stop every eight columns
+func (s *vtScreen) resetTabs() {
+	s.tabs = make([]bool, s.cols)
+	for x := 8; x < s.cols; x += 8 {
+		s.tabs[x] = true
+	}
+}
+
+// blankCell returns an erased cell, which keeps the current background
+func (s *vtScreen) blankCell() vtCell {
+	_, bg, _ := s.cursor.style.Decompose()
+	return vtCell{r: ' ', style: tcell.StyleDefault.Background(bg)}
+}
+
+// blankLine returns a line of erased cells
+func (s *vtScreen) blankLine() []vtCell {
+	line := make([]vtCell, s.cols)
+	blank := s.blankCell()
+	for i := range line {
+		line[i] = blank
+	}
+	return line
+}
+
+// blankLines returns n lines of erased cells
+func (s *vtScreen) blankLines(n int) [][]vtCell {
+	lines := make([][]vtCell, n)
+	for i := range lines {
+		lines[i] = s.blankLine()
+	}
+	return lines
+}
+
+// Alternate reports whether the alternate screen is shown
+func (s *vtScreen) Alternate() bool {
+	return s.buf == &s.alternate
+}
+
+// Resize changes the screen size, keeping the cursor line on the screen.
+// Lines are truncated or padded rather than reflowed.
+func (s *vtScreen) Resize(cols, rows int) {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+	for _, b := range []*vtBuffer{&s.main, &s.alternate} {
+		cursorY := b.saved.y
+		if b == s.buf {
+			cursorY = s.cursor.y
+		}
+		// Drop lines from the top when shrinking so the cursor stays visible
+		if drop := cursorY - rows + 1; drop > 0 {
+			if b == &s.main {
+				s.pushScrollback(b.lines[:drop])
+			}
+			b.lines = b.lines[drop:]
+			if b == s.buf {
+				s.cursor.y -= drop
+			} else {
+				b.saved.y -= drop
+			}
+		}
+		if len(b.lines) > rows {
+			b.lines = b.lines[:rows]
+		}
+		for i, line := range b.lines {
+			b.lines[i] = resizeLine(line, cols)
+		}
+		for len(b.lines) < rows {
+			b.lines = append(b.lines, resizeLine(nil, cols))
+		}
+	}
+	s.cols, s.rows = cols, rows
+	s.top, s.bottom = 0, rows
+	s.resetTabs()
+	s.cursor.x, s.cursor.y = clampInt(s.cursor.x, 0, cols-1), clampInt(s.cursor.y, 0, rows-1)
+	s.cursor.wrapNext = false
+}
+
+// resizeLine truncates or pads a line to the given width
+func resizeLine(line []vtCell, cols int) []vtCell {
+	if len(line) >= cols {
+		line = line[:cols]
+		if cols > 0 && line[cols-1].r != 0 && runewidth.RuneWidth(line[cols-1].r) > 1 {
+			line[cols-1].r, line[cols-1].comb = ' ', nil
+		}
+		return line
+	}
+	for len(line) < cols {
+		line = append(line, vtCell{r: ' ', style: tcell.StyleDefault})
+	}
+	return line
+}
+
+// clampInt limits n to the range min..max
+func clampInt(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// Cell returns the cell at column x of row y
+func (s *vtScreen) Cell(x, y int) vtCell {
+	return s.buf.lines[y][x]
+}
+
+// Line returns row y of the scrollback followed by the screen; the rows of
+// the screen start at ScrollbackLen
+func (s *vtScreen) Line(y int) []vtCell {
+	if n := s.ScrollbackLen(); y >= n {
+		return s.buf.lines[y-n]
+	}
+	return s.scrollback[y]
+}
+
+// ScrollbackLen returns the number of lines in the scrollback
+func (s *vtScreen) ScrollbackLen() int {
+	if s.Alternate() {
+		return 0
+	}
+	return len(s.scrollback)
+}
+
+// Cursor returns the cursor position and whether the cursor is shown
+func (s *vtScreen) Cursor() (int, int, bool) {
+	return s.cursor.x, s.cursor.y, s.cursorVisible
+}
+
+// Write interprets program output
+func (s *vtScreen) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(s.pending) > 0 {
+		p = append(s.pending, p...)
+		s.pending = nil
+	}
+	for len(p) > 0 {
+		b := p[0]
+		if s.state != vtGround || b < utf8.RuneSelf {
+			s.handleByte(b)
+			p = p[1:]
+			continue
+		}
+		if !utf8.FullRune(p) {
+			s.pending = append(s.pending, p...)
+			break
+		}
+		r, size := utf8.DecodeRune(p)
+		s.print(r)
+		p = p[size:]
+	}
+	return n, nil
+}
+
+// handleByte advances the escape sequence state machine by one byte
+func (s *vtScreen) handleByte(b byte) {
+	switch s.state {
+	case vtEscape:
+		s.handleEscape(b)
+		return
+	case vtCharset:
+		s.cursor.charsets[s.charsetOf] = b == '0'
+		s.state = vtGround
+		return
+	case vtSkip:
+		s.state = vtGround
+		return
+	case vtCSI:
+		switch {
+		case b >= 0x40 && b <= 0x7e:
+			s.state = vtGround
+			s.handleCSI(b)
+		case b >= 0x20:
+			s.params = append(s.params, b)
+		case b == 0x1b:
+			s.state = vtEscape
+		case b == 0x18 || b == 0x1a:
+			s.state = vtGround
+		default:
+			// Control characters are executed in the middle of a sequence
+			s.control(b)
+		}
+		return
+	case vtOSC:
+		switch b {
+		case 0x07:
+			s.state = vtGround
+			s.handleOSC()
+		case 0x1b:
+			s.state = vtStringEscape
+		default:
+			s.osc = append(s.osc, b)
+		}
+		return
+	case vtString:
+		if b == 0x1b {
+			s.state = vtStringEscape
+		} else if b == 0x07 {
+			s.state = vtGround
+		}
+		return
+	case vtStringEscape:
+		// ESC \ terminates the string; any other byte starts a new sequence
+		if s.osc != nil {
+			s.handleOSC()
+		}
+		s.state = vtGround
+		if b != '\\' {
+			s.state = vtEscape
+			s.handleEscape(b)
+		}
+		return
+	}
+
+	if b < 0x20 || b == 0x7f {
+		s.control(b)
+		return
+	}
+	s.print(rune(b))
+}
+
+// control executes a C0 control character
+func (s *vtScreen) control(b byte) {
+	switch b {
+	case 0x08: // BS
+		s.cursor.wrapNext = false
+		if s.cursor.x > 0 {
+			s.cursor.x--
+		}
+	case 0x09: // HT
+		s.tabForward(1)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		s.lineFeed()
+	case 0x0d: // CR
+		s.cursor.x = 0
+		s.cursor.wrapNext = false
+	case 0x0e: // SO
+		s.cursor.charset = 1
+	case 0x0f: // SI
+		s.cursor.charset = 0
+	case 0x1b:
+		s.state = vtEscape
+	}
+}
+
+// handleEscape interprets the byte following ESC
+func (s *vtScreen) handleEscape(b byte) {
+	s.state = vtGround
+	switch b {
+	case '[':
+		s.state = vtCSI
+		s.params = s.params[:0]
+	case ']':
+		s.state = vtOSC
+		s.osc = []byte{}
+	case 'P', 'X', '^', '_':
+		s.state = vtString
+		s.osc = nil
+	case '(', ')':
+		s.state = vtCharset
+		s.charsetOf = 0
+		if b == ')' {
+			s.charsetOf = 1
+		}
+	case '*', '+', '#', '%', ' ':
+		// Designations and options this terminal does not support
+		s.state = vtSkip
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cursor.x = 0
+		s.lineFeed()
+	case 'H':
+		if s.cursor.x < s.cols {
+			s.tabs[s.cursor.x] = true
+		}
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset(s.cols, s.rows)
+	}
+}
+
+// decGraphics maps the DEC special graphics charset to line drawing runes
+var decGraphics = map[rune]rune{
+	'`': '◆', 'a': '▒', 'f': '°', 'g': '±', 'j': '┘', 'k': '┐', 'l': '┌',
+	'm': '└', 'n': '┼', 'o': '⎺', 'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽',
+	't': '├', 'u': '┤', 'v': '┴', 'w': '┬', 'x': '│', 'y': '≤', 'z': '≥',
+	'{': 'π', '|': '≠', '}': '£', '~': '·',
+}
+
+// print writes a rune at the cursor and advances it
+func (s *vtScreen) print(r rune) {
+	if s.cursor.charsets[s.cursor.charset] {
+		if g, ok := decGraphics[r]; ok {
+			r = g
+		}
+	}
+	if unicode.IsControl(r) {
+		// C1 controls sent as UTF-8 are not interpreted
+		return
+	}
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		s.combine(r)
+		return
+	}
+	if width > s.cols {
+		return
+	}
+	if s.cursor.wrapNext || s.cursor.x+width > s.cols {
+		if s.autowrap {
+			s.cursor.x = 0
+			s.lineFeed()
+		} else {
+			s.cursor.x = s.cols - width
+		}
+	}
+	s.cursor.wrapNext = false
+
+	line := s.buf.lines[s.cursor.y]
+	if s.insert {
+		copy(line[s.cursor.x+width:], line[s.cursor.x:])
+	}
+	s.clearWide(line, s.cursor.x)
+	if width == 2 {
+		s.clearWide(line, s.cursor.x+1)
+	}
+	line[s.cursor.x] = vtCell{r: r, style: s.cursor.style, link: s.link}
+	if width == 2 {
+		line[s.cursor.x+1] = vtCell{r: 0, style: s.cursor.style, link: s.link}
+	}
+	s.cursor.x += width
+	if s.cursor.x >= s.cols {
+		s.cursor.x = s.cols - 1
+		s.cursor.wrapNext = true
+	}
+}
+
+// combine adds a zero-width rune, such as a combining accent or a variation
+// selector, to the rune last written, which is drawn with it
+func (s *vtScreen) combine(r rune) {
+	x := s.cursor.x
+	if !s.cursor.wrapNext {
+		x--
+	}
+	line := s.buf.lines[s.cursor.y]
+	if x > 0 && line[x].r == 0 {
+		// The right half of a wide rune
+		x--
+	}
+	if x < 0 || line[x].r == 0 {
+		return
+	}
+	// Cells copied to the scrollback may share the slice
+	line[x].comb = append(line[x].comb[:len(line[x].comb):len(line[x].comb)], r)
+}
+
+// clearWide blanks the other half of a wide rune about to be overwritten at
+// column x
+func (s *vtScreen) clearWide(line []vtCell, x int) {
+	if line[x].r == 0 && x > 0 {
+		line[x-1].r, line[x-1].comb = ' ', nil
+	} else if x+1 < len(line) && line[x+1].r == 0 {
+		line[x+1].r = ' '
+	}
+}
+
+// lineFeed moves the cursor down, scrolling at the bottom of the region
+func (s *vtScreen) lineFeed() {
+	s.cursor.wrapNext = false
+	if s.cursor.y == s.bottom-1 {
+		s.scrollUp(1)
+	} else if s.cursor.y < s.rows-1 {
+		s.cursor.y++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling at the top of the region
+func (s *vtScreen) reverseIndex() {
+	s.cursor.wrapNext = false
+	if s.cursor.y == s.top {
+		s.scrollDown(1)
+	} else if s.cursor.y > 0 {
+		s.cursor.y--
+	}
+}
+
+// scrollUp scrolls the scroll region up by n lines
+func (s *vtScreen) scrollUp(n int) {
+	if s.top == 0 && !s.Alternate() {
+		s.pushScrollback(s.buf.lines[:clampInt(n, 0, s.bottom)])
+	}
+	s.deleteLines(s.top, n)
+}
+
+// pushScrollback appends lines leaving the top of the main screen to the
+// scrollback, dropping the oldest lines beyond the limit
+func (s *vtScreen) pushScrollback(lines [][]vtCell) {
+	for _, line := range lines {
+		s.scrollback = append(s.scrollback, append([]vtCell(nil), line...))
+	}
+	s.scrolled += len(lines)
+	if over := len(s.scrollback) - vtScrollbackLines; over > 0 {
+		// Slicing rather than copying keeps this cheap for every line of a
+		// flood of output; the dropped lines are freed once append moves
+		// the scrollback to a larger array
+		s.scrollback = s.scrollback[over:]
+		s.trimmed += over
+	}
+}
+
+// scrollDown scrolls the scroll region down by n lines
+func (s *vtScreen) scrollDown(n int) {
+	s.insertLines(s.top, n)
+}
+
+// deleteLines removes n lines at row y, pulling up the rest of the scroll
+// region
+func (s *vtScreen) deleteLines(y, n int) {
+	if y < s.top || y >= s.bottom {
+		return
+	}
+	n = clampInt(n, 0, s.bottom-y)
+	lines := s.buf.lines
+	copy(lines[y:s.bottom], lines[y+n:s.bottom])
+	for i := s.bottom - n; i < s.bottom; i++ {
+		lines[i] = s.blankLine()
+	}
+}
+
+// insertLines inserts n blank lines at row y, pushing down the rest of the
+// scroll region
+func (s *vtScreen) insertLines(y, n int) {
+	if y < s.top || y >= s.bottom {
+		return
+	}
+	n = clampInt(n, 0, s.bottom-y)
+	lines := s.buf.lines
+	copy(lines[y+n:s.bottom], lines[y:s.bottom-n])
+	for i := y; i < y+n; i++ {
+		lines[i] = s.blankLine()
+	}
+}
+
+// tabForward moves the cursor to the n-th next tab stop
+func (s *vtScreen) tabForward(n int) {
+	s.cursor.wrapNext = false
+	for ; n > 0 && s.cursor.x < s.cols-1; n-- {
+		s.cursor.x++
+		for s.cursor.x < s.cols-1 && !s.tabs[s.cursor.x] {
+			s.cursor.x++
+		}
+	}
+}
+
+// tabBackward moves the cursor to the n-th previous tab stop
+func (s *vtScreen) tabBackward(n int) {
+	s.cursor.wrapNext = false
+	for ; n > 0 && s.cursor.x > 0; n-- {
+		s.cursor.x--
+		for s.cursor.x > 0 && !s.tabs[s.cursor.x] {
+			s.cursor.x--
+		}
+	}
+}
+
+// saveCursor remembers the cursor for restoreCursor (DECSC)
+func (s *vtScreen) saveCursor() {
+	s.buf.saved = s.cursor
+}
+
+// restoreCursor restores the cursor saved by saveCursor (DECRC)
+func (s *vtScreen) restoreCursor() {
+	s.cursor = s.buf.saved
+	s.cursor.x, s.cursor.y = clampInt(s.cursor.x, 0, s.cols-1), clampInt(s.cursor.y, 0, s.rows-1)
+}
+
+// moveCursor moves the cursor to column x of row y, relative to the scroll
+// region in origin mode
+func (s *vtScreen) moveCursor(x, y int) {
+	minY, maxY := 0, s.rows-1
+	if s.cursor.origin {
+		y += s.top
+		minY, maxY = s.top, s.bottom-1
+	}
+	s.cursor.x, s.cursor.y = clampInt(x, 0, s.cols-1), clampInt(y, minY, maxY)
+	s.cursor.wrapNext = false
+}
+
+// eraseCells blanks the cells from x up to, but not including, to on row y
+func (s *vtScreen) eraseCells(y, x, to int) {
+	line := s.buf.lines[y]
+	x, to = clampInt(x, 0, s.cols), clampInt(to, 0, s.cols)
+	if x < to {
+		s.clearWide(line, x)
+		s.clearWide(line, to-1)
+	}
+	blank := s.blankCell()
+	for ; x < to; x++ {
+		line[x] = blank
+	}
+}
+
+// csiParams splits the parameters of a CSI sequence, returning the private
+// marker ('?', '>', '=', or 0) and the final intermediate byte
+func csiParams(raw []byte) (private byte, params []int, intermediate byte) {
+	if len(raw) > 0 && raw[0] >= '<' && raw[0] <= '?' {
+		private, raw = raw[0], raw[1:]
+	}
+	if n := len(raw); n > 0 && raw[n-1] >= 0x20 && raw[n-1] <= 0x2f {
+		intermediate, raw = raw[n-1], raw[:n-1]
+	}
+	if len(raw) == 0 {
+		return private, nil, intermediate
+	}
+	for _, field := range strings.Split(string(raw), ";") {
+		// Colon separated sub-parameters are not supported; keep the first
+		if i := strings.IndexByte(field, ':'); i >= 0 {
+			field = field[:i]
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			n = 0
+		}
+		params = append(params, n)
+	}
+	return private, params, intermediate
+}
+
+// graphicsParams splits the parameters of an SGR sequence like csiParams,
+// except that colors given with colon separated sub-parameters, as in
+// 38:2::r:g:b, are turned into their semicolon separated form
+func graphicsParams(raw []byte) []int {
+	var params []int
+	for _, field := range strings.Split(string(raw), ";") {
+		subs := strings.Split(field, ":")
+		values := make([]int, len(subs))
+		for i, sub := range subs {
+			values[i], _ = strconv.Atoi(sub)
+		}
+		switch {
+		case len(values) == 1:
+			params = append(params, values[0])
+		case (values[0] == 38 || values[0] == 48 || values[0] == 58) && values[1] == 2 && len(values) >= 6:
+			// The color space identifier comes before the components
+			params = append(params, values[0], 2, values[3], values[4], values[5])
+		case values[0] == 38 || values[0] == 48 || values[0] == 58:
+			params = append(params, values...)
+		case values[0] == 4 && values[1] == 0:
+			// Underline styles other than none are drawn as underlines
+			params = append(params, 24)
+		default:
+			params = append(params, values[0])
+		}
+	}
+	return params
+}
+
+// param returns the i-th parameter, or def when it is missing or zero
+func param(params []int, i, def int) int {
+	if i < len(params) && params[i] != 0 {
+		return params[i]
+	}
+	return def
+}
+
+// handleCSI interprets a complete CSI sequence with final byte b
+func (s *vtScreen) handleCSI(b byte) {
+	private, params, intermediate := csiParams(s.params)
+	if intermediate != 0 {
+		// DECSCUSR and friends
+		return
+	}
+	n := param(params, 0, 1)
+	c := &s.cursor
+	switch private {
+	case '?':
+		switch b {
+		case 'h', 'l':
+			s.setPrivateModes(params, b == 'h')
+		}
+		return
+	case '>', '=', '<':
+		if b == 'c' && private == '>' {
+			s.send("\x1b[>0;0;0c")
+		}
+		return
+	}
+
+	switch b {
+	case '@': // ICH
+		line := s.buf.lines[c.y]
+		n = clampInt(n, 0, s.cols-c.x)
+		copy(line[c.x+n:], line[c.x:])
+		s.eraseCells(c.y, c.x, c.x+n)
+	case 'A': // CUU
+		top := 0
+		if c.y >= s.top {
+			top = s.top
+		}
+		c.y = clampInt(c.y-n, top, s.rows-1)
+		c.wrapNext = false
+	case 'B', 'e': // CUD, VPR
+		bottom := s.rows - 1
+		if c.y < s.bottom {
+			bottom = s.bottom - 1
+		}
+		c.y = clampInt(c.y+n, 0, bottom)
+		c.wrapNext = false
+	case 'C', 'a': // CUF, HPR
+		c.x = clampInt(c.x+n, 0, s.cols-1)
+		c.wrapNext = false
+	case 'D': // CUB
+		c.x = clampInt(c.x-n, 0, s.cols-1)
+		c.wrapNext = false
+	case 'E': // CNL
+		c.x = 0
+		s.handleCSIMove(c.y + n)
+	case 'F': // CPL
+		c.x = 0
+		s.handleCSIMove(c.y - n)
+	case 'G', '`': // CHA, HPA
+		c.x = clampInt(n-1, 0, s.cols-1)
+		c.wrapNext = false
+	case 'H', 'f': // CUP
+		s.moveCursor(param(params, 1, 1)-1, n-1)
+	case 'I': // CHT
+		s.tabForward(n)
+	case 'Z': // CBT
+		s.tabBackward(n)
+	case 'J': // ED
+		switch param(params, 0, 0) {
+		case 0:
+			s.eraseCells(c.y, c.x, s.cols)
+			for y := c.y + 1; y < s.rows; y++ {
+				s.eraseCells(y, 0, s.cols)
+			}
+		case 1:
+			for y := 0; y < c.y; y++ {
+				s.eraseCells(y, 0, s.cols)
+			}
+			s.eraseCells(c.y, 0, c.x+1)
+		case 2:
+			for y := 0; y < s.rows; y++ {
+				s.eraseCells(y, 0, s.cols)
+			}
+		case 3:
+			s.trimmed += len(s.scrollback)
+			s.scrollback = nil
+		}
+	case 'K': // EL
+		switch param(params, 0, 0) {
+		case 0:
+			s.eraseCells(c.y, c.x, s.cols)
+		case 1:
+			s.eraseCells(c.y, 0, c.x+1)
+		case 2:
+			s.eraseCells(c.y, 0, s.cols)
+		}
+	case 'L': // IL
+		s.insertLines(c.y, n)
+		c.x = 0
+	case 'M': // DL
+		s.deleteLines(c.y, n)
+		c.x = 0
+	case 'P': // DCH
+		line := s.buf.lines[c.y]
+		n = clampInt(n, 0, s.cols-c.x)
+		s.clearWide(line, c.x)
+		copy(line[c.x:], line[c.x+n:])
+		s.eraseCells(c.y, s.cols-n, s.cols)
+	case 'S': // SU
+		s.scrollUp(n)
+	case 'T': // SD
+		if len(params) <= 1 {
+			s.scrollDown(n)
+		}
+	case 'X': // ECH
+		s.eraseCells(c.y, c.x, c.x+n)
+	case 'b': // REP
+		if c.x > 0 {
+			r := s.buf.lines[c.y][c.x-1].r
+			for ; n > 0 && r != 0; n-- {
+				s.print(r)
+			}
+		}
+	case 'c': // DA
+		s.send("\x1b[?62;22c")
+	case 'd': // VPA
+		s.moveCursor(c.x, n-1)
+	case 'g': // TBC
+		switch param(params, 0, 0) {
+		case 0:
+			if c.x < s.cols {
+				s.tabs[c.x] = false
+			}
+		case 3:
+			s.tabs = make([]bool, s.cols)
+		}
+	case 'h', 'l': // SM, RM
+		for _, mode := range params {
+			if mode == 4 {
+				s.insert = b == 'h'
+			}
+		}
+	case 'm': // SGR
+		if private == 0 {
+			s.setGraphics(graphicsParams(s.params))
+		}
+	case 'n': // DSR
+		switch n {
+		case 5:
+			s.send("\x1b[0n")
+		case 6:
+			y := c.y
+			if c.origin {
+				y -= s.top
+			}
+			s.send(fmt.Sprintf("\x1b[%d;%dR", y+1, c.x+1))
+		}
+	case 'r': // DECSTBM
+		top, bottom := param(params, 0, 1), param(params, 1, s.rows)
+		if top < bottom && bottom <= s.rows {
+			s.top, s.bottom = top-1, bottom
+			s.moveCursor(0, 0)
+		}
+	case 's': // SCOSC
+		s.saveCursor()
+	case 'u': // SCORC
+		s.restoreCursor()
+	}
+}
+
+// handleCSIMove moves the cursor to row y within the screen
+func (s *vtScreen) handleCSIMove(y int) {
+	s.cursor.y = clampInt(y, 0, s.rows-1)
+	s.cursor.wrapNext = false
+}
+
+// setPrivateModes sets or resets DEC private modes
+func (s *vtScreen) setPrivateModes(modes []int, set bool) {
+	for _, mode := range modes {
+		switch mode {
+		case 1: // DECCKM
+			s.appCursor = set
+		case 6: // DECOM
+			s.cursor.origin = set
+			s.moveCursor(0, 0)
+		case 7: // DECAWM
+			s.autowrap = set
+		case 25: // DECTCEM
+			s.cursorVisible = set
+		case 47:
+			s.switchScreen(set, false)
+		case 1047:
+			if !set && s.Alternate() {
+				s.alternate.lines = s.blankLines(s.rows)
+			}
+			s.switchScreen(set, false)
+		case 1048:
+			if set {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		case 1049:
+			s.switchScreen(set, true)
+		case mouseX10, mouseNormal, mouseButtonMotion, mouseAnyMotion:
+			s.mouseTracking = mouseOff
+			if set {
+				s.mouseTracking = mode
+			}
+		case 1006:
+			s.mouseSGR = set
+		case 1007:
+			s.altScroll = set
+		case 2004:
+			s.bracketPaste = set
+		}
+	}
+}
+
+// switchScreen shows the alternate screen, or returns to the main one. With
+// saveCursor the cursor is saved on entry and restored on exit, and the
+// alternate screen is cleared.
+func (s *vtScreen) switchScreen(alternate, saveCursor bool) {
+	if alternate == s.Alternate() {
+		return
+	}
+	if alternate {
+		if saveCursor {
+			s.main.saved = s.cursor
+		}
+		s.buf = &s.alternate
+		if saveCursor {
+			s.alternate.lines = s.blankLines(s.rows)
+		}
+	} else {
+		s.buf = &s.main
+		if saveCursor {
+			s.restoreCursor()
+		}
+	}
+	s.top, s.bottom = 0, s.rows
+}
+
+// vtColor returns palette color n
+func vtColor(n int) tcell.Color {
+	return tcell.PaletteColor(n)
+}
+
+// setGraphics applies SGR parameters to the pen style
+func (s *vtScreen) setGraphics(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	style := s.cursor.style
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			style = tcell.StyleDefault
+		case p == 1:
+			style = style.Bold(true)
+		case p == 2:
+			style = style.Dim(true)
+		case p == 3:
+			style = style.Italic(true)
+		case p == 4:
+			style = style.Underline(true)
+		case p == 5 || p == 6:
+			style = style.Blink(true)
+		case p == 7:
+			style = style.Reverse(true)
+		case p == 9:
+			style = style.StrikeThrough(true)
+		case p == 21:
+			style = style.Underline(true)
+		case p == 22:
+			style = style.Bold(false).Dim(false)
+		case p == 23:
+			style = style.Italic(false)
+		case p == 24:
+			style = style.Underline(false)
+		case p == 25:
+			style = style.Blink(false)
+		case p == 27:
+			style = style.Reverse(false)
+		case p == 29:
+			style = style.StrikeThrough(false)
+		case p >= 30 && p <= 37:
+			style = style.Foreground(vtColor(p - 30))
+		case p == 39:
+			style = style.Foreground(tcell.ColorDefault)
+		case p >= 40 && p <= 47:
+			style = style.Background(vtColor(p - 40))
+		case p == 49:
+			style = style.Background(tcell.ColorDefault)
+		case p >= 90 && p <= 97:
+			style = style.Foreground(vtColor(p - 90 + 8))
+		case p >= 100 && p <= 107:
+			style = style.Background(vtColor(p - 100 + 8))
+		case p == 38 || p == 48 || p == 58:
+			color, used := extendedColor(params[i+1:])
+			i += used
+			switch {
+			case color == tcell.ColorDefault:
+			case p == 38:
+				style = style.Foreground(color)
+			case p == 48:
+				style = style.Background(color)
+			}
+			// tcell cannot color underlines, so 58 is skipped
+		}
+	}
+	s.cursor.style = style
+}
+
+// extendedColor returns the color given by the arguments of SGR 38, 48, or
+// 58: 5 and a palette index, or 2 and red, green, and blue components. It
+// also returns the number of arguments used, and ColorDefault for missing
+// or invalid ones.
+func extendedColor(args []int) (tcell.Color, int) {
+	if len(args) == 0 {
+		return tcell.ColorDefault, 0
+	}
+	switch args[0] {
+	case 5:
+		if len(args) < 2 || args[1] < 0 || args[1] > 255 {
+			return tcell.ColorDefault, len(args)
+		}
+		return vtColor(args[1]), 2
+	case 2:
+		if len(args) < 4 {
+			return tcell.ColorDefault, len(args)
+		}
+		r, g, b := args[1], args[2], args[3]
+		if r < 0 || r > 255 || g < 0 || g > 255 || b < 0 || b > 255 {
+			return tcell.ColorDefault, 4
+		}
+		return tcell.NewRGBColor(int32(r), int32(g), int32(b)), 4
+	}
+	return tcell.ColorDefault, 1
+}
+
+// handleOSC interprets an operating system command
+func (s *vtScreen) handleOSC() {
+	command, arg, _ := strings.Cut(string(s.osc), ";")
+	s.osc = nil
+	switch command {
+	case "0", "2":
+		s.title = arg
+	case "7":
+		if dir, ok := localDir(arg); ok {
+			s.dir = dir
+		}
+	case "8":
+		// The parameters before the URI, such as an id, are not needed as
+		// cells linking to the same URI are one link anyway
+		_, uri, _ := strings.Cut(arg, ";")
+		s.setLink(uri)
+	}
+}
+
+// setLink makes the text printed from now on link to uri, or to nothing if
+// uri is empty
+func (s *vtScreen) setLink(uri string) {
+	if uri == "" {
+		s.link = 0
+		return
+	}
+	if s.linkIDs == nil {
+		s.linkIDs = map[string]int{}
+	}
+	id, ok := s.linkIDs[uri]
+	if !ok {
+		s.links = append(s.links, uri)
+		id = len(s.links)
+		s.linkIDs[uri] = id
+	}
+	s.link = id
+}
+
+// localDir returns the path of a file URL, as shells report their working
+// directory with OSC 7, unless it names another host, as it does in an ssh
+// session
+func localDir(arg string) (string, bool) {
+	u, err := url.Parse(arg)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		if host, err := os.Hostname(); err != nil || !strings.EqualFold(u.Host, host) {
+			return "", false
+		}
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		// file:///C:/Users is the path C:/Users
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), true
+}
+
+// Title returns the window title set by the program
+func (s *vtScreen) Title() string {
+	return s.title
+}
+
+// send writes a response to the program
+func (s *vtScreen) send(response string) {
+	if s.reply != nil {
+		s.reply([]byte(response))
+	}
+}
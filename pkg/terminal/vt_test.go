@@ -0,0 +1,160 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// screenLines returns the text of the rows of the screen, without trailing
+// blanks
+func screenLines(s *vtScreen) []string {
+	lines := make([]string, s.rows)
+	for y := range lines {
+		var b strings.Builder
+		for x := 0; x < s.cols; x++ {
+			if r := s.Cell(x, y).r; r != 0 {
+				b.WriteRune(r)
+			}
+		}
+		lines[y] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+func checkLines(t *testing.T, s *vtScreen, want ...string) {
+	t.Helper()
+	if got := screenLines(s); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("screen = %q, want %q", got, want)
+	}
+}
+
+func checkCursor(t *testing.T, s *vtScreen, x, y int) {
+	t.Helper()
+	if gotX, gotY, _ := s.Cursor(); gotX != x || gotY != y {
+		t.Errorf("cursor = (%d, %d), want (%d, %d)", gotX, gotY, x, y)
+	}
+}
+
+func TestVTPrint(t *testing.T) {
+	s := newVTScreen(10, 3)
+	s.Write([]byte("one\r\ntwo\r\n"))
+	checkLines(t, s, "one", "two", "")
+	checkCursor(t, s, 0, 2)
+
+	// Lines longer than the screen wrap
+	s.Write([]byte("0123456789ab"))
+	checkLines(t, s, "two", "0123456789", "ab")
+	checkCursor(t, s, 2, 2)
+	if got := s.ScrollbackLen(); got != 1 {
+		t.Errorf("ScrollbackLen() = %d, want 1", got)
+	}
+}
+
+func TestVTSplitSequence(t *testing.T) {
+	s := newVTScreen(10, 2)
+	// An escape sequence and a UTF-8 rune cut between writes
+	for _, part := range []string{"\x1b[", "2;3H", "\xc3", "\xa9"} {
+		s.Write([]byte(part))
+	}
+	checkLines(t, s, "", "  é")
+	checkCursor(t, s, 3, 1)
+}
+
+func TestVTGraphics(t *testing.T) {
+	s := newVTScreen(10, 1)
+	s.Write([]byte("\x1b[1;31mr\x1b[0mn"))
+	if got, want := s.Cell(0, 0).style, tcell.StyleDefault.Bold(true).Foreground(tcell.PaletteColor(1)); got != want {
+		t.Errorf("style of r = %v, want %v", got, want)
+	}
+	if got := s.Cell(1, 0).style; got != tcell.StyleDefault {
+		t.Errorf("style after SGR 0 = %v, want the default", got)
+	}
+}
+
+func TestVTCursorMovement(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		x, y  int
+	}{
+		{"CUP", "\x1b[3;5H", 4, 2},
+		{"CUP defaults", "\x1b[3;5H\x1b[H", 0, 0},
+		{"CUP clamped", "\x1b[99;99H", 9, 4},
+		{"CUU", "\x1b[4;4H\x1b[2A", 3, 1},
+		{"CUU clamped", "\x1b[2;4H\x1b[9A", 3, 0},
+		{"CUD", "\x1b[2B", 0, 2},
+		{"CUF", "\x1b[3C", 3, 0},
+		{"CUB clamped", "\x1b[3C\x1b[9D", 0, 0},
+		{"CHA", "\x1b[3;3H\x1b[7G", 6, 2},
+		{"CNL", "\x1b[1;5H\x1b[2E", 0, 2},
+		{"CR LF", "abc\r\n", 0, 1},
+		{"BS", "abc\b", 2, 0},
+		{"TAB", "a\t", 8, 0},
+		{"DECSC DECRC", "\x1b[2;3H\x1b7\x1b[5;5H\x1b8", 2, 1},
+	}
+	for _, tt := range tests {
+		s := newVTScreen(10, 5)
+		s.Write([]byte(tt.input))
+		if x, y, _ := s.Cursor(); x != tt.x || y != tt.y {
+			t.Errorf("%s: cursor = (%d, %d), want (%d, %d)", tt.name, x, y, tt.x, tt.y)
+		}
+	}
+}
+
+func TestVTErase(t *testing.T) {
+	s := newVTScreen(5, 3)
+	s.Write([]byte("aaaaa\r\nbbbbb\r\nccccc"))
+	s.Write([]byte("\x1b[2;3H\x1b[K"))
+	checkLines(t, s, "aaaaa", "bb", "ccccc")
+	s.Write([]byte("\x1b[1K"))
+	checkLines(t, s, "aaaaa", "", "ccccc")
+	s.Write([]byte("\x1b[2J"))
+	checkLines(t, s, "", "", "")
+}
+
+func TestVTScrollRegion(t *testing.T) {
+	s := newVTScreen(5, 5)
+	s.Write([]byte("1\r\n2\r\n3\r\n4\r\n5"))
+	// Rows 2 to 4 scroll while 1 and 5 stay put
+	s.Write([]byte("\x1b[2;4r"))
+	checkCursor(t, s, 0, 0)
+	s.Write([]byte("\x1b[4;1H\nx"))
+	checkLines(t, s, "1", "3", "4", "x", "5")
+	if got := s.ScrollbackLen(); got != 0 {
+		t.Errorf("ScrollbackLen() = %d, want 0 for a region below the top", got)
+	}
+
+	// Reverse index at the top of the region scrolls it down
+	s.Write([]byte("\x1b[2;1H\x1bMy"))
+	checkLines(t, s, "1", "y", "3", "4", "5")
+
+	// Inserting and deleting lines stays within the region
+	s.Write([]byte("\x1b[3;1H\x1b[L"))
+	checkLines(t, s, "1", "y", "", "3", "5")
+	s.Write([]byte("\x1b[2M"))
+	checkLines(t, s, "1", "y", "", "", "5")
+
+	// Line feeds below the region move the cursor without scrolling
+	s.Write([]byte("\x1b[5;1H\n\n"))
+	checkCursor(t, s, 0, 4)
+	checkLines(t, s, "1", "y", "", "", "5")
+}
+
+func TestVTAlternateScreen(t *testing.T) {
+	s := newVTScreen(5, 2)
+	s.Write([]byte("main"))
+	s.Write([]byte("\x1b[?1049h"))
+	if !s.Alternate() {
+		t.Fatal("Alternate() = false after CSI ?1049h")
+	}
+	checkLines(t, s, "", "")
+	s.Write([]byte("alt"))
+	s.Write([]byte("\x1b[?1049l"))
+	if s.Alternate() {
+		t.Fatal("Alternate() = true after CSI ?1049l")
+	}
+	checkLines(t, s, "main", "")
+	checkCursor(t, s, 4, 0)
+}
@@ -0,0 +1,47 @@
+// Package textsearch finds the occurrences of a query in lines of text. It
+// is shared by the editor's find, the search in files, and the terminal's
+// copy mode search, so they all match the same way.
+package textsearch
+
+import "unicode"
+
+// Needle prepares a query for Find. The search ignores case unless the query
+// contains an upper case letter, and fold reports whether it does.
+func Needle(query []rune) (needle []rune, fold bool) {
+	for _, r := range query {
+		if unicode.IsUpper(r) {
+			return query, false
+		}
+	}
+	return query, true
+}
+
+// Find returns the indexes in line of the non-overlapping occurrences of
+// needle, optionally ignoring case. An empty needle matches nowhere.
+func Find(line, needle []rune, fold bool) []int {
+	if len(needle) == 0 {
+		return nil
+	}
+	var found []int
+	for i := 0; i+len(needle) <= len(line); i++ {
+		if match(line[i:i+len(needle)], needle, fold) {
+			found = append(found, i)
+			i += len(needle) - 1
+		}
+	}
+	return found
+}
+
+// match compares text against a needle of the same length, optionally
+// ignoring case
+func match(text, needle []rune, fold bool) bool {
+	for i, r := range text {
+		if fold {
+			r = unicode.ToLower(r)
+		}
+		if r != needle[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package textsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		line, query string
+		want        []int
+	}{
+		{"go Go GO", "go", []int{0, 3, 6}},
+		{"go Go GO", "Go", []int{3}},
+		{"aaaa", "aa", []int{0, 2}},
+		{"short", "longer than the line", nil},
+		{"anything", "", nil},
+	}
+	for _, tt := range tests {
+		needle, fold := Needle([]rune(tt.query))
+		if got := Find([]rune(tt.line), needle, fold); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Find(%q, %q) = %v, want %v", tt.line, tt.query, got, tt.want)
+		}
+	}
+}